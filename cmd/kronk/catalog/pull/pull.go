@@ -68,10 +68,14 @@ func runLocal(catalog *catalog.Catalog, models *models.Models, args []string) er
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
 	defer cancel()
 
-	_, err = models.DownloadShards(ctx, kronk.FmtLogger, modelURLs, projURLs)
+	mp, err := models.DownloadShards(ctx, kronk.FmtLogger, modelURLs, projURLs)
 	if err != nil {
 		return fmt.Errorf("download-model: %w", err)
 	}
 
+	if _, err := models.DownloadAdapters(ctx, kronk.FmtLogger, mp.ModelFile, model.Files.ToAdapterURLS()); err != nil {
+		return fmt.Errorf("download-adapters: %w", err)
+	}
+
 	return nil
 }
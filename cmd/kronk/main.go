@@ -9,6 +9,7 @@ import (
 	"github.com/ardanlabs/kronk/cmd/kronk/model"
 	"github.com/ardanlabs/kronk/cmd/kronk/security"
 	"github.com/ardanlabs/kronk/cmd/kronk/server"
+	"github.com/ardanlabs/kronk/cmd/kronk/template"
 	k "github.com/ardanlabs/kronk/sdk/kronk"
 	"github.com/spf13/cobra"
 )
@@ -42,4 +43,5 @@ func init() {
 	rootCmd.AddCommand(model.Cmd)
 	rootCmd.AddCommand(catalog.Cmd)
 	rootCmd.AddCommand(security.Cmd)
+	rootCmd.AddCommand(template.Cmd)
 }
@@ -0,0 +1,68 @@
+package fit
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/ardanlabs/kronk/sdk/tools/catalog"
+	"github.com/ardanlabs/kronk/sdk/tools/models"
+	"github.com/spf13/cobra"
+)
+
+var Cmd = &cobra.Command{
+	Use:   "fit",
+	Short: "Report which catalog models fit this machine",
+	Long: `Report which catalog models fit this machine
+
+The memory each model is estimated to use is calculated from the GGUF header
+of the model file. The header of a model that isn't pulled is downloaded.
+
+Flags:
+      --filter-category  Filter catalogs by category name (substring match)
+      --context-window   Context window to estimate with (default: catalog config or model metadata)
+      --type-k           K cache type to estimate with (default: catalog config or f16)
+      --type-v           V cache type to estimate with (default: catalog config or f16)
+      --memory           Memory available in bytes (default: system memory)
+
+Environment Variables:
+      KRONK_HF_TOKEN  Hugging Face token for reading the headers of gated models.
+      KRONK_MODELS    (default: $HOME/.kronk/models)  The path to the models directory`,
+	Args: cobra.NoArgs,
+	Run:  main,
+}
+
+func init() {
+	Cmd.Flags().String("filter-category", "", "Filter catalogs by category name (substring match)")
+	Cmd.Flags().Int("context-window", 0, "Context window to estimate with")
+	Cmd.Flags().String("type-k", "", "K cache type to estimate with")
+	Cmd.Flags().String("type-v", "", "V cache type to estimate with")
+	Cmd.Flags().Uint64("memory", 0, "Memory available in bytes")
+}
+
+func main(cmd *cobra.Command, args []string) {
+	if err := run(cmd); err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+}
+
+func run(cmd *cobra.Command) error {
+	var o options
+	o.filterCategory, _ = cmd.Flags().GetString("filter-category")
+	o.contextWindow, _ = cmd.Flags().GetInt("context-window")
+	o.typeK, _ = cmd.Flags().GetString("type-k")
+	o.typeV, _ = cmd.Flags().GetString("type-v")
+	o.memory, _ = cmd.Flags().GetUint64("memory")
+
+	models, err := models.New()
+	if err != nil {
+		return fmt.Errorf("unable to create models system: %w", err)
+	}
+
+	catalog, err := catalog.New()
+	if err != nil {
+		return fmt.Errorf("unable to create catalog system: %w", err)
+	}
+
+	return runLocal(catalog, models, o)
+}
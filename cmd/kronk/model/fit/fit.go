@@ -0,0 +1,180 @@
+// Package fit provides the fit command code.
+package fit
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/ardanlabs/kronk/sdk/tools/catalog"
+	"github.com/ardanlabs/kronk/sdk/tools/defaults"
+	"github.com/ardanlabs/kronk/sdk/tools/gguf"
+	"github.com/ardanlabs/kronk/sdk/tools/models"
+)
+
+type options struct {
+	filterCategory string
+	contextWindow  int
+	typeK          string
+	typeV          string
+	memory         uint64
+}
+
+type result struct {
+	model catalog.Model
+	est   gguf.Estimate
+	err   error
+}
+
+func runLocal(catalog *catalog.Catalog, models *models.Models, o options) error {
+	if err := catalog.Download(context.Background()); err != nil {
+		return fmt.Errorf("unable to download catalog: %w", err)
+	}
+
+	list, err := catalog.CatalogModelList(o.filterCategory)
+	if err != nil {
+		return fmt.Errorf("catalog-list: %w", err)
+	}
+
+	memory := cmp.Or(o.memory, defaults.SystemMemory())
+	if memory == 0 {
+		return fmt.Errorf("unable to identify the system memory, use the --memory flag")
+	}
+
+	results := make([]result, len(list))
+
+	for i, m := range list {
+		est, err := estimate(models, m, o)
+		results[i] = result{model: m, est: est, err: err}
+	}
+
+	print(results, memory)
+
+	return nil
+}
+
+// estimate returns the memory the model is estimated to use. The local files
+// are used when the model is pulled, otherwise the headers are downloaded.
+func estimate(models *models.Models, m catalog.Model, o options) (gguf.Estimate, error) {
+	cfg := gguf.EstimateConfig{
+		ContextWindow:  cmp.Or(o.contextWindow, m.Config.ContextWindow),
+		NUBatch:        m.Config.NUBatch,
+		TypeK:          cmp.Or(o.typeK, m.Config.TypeK),
+		TypeV:          cmp.Or(o.typeV, m.Config.TypeV),
+		FlashAttention: m.Config.FlashAttention,
+	}
+
+	var files []*gguf.File
+
+	switch m.Downloaded {
+	case true:
+		mp, err := models.RetrievePath(m.ID)
+		if err != nil {
+			return gguf.Estimate{}, err
+		}
+
+		paths := []string{mp.ModelFile}
+		if mp.ProjFile != "" {
+			paths = append(paths, mp.ProjFile)
+		}
+
+		for _, path := range paths {
+			file, err := gguf.Read(path)
+			if err != nil {
+				return gguf.Estimate{}, err
+			}
+
+			files = append(files, file)
+		}
+
+	default:
+		modelURLs, projURLs := m.Files.ToURLS()
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+		defer cancel()
+
+		for _, url := range append(modelURLs, projURLs...) {
+			file, err := gguf.ReadURL(ctx, url, os.Getenv("KRONK_HF_TOKEN"))
+			if err != nil {
+				return gguf.Estimate{}, err
+			}
+
+			files = append(files, file)
+		}
+	}
+
+	if len(files) == 0 {
+		return gguf.Estimate{}, fmt.Errorf("no model files")
+	}
+
+	return gguf.EstimateFiles(cfg, files...)
+}
+
+// =============================================================================
+
+func print(results []result, memory uint64) {
+	fmt.Printf("Memory: %s\n\n", formatSize(memory))
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
+	fmt.Fprintln(w, "CATALOG\tMODEL ID\tPULLED\tCONTEXT\tWEIGHTS\tKV CACHE\tCOMPUTE\tTOTAL\tFITS")
+
+	var failed []result
+
+	for _, r := range results {
+		if r.err != nil {
+			fmt.Fprintf(w, "%s\t%s\t%s\t-\t-\t-\t-\t-\tunknown\n", r.model.Category, r.model.ID, boolToStr(r.model.Downloaded))
+			failed = append(failed, r)
+			continue
+		}
+
+		fmt.Fprintf(w, "%s\t%s\t%s\t%d\t%s\t%s\t%s\t%s\t%s\n",
+			r.model.Category,
+			r.model.ID,
+			boolToStr(r.model.Downloaded),
+			r.est.ContextWindow,
+			formatSize(r.est.Weights),
+			formatSize(r.est.KVCache),
+			formatSize(r.est.Compute),
+			formatSize(r.est.Total()),
+			boolToStr(r.est.Total() <= memory),
+		)
+	}
+
+	w.Flush()
+
+	if len(failed) > 0 {
+		fmt.Println()
+		for _, r := range failed {
+			fmt.Printf("%s: %s\n", r.model.ID, r.err)
+		}
+	}
+}
+
+func formatSize(bytes uint64) string {
+	const (
+		KB = 1024
+		MB = KB * 1024
+		GB = MB * 1024
+	)
+
+	switch {
+	case bytes >= GB:
+		return fmt.Sprintf("%.1f GB", float64(bytes)/GB)
+	case bytes >= MB:
+		return fmt.Sprintf("%.1f MB", float64(bytes)/MB)
+	case bytes >= KB:
+		return fmt.Sprintf("%.1f KB", float64(bytes)/KB)
+	default:
+		return fmt.Sprintf("%d B", bytes)
+	}
+}
+
+func boolToStr(b bool) string {
+	if b {
+		return "yes"
+	}
+	return "no"
+}
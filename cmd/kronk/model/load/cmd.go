@@ -0,0 +1,70 @@
+package load
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+)
+
+var Cmd = &cobra.Command{
+	Use:   "load MODEL_NAME",
+	Short: "Load a model into the server",
+	Long: `Load a model into the server
+
+The model is loaded into the model cache and warmed up with a short request
+on every instance. The command returns once the model is ready to serve
+requests. The flags override the global and per-model settings.
+
+Flags:
+      --context-window   Context window to load the model with
+      --nbatch           Logical batch size to load the model with
+      --nubatch          Physical batch size to load the model with
+      --type-k           K cache type to load the model with
+      --type-v           V cache type to load the model with
+      --flash-attention  Flash attention mode: auto, enabled or disabled
+
+Environment Variables:
+      KRONK_TOKEN         (required when auth enabled)  Authentication token for the kronk server.
+      KRONK_WEB_API_HOST  (default localhost:8080)  IP Address for the kronk server.`,
+	Args: cobra.ExactArgs(1),
+	Run:  main,
+}
+
+func init() {
+	Cmd.Flags().Int("context-window", 0, "Context window to load the model with")
+	Cmd.Flags().Int("nbatch", 0, "Logical batch size to load the model with")
+	Cmd.Flags().Int("nubatch", 0, "Physical batch size to load the model with")
+	Cmd.Flags().String("type-k", "", "K cache type to load the model with")
+	Cmd.Flags().String("type-v", "", "V cache type to load the model with")
+	Cmd.Flags().String("flash-attention", "", "Flash attention mode: auto, enabled or disabled")
+}
+
+func main(cmd *cobra.Command, args []string) {
+	if err := run(cmd, args); err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+}
+
+func run(cmd *cobra.Command, args []string) error {
+	rc := make(map[string]any)
+
+	for _, name := range []string{"context-window", "nbatch", "nubatch"} {
+		if v, _ := cmd.Flags().GetInt(name); v != 0 {
+			rc[flagToKey(name)] = v
+		}
+	}
+
+	for _, name := range []string{"type-k", "type-v", "flash-attention"} {
+		if v, _ := cmd.Flags().GetString(name); v != "" {
+			rc[flagToKey(name)] = v
+		}
+	}
+
+	if err := runWeb(args, rc); err != nil {
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,99 @@
+// Package load provides the load command code.
+package load
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/ardanlabs/kronk/cmd/kronk/client"
+	"github.com/ardanlabs/kronk/cmd/server/app/domain/toolapp"
+)
+
+func runWeb(args []string, rc map[string]any) error {
+	url, err := client.DefaultURL("/v1/models")
+	if err != nil {
+		return fmt.Errorf("default-url: %w", err)
+	}
+
+	url = fmt.Sprintf("%s/%s/load", url, args[0])
+
+	fmt.Println("URL:", url)
+
+	var body client.D
+	if len(rc) > 0 {
+		body = client.D{
+			"runtime_config": rc,
+		}
+	}
+
+	cln := client.New(
+		client.FmtLogger,
+		client.WithBearer(os.Getenv("KRONK_TOKEN")),
+	)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Minute)
+	defer cancel()
+
+	// The load request returns once the model is loaded and warmed up, so
+	// follow the load progress while waiting.
+	progressCtx, stopProgress := context.WithCancel(ctx)
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+		followProgress(progressCtx, url)
+	}()
+
+	var resp toolapp.LoadResponse
+	err = cln.Do(ctx, http.MethodPost, url, body, &resp)
+
+	stopProgress()
+	<-done
+
+	if err != nil {
+		return fmt.Errorf("\nload-model: %w", err)
+	}
+
+	fmt.Printf("\rModel %s is %s\n", resp.ID, resp.Status)
+
+	return nil
+}
+
+// followProgress prints the load progress until the context is canceled. The
+// model isn't being loaded until the load request reaches the server, so the
+// progress is requested again until the load starts.
+func followProgress(ctx context.Context, url string) {
+	cln := client.NewSSE[toolapp.LoadResponse](
+		client.NoopLogger,
+		client.WithBearer(os.Getenv("KRONK_TOKEN")),
+	)
+
+	for {
+		ch := make(chan toolapp.LoadResponse)
+		if err := cln.Do(ctx, http.MethodGet, url, nil, ch); err != nil {
+			return
+		}
+
+		for lr := range ch {
+			if lr.Status == "loading" {
+				fmt.Printf("\rLoading: %3.0f%%", lr.Progress*100)
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+
+		case <-time.After(250 * time.Millisecond):
+		}
+	}
+}
+
+// flagToKey returns the runtime config key for a flag name.
+func flagToKey(name string) string {
+	return strings.ReplaceAll(name, "-", "_")
+}
@@ -2,29 +2,35 @@
 package model
 
 import (
+	"github.com/ardanlabs/kronk/cmd/kronk/model/fit"
 	"github.com/ardanlabs/kronk/cmd/kronk/model/index"
 	"github.com/ardanlabs/kronk/cmd/kronk/model/list"
+	"github.com/ardanlabs/kronk/cmd/kronk/model/load"
 	"github.com/ardanlabs/kronk/cmd/kronk/model/ps"
 	"github.com/ardanlabs/kronk/cmd/kronk/model/pull"
 	"github.com/ardanlabs/kronk/cmd/kronk/model/remove"
 	"github.com/ardanlabs/kronk/cmd/kronk/model/show"
+	"github.com/ardanlabs/kronk/cmd/kronk/model/unload"
 	"github.com/spf13/cobra"
 )
 
 var Cmd = &cobra.Command{
 	Use:   "model",
 	Short: "Manage models",
-	Long:  `Manage models - list, pull, remove, show, and check running models`,
+	Long:  `Manage models - list, pull, remove, show, load, unload, check running models, and check which models fit`,
 	Run: func(cmd *cobra.Command, args []string) {
 		cmd.Help()
 	},
 }
 
 func init() {
+	Cmd.AddCommand(fit.Cmd)
 	Cmd.AddCommand(index.Cmd)
 	Cmd.AddCommand(list.Cmd)
+	Cmd.AddCommand(load.Cmd)
 	Cmd.AddCommand(pull.Cmd)
 	Cmd.AddCommand(remove.Cmd)
 	Cmd.AddCommand(show.Cmd)
 	Cmd.AddCommand(ps.Cmd)
+	Cmd.AddCommand(unload.Cmd)
 }
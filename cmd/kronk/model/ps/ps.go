@@ -2,15 +2,18 @@
 package ps
 
 import (
+	"cmp"
 	"context"
 	"fmt"
 	"net/http"
 	"os"
+	"strings"
 	"text/tabwriter"
 	"time"
 
 	"github.com/ardanlabs/kronk/cmd/kronk/client"
 	"github.com/ardanlabs/kronk/cmd/server/app/domain/toolapp"
+	"github.com/ardanlabs/kronk/sdk/kronk"
 )
 
 func runWeb() error {
@@ -43,13 +46,25 @@ func runWeb() error {
 
 func printWeb(models []toolapp.ModelDetail) {
 	w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
-	fmt.Fprintln(w, "ID\tOWNED BY\tMODEL FAMILY\tSIZE\tEXPIRES\tSESSIONS")
+	fmt.Fprintln(w, "ID\tCONFIG\tOWNED BY\tMODEL FAMILY\tSIZE\tCONTEXT\tKV CACHE\tFLASH ATTN\tADAPTERS\tSTATUS\tEXPIRES\tSESSIONS\tQUEUED\tINSTANCES\tDEVICES")
 
 	for _, model := range models {
 		size := formatSize(model.Size)
-		expiresIn := time.Until(model.ExpiresAt).Truncate(time.Second)
-
-		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%d\n", model.ID, model.OwnedBy, model.ModelFamily, size, expiresIn, model.ActiveStreams)
+		expiresIn := time.Until(model.ExpiresAt).Truncate(time.Second).String()
+		if model.Pinned {
+			expiresIn = "pinned"
+		}
+
+		status := "ready"
+		if model.Loading {
+			status = fmt.Sprintf("loading %.0f%%", model.LoadProgress*100)
+			expiresIn = "-"
+		}
+		adapters := strings.Join(model.Config.Adapters, ",")
+		kvCache := cmp.Or(model.Config.TypeK, "f16") + "/" + cmp.Or(model.Config.TypeV, "f16")
+		flashAttn := cmp.Or(model.Config.FlashAttention, "auto")
+
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%d\t%s\t%s\t%s\t%s\t%s\t%d\t%d\t%s\t%s\n", model.ID, model.ConfigHash, model.OwnedBy, model.ModelFamily, size, model.Config.ContextWindow, kvCache, flashAttn, adapters, status, expiresIn, model.ActiveStreams, model.Queued, instances(model.Instances), devices(model.Instances))
 	}
 
 	w.Flush()
@@ -73,3 +88,59 @@ func formatSize(bytes int64) string {
 		return fmt.Sprintf("%d B", bytes)
 	}
 }
+
+// instances returns the number of healthy instances out of the total, along
+// with the state of the instances that are not healthy.
+func instances(status []toolapp.InstanceStatus) string {
+	if len(status) == 0 {
+		return "-"
+	}
+
+	var healthy int
+	var unhealthy []string
+
+	for _, inst := range status {
+		switch inst.State {
+		case kronk.InstanceIdle, kronk.InstanceBusy:
+			healthy++
+		default:
+			unhealthy = append(unhealthy, fmt.Sprintf("%d:%s", inst.ID, inst.State))
+		}
+	}
+
+	s := fmt.Sprintf("%d/%d", healthy, len(status))
+	if len(unhealthy) > 0 {
+		s += " (" + strings.Join(unhealthy, ",") + ")"
+	}
+
+	return s
+}
+
+// devices returns the number of instances on each device in the order the
+// devices were first used.
+func devices(status []toolapp.InstanceStatus) string {
+	var names []string
+	counts := make(map[string]int)
+
+	for _, inst := range status {
+		if inst.Device == "" || inst.State == kronk.InstanceFailed {
+			continue
+		}
+
+		if counts[inst.Device] == 0 {
+			names = append(names, inst.Device)
+		}
+		counts[inst.Device]++
+	}
+
+	if len(names) == 0 {
+		return "-"
+	}
+
+	parts := make([]string, len(names))
+	for i, name := range names {
+		parts[i] = fmt.Sprintf("%s:%d", name, counts[name])
+	}
+
+	return strings.Join(parts, ",")
+}
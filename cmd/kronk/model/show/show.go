@@ -6,12 +6,13 @@ import (
 	"fmt"
 	"net/http"
 	"os"
+	"path/filepath"
+	"strings"
 	"time"
 
 	"github.com/ardanlabs/kronk/cmd/kronk/client"
 	"github.com/ardanlabs/kronk/cmd/server/app/domain/toolapp"
-	"github.com/ardanlabs/kronk/sdk/kronk"
-	"github.com/ardanlabs/kronk/sdk/kronk/model"
+	"github.com/ardanlabs/kronk/sdk/tools/gguf"
 	"github.com/ardanlabs/kronk/sdk/tools/models"
 )
 
@@ -51,24 +52,17 @@ func runLocal(models *models.Models, args []string) error {
 		return fmt.Errorf("unable to retrieve model info: %w", err)
 	}
 
-	if err := kronk.Init(); err != nil {
-		return fmt.Errorf("unable to init kronk: %w", err)
-	}
-
 	mp, err := models.RetrievePath(modelID)
 	if err != nil {
 		return fmt.Errorf("unable to retrieve model path: %w", err)
 	}
 
-	krn, err := kronk.New(1, model.Config{
-		ModelFile: mp.ModelFile,
-	})
-
+	file, err := gguf.Read(mp.ModelFile)
 	if err != nil {
-		return err
+		return fmt.Errorf("unable to read model file: %w", err)
 	}
 
-	printLocal(mi, krn.ModelInfo())
+	printLocal(mi, mp, file)
 
 	return nil
 }
@@ -76,39 +70,55 @@ func runLocal(models *models.Models, args []string) error {
 // =============================================================================
 
 func printWeb(mi toolapp.ModelInfoResponse) {
-	fmt.Printf("ID:          %s\n", mi.ID)
-	fmt.Printf("Object:      %s\n", mi.Object)
-	fmt.Printf("Created:     %v\n", time.UnixMilli(mi.Created))
-	fmt.Printf("OwnedBy:     %s\n", mi.OwnedBy)
-	fmt.Printf("Desc:        %s\n", mi.Desc)
-	fmt.Printf("Size:        %.2f MiB\n", float64(mi.Size)/(1024*1024))
-	fmt.Printf("HasProj:     %t\n", mi.HasProjection)
-	fmt.Printf("HasEncoder:  %t\n", mi.HasEncoder)
-	fmt.Printf("HasDecoder:  %t\n", mi.HasDecoder)
-	fmt.Printf("IsRecurrent: %t\n", mi.IsRecurrent)
-	fmt.Printf("IsHybrid:    %t\n", mi.IsHybrid)
-	fmt.Printf("IsGPT:       %t\n", mi.IsGPT)
+	fmt.Printf("ID:           %s\n", mi.ID)
+	fmt.Printf("Object:       %s\n", mi.Object)
+	fmt.Printf("Created:      %v\n", time.UnixMilli(mi.Created))
+	fmt.Printf("OwnedBy:      %s\n", mi.OwnedBy)
+	fmt.Printf("Desc:         %s\n", mi.Desc)
+	fmt.Printf("Size:         %.2f MiB\n", float64(mi.Size)/(1024*1024))
+	fmt.Printf("Arch:         %s\n", mi.Architecture)
+	fmt.Printf("Parameters:   %d\n", mi.ParameterCount)
+	fmt.Printf("Quantization: %s\n", mi.Quantization)
+	fmt.Printf("Context:      %d\n", mi.ContextLength)
+	fmt.Printf("HasProj:      %t\n", mi.HasProjection)
+	fmt.Printf("HasEncoder:   %t\n", mi.HasEncoder)
+	fmt.Printf("HasDecoder:   %t\n", mi.HasDecoder)
+	fmt.Printf("IsRecurrent:  %t\n", mi.IsRecurrent)
+	fmt.Printf("IsHybrid:     %t\n", mi.IsHybrid)
+	fmt.Printf("IsGPT:        %t\n", mi.IsGPT)
+	fmt.Printf("Adapters:     %s\n", strings.Join(mi.Adapters, ", "))
 	fmt.Println("Metadata:")
 	for k, v := range mi.Metadata {
 		fmt.Printf("  %s: %s\n", k, v)
 	}
 }
 
-func printLocal(mi models.Info, details model.ModelInfo) {
-	fmt.Printf("ID:          %s\n", mi.ID)
-	fmt.Printf("Object:      %s\n", mi.Object)
-	fmt.Printf("Created:     %v\n", time.UnixMilli(mi.Created))
-	fmt.Printf("OwnedBy:     %s\n", mi.OwnedBy)
-	fmt.Printf("Desc:        %s\n", details.Desc)
-	fmt.Printf("Size:        %.2f MiB\n", float64(details.Size)/(1024*1024))
-	fmt.Printf("HasProj:     %t\n", details.HasProjection)
-	fmt.Printf("HasEncoder:  %t\n", details.HasEncoder)
-	fmt.Printf("HasDecoder:  %t\n", details.HasDecoder)
-	fmt.Printf("IsRecurrent: %t\n", details.IsRecurrent)
-	fmt.Printf("IsHybrid:    %t\n", details.IsHybrid)
-	fmt.Printf("IsGPT:       %t\n", details.IsGPTModel)
+func printLocal(mi models.Info, mp models.Path, file *gguf.File) {
+	info := file.Info()
+
+	adapters := make([]string, len(mp.AdapterFiles))
+	for i, adapterFile := range mp.AdapterFiles {
+		adapters[i] = filepath.Base(adapterFile)
+	}
+
+	fmt.Printf("ID:           %s\n", mi.ID)
+	fmt.Printf("Object:       %s\n", mi.Object)
+	fmt.Printf("Created:      %v\n", time.UnixMilli(mi.Created))
+	fmt.Printf("OwnedBy:      %s\n", mi.OwnedBy)
+	fmt.Printf("Name:         %s\n", info.Name)
+	fmt.Printf("Size:         %.2f MiB\n", float64(file.WeightsSize())/(1024*1024))
+	fmt.Printf("Arch:         %s\n", info.Architecture)
+	fmt.Printf("Parameters:   %d\n", info.ParameterCount)
+	fmt.Printf("Quantization: %s\n", info.Quantization)
+	fmt.Printf("Context:      %d\n", info.ContextLength)
+	fmt.Printf("HasProj:      %t\n", mp.ProjFile != "")
+	fmt.Printf("HasEncoder:   %t\n", info.HasEncoder)
+	fmt.Printf("HasDecoder:   %t\n", info.HasDecoder)
+	fmt.Printf("IsRecurrent:  %t\n", info.IsRecurrent)
+	fmt.Printf("IsHybrid:     %t\n", info.IsHybrid)
+	fmt.Printf("Adapters:     %s\n", strings.Join(adapters, ", "))
 	fmt.Println("Metadata:")
-	for k, v := range details.Metadata {
+	for k, v := range file.MetadataStrings() {
 		fmt.Printf("  %s: %s\n", k, v)
 	}
 }
@@ -0,0 +1,38 @@
+package unload
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+)
+
+var Cmd = &cobra.Command{
+	Use:   "unload MODEL_NAME",
+	Short: "Unload a model from the server",
+	Long: `Unload a model from the server
+
+Every variant of the model is removed from the model cache. The active
+requests for the model finish before it's unloaded.
+
+Environment Variables:
+      KRONK_TOKEN         (required when auth enabled)  Authentication token for the kronk server.
+      KRONK_WEB_API_HOST  (default localhost:8080)  IP Address for the kronk server.`,
+	Args: cobra.ExactArgs(1),
+	Run:  main,
+}
+
+func main(cmd *cobra.Command, args []string) {
+	if err := run(args); err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+}
+
+func run(args []string) error {
+	if err := runWeb(args); err != nil {
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,41 @@
+// Package unload provides the unload command code.
+package unload
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/ardanlabs/kronk/cmd/kronk/client"
+	"github.com/ardanlabs/kronk/cmd/server/app/domain/toolapp"
+)
+
+func runWeb(args []string) error {
+	url, err := client.DefaultURL("/v1/models")
+	if err != nil {
+		return fmt.Errorf("default-url: %w", err)
+	}
+
+	url = fmt.Sprintf("%s/%s/unload", url, args[0])
+
+	fmt.Println("URL:", url)
+
+	cln := client.New(
+		client.FmtLogger,
+		client.WithBearer(os.Getenv("KRONK_TOKEN")),
+	)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+
+	var resp toolapp.LoadResponse
+	if err := cln.Do(ctx, http.MethodPost, url, nil, &resp); err != nil {
+		return fmt.Errorf("unload-model: %w", err)
+	}
+
+	fmt.Printf("Model %s is %s\n", resp.ID, resp.Status)
+
+	return nil
+}
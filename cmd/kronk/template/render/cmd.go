@@ -0,0 +1,76 @@
+package render
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/ardanlabs/kronk/sdk/tools/models"
+	"github.com/spf13/cobra"
+)
+
+var Cmd = &cobra.Command{
+	Use:   "render",
+	Short: "Render the prompt for a chat request",
+	Long: `Render the prompt the model's chat template creates for a chat request
+without running inference. The file contains the chat request as json, either
+a document with a messages field or just the array of messages.
+
+Examples:
+      kronk template render --model qwen3-8b-q8_0 --file messages.json
+      kronk template render --model qwen3-8b-q8_0 --file messages.json --local
+
+Environment Variables (web mode - default):
+      KRONK_TOKEN         (required when auth enabled)  Authentication token for the kronk server.
+      KRONK_WEB_API_HOST  (default localhost:8080)  IP Address for the kronk server.
+
+Environment Variables (--local mode):
+      KRONK_MODELS  (default: $HOME/.kronk/models)  The path to the models directory`,
+	Args: cobra.NoArgs,
+	Run:  main,
+}
+
+func init() {
+	Cmd.Flags().Bool("local", false, "Run without the model server")
+	Cmd.Flags().String("model", "", "The model to render the prompt with")
+	Cmd.Flags().String("file", "", "The file containing the chat request")
+	Cmd.MarkFlagRequired("model")
+	Cmd.MarkFlagRequired("file")
+}
+
+func main(cmd *cobra.Command, args []string) {
+	if err := run(cmd); err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+}
+
+func run(cmd *cobra.Command) error {
+	local, _ := cmd.Flags().GetBool("local")
+	modelID, _ := cmd.Flags().GetString("model")
+	file, _ := cmd.Flags().GetString("file")
+
+	req, err := readRequest(file)
+	if err != nil {
+		return err
+	}
+
+	switch local {
+	case true:
+		models, err := models.New()
+		if err != nil {
+			return fmt.Errorf("unable to create models system: %w", err)
+		}
+
+		err = runLocal(models, modelID, req)
+		if err != nil {
+			return err
+		}
+
+	default:
+		if err := runWeb(modelID, req); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
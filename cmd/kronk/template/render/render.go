@@ -0,0 +1,127 @@
+// Package render provides the template render command code.
+package render
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/ardanlabs/kronk/cmd/kronk/client"
+	"github.com/ardanlabs/kronk/sdk/kronk"
+	"github.com/ardanlabs/kronk/sdk/kronk/model"
+	"github.com/ardanlabs/kronk/sdk/tools/models"
+)
+
+func runWeb(modelID string, req map[string]any) error {
+	url, err := client.DefaultURL("/v1/chat/render")
+	if err != nil {
+		return fmt.Errorf("default-url: %w", err)
+	}
+
+	fmt.Println("URL:", url)
+
+	cln := client.New(
+		client.FmtLogger,
+		client.WithBearer(os.Getenv("KRONK_TOKEN")),
+	)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+
+	body := client.D(req)
+	body["model"] = modelID
+
+	var resp model.RenderResponse
+	if err := cln.Do(ctx, http.MethodPost, url, body, &resp); err != nil {
+		return fmt.Errorf("do: unable to render prompt: %w", err)
+	}
+
+	printResponse(resp)
+
+	return nil
+}
+
+func runLocal(models *models.Models, modelID string, req map[string]any) error {
+	if err := kronk.Init(); err != nil {
+		return fmt.Errorf("unable to init kronk: %w", err)
+	}
+
+	mp, err := models.RetrievePath(modelID)
+	if err != nil {
+		return fmt.Errorf("unable to retrieve model path: %w", err)
+	}
+
+	adapters := make([]model.AdapterConfig, len(mp.AdapterFiles))
+	for i, adapterFile := range mp.AdapterFiles {
+		adapters[i] = model.AdapterConfig{Path: adapterFile}
+	}
+
+	krn, err := kronk.New(1, model.Config{
+		ModelFile: mp.ModelFile,
+		Adapters:  adapters,
+	})
+
+	if err != nil {
+		return err
+	}
+
+	defer krn.Unload(context.Background())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+
+	resp, err := krn.RenderPrompt(ctx, model.MapToModelD(req))
+	if err != nil {
+		return fmt.Errorf("unable to render prompt: %w", err)
+	}
+
+	printResponse(resp)
+
+	return nil
+}
+
+// =============================================================================
+
+// readRequest reads the chat request from the file. The file can hold a full
+// chat request or just the array of messages.
+func readRequest(file string) (map[string]any, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read file: %w", err)
+	}
+
+	var v any
+	if err := json.Unmarshal(data, &v); err != nil {
+		return nil, fmt.Errorf("unable to parse file: %w", err)
+	}
+
+	switch req := v.(type) {
+	case map[string]any:
+		return req, nil
+
+	case []any:
+		return map[string]any{"messages": req}, nil
+
+	default:
+		return nil, fmt.Errorf("file must contain a json document or an array of messages")
+	}
+}
+
+func printResponse(resp model.RenderResponse) {
+	fmt.Printf("Model:   %s\n", resp.Model)
+	fmt.Printf("Tokens:  %d\n", resp.Tokens)
+
+	if len(resp.Media) > 0 {
+		fmt.Printf("Marker:  %s\n", resp.MediaMarker)
+		fmt.Println("Media:")
+		for i, med := range resp.Media {
+			fmt.Printf("  [%d] offset: %d  size: %d bytes\n", i, med.Offset, med.Size)
+		}
+	}
+
+	fmt.Println("Prompt:")
+	fmt.Println(resp.Prompt)
+}
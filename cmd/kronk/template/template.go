@@ -0,0 +1,20 @@
+// Package template provide support for the template sub-command.
+package template
+
+import (
+	"github.com/ardanlabs/kronk/cmd/kronk/template/render"
+	"github.com/spf13/cobra"
+)
+
+var Cmd = &cobra.Command{
+	Use:   "template",
+	Short: "Work with model chat templates",
+	Long:  `Work with model chat templates - render the prompt for a chat request`,
+	Run: func(cmd *cobra.Command, args []string) {
+		cmd.Help()
+	},
+}
+
+func init() {
+	Cmd.AddCommand(render.Cmd)
+}
@@ -84,6 +84,12 @@ export default function DocsAPIChat() {
                     <td>No</td>
                     <td>Array of tool definitions for function calling. See Tool Definitions section below.</td>
                   </tr>
+                  <tr>
+                    <td><code>priority</code></td>
+                    <td><code>string</code></td>
+                    <td>No</td>
+                    <td>Queue priority for the request: interactive (default) or batch. Interactive requests are handed a model instance before batch requests. Requests are queued fairly between the token subjects. When the queue is full, a 429 is returned with a Retry-After header.</td>
+                  </tr>
                   <tr>
                     <td><code>temperature</code></td>
                     <td><code>float32</code></td>
@@ -72,6 +72,12 @@ export default function DocsAPIEmbeddings() {
                     <td>Yes</td>
                     <td>Text to generate embeddings for. Can be a string or array of strings.</td>
                   </tr>
+                  <tr>
+                    <td><code>priority</code></td>
+                    <td><code>string</code></td>
+                    <td>No</td>
+                    <td>Queue priority for the request: interactive (default) or batch. Interactive requests are handed a model instance before batch requests. Requests are queued fairly between the token subjects. When the queue is full, a 429 is returned with a Retry-After header.</td>
+                  </tr>
                 </tbody>
               </table>
               <h5>Response</h5>
@@ -169,7 +169,7 @@ export default function DocsAPITools() {
                 </tbody>
               </table>
               <h5>Response</h5>
-              <p>Returns a list of running models with id, owned_by, model_family, size, expires_at, and active_streams.</p>
+              <p>Returns a list of running models with id, owned_by, model_family, size, expires_at, pinned, active_streams, queued, instances, loading, and load_progress. Each instance reports the device it was placed on, its state (idle, busy, quarantined, reloading, failed), requests, failures, panics, reloads, busy_time_ms, and last_error.</p>
               <h5>Example</h5>
               <p className="example-label"><strong>List running models:</strong></p>
               <pre className="code-block">
@@ -177,6 +177,126 @@ export default function DocsAPITools() {
               </pre>
             </div>
 
+            <div className="doc-section" id="models-get--models-model-load">
+              <h4><span className="method-get">GET</span> /models/&#123;model&#125;/load</h4>
+              <p className="doc-description">Follow the progress of loading a model into the cache. Requests for a model that isn't in the cache share a single load. Returns streaming progress updates until the model is loaded.</p>
+              <p><strong>Authentication:</strong> Optional when auth is enabled.</p>
+              <h5>Headers</h5>
+              <table className="flags-table">
+                <thead>
+                  <tr>
+                    <th>Header</th>
+                    <th>Required</th>
+                    <th>Description</th>
+                  </tr>
+                </thead>
+                <tbody>
+                  <tr>
+                    <td><code>Authorization</code></td>
+                    <td>No</td>
+                    <td>Bearer token for authentication</td>
+                  </tr>
+                </tbody>
+              </table>
+              <h5>Response</h5>
+              <p>Streams the load progress as Server-Sent Events with status, id, config_hash, and progress from 0 to 1. The status is loading, loaded, unloaded when the model isn't being loaded, or the error that failed the load.</p>
+              <h5>Example</h5>
+              <p className="example-label"><strong>Follow the load progress of a model:</strong></p>
+              <pre className="code-block">
+                <code>{`curl -N -X GET http://localhost:8080/v1/models/qwen3-8b-q8_0/load`}</code>
+              </pre>
+            </div>
+
+            <div className="doc-section" id="models-post--models-model-load">
+              <h4><span className="method-post">POST</span> /models/&#123;model&#125;/load</h4>
+              <p className="doc-description">Load a model into the cache and warm it up with a short request on every instance. Returns once the model is ready to serve requests.</p>
+              <p><strong>Authentication:</strong> Required when auth is enabled. Admin token required.</p>
+              <h5>Headers</h5>
+              <table className="flags-table">
+                <thead>
+                  <tr>
+                    <th>Header</th>
+                    <th>Required</th>
+                    <th>Description</th>
+                  </tr>
+                </thead>
+                <tbody>
+                  <tr>
+                    <td><code>Authorization</code></td>
+                    <td>Yes</td>
+                    <td>Bearer token for admin authentication</td>
+                  </tr>
+                  <tr>
+                    <td><code>Content-Type</code></td>
+                    <td>No</td>
+                    <td>Must be application/json</td>
+                  </tr>
+                </tbody>
+              </table>
+              <h5>Request Body</h5>
+              <p><code>application/json</code></p>
+              <table className="flags-table">
+                <thead>
+                  <tr>
+                    <th>Field</th>
+                    <th>Type</th>
+                    <th>Required</th>
+                    <th>Description</th>
+                  </tr>
+                </thead>
+                <tbody>
+                  <tr>
+                    <td><code>runtime_config</code></td>
+                    <td><code>object</code></td>
+                    <td>No</td>
+                    <td>Overrides the global and per-model settings the model is loaded with. Accepts the same fields as the runtime_config of a chat request.</td>
+                  </tr>
+                </tbody>
+              </table>
+              <h5>Response</h5>
+              <p>Returns the status and id of the loaded model.</p>
+              <h5>Example</h5>
+              <p className="example-label"><strong>Load a model with a larger context window:</strong></p>
+              <pre className="code-block">
+                <code>{`curl -X POST http://localhost:8080/v1/models/qwen3-8b-q8_0/load \\
+  -H "Authorization: Bearer $KRONK_TOKEN" \\
+  -H "Content-Type: application/json" \\
+  -d '{
+    "runtime_config": {"context_window": 32768}
+  }'`}</code>
+              </pre>
+            </div>
+
+            <div className="doc-section" id="models-post--models-model-unload">
+              <h4><span className="method-post">POST</span> /models/&#123;model&#125;/unload</h4>
+              <p className="doc-description">Unload every variant of a model from the cache. The active requests for the model finish before it&apos;s unloaded.</p>
+              <p><strong>Authentication:</strong> Required when auth is enabled. Admin token required.</p>
+              <h5>Headers</h5>
+              <table className="flags-table">
+                <thead>
+                  <tr>
+                    <th>Header</th>
+                    <th>Required</th>
+                    <th>Description</th>
+                  </tr>
+                </thead>
+                <tbody>
+                  <tr>
+                    <td><code>Authorization</code></td>
+                    <td>Yes</td>
+                    <td>Bearer token for admin authentication</td>
+                  </tr>
+                </tbody>
+              </table>
+              <h5>Response</h5>
+              <p>Returns the status and id of the unloaded model.</p>
+              <h5>Example</h5>
+              <p className="example-label"><strong>Unload a model:</strong></p>
+              <pre className="code-block">
+                <code>{`curl -X POST http://localhost:8080/v1/models/qwen3-8b-q8_0/unload -H "Authorization: Bearer $KRONK_TOKEN"`}</code>
+              </pre>
+            </div>
+
             <div className="doc-section" id="models-post--models-index">
               <h4><span className="method-post">POST</span> /models/index</h4>
               <p className="doc-description">Rebuild the model index for fast model access.</p>
@@ -622,6 +742,9 @@ export default function DocsAPITools() {
                 <li><a href="#models-get--models">GET /models</a></li>
                 <li><a href="#models-get--models-model">GET /models/&#123;model&#125;</a></li>
                 <li><a href="#models-get--models-ps">GET /models/ps</a></li>
+                <li><a href="#models-get--models-model-load">GET /models/&#123;model&#125;/load</a></li>
+                <li><a href="#models-post--models-model-load">POST /models/&#123;model&#125;/load</a></li>
+                <li><a href="#models-post--models-model-unload">POST /models/&#123;model&#125;/unload</a></li>
                 <li><a href="#models-post--models-index">POST /models/index</a></li>
                 <li><a href="#models-post--models-pull">POST /models/pull</a></li>
                 <li><a href="#models-delete--models-model">DELETE /models/&#123;model&#125;</a></li>
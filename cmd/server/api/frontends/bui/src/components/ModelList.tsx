@@ -234,6 +234,22 @@ export default function ModelList() {
               <label>Created</label>
               <span>{new Date(modelInfo.created).toLocaleString()}</span>
             </div>
+            <div className="model-meta-item">
+              <label>Architecture</label>
+              <span>{modelInfo.architecture}</span>
+            </div>
+            <div className="model-meta-item">
+              <label>Parameters</label>
+              <span>{modelInfo.parameter_count.toLocaleString()}</span>
+            </div>
+            <div className="model-meta-item">
+              <label>Quantization</label>
+              <span>{modelInfo.quantization}</span>
+            </div>
+            <div className="model-meta-item">
+              <label>Context Length</label>
+              <span>{modelInfo.context_length.toLocaleString()}</span>
+            </div>
             <div className="model-meta-item">
               <label>Has Projection</label>
               <span className={`badge ${modelInfo.has_projection ? 'badge-yes' : 'badge-no'}`}>
@@ -1,6 +1,6 @@
 import { useState, useEffect } from 'react';
 import { api } from '../services/api';
-import type { ModelDetailsResponse } from '../types';
+import type { InstanceStatus, ModelDetailsResponse } from '../types';
 
 function formatBytes(bytes: number): string {
   if (bytes === 0) return '0 B';
@@ -14,6 +14,26 @@ function formatDate(dateStr: string): string {
   return new Date(dateStr).toLocaleString();
 }
 
+function formatInstances(instances: InstanceStatus[] | null): string {
+  if (!instances || instances.length === 0) return '-';
+  const healthy = instances.filter((inst) => inst.state === 'idle' || inst.state === 'busy').length;
+  const unhealthy = instances
+    .filter((inst) => inst.state !== 'idle' && inst.state !== 'busy')
+    .map((inst) => `${inst.id}: ${inst.state}`);
+  const summary = `${healthy}/${instances.length} healthy`;
+  return unhealthy.length > 0 ? `${summary} (${unhealthy.join(', ')})` : summary;
+}
+
+function formatDevices(instances: InstanceStatus[] | null): string {
+  const counts = new Map<string, number>();
+  for (const inst of instances ?? []) {
+    if (!inst.device || inst.state === 'failed') continue;
+    counts.set(inst.device, (counts.get(inst.device) ?? 0) + 1);
+  }
+  if (counts.size === 0) return '-';
+  return Array.from(counts, ([device, count]) => `${device}: ${count}`).join(', ');
+}
+
 export default function ModelPs() {
   const [data, setData] = useState<ModelDetailsResponse | null>(null);
   const [loading, setLoading] = useState(true);
@@ -60,6 +80,9 @@ export default function ModelPs() {
                     <th>Size</th>
                     <th>Expires At</th>
                     <th>Active Streams</th>
+                    <th>Queued</th>
+                    <th>Instances</th>
+                    <th>Devices</th>
                   </tr>
                 </thead>
                 <tbody>
@@ -69,8 +92,11 @@ export default function ModelPs() {
                       <td>{model.owned_by}</td>
                       <td>{model.model_family}</td>
                       <td>{formatBytes(model.size)}</td>
-                      <td>{formatDate(model.expires_at)}</td>
+                      <td>{model.loading ? `Loading ${Math.round(model.load_progress * 100)}%` : model.pinned ? 'Pinned' : formatDate(model.expires_at)}</td>
                       <td>{model.active_streams}</td>
+                      <td>{model.queued}</td>
+                      <td>{formatInstances(model.instances)}</td>
+                      <td>{formatDevices(model.instances)}</td>
                     </tr>
                   ))}
                 </tbody>
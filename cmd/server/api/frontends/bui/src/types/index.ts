@@ -13,13 +13,30 @@ export interface ListModelInfoResponse {
   data: ListModelDetail[];
 }
 
+export interface InstanceStatus {
+  id: number;
+  device?: string;
+  state: string;
+  requests: number;
+  failures: number;
+  panics: number;
+  reloads: number;
+  busy_time_ms: number;
+  last_error?: string;
+}
+
 export interface ModelDetail {
   id: string;
   owned_by: string;
   model_family: string;
   size: number;
   expires_at: string;
+  pinned: boolean;
   active_streams: number;
+  queued: number;
+  instances: InstanceStatus[] | null;
+  loading: boolean;
+  load_progress: number;
 }
 
 export type ModelDetailsResponse = ModelDetail[];
@@ -31,6 +48,10 @@ export interface ModelInfoResponse {
   owned_by: string;
   desc: string;
   size: number;
+  architecture: string;
+  parameter_count: number;
+  quantization: string;
+  context_length: number;
   has_projection: boolean;
   has_encoder: boolean;
   has_decoder: boolean;
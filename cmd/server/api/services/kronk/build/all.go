@@ -2,6 +2,7 @@
 package build
 
 import (
+	"github.com/ardanlabs/kronk/cmd/server/app/domain/audioapp"
 	"github.com/ardanlabs/kronk/cmd/server/app/domain/chatapp"
 	"github.com/ardanlabs/kronk/cmd/server/app/domain/checkapp"
 	"github.com/ardanlabs/kronk/cmd/server/app/domain/embedapp"
@@ -31,6 +32,7 @@ func (all) Add(app *web.App, cfg mux.Config) {
 		Cache:      cfg.Cache,
 		Libs:       cfg.Libs,
 		Models:     cfg.Models,
+		Sessions:   cfg.Sessions,
 		Catalog:    cfg.Catalog,
 		Templates:  cfg.Templates,
 	})
@@ -46,4 +48,10 @@ func (all) Add(app *web.App, cfg mux.Config) {
 		AuthClient: cfg.AuthClient,
 		Cache:      cfg.Cache,
 	})
+
+	audioapp.Routes(app, audioapp.Config{
+		Log:        cfg.Log,
+		AuthClient: cfg.AuthClient,
+		Cache:      cfg.Cache,
+	})
 }
@@ -0,0 +1,65 @@
+package kronk
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// byteSize represents a number of bytes that can be configured with a size
+// suffix like 512MB or 24GiB. The units are powers of 1024, so GB and GiB
+// are the same. A value without a suffix is a number of bytes.
+type byteSize uint64
+
+var byteUnits = []struct {
+	suffix string
+	size   uint64
+}{
+	{"TIB", 1 << 40},
+	{"GIB", 1 << 30},
+	{"MIB", 1 << 20},
+	{"KIB", 1 << 10},
+	{"TB", 1 << 40},
+	{"GB", 1 << 30},
+	{"MB", 1 << 20},
+	{"KB", 1 << 10},
+	{"T", 1 << 40},
+	{"G", 1 << 30},
+	{"M", 1 << 20},
+	{"K", 1 << 10},
+	{"B", 1},
+}
+
+// UnmarshalText implements the encoding.TextUnmarshaler interface.
+func (bs *byteSize) UnmarshalText(text []byte) error {
+	value := strings.ToUpper(strings.TrimSpace(string(text)))
+
+	unit := uint64(1)
+	for _, u := range byteUnits {
+		if strings.HasSuffix(value, u.suffix) {
+			value = strings.TrimSpace(strings.TrimSuffix(value, u.suffix))
+			unit = u.size
+			break
+		}
+	}
+
+	n, err := strconv.ParseFloat(value, 64)
+	if err != nil || n < 0 {
+		return fmt.Errorf("byte-size: invalid size %q", text)
+	}
+
+	*bs = byteSize(n * float64(unit))
+
+	return nil
+}
+
+// String implements the fmt.Stringer interface.
+func (bs byteSize) String() string {
+	for _, u := range byteUnits {
+		if len(u.suffix) == 3 && uint64(bs) >= u.size && uint64(bs)%u.size == 0 {
+			return fmt.Sprintf("%d%s", uint64(bs)/u.size, strings.Replace(u.suffix, "I", "i", 1))
+		}
+	}
+
+	return strconv.FormatUint(uint64(bs), 10)
+}
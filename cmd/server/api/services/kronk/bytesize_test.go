@@ -0,0 +1,43 @@
+package kronk
+
+import "testing"
+
+func Test_ByteSize(t *testing.T) {
+	tests := []struct {
+		value string
+		exp   byteSize
+		str   string
+	}{
+		{"0", 0, "0"},
+		{"1073741824", 1 << 30, "1GiB"},
+		{"24GB", 24 << 30, "24GiB"},
+		{"24GiB", 24 << 30, "24GiB"},
+		{"1.5g", 3 << 29, "1536MiB"},
+		{"512 MB", 512 << 20, "512MiB"},
+		{"1000B", 1000, "1000"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			var bs byteSize
+			if err := bs.UnmarshalText([]byte(tt.value)); err != nil {
+				t.Fatalf("unmarshal: %v", err)
+			}
+
+			if bs != tt.exp {
+				t.Fatalf("expected %d, got %d", tt.exp, bs)
+			}
+
+			if bs.String() != tt.str {
+				t.Errorf("expected %s, got %s", tt.str, bs.String())
+			}
+		})
+	}
+
+	for _, value := range []string{"", "GB", "-1GB", "24XB"} {
+		var bs byteSize
+		if err := bs.UnmarshalText([]byte(value)); err == nil {
+			t.Errorf("%q: expected an error", value)
+		}
+	}
+}
@@ -11,6 +11,7 @@ import (
 	"net/http"
 	"os"
 	"os/signal"
+	"path/filepath"
 	"runtime"
 	"syscall"
 	"time"
@@ -26,9 +27,13 @@ import (
 	"github.com/ardanlabs/kronk/sdk/kronk"
 	"github.com/ardanlabs/kronk/sdk/observ/otel"
 	"github.com/ardanlabs/kronk/sdk/tools/catalog"
+	"github.com/ardanlabs/kronk/sdk/tools/configs"
+	"github.com/ardanlabs/kronk/sdk/tools/defaults"
+	"github.com/ardanlabs/kronk/sdk/tools/fetcher"
 	"github.com/ardanlabs/kronk/sdk/tools/libs"
 	"github.com/ardanlabs/kronk/sdk/tools/models"
 	"github.com/ardanlabs/kronk/sdk/tools/security"
+	"github.com/ardanlabs/kronk/sdk/tools/sessions"
 	"github.com/ardanlabs/kronk/sdk/tools/templates"
 	"google.golang.org/grpc/test/bufconn"
 )
@@ -109,11 +114,40 @@ func run(ctx context.Context, log *logger.Logger, showHelp bool) error {
 			GithubRepo string `conf:"default:https://api.github.com/repos/ardanlabs/kronk_catalogs/contents/templates"`
 		}
 		Model struct {
-			Device        string
-			MaxInstances  int           `conf:"default:1"`
-			MaxInCache    int           `conf:"default:3"`
-			ContextWindow int           `conf:"default:0"`
-			CacheTTL      time.Duration `conf:"default:5m"`
+			Device         string
+			Devices        []string
+			Placement      string        `conf:"default:round-robin"`
+			MaxInstances   int           `conf:"default:1"`
+			MaxInCache     int           `conf:"default:3"`
+			ContextWindow  int           `conf:"default:0"`
+			CacheTTL       time.Duration `conf:"default:5m"`
+			MemoryBudget   byteSize      `conf:"default:0,env:MODEL_MEMORYBUDGET"` // Size like 24GB, 0 is no budget.
+			MaxQueue       int           `conf:"default:0"`
+			ScaleUpWait    time.Duration `conf:"default:2s"`
+			ScaleDownAfter time.Duration `conf:"default:5m"`
+			UseMmap        bool          `conf:"default:true"`
+			UseMlock       bool          `conf:"default:false"`
+			NGPULayers     int           `conf:"default:-1"`
+			OffloadKQV     bool          `conf:"default:true"`
+			FlashAttention string        `conf:"default:auto"`
+			TypeK          string        `conf:"default:f16"`
+			TypeV          string        `conf:"default:f16"`
+			NSeqMax        int           `conf:"default:0"`
+			SWAFull        bool          `conf:"default:false"`
+			Preload        []string
+		}
+		Media struct {
+			AllowedHosts []string
+			FileRoots    []string
+			MaxSize      int64         `conf:"default:20971520"`
+			Timeout      time.Duration `conf:"default:30s"`
+			Cache        bool          `conf:"default:true"`
+			CacheTTL     time.Duration `conf:"default:24h"`
+			MaxPixels    int           `conf:"default:4194304"`
+		}
+		Session struct {
+			TTL     time.Duration `conf:"default:24h"`
+			MaxSize int64         `conf:"default:0"`
 		}
 		Arch         string
 		OS           string
@@ -270,6 +304,42 @@ func run(ctx context.Context, log *logger.Logger, showHelp bool) error {
 		return fmt.Errorf("unable to create catalog system: %w", err)
 	}
 
+	// -------------------------------------------------------------------------
+	// Model Config System
+
+	modelConfigs, err := configs.New()
+	if err != nil {
+		return fmt.Errorf("unable to create model config system: %w", err)
+	}
+
+	// -------------------------------------------------------------------------
+	// Session System
+
+	sessions, err := sessions.New()
+	if err != nil {
+		return fmt.Errorf("unable to create sessions system: %w", err)
+	}
+
+	// -------------------------------------------------------------------------
+	// Media Fetcher
+
+	var mediaCacheDir string
+	if cfg.Media.Cache {
+		mediaCacheDir = filepath.Join(defaults.BaseDir(""), "media")
+	}
+
+	mediaFetcher, err := fetcher.New(fetcher.Config{
+		AllowedHosts: cfg.Media.AllowedHosts,
+		FileRoots:    cfg.Media.FileRoots,
+		MaxSize:      cfg.Media.MaxSize,
+		Timeout:      cfg.Media.Timeout,
+		CacheDir:     mediaCacheDir,
+		CacheTTL:     cfg.Media.CacheTTL,
+	})
+	if err != nil {
+		return fmt.Errorf("unable to create media fetcher: %w", err)
+	}
+
 	// -------------------------------------------------------------------------
 	// Catalog System
 
@@ -307,17 +377,45 @@ func run(ctx context.Context, log *logger.Logger, showHelp bool) error {
 		return fmt.Errorf("installation invalid: %w", err)
 	}
 
-	cache, err := cache.NewCache(cache.Config{
+	runtime := cache.RuntimeConfig{
+		UseMmap:        &cfg.Model.UseMmap,
+		UseMlock:       &cfg.Model.UseMlock,
+		OffloadKQV:     &cfg.Model.OffloadKQV,
+		FlashAttention: cfg.Model.FlashAttention,
+		TypeK:          cfg.Model.TypeK,
+		TypeV:          cfg.Model.TypeV,
+		NSeqMax:        cfg.Model.NSeqMax,
+		SWAFull:        &cfg.Model.SWAFull,
+	}
+
+	// A negative value means all the layers are offloaded.
+	if cfg.Model.NGPULayers >= 0 {
+		runtime.NGPULayers = &cfg.Model.NGPULayers
+	}
+
+	krnCache, err := cache.NewCache(cache.Config{
 		Log:            log.Info,
 		Templates:      tmplts,
 		Arch:           libs.Arch(),
 		OS:             libs.OS(),
 		Processor:      libs.Processor(),
 		Device:         cfg.Model.Device,
+		Devices:        cfg.Model.Devices,
+		Placement:      cfg.Model.Placement,
 		MaxInCache:     cfg.Model.MaxInCache,
 		ModelInstances: cfg.Model.MaxInstances,
 		ContextWindow:  cfg.Model.ContextWindow,
 		CacheTTL:       cfg.Model.CacheTTL,
+		SessionTTL:     cfg.Session.TTL,
+		SessionMaxSize: cfg.Session.MaxSize,
+		MediaFetcher:   mediaFetcher,
+		MaxImagePixels: cfg.Media.MaxPixels,
+		Configs:        modelConfigs,
+		MemoryBudget:   uint64(cfg.Model.MemoryBudget),
+		MaxQueue:       cfg.Model.MaxQueue,
+		ScaleUpWait:    cfg.Model.ScaleUpWait,
+		ScaleDownAfter: cfg.Model.ScaleDownAfter,
+		Runtime:        runtime,
 	})
 
 	if err != nil {
@@ -330,11 +428,22 @@ func run(ctx context.Context, log *logger.Logger, showHelp bool) error {
 		ctx, cancel := context.WithTimeout(context.Background(), cfg.Web.ShutdownTimeout)
 		defer cancel()
 
-		if err := cache.Shutdown(ctx); err != nil {
+		if err := krnCache.Shutdown(ctx); err != nil {
 			log.Error(ctx, "kronk manager", "ERROR", err)
 		}
 	}()
 
+	// -------------------------------------------------------------------------
+	// Preload Models
+
+	for _, modelID := range cfg.Model.Preload {
+		log.Info(ctx, "startup", "status", "preloading model", "model", modelID)
+
+		if err := krnCache.LoadModel(ctx, modelID, cache.RuntimeConfig{}); err != nil {
+			log.Error(ctx, "startup", "status", "preloading model", "model", modelID, "ERROR", err)
+		}
+	}
+
 	// -------------------------------------------------------------------------
 	// Start Debug Service
 
@@ -359,9 +468,10 @@ func run(ctx context.Context, log *logger.Logger, showHelp bool) error {
 		Log:        log,
 		AuthClient: authClient,
 		Tracer:     tracer,
-		Cache:      cache,
+		Cache:      krnCache,
 		Libs:       libs,
 		Models:     models,
+		Sessions:   sessions,
 		Catalog:    ctlg,
 		Templates:  tmplts,
 	}
@@ -287,12 +287,18 @@ func escapeTemplateLiteral(s string) string {
 
 // =============================================================================
 
+const priorityDesc = "Queue priority for the request: interactive (default) or batch. Interactive requests are handed a model instance before batch requests. Requests are queued fairly between the token subjects. When the queue is full, a 429 is returned with a Retry-After header."
+
+const runtimeConfigDesc = "Load time settings for the model: context_window, nbatch, nubatch, nthreads, nthreads_batch, adapters, use_mmap, use_mlock, ngpu_layers, offload_kqv, flash_attention (auto, enabled, disabled), type_k and type_v (f32, f16, bf16, q8_0, q5_1, q5_0, q4_1, q4_0, iq4_nl), rope_scaling (none, linear, yarn, longrope), rope_freq_base, rope_freq_scale, yarn_ext_factor, yarn_attn_factor, yarn_beta_fast, yarn_beta_slow, yarn_orig_ctx, nseq_max, and swa_full. The model is loaded as a separate variant for each unique set of settings. Token must have 'runtime-config' endpoint access."
+
 func chatCompletionFields() []field {
 	baseFields := []field{
 		{Name: "model", Type: "string", Required: true, Description: "Model ID to use for completion (e.g., 'qwen3-8b-q8_0')"},
 		{Name: "messages", Type: "array", Required: true, Description: "Array of message objects. See Message Formats section below for supported formats."},
 		{Name: "stream", Type: "boolean", Required: false, Description: "Enable streaming responses (default: false)"},
 		{Name: "tools", Type: "array", Required: false, Description: "Array of tool definitions for function calling. See Tool Definitions section below."},
+		{Name: "priority", Type: "string", Required: false, Description: priorityDesc},
+		{Name: "runtime_config", Type: "object", Required: false, Description: runtimeConfigDesc},
 	}
 
 	paramFields := paramsToFields()
@@ -459,6 +465,38 @@ func chatDoc() apiDoc {
 						},
 						Examples: chatCompletionExamples(),
 					},
+					{
+						Method:      "POST",
+						Path:        "/chat/render",
+						Description: "Render the prompt the model's chat template creates for a chat request without running inference. Useful for debugging chat templates.",
+						Auth:        "Required when auth is enabled. Token must have 'chat-render' endpoint access.",
+						Headers: []header{
+							{Name: "Authorization", Description: "Bearer token for authentication", Required: true},
+							{Name: "Content-Type", Description: "Must be application/json", Required: true},
+						},
+						RequestBody: &requestBody{
+							ContentType: "application/json",
+							Fields:      chatCompletionFields(),
+						},
+						Response: &response{
+							ContentType: "application/json",
+							Description: "Returns the rendered prompt, the number of text tokens in the prompt, and the byte offsets of the media markers.",
+						},
+						Examples: []example{
+							{
+								Description: "Render the prompt for a chat request:",
+								Code: `curl -X POST http://localhost:8080/v1/chat/render \
+  -H "Authorization: Bearer $KRONK_TOKEN" \
+  -H "Content-Type: application/json" \
+  -d '{
+    "model": "qwen3-8b-q8_0",
+    "messages": [
+      {"role": "user", "content": "Hello, how are you?"}
+    ]
+  }'`,
+							},
+						},
+					},
 				},
 			},
 			messageFormatsGroup(),
@@ -597,6 +635,8 @@ func embeddingsDoc() apiDoc {
 							Fields: []field{
 								{Name: "model", Type: "string", Required: true, Description: "Embedding model ID (e.g., 'embeddinggemma-300m-qat-Q8_0')"},
 								{Name: "input", Type: "string|array", Required: true, Description: "Text to generate embeddings for. Can be a string or array of strings."},
+								{Name: "priority", Type: "string", Required: false, Description: priorityDesc},
+								{Name: "runtime_config", Type: "object", Required: false, Description: runtimeConfigDesc},
 							},
 						},
 						Response: &response{
@@ -737,7 +777,7 @@ func modelsEndpoints() endpointGroup {
 				},
 				Response: &response{
 					ContentType: "application/json",
-					Description: "Returns a list of running models with id, owned_by, model_family, size, expires_at, and active_streams.",
+					Description: "Returns a list of running models with id, owned_by, model_family, size, expires_at, pinned, active_streams, queued, instances, loading, and load_progress. Each instance reports the device it was placed on, its state (idle, busy, quarantined, reloading, failed), requests, failures, panics, reloads, busy_time_ms, and last_error.",
 				},
 				Examples: []example{
 					{
@@ -746,6 +786,75 @@ func modelsEndpoints() endpointGroup {
 					},
 				},
 			},
+			{
+				Method:      "GET",
+				Path:        "/models/{model}/load",
+				Description: "Follow the progress of loading a model into the cache. Requests for a model that isn't in the cache share a single load. Returns streaming progress updates until the model is loaded.",
+				Auth:        "Optional when auth is enabled.",
+				Headers: []header{
+					{Name: "Authorization", Description: "Bearer token for authentication", Required: false},
+				},
+				Response: &response{
+					ContentType: "text/event-stream",
+					Description: "Streams the load progress as Server-Sent Events with status, id, config_hash, and progress from 0 to 1. The status is loading, loaded, unloaded when the model isn't being loaded, or the error that failed the load.",
+				},
+				Examples: []example{
+					{
+						Description: "Follow the load progress of a model:",
+						Code:        `curl -N -X GET http://localhost:8080/v1/models/qwen3-8b-q8_0/load`,
+					},
+				},
+			},
+			{
+				Method:      "POST",
+				Path:        "/models/{model}/load",
+				Description: "Load a model into the cache and warm it up with a short request on every instance. Returns once the model is ready to serve requests.",
+				Auth:        "Required when auth is enabled. Admin token required.",
+				Headers: []header{
+					{Name: "Authorization", Description: "Bearer token for admin authentication", Required: true},
+					{Name: "Content-Type", Description: "Must be application/json", Required: false},
+				},
+				RequestBody: &requestBody{
+					ContentType: "application/json",
+					Fields: []field{
+						{Name: "runtime_config", Type: "object", Required: false, Description: "Overrides the global and per-model settings the model is loaded with. Accepts the same fields as the runtime_config of a chat request."},
+					},
+				},
+				Response: &response{
+					ContentType: "application/json",
+					Description: "Returns the status and id of the loaded model.",
+				},
+				Examples: []example{
+					{
+						Description: "Load a model with a larger context window:",
+						Code: `curl -X POST http://localhost:8080/v1/models/qwen3-8b-q8_0/load \
+  -H "Authorization: Bearer $KRONK_TOKEN" \
+  -H "Content-Type: application/json" \
+  -d '{
+    "runtime_config": {"context_window": 32768}
+  }'`,
+					},
+				},
+			},
+			{
+				Method:      "POST",
+				Path:        "/models/{model}/unload",
+				Description: "Unload every variant of a model from the cache. The active requests for the model finish before it's unloaded.",
+				Auth:        "Required when auth is enabled. Admin token required.",
+				Headers: []header{
+					{Name: "Authorization", Description: "Bearer token for admin authentication", Required: true},
+				},
+				Response: &response{
+					ContentType: "application/json",
+					Description: "Returns the status and id of the unloaded model.",
+				},
+				Examples: []example{
+					{
+						Description: "Unload a model:",
+						Code:        `curl -X POST http://localhost:8080/v1/models/qwen3-8b-q8_0/unload -H "Authorization: Bearer $KRONK_TOKEN"`,
+					},
+				},
+			},
 			{
 				Method:      "POST",
 				Path:        "/models/index",
@@ -85,23 +85,27 @@ func extractDocText(output string) string {
 
 func extractFieldDescription(fieldName string, docText string) string {
 	fieldDescriptions := map[string]string{
-		"Temperature":     "Controls randomness of output by rescaling probability distribution",
-		"TopK":            "Limits token pool to K most probable tokens",
-		"TopP":            "Nucleus sampling - selects tokens whose cumulative probability exceeds threshold",
-		"MinP":            "Dynamic sampling threshold balancing coherence and diversity",
-		"MaxTokens":       "Maximum output tokens to generate",
-		"Thinking":        "Enable model thinking/reasoning for non-GPT models",
-		"ReasoningEffort": "Reasoning level for GPT models: none, minimal, low, medium, high",
+		"Temperature":          "Controls randomness of output by rescaling probability distribution",
+		"TopK":                 "Limits token pool to K most probable tokens",
+		"TopP":                 "Nucleus sampling - selects tokens whose cumulative probability exceeds threshold",
+		"MinP":                 "Dynamic sampling threshold balancing coherence and diversity",
+		"MaxTokens":            "Maximum output tokens to generate",
+		"Thinking":             "Enable model thinking/reasoning for non-GPT models",
+		"ReasoningEffort":      "Reasoning level: none, minimal, low, medium, high. Mapped to a reasoning budget for non-GPT models",
+		"MaxReasoningTokens":   "Maximum tokens the model can use for reasoning before it must answer",
+		"ContinueFinalMessage": "Continue the final assistant message instead of starting a new one (prefill)",
 	}
 
 	patterns := map[string]*regexp.Regexp{
-		"Temperature":     regexp.MustCompile(`Temperature[^.]+\.[^.]*default[^.]*(\d+\.?\d*)`),
-		"TopK":            regexp.MustCompile(`Top-?K[^.]+\.[^.]*default[^.]*(\d+)`),
-		"TopP":            regexp.MustCompile(`Top-?P[^.]+\.[^.]*default[^.]*(\d+\.?\d*)`),
-		"MinP":            regexp.MustCompile(`Min-?P[^.]+\.[^.]*default[^.]*(\d+\.?\d*)`),
-		"MaxTokens":       regexp.MustCompile(`MaxTokens[^.]+\.[^.]*default[^.]*(\d+)`),
-		"Thinking":        regexp.MustCompile(`EnableThinking[^.]+\.[^.]*default[^.]*"([^"]+)"`),
-		"ReasoningEffort": nil,
+		"Temperature":          regexp.MustCompile(`Temperature[^.]+\.[^.]*default[^.]*(\d+\.?\d*)`),
+		"TopK":                 regexp.MustCompile(`Top-?K[^.]+\.[^.]*default[^.]*(\d+)`),
+		"TopP":                 regexp.MustCompile(`Top-?P[^.]+\.[^.]*default[^.]*(\d+\.?\d*)`),
+		"MinP":                 regexp.MustCompile(`Min-?P[^.]+\.[^.]*default[^.]*(\d+\.?\d*)`),
+		"MaxTokens":            regexp.MustCompile(`MaxTokens[^.]+\.[^.]*default[^.]*(\d+)`),
+		"Thinking":             regexp.MustCompile(`EnableThinking[^.]+\.[^.]*default[^.]*"([^"]+)"`),
+		"ReasoningEffort":      nil,
+		"MaxReasoningTokens":   nil,
+		"ContinueFinalMessage": nil,
 	}
 
 	desc := fieldDescriptions[fieldName]
@@ -143,6 +147,8 @@ func defaultParamFields() []field {
 		{Name: "min_p", Type: "float32", Required: false, Description: "Dynamic sampling threshold (default: 0.0)"},
 		{Name: "max_tokens", Type: "int", Required: false, Description: "Maximum output tokens (default: 1024)"},
 		{Name: "enable_thinking", Type: "boolean", Required: false, Description: "Enable model thinking for non-GPT models (default: true)"},
-		{Name: "reasoning_effort", Type: "string", Required: false, Description: "Reasoning level for GPT models (default: medium)"},
+		{Name: "reasoning_effort", Type: "string", Required: false, Description: "Reasoning level, mapped to a reasoning budget for non-GPT models (default: medium)"},
+		{Name: "max_reasoning_tokens", Type: "int", Required: false, Description: "Maximum tokens the model can use for reasoning (default: 0, no limit)"},
+		{Name: "continue_final_message", Type: "boolean", Required: false, Description: "Continue the final assistant message instead of starting a new one (default: false)"},
 	}
 }
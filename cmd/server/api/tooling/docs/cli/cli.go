@@ -361,8 +361,8 @@ func libsCommand() command {
 func modelCommand() command {
 	return command{
 		Name:  "model",
-		Short: "Manage models - list, pull, remove, show, and check running models.",
-		Long:  "Manage models - list, pull, remove, show, and check running models",
+		Short: "Manage models - list, pull, remove, show, load, unload, and check running models.",
+		Long:  "Manage models - list, pull, remove, show, load, unload, and check running models",
 		Usage: "kronk model <command> [flags]",
 		Subcommands: []subcommand{
 			{
@@ -399,6 +399,27 @@ func modelCommand() command {
 					"# List with local mode\nkronk model list --local",
 				},
 			},
+			{
+				Name:  "load",
+				Short: "Load a model into the server and warm it up.",
+				Usage: "kronk model load <MODEL_NAME> [flags]",
+				Flags: []flag{
+					{Name: "--context-window", Description: "Context window to load the model with"},
+					{Name: "--nbatch", Description: "Logical batch size to load the model with"},
+					{Name: "--nubatch", Description: "Physical batch size to load the model with"},
+					{Name: "--type-k", Description: "K cache type to load the model with"},
+					{Name: "--type-v", Description: "V cache type to load the model with"},
+					{Name: "--flash-attention", Description: "Flash attention mode: auto, enabled or disabled"},
+				},
+				EnvVars: []envVar{
+					{Name: "KRONK_TOKEN", Default: "", Description: "Authentication token for the kronk server (required when auth enabled)"},
+					{Name: "KRONK_WEB_API_HOST", Default: "localhost:8080", Description: "IP Address for the kronk server"},
+				},
+				Examples: []string{
+					"# Load a model\nkronk model load qwen3-8b-q8_0",
+					"# Load a model with a larger context window\nkronk model load qwen3-8b-q8_0 --context-window 32768",
+				},
+			},
 			{
 				Name:  "ps",
 				Short: "List running models.",
@@ -464,6 +485,19 @@ func modelCommand() command {
 					"# Show with local mode\nkronk model show llama-3.2-1b-q4 --local",
 				},
 			},
+			{
+				Name:  "unload",
+				Short: "Unload a model from the server.",
+				Usage: "kronk model unload <MODEL_NAME>",
+				Flags: []flag{},
+				EnvVars: []envVar{
+					{Name: "KRONK_TOKEN", Default: "", Description: "Authentication token for the kronk server (required when auth enabled)"},
+					{Name: "KRONK_WEB_API_HOST", Default: "localhost:8080", Description: "IP Address for the kronk server"},
+				},
+				Examples: []string{
+					"# Unload a model\nkronk model unload qwen3-8b-q8_0",
+				},
+			},
 		},
 	}
 }
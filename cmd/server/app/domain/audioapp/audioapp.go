@@ -0,0 +1,229 @@
+// Package audioapp provides the audio api endpoints.
+package audioapp
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ardanlabs/kronk/cmd/server/app/sdk/audio"
+	"github.com/ardanlabs/kronk/cmd/server/app/sdk/cache"
+	"github.com/ardanlabs/kronk/cmd/server/app/sdk/errs"
+	"github.com/ardanlabs/kronk/cmd/server/app/sdk/inference"
+	"github.com/ardanlabs/kronk/cmd/server/app/sdk/mid"
+	"github.com/ardanlabs/kronk/cmd/server/foundation/logger"
+	"github.com/ardanlabs/kronk/cmd/server/foundation/web"
+	"github.com/ardanlabs/kronk/sdk/kronk"
+	"github.com/ardanlabs/kronk/sdk/kronk/model"
+)
+
+const (
+	maxUploadSize = 25 * 1024 * 1024
+	chunkLength   = 30 * time.Second
+)
+
+type app struct {
+	log   *logger.Logger
+	cache *cache.Cache
+}
+
+func newApp(cfg Config) *app {
+	return &app{
+		log:   cfg.Log,
+		cache: cfg.Cache,
+	}
+}
+
+func (a *app) transcriptions(ctx context.Context, r *http.Request) web.Encoder {
+	return a.process(ctx, r, taskTranscribe)
+}
+
+func (a *app) translations(ctx context.Context, r *http.Request) web.Encoder {
+	return a.process(ctx, r, taskTranslate)
+}
+
+func (a *app) process(ctx context.Context, r *http.Request, task string) web.Encoder {
+	req, err := parseRequest(web.GetWriter(ctx), r)
+	if err != nil {
+		return errs.New(errs.InvalidArgument, err)
+	}
+
+	pcm, err := audio.Decode(req.file)
+	if err != nil {
+		return errs.New(errs.InvalidArgument, err)
+	}
+
+	krn, release, err := a.cache.AquireModel(ctx, req.model)
+	if err != nil {
+		return inference.AquireError(err)
+	}
+	defer release()
+
+	if !krn.ModelInfo().HasProjection {
+		return errs.Errorf(errs.InvalidArgument, "model doesn't support audio")
+	}
+
+	ctx = kronk.WithSubject(ctx, mid.GetSubject(ctx))
+	ctx = kronk.WithPriority(ctx, req.priority)
+
+	ctx, cancel := context.WithTimeout(ctx, 180*time.Minute)
+	defer cancel()
+
+	chunks := audio.Split(pcm, chunkLength)
+
+	a.log.Info(ctx, "audio", "task", task, "model", req.model, "duration", pcm.Duration(), "chunks", len(chunks), "format", req.responseFormat)
+
+	segments := make([]segment, 0, len(chunks))
+
+	for i, chunk := range chunks {
+		text, err := a.processChunk(ctx, krn, req, task, chunk)
+		if err != nil {
+			return inference.Error(fmt.Errorf("chunk[%d]: %w", i, err))
+		}
+
+		segments = append(segments, segment{
+			ID:    i,
+			Start: chunk.Start.Seconds(),
+			End:   chunk.End.Seconds(),
+			Text:  text,
+		})
+	}
+
+	return toResponse(req.responseFormat, task, req.language, pcm.Duration(), segments)
+}
+
+func (a *app) processChunk(ctx context.Context, krn *kronk.Kronk, req request, task string, chunk audio.Chunk) (string, error) {
+	d := model.D{
+		"messages": model.DocumentArray(
+			model.D{
+				"role": "user",
+				"content": []model.D{
+					{
+						"type": "input_audio",
+						"input_audio": model.D{
+							"data":   base64.StdEncoding.EncodeToString(audio.EncodeWAV(chunk.PCM)),
+							"format": "wav",
+						},
+					},
+					{
+						"type": "text",
+						"text": instruction(task, req.language, req.prompt),
+					},
+				},
+			},
+		),
+		"temperature": req.temperature,
+	}
+
+	resp, err := krn.Chat(ctx, d)
+	if err != nil {
+		return "", err
+	}
+
+	if len(resp.Choice) == 0 {
+		return "", errors.New("no response from the model")
+	}
+
+	if resp.Choice[0].FinishReason == model.FinishReasonError {
+		return "", errors.New(resp.Choice[0].Delta.Content)
+	}
+
+	return strings.TrimSpace(resp.Choice[0].Delta.Content), nil
+}
+
+// =============================================================================
+
+type request struct {
+	file           []byte
+	model          string
+	language       string
+	prompt         string
+	responseFormat string
+	temperature    float64
+	priority       kronk.Priority
+}
+
+func parseRequest(w http.ResponseWriter, r *http.Request) (request, error) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxUploadSize)
+
+	if err := r.ParseMultipartForm(maxUploadSize); err != nil {
+		return request{}, fmt.Errorf("parse-request: unable to parse multipart form: %w", err)
+	}
+
+	file, _, err := r.FormFile("file")
+	if err != nil {
+		return request{}, fmt.Errorf("parse-request: missing file field: %w", err)
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(file)
+	if err != nil {
+		return request{}, fmt.Errorf("parse-request: unable to read file: %w", err)
+	}
+
+	req := request{
+		file:           data,
+		model:          r.FormValue("model"),
+		language:       r.FormValue("language"),
+		prompt:         r.FormValue("prompt"),
+		responseFormat: r.FormValue("response_format"),
+	}
+
+	if req.model == "" {
+		return request{}, errors.New("parse-request: missing model field")
+	}
+
+	if req.responseFormat == "" {
+		req.responseFormat = formatJSON
+	}
+
+	switch req.responseFormat {
+	case formatJSON, formatText, formatSRT, formatVTT:
+	default:
+		return request{}, fmt.Errorf("parse-request: unsupported response_format %q, expecting json, text, srt, or vtt", req.responseFormat)
+	}
+
+	if v := r.FormValue("temperature"); v != "" {
+		req.temperature, err = strconv.ParseFloat(v, 64)
+		if err != nil {
+			return request{}, fmt.Errorf("parse-request: temperature is not valid: %w", err)
+		}
+	}
+
+	req.priority, err = kronk.ParsePriority(r.FormValue("priority"))
+	if err != nil {
+		return request{}, fmt.Errorf("parse-request: %w", err)
+	}
+
+	return req, nil
+}
+
+func instruction(task string, language string, prompt string) string {
+	var sb strings.Builder
+
+	switch task {
+	case taskTranslate:
+		sb.WriteString("Translate the speech in this audio into English. ")
+		sb.WriteString("Respond with only the English translation and nothing else.")
+
+	default:
+		sb.WriteString("Transcribe the speech in this audio exactly as it is spoken. ")
+		sb.WriteString("Respond with only the transcription and nothing else.")
+
+		if language != "" {
+			fmt.Fprintf(&sb, " The audio is in the language with the ISO-639-1 code %q.", language)
+		}
+	}
+
+	if prompt != "" {
+		fmt.Fprintf(&sb, "\nUse this text as context for names and spelling: %s", prompt)
+	}
+
+	return sb.String()
+}
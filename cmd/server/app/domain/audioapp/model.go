@@ -0,0 +1,130 @@
+package audioapp
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/ardanlabs/kronk/cmd/server/foundation/web"
+)
+
+const (
+	taskTranscribe = "transcribe"
+	taskTranslate  = "translate"
+)
+
+const (
+	formatJSON = "json"
+	formatText = "text"
+	formatSRT  = "srt"
+	formatVTT  = "vtt"
+)
+
+// segment represents the text for a section of the audio.
+type segment struct {
+	ID    int     `json:"id"`
+	Start float64 `json:"start"`
+	End   float64 `json:"end"`
+	Text  string  `json:"text"`
+}
+
+// TranscriptionResponse represents the json response for a transcription or
+// translation.
+type TranscriptionResponse struct {
+	Task     string    `json:"task"`
+	Language string    `json:"language,omitempty"`
+	Duration float64   `json:"duration"`
+	Text     string    `json:"text"`
+	Segments []segment `json:"segments"`
+}
+
+// Encode implements the encoder interface.
+func (app TranscriptionResponse) Encode() ([]byte, string, error) {
+	data, err := json.Marshal(app)
+	return data, "application/json", err
+}
+
+// TextResponse represents a plain text response for the text, srt, and vtt
+// formats.
+type TextResponse struct {
+	Text        string
+	ContentType string
+}
+
+// Encode implements the encoder interface.
+func (app TextResponse) Encode() ([]byte, string, error) {
+	return []byte(app.Text), app.ContentType, nil
+}
+
+func toResponse(format string, task string, language string, duration time.Duration, segments []segment) web.Encoder {
+	texts := make([]string, 0, len(segments))
+	for _, seg := range segments {
+		if seg.Text != "" {
+			texts = append(texts, seg.Text)
+		}
+	}
+
+	text := strings.Join(texts, " ")
+
+	switch format {
+	case formatText:
+		return TextResponse{Text: text + "\n", ContentType: "text/plain; charset=utf-8"}
+
+	case formatSRT:
+		return TextResponse{Text: toSRT(segments), ContentType: "text/plain; charset=utf-8"}
+
+	case formatVTT:
+		return TextResponse{Text: toVTT(segments), ContentType: "text/vtt; charset=utf-8"}
+	}
+
+	return TranscriptionResponse{
+		Task:     task,
+		Language: language,
+		Duration: duration.Seconds(),
+		Text:     text,
+		Segments: segments,
+	}
+}
+
+func toSRT(segments []segment) string {
+	var sb strings.Builder
+
+	var n int
+	for _, seg := range segments {
+		if seg.Text == "" {
+			continue
+		}
+
+		n++
+		fmt.Fprintf(&sb, "%d\n%s --> %s\n%s\n\n", n, timestamp(seg.Start, ","), timestamp(seg.End, ","), seg.Text)
+	}
+
+	return sb.String()
+}
+
+func toVTT(segments []segment) string {
+	var sb strings.Builder
+
+	sb.WriteString("WEBVTT\n\n")
+
+	for _, seg := range segments {
+		if seg.Text == "" {
+			continue
+		}
+
+		fmt.Fprintf(&sb, "%s --> %s\n%s\n\n", timestamp(seg.Start, "."), timestamp(seg.End, "."), seg.Text)
+	}
+
+	return sb.String()
+}
+
+func timestamp(seconds float64, sep string) string {
+	ms := int64(seconds*1000 + 0.5)
+
+	h := ms / 3_600_000
+	m := (ms / 60_000) % 60
+	s := (ms / 1000) % 60
+
+	return fmt.Sprintf("%02d:%02d:%02d%s%03d", h, m, s, sep, ms%1000)
+}
@@ -0,0 +1,31 @@
+package audioapp
+
+import (
+	"net/http"
+
+	"github.com/ardanlabs/kronk/cmd/server/app/sdk/authclient"
+	"github.com/ardanlabs/kronk/cmd/server/app/sdk/cache"
+	"github.com/ardanlabs/kronk/cmd/server/app/sdk/mid"
+	"github.com/ardanlabs/kronk/cmd/server/foundation/logger"
+	"github.com/ardanlabs/kronk/cmd/server/foundation/web"
+)
+
+// Config contains all the mandatory systems required by handlers.
+type Config struct {
+	Log        *logger.Logger
+	AuthClient *authclient.Client
+	Cache      *cache.Cache
+}
+
+// Routes adds specific routes for this group.
+func Routes(app *web.App, cfg Config) {
+	const version = "v1"
+
+	api := newApp(cfg)
+
+	transcriptions := mid.Authenticate(cfg.AuthClient, false, "audio-transcriptions")
+	translations := mid.Authenticate(cfg.AuthClient, false, "audio-translations")
+
+	app.HandlerFunc(http.MethodPost, version, "/audio/transcriptions", api.transcriptions, transcriptions)
+	app.HandlerFunc(http.MethodPost, version, "/audio/translations", api.translations, translations)
+}
@@ -0,0 +1,17 @@
+package chatapp
+
+import (
+	"encoding/json"
+
+	"github.com/ardanlabs/kronk/sdk/kronk/model"
+)
+
+// RenderResponse represents the prompt the chat template creates for a chat
+// request.
+type RenderResponse model.RenderResponse
+
+// Encode implements the encoder interface.
+func (app RenderResponse) Encode() ([]byte, string, error) {
+	data, err := json.Marshal(app)
+	return data, "application/json", err
+}
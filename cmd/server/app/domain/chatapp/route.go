@@ -26,4 +26,8 @@ func Routes(app *web.App, cfg Config) {
 	auth := mid.Authenticate(cfg.AuthClient, false, "chat-completions")
 
 	app.HandlerFunc(http.MethodPost, version, "/chat/completions", api.chatCompletions, auth)
+
+	render := mid.Authenticate(cfg.AuthClient, false, "chat-render")
+
+	app.HandlerFunc(http.MethodPost, version, "/chat/render", api.chatRender, render)
 }
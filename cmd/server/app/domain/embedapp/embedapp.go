@@ -7,7 +7,9 @@ import (
 	"net/http"
 	"time"
 
+	"github.com/ardanlabs/kronk/cmd/server/app/sdk/authclient"
 	"github.com/ardanlabs/kronk/cmd/server/app/sdk/errs"
+	"github.com/ardanlabs/kronk/cmd/server/app/sdk/inference"
 	"github.com/ardanlabs/kronk/cmd/server/foundation/logger"
 	"github.com/ardanlabs/kronk/cmd/server/foundation/web"
 	"github.com/ardanlabs/kronk/cmd/server/app/sdk/cache"
@@ -15,14 +17,16 @@ import (
 )
 
 type app struct {
-	log   *logger.Logger
-	cache *cache.Cache
+	log        *logger.Logger
+	authClient *authclient.Client
+	cache      *cache.Cache
 }
 
 func newApp(cfg Config) *app {
 	return &app{
-		log:   cfg.Log,
-		cache: cfg.Cache,
+		log:        cfg.Log,
+		authClient: cfg.AuthClient,
+		cache:      cfg.Cache,
 	}
 }
 
@@ -42,10 +46,21 @@ func (a *app) embeddings(ctx context.Context, r *http.Request) web.Encoder {
 		return errs.Errorf(errs.InvalidArgument, "model name must be a string")
 	}
 
-	krn, err := a.cache.AquireModel(ctx, modelID)
+	rc, errResp := inference.RuntimeConfig(ctx, a.authClient, r, req)
+	if errResp != nil {
+		return errResp
+	}
+
+	ctx, errResp = inference.Schedule(ctx, req)
+	if errResp != nil {
+		return errResp
+	}
+
+	krn, release, err := a.cache.AquireModelWithConfig(ctx, modelID, rc)
 	if err != nil {
-		return errs.New(errs.InvalidArgument, err)
+		return inference.AquireError(err)
 	}
+	defer release()
 
 	if !krn.ModelInfo().IsEmbedModel {
 		return errs.Errorf(errs.InvalidArgument, "model doesn't support embedding")
@@ -59,7 +74,7 @@ func (a *app) embeddings(ctx context.Context, r *http.Request) web.Encoder {
 	a.log.Info(ctx, "embedding", "req", req)
 
 	if _, err := krn.EmbeddingsHTTP(ctx, a.log.Info, web.GetWriter(ctx), d); err != nil {
-		return errs.New(errs.Internal, err)
+		return inference.Error(err)
 	}
 
 	return web.NewNoResponse()
@@ -3,14 +3,18 @@ package toolapp
 import (
 	"encoding/json"
 	"fmt"
+	"path/filepath"
+	"strings"
 	"time"
 
 	"github.com/ardanlabs/kronk/cmd/server/app/sdk/authclient"
 	"github.com/ardanlabs/kronk/cmd/server/app/sdk/cache"
-	"github.com/ardanlabs/kronk/sdk/kronk/model"
+	"github.com/ardanlabs/kronk/sdk/kronk"
 	"github.com/ardanlabs/kronk/sdk/tools/catalog"
+	"github.com/ardanlabs/kronk/sdk/tools/gguf"
 	"github.com/ardanlabs/kronk/sdk/tools/libs"
 	"github.com/ardanlabs/kronk/sdk/tools/models"
+	"github.com/ardanlabs/kronk/sdk/tools/sessions"
 )
 
 // VersionResponse returns information about the installed libraries.
@@ -100,8 +104,9 @@ func toListModelsInfo(models []models.File) ListModelInfoResponse {
 
 // PullRequest represents the input for the pull command.
 type PullRequest struct {
-	ModelURL string `json:"model_url"`
-	ProjURL  string `json:"proj_url"`
+	ModelURL    string   `json:"model_url"`
+	ProjURL     string   `json:"proj_url"`
+	AdapterURLs []string `json:"adapter_urls"`
 }
 
 // Decode implements the decoder interface.
@@ -111,10 +116,11 @@ func (app *PullRequest) Decode(data []byte) error {
 
 // PullResponse returns information about a model being downloaded.
 type PullResponse struct {
-	Status     string `json:"status"`
-	ModelFile  string `json:"model_file,omitempty"`
-	ProjFile   string `json:"proj_file,omitempty"`
-	Downloaded bool   `json:"downloaded,omitempty"`
+	Status       string   `json:"status"`
+	ModelFile    string   `json:"model_file,omitempty"`
+	ProjFile     string   `json:"proj_file,omitempty"`
+	AdapterFiles []string `json:"adapter_files,omitempty"`
+	Downloaded   bool     `json:"downloaded,omitempty"`
 }
 
 // Encode implements the encoder interface.
@@ -125,10 +131,11 @@ func (app PullResponse) Encode() ([]byte, string, error) {
 
 func toAppPull(status string, mp models.Path) string {
 	pr := PullResponse{
-		Status:     status,
-		ModelFile:  mp.ModelFile,
-		ProjFile:   mp.ProjFile,
-		Downloaded: mp.Downloaded,
+		Status:       status,
+		ModelFile:    mp.ModelFile,
+		ProjFile:     mp.ProjFile,
+		AdapterFiles: mp.AdapterFiles,
+		Downloaded:   mp.Downloaded,
 	}
 
 	d, err := json.Marshal(pr)
@@ -141,21 +148,80 @@ func toAppPull(status string, mp models.Path) string {
 
 // =============================================================================
 
+// LoadRequest represents a request to load a model. The runtime config
+// overrides the global and per-model settings.
+type LoadRequest struct {
+	RuntimeConfig map[string]any `json:"runtime_config"`
+}
+
+// Decode implements the decoder interface. The body is optional.
+func (app *LoadRequest) Decode(data []byte) error {
+	if len(data) == 0 {
+		return nil
+	}
+
+	return json.Unmarshal(data, app)
+}
+
+// LoadResponse returns the progress of a model being loaded.
+type LoadResponse struct {
+	Status     string  `json:"status"`
+	ID         string  `json:"id"`
+	ConfigHash string  `json:"config_hash,omitempty"`
+	Progress   float32 `json:"progress"`
+}
+
+// Encode implements the encoder interface.
+func (app LoadResponse) Encode() ([]byte, string, error) {
+	data, err := json.Marshal(app)
+	return data, "application/json", err
+}
+
+func toAppLoad(status string, modelID string, l *cache.Load) string {
+	lr := LoadResponse{
+		Status: status,
+		ID:     modelID,
+	}
+
+	if l != nil {
+		lr.ConfigHash = l.ConfigHash
+		lr.Progress = l.Progress()
+	}
+
+	if status == "loaded" {
+		lr.Progress = 1
+	}
+
+	d, err := json.Marshal(lr)
+	if err != nil {
+		return fmt.Sprintf("data: {\"Status\":%q}\n", err.Error())
+	}
+
+	return fmt.Sprintf("data: %s\n", string(d))
+}
+
+// =============================================================================
+
 // ModelInfoResponse returns information about a model.
 type ModelInfoResponse struct {
-	ID            string            `json:"id"`
-	Object        string            `json:"object"`
-	Created       int64             `json:"created"`
-	OwnedBy       string            `json:"owned_by"`
-	Desc          string            `json:"desc"`
-	Size          uint64            `json:"size"`
-	HasProjection bool              `json:"has_projection"`
-	HasEncoder    bool              `json:"has_encoder"`
-	HasDecoder    bool              `json:"has_decoder"`
-	IsRecurrent   bool              `json:"is_recurrent"`
-	IsHybrid      bool              `json:"is_hybrid"`
-	IsGPT         bool              `json:"is_gpt"`
-	Metadata      map[string]string `json:"metadata"`
+	ID             string            `json:"id"`
+	Object         string            `json:"object"`
+	Created        int64             `json:"created"`
+	OwnedBy        string            `json:"owned_by"`
+	Desc           string            `json:"desc"`
+	Size           uint64            `json:"size"`
+	Architecture   string            `json:"architecture"`
+	ParameterCount uint64            `json:"parameter_count"`
+	Quantization   string            `json:"quantization"`
+	ContextLength  int               `json:"context_length"`
+	HasProjection  bool              `json:"has_projection"`
+	HasEncoder     bool              `json:"has_encoder"`
+	HasDecoder     bool              `json:"has_decoder"`
+	IsRecurrent    bool              `json:"is_recurrent"`
+	IsHybrid       bool              `json:"is_hybrid"`
+	IsGPT          bool              `json:"is_gpt"`
+	Adapters       []string          `json:"adapters,omitempty"`
+	Metadata       map[string]string `json:"metadata"`
 }
 
 // Encode implements the encoder interface.
@@ -164,34 +230,121 @@ func (app ModelInfoResponse) Encode() ([]byte, string, error) {
 	return data, "application/json", err
 }
 
-func toModelInfo(model models.Info, mi model.ModelInfo) ModelInfoResponse {
+func toModelInfo(model models.Info, mp models.Path, file *gguf.File) ModelInfoResponse {
+	info := file.Info()
+
+	var desc []string
+	for _, v := range []string{info.Architecture, info.SizeLabel, info.Quantization} {
+		if v != "" {
+			desc = append(desc, v)
+		}
+	}
+
+	adapters := make([]string, len(mp.AdapterFiles))
+	for i, adapterFile := range mp.AdapterFiles {
+		name := filepath.Base(adapterFile)
+		adapters[i] = strings.ToLower(strings.TrimSuffix(name, filepath.Ext(name)))
+	}
+
 	return ModelInfoResponse{
-		ID:            model.ID,
-		Object:        model.Object,
-		Created:       model.Created,
-		OwnedBy:       model.OwnedBy,
-		Desc:          mi.Desc,
-		Size:          mi.Size,
-		HasProjection: mi.HasProjection,
-		HasEncoder:    mi.HasEncoder,
-		HasDecoder:    mi.HasDecoder,
-		IsRecurrent:   mi.IsRecurrent,
-		IsHybrid:      mi.IsHybrid,
-		IsGPT:         mi.IsGPTModel,
-		Metadata:      mi.Metadata,
+		ID:             model.ID,
+		Object:         model.Object,
+		Created:        model.Created,
+		OwnedBy:        model.OwnedBy,
+		Desc:           strings.Join(desc, " "),
+		Size:           file.WeightsSize(),
+		Architecture:   info.Architecture,
+		ParameterCount: info.ParameterCount,
+		Quantization:   info.Quantization,
+		ContextLength:  info.ContextLength,
+		HasProjection:  mp.ProjFile != "",
+		HasEncoder:     info.HasEncoder,
+		HasDecoder:     info.HasDecoder,
+		IsRecurrent:    info.IsRecurrent,
+		IsHybrid:       info.IsHybrid,
+		IsGPT:          strings.Contains(model.ID, "gpt"),
+		Adapters:       adapters,
+		Metadata:       file.MetadataStrings(),
 	}
 }
 
 // =============================================================================
 
+// RuntimeConfig provides the settings a model in the cache was loaded with.
+type RuntimeConfig struct {
+	ContextWindow  int      `json:"context_window"`
+	NBatch         int      `json:"nbatch"`
+	NUBatch        int      `json:"nubatch"`
+	NThreads       int      `json:"nthreads"`
+	NThreadsBatch  int      `json:"nthreads_batch"`
+	Adapters       []string `json:"adapters,omitempty"`
+	UseMmap        *bool    `json:"use_mmap,omitempty"`
+	UseMlock       *bool    `json:"use_mlock,omitempty"`
+	NGPULayers     *int     `json:"ngpu_layers,omitempty"`
+	OffloadKQV     *bool    `json:"offload_kqv,omitempty"`
+	FlashAttention string   `json:"flash_attention,omitempty"`
+	TypeK          string   `json:"type_k,omitempty"`
+	TypeV          string   `json:"type_v,omitempty"`
+	RopeScaling    string   `json:"rope_scaling,omitempty"`
+	RopeFreqBase   float32  `json:"rope_freq_base,omitempty"`
+	RopeFreqScale  float32  `json:"rope_freq_scale,omitempty"`
+	YarnExtFactor  float32  `json:"yarn_ext_factor,omitempty"`
+	YarnAttnFactor float32  `json:"yarn_attn_factor,omitempty"`
+	YarnBetaFast   float32  `json:"yarn_beta_fast,omitempty"`
+	YarnBetaSlow   float32  `json:"yarn_beta_slow,omitempty"`
+	YarnOrigCtx    int      `json:"yarn_orig_ctx,omitempty"`
+	NSeqMax        int      `json:"nseq_max,omitempty"`
+	SWAFull        *bool    `json:"swa_full,omitempty"`
+}
+
 // ModelDetail provides details for the models in the cache.
 type ModelDetail struct {
-	ID            string    `json:"id"`
-	OwnedBy       string    `json:"owned_by"`
-	ModelFamily   string    `json:"model_family"`
-	Size          int64     `json:"size"`
-	ExpiresAt     time.Time `json:"expires_at"`
-	ActiveStreams int       `json:"active_streams"`
+	ID            string           `json:"id"`
+	OwnedBy       string           `json:"owned_by"`
+	ModelFamily   string           `json:"model_family"`
+	Size          int64            `json:"size"`
+	ExpiresAt     time.Time        `json:"expires_at"`
+	Pinned        bool             `json:"pinned"`
+	ActiveStreams int              `json:"active_streams"`
+	Queued        int              `json:"queued"`
+	Instances     []InstanceStatus `json:"instances"`
+	Loading       bool             `json:"loading"`
+	LoadProgress  float32          `json:"load_progress"`
+	ConfigHash    string           `json:"config_hash"`
+	Config        RuntimeConfig    `json:"config"`
+}
+
+// InstanceStatus provides the state and health of an instance of a model.
+type InstanceStatus struct {
+	ID         int    `json:"id"`
+	Device     string `json:"device,omitempty"`
+	State      string `json:"state"`
+	Requests   int    `json:"requests"`
+	Failures   int    `json:"failures"`
+	Panics     int    `json:"panics"`
+	Reloads    int    `json:"reloads"`
+	BusyTimeMS int64  `json:"busy_time_ms"`
+	LastError  string `json:"last_error,omitempty"`
+}
+
+func toInstanceStatus(instances []kronk.InstanceStatus) []InstanceStatus {
+	status := make([]InstanceStatus, len(instances))
+
+	for i, inst := range instances {
+		status[i] = InstanceStatus{
+			ID:         inst.ID,
+			Device:     inst.Device,
+			State:      inst.State,
+			Requests:   inst.Requests,
+			Failures:   inst.Failures,
+			Panics:     inst.Panics,
+			Reloads:    inst.Reloads,
+			BusyTimeMS: inst.BusyTime.Milliseconds(),
+			LastError:  inst.LastError,
+		}
+	}
+
+	return status
 }
 
 // ModelDetailsResponse is a collection of model detail.
@@ -213,7 +366,38 @@ func toModelDetails(models []cache.ModelDetail) ModelDetailsResponse {
 			ModelFamily:   model.ModelFamily,
 			Size:          model.Size,
 			ExpiresAt:     model.ExpiresAt,
+			Pinned:        model.Pinned,
 			ActiveStreams: model.ActiveStreams,
+			Queued:        model.Queued,
+			Instances:     toInstanceStatus(model.Instances),
+			Loading:       model.Loading,
+			LoadProgress:  model.LoadProgress,
+			ConfigHash:    model.ConfigHash,
+			Config: RuntimeConfig{
+				ContextWindow:  model.Config.ContextWindow,
+				NBatch:         model.Config.NBatch,
+				NUBatch:        model.Config.NUBatch,
+				NThreads:       model.Config.NThreads,
+				NThreadsBatch:  model.Config.NThreadsBatch,
+				Adapters:       model.Config.Adapters,
+				UseMmap:        model.Config.UseMmap,
+				UseMlock:       model.Config.UseMlock,
+				NGPULayers:     model.Config.NGPULayers,
+				OffloadKQV:     model.Config.OffloadKQV,
+				FlashAttention: model.Config.FlashAttention,
+				TypeK:          model.Config.TypeK,
+				TypeV:          model.Config.TypeV,
+				RopeScaling:    model.Config.RopeScaling,
+				RopeFreqBase:   model.Config.RopeFreqBase,
+				RopeFreqScale:  model.Config.RopeFreqScale,
+				YarnExtFactor:  model.Config.YarnExtFactor,
+				YarnAttnFactor: model.Config.YarnAttnFactor,
+				YarnBetaFast:   model.Config.YarnBetaFast,
+				YarnBetaSlow:   model.Config.YarnBetaSlow,
+				YarnOrigCtx:    model.Config.YarnOrigCtx,
+				NSeqMax:        model.Config.NSeqMax,
+				SWAFull:        model.Config.SWAFull,
+			},
 		}
 	}
 
@@ -222,6 +406,54 @@ func toModelDetails(models []cache.ModelDetail) ModelDetailsResponse {
 
 // =============================================================================
 
+// SessionResponse provides information about a persisted conversation session.
+type SessionResponse struct {
+	ID       string    `json:"id"`
+	ModelID  string    `json:"model_id"`
+	Adapters []string  `json:"adapters,omitempty"`
+	Tokens   int       `json:"tokens"`
+	Size     int64     `json:"size"`
+	Saved    time.Time `json:"saved"`
+}
+
+// Encode implements the encoder interface.
+func (app SessionResponse) Encode() ([]byte, string, error) {
+	data, err := json.Marshal(app)
+	return data, "application/json", err
+}
+
+func toSessionResponse(info sessions.Info) SessionResponse {
+	return SessionResponse{
+		ID:       info.ID,
+		ModelID:  info.ModelID,
+		Adapters: info.Adapters,
+		Tokens:   info.Tokens,
+		Size:     info.Size,
+		Saved:    info.Saved,
+	}
+}
+
+// SessionsResponse is a collection of sessions.
+type SessionsResponse []SessionResponse
+
+// Encode implements the encoder interface.
+func (app SessionsResponse) Encode() ([]byte, string, error) {
+	data, err := json.Marshal(app)
+	return data, "application/json", err
+}
+
+func toSessionsResponse(list []sessions.Info) SessionsResponse {
+	resp := make(SessionsResponse, len(list))
+
+	for i, info := range list {
+		resp[i] = toSessionResponse(info)
+	}
+
+	return resp
+}
+
+// =============================================================================
+
 // CatalogMetadata represents extra information about the model.
 type CatalogMetadata struct {
 	Created     time.Time `json:"created"`
@@ -248,8 +480,9 @@ type CatalogFile struct {
 
 // CatalogFiles represents file information for a model.
 type CatalogFiles struct {
-	Models []CatalogFile `json:"model"`
-	Projs  []CatalogFile `json:"proj"`
+	Models   []CatalogFile `json:"model"`
+	Projs    []CatalogFile `json:"proj"`
+	Adapters []CatalogFile `json:"adapter,omitempty"`
 }
 
 // CatalogModelResponse represents information for a model.
@@ -293,6 +526,11 @@ func toCatalogModelResponse(model catalog.Model) CatalogModelResponse {
 		projs[i] = CatalogFile(proj)
 	}
 
+	adapters := make([]CatalogFile, len(model.Files.Adapters))
+	for i, adapter := range model.Files.Adapters {
+		adapters[i] = CatalogFile(adapter)
+	}
+
 	return CatalogModelResponse{
 		ID:          model.ID,
 		Category:    model.Category,
@@ -302,8 +540,9 @@ func toCatalogModelResponse(model catalog.Model) CatalogModelResponse {
 		GatedModel:  model.GatedModel,
 		Template:    model.Template,
 		Files: CatalogFiles{
-			Models: models,
-			Projs:  projs,
+			Models:   models,
+			Projs:    projs,
+			Adapters: adapters,
 		},
 		Capabilities: CatalogCapabilities{
 			Endpoint:  model.Capabilities.Endpoint,
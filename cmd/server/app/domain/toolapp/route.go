@@ -11,6 +11,7 @@ import (
 	"github.com/ardanlabs/kronk/sdk/tools/catalog"
 	"github.com/ardanlabs/kronk/sdk/tools/libs"
 	"github.com/ardanlabs/kronk/sdk/tools/models"
+	"github.com/ardanlabs/kronk/sdk/tools/sessions"
 	"github.com/ardanlabs/kronk/sdk/tools/templates"
 )
 
@@ -21,6 +22,7 @@ type Config struct {
 	Cache      *cache.Cache
 	Libs       *libs.Libs
 	Models     *models.Models
+	Sessions   *sessions.Sessions
 	Catalog    *catalog.Catalog
 	Templates  *templates.Templates
 }
@@ -41,10 +43,17 @@ func Routes(app *web.App, cfg Config) {
 	app.HandlerFunc(http.MethodGet, version, "/v1/models/", api.missingModel, auth)
 	app.HandlerFunc(http.MethodGet, version, "/v1/models/{model}", api.showModel, auth)
 	app.HandlerFunc(http.MethodGet, version, "/v1/models/ps", api.modelPS, auth)
+	app.HandlerFunc(http.MethodGet, version, "/v1/models/{model}/load", api.loadProgress, auth)
+	app.HandlerFunc(http.MethodPost, version, "/v1/models/{model}/load", api.loadModel, authAdmin)
+	app.HandlerFunc(http.MethodPost, version, "/v1/models/{model}/unload", api.unloadModel, authAdmin)
 	app.HandlerFunc(http.MethodPost, version, "/v1/models/index", api.indexModels, authAdmin)
 	app.HandlerFunc(http.MethodPost, version, "/v1/models/pull", api.pullModels, authAdmin)
 	app.HandlerFunc(http.MethodDelete, version, "/v1/models/{model}", api.removeModel, authAdmin)
 
+	app.HandlerFunc(http.MethodGet, version, "/v1/sessions", api.listSessions, authAdmin)
+	app.HandlerFunc(http.MethodGet, version, "/v1/sessions/{session}", api.showSession, authAdmin)
+	app.HandlerFunc(http.MethodDelete, version, "/v1/sessions/{session}", api.removeSession, authAdmin)
+
 	app.HandlerFunc(http.MethodGet, version, "/v1/catalog", api.listCatalog, auth)
 	app.HandlerFunc(http.MethodGet, version, "/v1/catalog/filter/{filter}", api.listCatalog, auth)
 	app.HandlerFunc(http.MethodGet, version, "/v1/catalog/{model}", api.showCatalogModel, auth)
@@ -3,11 +3,13 @@ package toolapp
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net/http"
 	"net/url"
 	"os"
 	"strings"
+	"time"
 
 	"github.com/ardanlabs/kronk/cmd/server/app/domain/authapp"
 	"github.com/ardanlabs/kronk/cmd/server/app/sdk/authclient"
@@ -16,8 +18,10 @@ import (
 	"github.com/ardanlabs/kronk/cmd/server/foundation/logger"
 	"github.com/ardanlabs/kronk/cmd/server/foundation/web"
 	"github.com/ardanlabs/kronk/sdk/tools/catalog"
+	"github.com/ardanlabs/kronk/sdk/tools/gguf"
 	"github.com/ardanlabs/kronk/sdk/tools/libs"
 	"github.com/ardanlabs/kronk/sdk/tools/models"
+	"github.com/ardanlabs/kronk/sdk/tools/sessions"
 	"github.com/ardanlabs/kronk/sdk/tools/templates"
 	"google.golang.org/protobuf/proto"
 )
@@ -28,6 +32,7 @@ type app struct {
 	authClient *authclient.Client
 	libs       *libs.Libs
 	models     *models.Models
+	sessions   *sessions.Sessions
 	catalog    *catalog.Catalog
 	templates  *templates.Templates
 }
@@ -39,6 +44,7 @@ func newApp(cfg Config) *app {
 		authClient: cfg.AuthClient,
 		libs:       cfg.Libs,
 		models:     cfg.Models,
+		sessions:   cfg.Sessions,
 		catalog:    cfg.Catalog,
 		templates:  cfg.Templates,
 	}
@@ -180,6 +186,19 @@ func (a *app) pullModels(ctx context.Context, r *http.Request) web.Encoder {
 		return errs.Errorf(errs.Internal, "unable to install model: %s", err)
 	}
 
+	adapterFiles, err := a.models.DownloadAdapters(ctx, logger, mp.ModelFile, req.AdapterURLs)
+	if err != nil {
+		ver := toAppPull(err.Error(), models.Path{})
+
+		a.log.Info(ctx, "pull-model", "info", ver[:len(ver)-1])
+		fmt.Fprint(w, ver)
+		f.Flush()
+
+		return errs.Errorf(errs.Internal, "unable to install adapters: %s", err)
+	}
+
+	mp.AdapterFiles = adapterFiles
+
 	ver := toAppPull("downloaded", mp)
 
 	a.log.Info(ctx, "pull-model", "info", ver[:len(ver)-1])
@@ -206,6 +225,44 @@ func (a *app) removeModel(ctx context.Context, r *http.Request) web.Encoder {
 	return nil
 }
 
+func (a *app) listSessions(ctx context.Context, r *http.Request) web.Encoder {
+	list, err := a.sessions.List()
+	if err != nil {
+		return errs.New(errs.Internal, err)
+	}
+
+	return toSessionsResponse(list)
+}
+
+func (a *app) showSession(ctx context.Context, r *http.Request) web.Encoder {
+	sessionID := web.Param(r, "session")
+
+	info, err := a.sessions.Retrieve(sessionID)
+	if err != nil {
+		if errors.Is(err, sessions.ErrNotFound) {
+			return errs.New(errs.NotFound, err)
+		}
+		return errs.New(errs.InvalidArgument, err)
+	}
+
+	return toSessionResponse(info)
+}
+
+func (a *app) removeSession(ctx context.Context, r *http.Request) web.Encoder {
+	sessionID := web.Param(r, "session")
+
+	a.log.Info(ctx, "tool-remove-session", "sessionID", sessionID)
+
+	if err := a.sessions.Remove(sessionID); err != nil {
+		if errors.Is(err, sessions.ErrNotFound) {
+			return errs.New(errs.NotFound, err)
+		}
+		return errs.New(errs.InvalidArgument, err)
+	}
+
+	return nil
+}
+
 func (a *app) missingModel(ctx context.Context, r *http.Request) web.Encoder {
 	return errs.New(errs.InvalidArgument, fmt.Errorf("model parameter is required"))
 }
@@ -218,12 +275,19 @@ func (a *app) showModel(ctx context.Context, r *http.Request) web.Encoder {
 		return errs.New(errs.Internal, err)
 	}
 
-	krn, err := a.cache.AquireModel(ctx, mi.ID)
+	mp, err := a.models.RetrievePath(mi.ID)
+	if err != nil {
+		return errs.New(errs.Internal, err)
+	}
+
+	// The model information is read from the model file so the model
+	// doesn't need to be loaded.
+	file, err := gguf.Read(mp.ModelFile)
 	if err != nil {
 		return errs.New(errs.Internal, err)
 	}
 
-	return toModelInfo(mi, krn.ModelInfo())
+	return toModelInfo(mi, mp, file)
 }
 
 func (a *app) modelPS(ctx context.Context, r *http.Request) web.Encoder {
@@ -237,6 +301,119 @@ func (a *app) modelPS(ctx context.Context, r *http.Request) web.Encoder {
 	return toModelDetails(models)
 }
 
+func (a *app) loadProgress(ctx context.Context, r *http.Request) web.Encoder {
+	modelID := web.Param(r, "model")
+
+	w := web.GetWriter(ctx)
+
+	f, ok := w.(http.Flusher)
+	if !ok {
+		return errs.Errorf(errs.Internal, "streaming not supported")
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Transfer-Encoding", "chunked")
+	w.WriteHeader(http.StatusOK)
+	f.Flush()
+
+	// -------------------------------------------------------------------------
+
+	ticker := time.NewTicker(500 * time.Millisecond)
+	defer ticker.Stop()
+
+	for {
+		loads := a.cache.Loads(modelID)
+		if len(loads) == 0 {
+			break
+		}
+
+		for _, l := range loads {
+			fmt.Fprint(w, toAppLoad("loading", modelID, l))
+		}
+		f.Flush()
+
+		select {
+		case <-ctx.Done():
+			return web.NewNoResponse()
+
+		case <-loads[0].Done():
+			if err := loads[0].Err(); err != nil {
+				fmt.Fprint(w, toAppLoad(err.Error(), modelID, loads[0]))
+				f.Flush()
+
+				return errs.Errorf(errs.Internal, "unable to load model: %s", err)
+			}
+
+		case <-ticker.C:
+		}
+	}
+
+	status := "unloaded"
+	if a.cache.IsLoaded(modelID) {
+		status = "loaded"
+	}
+
+	fmt.Fprint(w, toAppLoad(status, modelID, nil))
+	f.Flush()
+
+	return web.NewNoResponse()
+}
+
+func (a *app) loadModel(ctx context.Context, r *http.Request) web.Encoder {
+	modelID := web.Param(r, "model")
+
+	var req LoadRequest
+	if err := web.Decode(r, &req); err != nil {
+		return errs.New(errs.InvalidArgument, err)
+	}
+
+	var rc cache.RuntimeConfig
+	if req.RuntimeConfig != nil {
+		var err error
+		rc, err = cache.ParseRuntimeConfig(req.RuntimeConfig)
+		if err != nil {
+			return errs.New(errs.InvalidArgument, err)
+		}
+	}
+
+	a.log.Info(ctx, "tool-load", "modelName", modelID)
+
+	if err := a.cache.LoadModel(ctx, modelID, rc); err != nil {
+		switch {
+		case errors.Is(err, cache.ErrCacheFull) || errors.Is(err, cache.ErrMemoryBudget):
+			return errs.New(errs.ResourceExhausted, err)
+
+		default:
+			return errs.New(errs.Internal, err)
+		}
+	}
+
+	return LoadResponse{
+		Status:   "loaded",
+		ID:       modelID,
+		Progress: 1,
+	}
+}
+
+func (a *app) unloadModel(ctx context.Context, r *http.Request) web.Encoder {
+	modelID := web.Param(r, "model")
+
+	a.log.Info(ctx, "tool-unload", "modelName", modelID)
+
+	if _, err := a.cache.UnloadModel(ctx, modelID); err != nil {
+		if errors.Is(err, cache.ErrNotLoaded) {
+			return errs.New(errs.NotFound, err)
+		}
+
+		return errs.New(errs.Internal, err)
+	}
+
+	return LoadResponse{
+		Status: "unloaded",
+		ID:     modelID,
+	}
+}
+
 func (a *app) listCatalog(ctx context.Context, r *http.Request) web.Encoder {
 	filterCategory := web.Param(r, "filter")
 
@@ -307,6 +484,19 @@ func (a *app) pullCatalog(ctx context.Context, r *http.Request) web.Encoder {
 		return errs.Errorf(errs.Internal, "unable to install model: %s", err)
 	}
 
+	adapterFiles, err := a.models.DownloadAdapters(ctx, logger, mp.ModelFile, model.Files.ToAdapterURLS())
+	if err != nil {
+		ver := toAppPull(err.Error(), models.Path{})
+
+		a.log.Info(ctx, "pull-model", "info", ver[:len(ver)-1])
+		fmt.Fprint(w, ver)
+		f.Flush()
+
+		return errs.Errorf(errs.Internal, "unable to install adapters: %s", err)
+	}
+
+	mp.AdapterFiles = adapterFiles
+
 	ver := toAppPull("downloaded", mp)
 
 	a.log.Info(ctx, "pull-model", "info", ver[:len(ver)-1])
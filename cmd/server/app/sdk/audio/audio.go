@@ -0,0 +1,320 @@
+// Package audio provides support for decoding audio into the PCM format audio
+// models expect and splitting long audio into chunks.
+package audio
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"time"
+
+	"github.com/hajimehoshi/go-mp3"
+)
+
+// SampleRate is the sample rate audio is converted to. This is the rate the
+// audio projectors for the supported models expect.
+const SampleRate = 16000
+
+// PCM represents mono audio samples at SampleRate in the range [-1, 1].
+type PCM []float32
+
+// Duration returns the length of the audio.
+func (pcm PCM) Duration() time.Duration {
+	return time.Duration(len(pcm)) * time.Second / SampleRate
+}
+
+// Chunk represents a section of the audio and where it starts and ends in the
+// original audio.
+type Chunk struct {
+	PCM   PCM
+	Start time.Duration
+	End   time.Duration
+}
+
+// =============================================================================
+
+// Decode converts WAV or MP3 audio into mono PCM samples at SampleRate.
+func Decode(data []byte) (PCM, error) {
+	var samples []float32
+	var rate int
+	var err error
+
+	switch {
+	case len(data) >= 12 && bytes.Equal(data[:4], []byte("RIFF")) && bytes.Equal(data[8:12], []byte("WAVE")):
+		samples, rate, err = decodeWAV(data)
+
+	case isMP3(data):
+		samples, rate, err = decodeMP3(data)
+
+	default:
+		return nil, errors.New("decode: unsupported audio format, expecting wav or mp3")
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("decode: %w", err)
+	}
+
+	if len(samples) == 0 {
+		return nil, errors.New("decode: audio has no samples")
+	}
+
+	return resample(samples, rate, SampleRate), nil
+}
+
+// Split breaks the audio into chunks that are no longer than maxLen. To
+// avoid cutting words in half, each chunk ends at the quietest point found
+// in the last 5 seconds before maxLen is reached.
+func Split(pcm PCM, maxLen time.Duration) []Chunk {
+	maxSamples := int(maxLen.Seconds() * SampleRate)
+	if maxSamples <= 0 || len(pcm) <= maxSamples {
+		return []Chunk{{PCM: pcm, Start: 0, End: pcm.Duration()}}
+	}
+
+	const window = SampleRate / 10
+	search := min(5*SampleRate, maxSamples/2)
+
+	var chunks []Chunk
+
+	for start := 0; start < len(pcm); {
+		end := start + maxSamples
+
+		if end >= len(pcm) {
+			end = len(pcm)
+		} else {
+			end = quietest(pcm, end-search, end, window)
+		}
+
+		chunks = append(chunks, Chunk{
+			PCM:   pcm[start:end],
+			Start: samplesToDuration(start),
+			End:   samplesToDuration(end),
+		})
+
+		start = end
+	}
+
+	return chunks
+}
+
+// EncodeWAV encodes the PCM samples as a 16 bit mono WAV file.
+func EncodeWAV(pcm PCM) []byte {
+	const bitsPerSample = 16
+	const channels = 1
+
+	dataSize := len(pcm) * 2
+
+	var buf bytes.Buffer
+	buf.Grow(44 + dataSize)
+
+	buf.WriteString("RIFF")
+	binary.Write(&buf, binary.LittleEndian, uint32(36+dataSize))
+	buf.WriteString("WAVE")
+
+	buf.WriteString("fmt ")
+	binary.Write(&buf, binary.LittleEndian, uint32(16))
+	binary.Write(&buf, binary.LittleEndian, uint16(1))
+	binary.Write(&buf, binary.LittleEndian, uint16(channels))
+	binary.Write(&buf, binary.LittleEndian, uint32(SampleRate))
+	binary.Write(&buf, binary.LittleEndian, uint32(SampleRate*channels*bitsPerSample/8))
+	binary.Write(&buf, binary.LittleEndian, uint16(channels*bitsPerSample/8))
+	binary.Write(&buf, binary.LittleEndian, uint16(bitsPerSample))
+
+	buf.WriteString("data")
+	binary.Write(&buf, binary.LittleEndian, uint32(dataSize))
+
+	samples := make([]int16, len(pcm))
+	for i, s := range pcm {
+		samples[i] = int16(max(-1, min(1, s)) * math.MaxInt16)
+	}
+
+	binary.Write(&buf, binary.LittleEndian, samples)
+
+	return buf.Bytes()
+}
+
+// =============================================================================
+
+func decodeWAV(data []byte) ([]float32, int, error) {
+	var format, channels, bits int
+	var rate int
+	var pcm []byte
+
+	for pos := 12; pos+8 <= len(data); {
+		id := string(data[pos : pos+4])
+		size := int(binary.LittleEndian.Uint32(data[pos+4:]))
+		pos += 8
+
+		if size < 0 || pos+size > len(data) {
+			// Some encoders write a data size larger than the file when
+			// streaming, so use what is there.
+			if id != "data" {
+				return nil, 0, errors.New("corrupt wav file")
+			}
+			size = len(data) - pos
+		}
+
+		switch id {
+		case "fmt ":
+			if size < 16 {
+				return nil, 0, errors.New("corrupt wav format chunk")
+			}
+
+			format = int(binary.LittleEndian.Uint16(data[pos:]))
+			channels = int(binary.LittleEndian.Uint16(data[pos+2:]))
+			rate = int(binary.LittleEndian.Uint32(data[pos+4:]))
+			bits = int(binary.LittleEndian.Uint16(data[pos+14:]))
+
+			// WAVE_FORMAT_EXTENSIBLE stores the real format in the sub-format.
+			if format == 0xFFFE && size >= 26 {
+				format = int(binary.LittleEndian.Uint16(data[pos+24:]))
+			}
+
+		case "data":
+			pcm = data[pos : pos+size]
+		}
+
+		// Chunks are padded to an even size.
+		pos += size + size%2
+	}
+
+	if channels <= 0 || rate <= 0 {
+		return nil, 0, errors.New("wav file has no format information")
+	}
+
+	if pcm == nil {
+		return nil, 0, errors.New("wav file has no audio data")
+	}
+
+	var sample func(b []byte) float32
+
+	switch {
+	case format == 1 && bits == 8:
+		sample = func(b []byte) float32 { return (float32(b[0]) - 128) / 128 }
+
+	case format == 1 && bits == 16:
+		sample = func(b []byte) float32 { return float32(int16(binary.LittleEndian.Uint16(b))) / 32768 }
+
+	case format == 1 && bits == 24:
+		sample = func(b []byte) float32 {
+			v := int32(b[0]) | int32(b[1])<<8 | int32(int8(b[2]))<<16
+			return float32(v) / (1 << 23)
+		}
+
+	case format == 1 && bits == 32:
+		sample = func(b []byte) float32 { return float32(int32(binary.LittleEndian.Uint32(b))) / (1 << 31) }
+
+	case format == 3 && bits == 32:
+		sample = func(b []byte) float32 { return math.Float32frombits(binary.LittleEndian.Uint32(b)) }
+
+	default:
+		return nil, 0, fmt.Errorf("unsupported wav encoding: format %d with %d bits", format, bits)
+	}
+
+	frameSize := channels * bits / 8
+	frames := len(pcm) / frameSize
+
+	samples := make([]float32, frames)
+
+	for i := range frames {
+		frame := pcm[i*frameSize:]
+
+		var sum float32
+		for c := range channels {
+			sum += sample(frame[c*bits/8:])
+		}
+
+		samples[i] = sum / float32(channels)
+	}
+
+	return samples, rate, nil
+}
+
+func isMP3(data []byte) bool {
+	if bytes.HasPrefix(data, []byte("ID3")) {
+		return true
+	}
+
+	return len(data) >= 2 && data[0] == 0xFF && data[1]&0xE0 == 0xE0
+}
+
+func decodeMP3(data []byte) ([]float32, int, error) {
+	dec, err := mp3.NewDecoder(bytes.NewReader(data))
+	if err != nil {
+		return nil, 0, fmt.Errorf("corrupt mp3 file: %w", err)
+	}
+
+	// The decoder always produces 16 bit little endian stereo samples.
+	pcm, err := io.ReadAll(dec)
+	if err != nil {
+		return nil, 0, fmt.Errorf("corrupt mp3 file: %w", err)
+	}
+
+	frames := len(pcm) / 4
+	samples := make([]float32, frames)
+
+	for i := range frames {
+		l := int16(binary.LittleEndian.Uint16(pcm[i*4:]))
+		r := int16(binary.LittleEndian.Uint16(pcm[i*4+2:]))
+		samples[i] = (float32(l) + float32(r)) / 2 / 32768
+	}
+
+	return samples, dec.SampleRate(), nil
+}
+
+// resample converts the samples to the specified rate using linear
+// interpolation.
+func resample(samples []float32, from int, to int) PCM {
+	if from == to {
+		return samples
+	}
+
+	n := int(int64(len(samples)) * int64(to) / int64(from))
+	out := make(PCM, n)
+
+	ratio := float64(from) / float64(to)
+
+	for i := range n {
+		pos := float64(i) * ratio
+		idx := int(pos)
+		frac := float32(pos - float64(idx))
+
+		if idx+1 >= len(samples) {
+			out[i] = samples[len(samples)-1]
+			continue
+		}
+
+		out[i] = samples[idx]*(1-frac) + samples[idx+1]*frac
+	}
+
+	return out
+}
+
+// quietest returns the sample position between from and to where the energy
+// of the audio over the window is the lowest.
+func quietest(pcm PCM, from int, to int, window int) int {
+	from = max(from, 0)
+
+	best := to
+	bestEnergy := math.MaxFloat64
+
+	for pos := from; pos+window <= to; pos += window / 2 {
+		var energy float64
+		for _, s := range pcm[pos : pos+window] {
+			energy += float64(s) * float64(s)
+		}
+
+		if energy < bestEnergy {
+			bestEnergy = energy
+			best = pos + window/2
+		}
+	}
+
+	return best
+}
+
+func samplesToDuration(samples int) time.Duration {
+	return time.Duration(samples) * time.Second / SampleRate
+}
@@ -0,0 +1,130 @@
+package audio_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/ardanlabs/kronk/cmd/server/app/sdk/audio"
+)
+
+func Test_DecodeWAV(t *testing.T) {
+	const rate = 44100
+
+	// One second of stereo 16 bit audio.
+	samples := make([]int16, rate*2)
+	for i := range rate {
+		v := int16(math.Sin(float64(i)*2*math.Pi*440/rate) * 10000)
+		samples[i*2] = v
+		samples[i*2+1] = v
+	}
+
+	pcm, err := audio.Decode(wavFile(t, samples, rate, 2))
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	if len(pcm) != audio.SampleRate {
+		t.Fatalf("expected %d samples, got %d", audio.SampleRate, len(pcm))
+	}
+
+	if pcm.Duration() != time.Second {
+		t.Fatalf("expected 1s of audio, got %v", pcm.Duration())
+	}
+}
+
+func Test_DecodeUnsupported(t *testing.T) {
+	if _, err := audio.Decode([]byte("this is not audio")); err == nil {
+		t.Fatal("expected an error for unsupported audio")
+	}
+
+	if _, err := audio.Decode([]byte("RIFF\x04\x00\x00\x00WAVE")); err == nil {
+		t.Fatal("expected an error for a wav file without data")
+	}
+}
+
+func Test_EncodeWAV(t *testing.T) {
+	pcm := make(audio.PCM, audio.SampleRate/2)
+	for i := range pcm {
+		pcm[i] = 0.5
+	}
+
+	got, err := audio.Decode(audio.EncodeWAV(pcm))
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	if len(got) != len(pcm) {
+		t.Fatalf("expected %d samples, got %d", len(pcm), len(got))
+	}
+
+	if math.Abs(float64(got[0]-0.5)) > 0.001 {
+		t.Fatalf("expected sample value 0.5, got %f", got[0])
+	}
+}
+
+func Test_Split(t *testing.T) {
+	pcm := make(audio.PCM, 70*audio.SampleRate)
+	for i := range pcm {
+		pcm[i] = 0.5
+	}
+
+	// Make a quiet spot at 28 seconds so the first chunk ends there.
+	quiet := 28 * audio.SampleRate
+	for i := quiet - audio.SampleRate/10; i < quiet+audio.SampleRate/10; i++ {
+		pcm[i] = 0
+	}
+
+	chunks := audio.Split(pcm, 30*time.Second)
+
+	if len(chunks) != 3 {
+		t.Fatalf("expected 3 chunks, got %d", len(chunks))
+	}
+
+	if d := chunks[0].End - 28*time.Second; d < -100*time.Millisecond || d > 100*time.Millisecond {
+		t.Fatalf("expected the first chunk to end near 28s, got %v", chunks[0].End)
+	}
+
+	var total int
+	for i, chunk := range chunks {
+		if chunk.End-chunk.Start > 30*time.Second {
+			t.Fatalf("chunk %d is longer than 30s: %v", i, chunk.End-chunk.Start)
+		}
+
+		if i > 0 && chunk.Start != chunks[i-1].End {
+			t.Fatalf("chunk %d doesn't start where the previous chunk ended", i)
+		}
+
+		total += len(chunk.PCM)
+	}
+
+	if total != len(pcm) {
+		t.Fatalf("expected the chunks to cover %d samples, got %d", len(pcm), total)
+	}
+}
+
+// =============================================================================
+
+func wavFile(t *testing.T, samples []int16, rate int, channels int) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	buf.WriteString("RIFF")
+	binary.Write(&buf, binary.LittleEndian, uint32(36+len(samples)*2))
+	buf.WriteString("WAVE")
+	buf.WriteString("fmt ")
+	binary.Write(&buf, binary.LittleEndian, uint32(16))
+	binary.Write(&buf, binary.LittleEndian, uint16(1))
+	binary.Write(&buf, binary.LittleEndian, uint16(channels))
+	binary.Write(&buf, binary.LittleEndian, uint32(rate))
+	binary.Write(&buf, binary.LittleEndian, uint32(rate*channels*2))
+	binary.Write(&buf, binary.LittleEndian, uint16(channels*2))
+	binary.Write(&buf, binary.LittleEndian, uint16(16))
+	buf.WriteString("data")
+	binary.Write(&buf, binary.LittleEndian, uint32(len(samples)*2))
+	binary.Write(&buf, binary.LittleEndian, samples)
+
+	return buf.Bytes()
+}
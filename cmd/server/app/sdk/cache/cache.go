@@ -4,15 +4,22 @@
 package cache
 
 import (
+	"cmp"
 	"context"
+	"errors"
 	"fmt"
+	"os"
+	"path/filepath"
 	"strings"
+	"sync"
 	"sync/atomic"
 	"time"
 
 	"github.com/ardanlabs/kronk/sdk/kronk"
 	"github.com/ardanlabs/kronk/sdk/kronk/model"
+	"github.com/ardanlabs/kronk/sdk/tools/configs"
 	"github.com/ardanlabs/kronk/sdk/tools/models"
+	"github.com/ardanlabs/kronk/sdk/tools/sessions"
 	"github.com/ardanlabs/kronk/sdk/tools/templates"
 	"github.com/hybridgroup/yzma/pkg/download"
 	"github.com/maypok86/otter/v2"
@@ -30,8 +37,21 @@ import (
 // $HOME/kronk/libraries/llama-bench --list-devices
 // Leave empty for the system to pick the device.
 //
+// Devices: Specify a set of devices to spread the instances of a model
+// across. Device is ignored when Devices is set. The per-model
+// configurations can set a different set of devices.
+//
+// Placement: Defines how the instances are placed on the Devices. With
+// round-robin, an instance is placed on the device with the fewest instances
+// of the model. With least-loaded, it's placed on the device with the fewest
+// bytes of models loaded by this server. Defaults to round-robin if the value
+// is empty.
+//
 // MaxInCache: Defines the maximum number of unique models will be available at a
-// time. Defaults to 3 if the value is 0.
+// time. Defaults to 3 if the value is 0. Not used when a MemoryBudget is set.
+// When the cache is full, idle models are evicted starting with the least
+// recently used. Models used by requests and pinned models are never
+// evicted and the model isn't loaded when there is no room.
 //
 // ModelInstances: Defines how many instances of the same model should be
 // loaded. Defaults to 1 if the value is 0.
@@ -41,7 +61,49 @@ import (
 // is the default.
 //
 // CacheTTL: Defines the time an existing model can live in the cache without
-// being used.
+// being used. Every request for the model restarts the timer. A model that
+// expires while requests are using it stays in the cache until they finish.
+// The per-model configurations can set a different TTL or pin a model so it
+// never expires.
+//
+// SessionTTL: Defines the time a persisted conversation session is kept
+// since it was last used. Sessions don't expire if the value is 0.
+//
+// SessionMaxSize: Defines the maximum number of bytes persisted sessions can
+// use on disk. There is no limit if the value is 0.
+//
+// MediaFetcher: Defines how media provided as a URL in a request is
+// retrieved. URLs are not supported if the value is nil.
+//
+// MaxImagePixels: Defines the maximum number of pixels an image in a request
+// can have before it's downscaled. Defaults to 2048x2048 if the value is 0.
+//
+// Configs: Defines the per-model configurations. The settings for a model
+// override the global settings in this config. The configurations embedded
+// in the catalog are used for models without a configuration file. If left
+// nil, the configuration files in the default location are used.
+//
+// MemoryBudget: Defines the number of bytes the models in the cache can use
+// at most. A model is weighed by its size plus the estimated KV cache for the
+// context window and compute buffers. When a model doesn't fit, idle models
+// are evicted like when the cache is full. There is no budget if the value
+// is 0.
+//
+// MaxQueue: Defines the maximum number of requests that can wait for an
+// instance of a model. Requests made when the queue is full are rejected so
+// the client can retry later. There is no limit if the value is 0.
+//
+// ScaleUpWait: Defines the time a request can wait for an instance of a
+// model configured with max instances before another instance is added.
+// Defaults to 2 seconds if the value is 0.
+//
+// ScaleDownAfter: Defines the time an added instance can be idle before
+// it's removed, shrinking the model back to its min instances. Defaults to
+// 5 minutes if the value is 0.
+//
+// Runtime: Defines the global llama.cpp load and context options. The
+// per-model configurations and the runtime config in a request override
+// these settings.
 type Config struct {
 	Log            model.Logger
 	Templates      *templates.Templates
@@ -49,10 +111,22 @@ type Config struct {
 	OS             download.OS
 	Processor      download.Processor
 	Device         string
+	Devices        []string
+	Placement      string
 	MaxInCache     int
 	ModelInstances int
 	ContextWindow  int
 	CacheTTL       time.Duration
+	SessionTTL     time.Duration
+	SessionMaxSize int64
+	MediaFetcher   model.MediaFetcher
+	MaxImagePixels int
+	Configs        *configs.Configs
+	MemoryBudget   uint64
+	MaxQueue       int
+	ScaleUpWait    time.Duration
+	ScaleDownAfter time.Duration
+	Runtime        RuntimeConfig
 }
 
 func validateConfig(cfg Config) (Config, error) {
@@ -65,6 +139,19 @@ func validateConfig(cfg Config) (Config, error) {
 		cfg.Templates = templates
 	}
 
+	if cfg.Configs == nil {
+		configs, err := configs.New()
+		if err != nil {
+			return Config{}, err
+		}
+
+		cfg.Configs = configs
+	}
+
+	if err := cfg.Runtime.validate(); err != nil {
+		return Config{}, fmt.Errorf("validate-config: runtime: %w", err)
+	}
+
 	if cfg.MaxInCache <= 0 {
 		cfg.MaxInCache = 3
 	}
@@ -80,20 +167,63 @@ func validateConfig(cfg Config) (Config, error) {
 	return cfg, nil
 }
 
+const (
+	// pinnedTTL is the expiry used for pinned models, which is long enough
+	// for them to never expire.
+	pinnedTTL = 100 * 365 * 24 * time.Hour
+
+	// drainInterval is how often an evicted model logs it's still waiting
+	// for the requests using it to finish.
+	drainInterval = 30 * time.Second
+
+	// drainTimeout is the maximum time an evicted model waits for the
+	// requests using it to finish, which is the longest request timeout.
+	drainTimeout = 180 * time.Minute
+
+	// unloadRetry is the time to wait before unloading a model that failed
+	// to unload again.
+	unloadRetry = 30 * time.Second
+
+	// leaseRetry is the time to wait before looking up a model again when
+	// it's being evicted.
+	leaseRetry = 10 * time.Millisecond
+)
+
 // Cache manages a set of Kronk APIs for use. It maintains a cache of these
 // APIs and will unload over time if not in use.
 type Cache struct {
-	log           model.Logger
-	templates     *templates.Templates
-	arch          download.Arch
-	os            download.OS
-	processor     download.Processor
-	device        string
-	instances     int
-	contextWindow int
-	cache         *otter.Cache[string, *kronk.Kronk]
-	itemsInCache  atomic.Int32
-	models        *models.Models
+	log            model.Logger
+	templates      *templates.Templates
+	arch           download.Arch
+	os             download.OS
+	processor      download.Processor
+	device         string
+	devices        []string
+	placement      string
+	instances      int
+	runtime        RuntimeConfig
+	sessionTTL     time.Duration
+	sessionMaxSize int64
+	mediaFetcher   model.MediaFetcher
+	maxImagePixels int
+	cacheTTL       time.Duration
+	configs        *configs.Configs
+	memoryBudget   uint64
+	maxQueue       int
+	scaleUpWait    time.Duration
+	scaleDownAfter time.Duration
+	loadMu         sync.Mutex
+	loads          map[string]*Load
+	capacity       uint64
+	capMu          sync.Mutex
+	slots          map[*slot]struct{}
+	scaled         map[*reservation]struct{}
+	cache          *otter.Cache[string, *kronk.Kronk]
+	unload         func(ctx context.Context, krn *kronk.Kronk) error
+	unloadRetry    time.Duration
+	itemsInCache   atomic.Int32
+	models         *models.Models
+	sessions       *sessions.Sessions
 }
 
 // NewCache constructs the manager for use.
@@ -108,21 +238,55 @@ func NewCache(cfg Config) (*Cache, error) {
 		return nil, fmt.Errorf("creating models system: %w", err)
 	}
 
+	sessions, err := sessions.New()
+	if err != nil {
+		return nil, fmt.Errorf("creating sessions system: %w", err)
+	}
+
+	mcs, err := cfg.Templates.Catalog().RetrieveModelConfigs()
+	if err != nil {
+		return nil, fmt.Errorf("retrieving catalog model configs: %w", err)
+	}
+
+	if err := cfg.Configs.AddDefaults(mcs); err != nil {
+		return nil, fmt.Errorf("adding catalog model configs: %w", err)
+	}
+
 	c := Cache{
-		log:           cfg.Log,
-		templates:     cfg.Templates,
-		arch:          cfg.Arch,
-		os:            cfg.OS,
-		processor:     cfg.Processor,
-		device:        cfg.Device,
-		instances:     cfg.ModelInstances,
-		contextWindow: cfg.ContextWindow,
-		models:        models,
+		log:            cfg.Log,
+		templates:      cfg.Templates,
+		arch:           cfg.Arch,
+		os:             cfg.OS,
+		processor:      cfg.Processor,
+		device:         cfg.Device,
+		devices:        cfg.Devices,
+		placement:      cfg.Placement,
+		instances:      cfg.ModelInstances,
+		runtime:        RuntimeConfig{ContextWindow: cfg.ContextWindow}.override(cfg.Runtime),
+		sessionTTL:     cfg.SessionTTL,
+		sessionMaxSize: cfg.SessionMaxSize,
+		mediaFetcher:   cfg.MediaFetcher,
+		maxImagePixels: cfg.MaxImagePixels,
+		cacheTTL:       cfg.CacheTTL,
+		configs:        cfg.Configs,
+		memoryBudget:   cfg.MemoryBudget,
+		maxQueue:       cfg.MaxQueue,
+		scaleUpWait:    cfg.ScaleUpWait,
+		scaleDownAfter: cfg.ScaleDownAfter,
+		loads:          make(map[string]*Load),
+		capacity:       cmp.Or(cfg.MemoryBudget, uint64(cfg.MaxInCache)),
+		slots:          make(map[*slot]struct{}),
+		scaled:         make(map[*reservation]struct{}),
+		unload:         unloadKronk,
+		unloadRetry:    unloadRetry,
+		models:         models,
+		sessions:       sessions,
 	}
 
+	// The size of the cache is managed when a model is acquired since
+	// models with active streams or pinned can't be evicted.
 	opt := otter.Options[string, *kronk.Kronk]{
-		MaximumSize:      cfg.MaxInCache,
-		ExpiryCalculator: otter.ExpiryWriting[string, *kronk.Kronk](cfg.CacheTTL),
+		ExpiryCalculator: otter.ExpiryAccessingFunc(c.expiry),
 		OnDeletion:       c.eviction,
 	}
 
@@ -193,61 +357,233 @@ func (c *Cache) ModelStatus() ([]ModelDetail, error) {
 	ps := make([]ModelDetail, 0, len(entries))
 ids:
 	for _, model := range entries {
+		modelID, hash := splitKey(model.Key)
+
 		for _, mi := range list {
 			id := strings.ToLower(mi.ID)
 
-			if id == model.Key {
+			if id == modelID {
 				ps = append(ps, ModelDetail{
 					ID:            mi.ID,
+					ConfigHash:    hash,
+					Config:        effectiveConfig(model.Value),
 					OwnedBy:       mi.OwnedBy,
 					ModelFamily:   mi.ModelFamily,
 					Size:          mi.Size,
 					ExpiresAt:     model.ExpiresAt(),
+					Pinned:        c.pinned(model.Key),
 					ActiveStreams: model.Value.ActiveStreams(),
+					Queued:        model.Value.QueuedRequests(),
+					Instances:     model.Value.InstanceStatus(),
 				})
 				continue ids
 			}
 		}
 	}
 
+	// Include the models that are being loaded.
+	for _, l := range c.Loads("") {
+		for _, mi := range list {
+			if strings.ToLower(mi.ID) == l.ModelID {
+				ps = append(ps, ModelDetail{
+					ID:           mi.ID,
+					ConfigHash:   l.ConfigHash,
+					OwnedBy:      mi.OwnedBy,
+					ModelFamily:  mi.ModelFamily,
+					Size:         mi.Size,
+					Pinned:       c.pinned(l.key),
+					Loading:      true,
+					LoadProgress: l.Progress(),
+				})
+				break
+			}
+		}
+	}
+
 	return ps, nil
 }
 
-// AquireModel will provide a kronk API for the specified model. If the model
-// is not in the cache, an API for the model will be created.
-func (c *Cache) AquireModel(ctx context.Context, modelID string) (*kronk.Kronk, error) {
-	modelID = strings.ToLower(modelID)
+// AquireModel will provide a kronk API for the specified model. The model can
+// be specified by its id or one of its configured aliases. If the model is
+// not in the cache, an API for the model will be created. The model isn't
+// evicted until the returned release function is called.
+func (c *Cache) AquireModel(ctx context.Context, modelID string) (*kronk.Kronk, func(), error) {
+	return c.AquireModelWithConfig(ctx, modelID, RuntimeConfig{})
+}
+
+// AquireModelWithConfig will provide a kronk API for the specified model
+// loaded with the runtime config. The runtime config overrides the global
+// and per-model settings. The cache keeps a separate API for each unique
+// set of settings for a model. Concurrent requests for a model that isn't in
+// the cache share a single load. The model isn't evicted until the returned
+// release function is called.
+func (c *Cache) AquireModelWithConfig(ctx context.Context, modelID string, rc RuntimeConfig) (*kronk.Kronk, func(), error) {
+	modelID = c.configs.Resolve(modelID)
+
+	mc, _ := c.configs.Retrieve(modelID)
+
+	rc = c.runtime.
+		override(toRuntimeConfig(mc)).
+		override(rc).
+		normalize()
+
+	key := cacheKey(modelID, rc)
 
-	krn, exists := c.cache.GetIfPresent(modelID)
+	// A model can be evicted between being found and being leased, in
+	// which case it's looked up again once it has left the cache.
+	for {
+		krn, err := c.aquire(ctx, key, modelID, mc, rc)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		if release, leased := c.lease(krn); leased {
+			return krn, release, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, nil, fmt.Errorf("aquire-model: %w", ctx.Err())
+
+		case <-time.After(leaseRetry):
+		}
+	}
+}
+
+// aquire returns the kronk API for the key from the cache or loads it.
+func (c *Cache) aquire(ctx context.Context, key string, modelID string, mc configs.ModelConfig, rc RuntimeConfig) (*kronk.Kronk, error) {
+	krn, exists := c.cache.GetIfPresent(key)
 	if exists {
 		return krn, nil
 	}
 
+	// Only one load runs for a key at a time. The requests that arrive
+	// while the model is loading wait for the same load.
+	c.loadMu.Lock()
+
+	if krn, exists := c.cache.GetIfPresent(key); exists {
+		c.loadMu.Unlock()
+		return krn, nil
+	}
+
+	l, loading := c.loads[key]
+	if !loading {
+		l = newLoad(key)
+		c.loads[key] = l
+
+		// The load isn't bound to the request that started it so other
+		// requests still get the model if that request is canceled.
+		loadCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), loadTimeout)
+
+		go func() {
+			defer cancel()
+
+			krn, err := c.load(loadCtx, l, key, modelID, mc, rc)
+
+			c.loadMu.Lock()
+			delete(c.loads, key)
+			c.loadMu.Unlock()
+
+			l.finish(krn, err)
+		}()
+	}
+
+	c.loadMu.Unlock()
+
+	select {
+	case <-ctx.Done():
+		return nil, fmt.Errorf("aquire-model: waiting for model to load: %w", ctx.Err())
+
+	case <-l.Done():
+	}
+
+	if err := l.Err(); err != nil {
+		return nil, err
+	}
+
+	return l.krn, nil
+}
+
+// load creates a kronk API for the model and adds it to the cache.
+func (c *Cache) load(ctx context.Context, l *Load, key string, modelID string, mc configs.ModelConfig, rc RuntimeConfig) (*kronk.Kronk, error) {
 	fi, err := c.models.RetrievePath(modelID)
 	if err != nil {
 		return nil, fmt.Errorf("aquire-model: %w", err)
 	}
 
-	cfg := model.Config{
-		Log:           c.log,
-		ModelFile:     fi.ModelFile,
-		ProjFile:      fi.ProjFile,
-		Device:        c.device,
-		ContextWindow: c.contextWindow,
+	// Adapters found next to the model are loaded with a scale of 0 so
+	// they are only applied when a request selects them.
+	adapters, err := rc.selectAdapters(fi.AdapterFiles)
+	if err != nil {
+		return nil, fmt.Errorf("aquire-model: %w", err)
+	}
+
+	cfg := rc.apply(model.Config{
+		Log:            c.log,
+		ModelFile:      fi.ModelFile,
+		ProjFile:       fi.ProjFile,
+		JinjaFile:      c.templateFile(mc.Template),
+		Device:         cmp.Or(mc.Device, c.device),
+		Adapters:       adapters,
+		SessionDir:     c.sessions.Path(),
+		SessionTTL:     c.sessionTTL,
+		SessionMaxSize: c.sessionMaxSize,
+		MediaFetcher:   c.mediaFetcher,
+		MaxImagePixels: c.maxImagePixels,
+		DefaultParams:  mc.Params,
+		LoadProgress:   l.setProgress,
+	})
+
+	instances := cmp.Or(mc.MinInstances, mc.Instances, c.instances)
+
+	s, est, err := c.reserve(ctx, key, fi, rc, instances)
+	if err != nil {
+		return nil, fmt.Errorf("aquire-model: %w", err)
 	}
 
-	krn, err = kronk.New(c.instances, cfg,
+	opts := []kronk.Option{
 		kronk.WithTemplateRetriever(c.templates),
-	)
+		kronk.WithMaxQueue(c.maxQueue),
+	}
+
+	devices := c.devices
+	if len(mc.Devices) > 0 {
+		devices = mc.Devices
+	}
+
+	if len(devices) > 0 {
+		opts = append(opts, kronk.WithPlacement(kronk.Placement{
+			Devices: devices,
+			Policy:  cmp.Or(mc.Placement, c.placement),
+		}))
+	}
+
+	// Instances above the minimum are added when requests wait too long and
+	// need room in the memory budget like any other model.
+	if mc.MaxInstances > instances {
+		opts = append(opts, kronk.WithScaling(kronk.Scaling{
+			MaxInstances: mc.MaxInstances,
+			QueueWait:    c.scaleUpWait,
+			CoolDown:     c.scaleDownAfter,
+			Reserve: func(ctx context.Context) (func(), error) {
+				return c.reserveInstance(s, instances)
+			},
+		}))
+	}
+
+	krn, err := kronk.New(instances, cfg, opts...)
 
 	if err != nil {
+		c.release(s)
 		return nil, fmt.Errorf("unable to create inference model: %w", err)
 	}
 
-	c.cache.Set(modelID, krn)
+	c.loaded(s, krn, est, instances)
+
+	c.cache.Set(key, krn)
 	c.itemsInCache.Add(1)
 
-	totalEntries := len(krn.SystemInfo())*2 + (5 * 2)
+	totalEntries := len(krn.SystemInfo())*2 + (6 * 2)
 	info := make([]any, 0, totalEntries)
 	for k, v := range krn.SystemInfo() {
 		info = append(info, k)
@@ -258,6 +594,8 @@ func (c *Cache) AquireModel(ctx context.Context, modelID string) (*kronk.Kronk,
 	info = append(info, "kronk cache add")
 	info = append(info, "model-name")
 	info = append(info, modelID)
+	info = append(info, "config-hash")
+	info = append(info, rc.hash())
 	info = append(info, "contextWindow")
 	info = append(info, krn.ModelConfig().ContextWindow)
 	info = append(info, "isGPTModel")
@@ -270,15 +608,99 @@ func (c *Cache) AquireModel(ctx context.Context, modelID string) (*kronk.Kronk,
 	return krn, nil
 }
 
+// templateFile returns the path to the configured template. A template that
+// isn't a path to a file is looked up in the templates folder.
+func (c *Cache) templateFile(template string) string {
+	if template == "" {
+		return ""
+	}
+
+	if _, err := os.Stat(template); err == nil {
+		return template
+	}
+
+	return filepath.Join(c.templates.TemplatesPath(), template)
+}
+
+// expiry returns the time a model can live in the cache without being used.
+// Pinned models don't expire.
+func (c *Cache) expiry(entry otter.Entry[string, *kronk.Kronk]) time.Duration {
+	modelID, _ := splitKey(entry.Key)
+
+	mc, exists := c.configs.Retrieve(modelID)
+
+	switch {
+	case exists && mc.Pinned:
+		return pinnedTTL
+
+	case exists && mc.TTL > 0:
+		return mc.TTL
+	}
+
+	return c.cacheTTL
+}
+
+// unloadKronk unloads the models of the kronk API once its active streams
+// have finished.
+func unloadKronk(ctx context.Context, krn *kronk.Kronk) error {
+	return krn.Unload(ctx)
+}
+
 func (c *Cache) eviction(event otter.DeletionEvent[string, *kronk.Kronk]) {
-	const unloadTimeout = 5 * time.Second
-	ctx, cancel := context.WithTimeout(context.Background(), unloadTimeout)
-	defer cancel()
+	ctx := context.Background()
+
+	// A model that expires while requests are still using it is put back
+	// in the cache so it stays available until it's idle.
+	if event.Cause == otter.CauseExpiration && (c.leased(event.Value) || event.Value.ActiveStreams() > 0) {
+		if _, inserted := c.cache.SetIfAbsent(event.Key, event.Value); inserted {
+			c.log(ctx, "kronk cache eviction", "key", event.Key, "status", "model is busy, expiry deferred", "active-streams", event.Value.ActiveStreams())
+			return
+		}
+	}
 
 	c.log(ctx, "kronk cache eviction", "key", event.Key, "cause", event.Cause, "was-evicted", event.WasEvicted())
-	if err := event.Value.Unload(ctx); err != nil {
-		c.log(ctx, "kronk cache eviction", "key", event.Key, "ERROR", err)
+
+	// No new requests can use the model once it's being evicted.
+	c.evicting(event.Value)
+
+	// The memory is only released once the requests using the model have
+	// finished and the model is unloaded. The requests are bounded by their
+	// timeouts so the wait is bounded by the longest request timeout.
+	deadline := time.Now().Add(drainTimeout)
+
+	var err error
+	for {
+		unloadCtx, cancel := context.WithTimeout(ctx, min(drainInterval, time.Until(deadline)))
+		err = c.drainLeases(unloadCtx, event.Value)
+		if err == nil {
+			err = c.unload(unloadCtx, event.Value)
+		}
+		cancel()
+
+		if err == nil || !errors.Is(err, context.DeadlineExceeded) || !time.Now().Before(deadline) {
+			break
+		}
+
+		c.log(ctx, "kronk cache eviction", "key", event.Key, "status", "waiting for active requests", "active-streams", event.Value.ActiveStreams())
+	}
+
+	// A model that failed to unload could still be using its memory, so its
+	// room stays reserved and the unload is retried until it succeeds. The
+	// callers waiting for the model are told the unload failed.
+	for err != nil {
+		c.log(ctx, "kronk cache eviction", "key", event.Key, "status", "unable to unload model, retrying", "ERROR", err)
+		c.unloaded(event.Value, err)
+
+		time.Sleep(c.unloadRetry)
+
+		unloadCtx, cancel := context.WithTimeout(ctx, drainInterval)
+		err = c.drainLeases(unloadCtx, event.Value)
+		if err == nil {
+			err = c.unload(unloadCtx, event.Value)
+		}
+		cancel()
 	}
 
+	c.unloaded(event.Value, nil)
 	c.itemsInCache.Add(-1)
 }
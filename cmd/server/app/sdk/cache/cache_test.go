@@ -69,10 +69,12 @@ func Test_AcquireModel(t *testing.T) {
 
 	t.Run("acquire model first time", func(t *testing.T) {
 		ctx := context.Background()
-		k, err := mgr.AquireModel(ctx, modelID)
+		k, release, err := mgr.AquireModel(ctx, modelID)
 		if err != nil {
 			t.Fatalf("expected no error acquiring model, got: %v", err)
 		}
+		defer release()
+
 		if k == nil {
 			t.Fatal("expected non-nil kronk instance")
 		}
@@ -80,15 +82,17 @@ func Test_AcquireModel(t *testing.T) {
 
 	t.Run("acquire same model from cache", func(t *testing.T) {
 		ctx := context.Background()
-		k1, err := mgr.AquireModel(ctx, modelID)
+		k1, release1, err := mgr.AquireModel(ctx, modelID)
 		if err != nil {
 			t.Fatalf("expected no error acquiring model, got: %v", err)
 		}
+		defer release1()
 
-		k2, err := mgr.AquireModel(ctx, modelID)
+		k2, release2, err := mgr.AquireModel(ctx, modelID)
 		if err != nil {
 			t.Fatalf("expected no error acquiring cached model, got: %v", err)
 		}
+		defer release2()
 
 		if k1 != k2 {
 			t.Error("expected same kronk instance from cache")
@@ -97,13 +101,61 @@ func Test_AcquireModel(t *testing.T) {
 
 	t.Run("acquire non-existent model", func(t *testing.T) {
 		ctx := context.Background()
-		_, err := mgr.AquireModel(ctx, "non-existent-model-xyz")
+		_, _, err := mgr.AquireModel(ctx, "non-existent-model-xyz")
 		if err == nil {
 			t.Fatal("expected error for non-existent model")
 		}
 	})
 }
 
+func Test_ConcurrentAcquire(t *testing.T) {
+	log := initKronk(t)
+
+	modelID := findAvailableModel(t, "")
+
+	cfg := cache.Config{
+		Log:            log,
+		MaxInCache:     3,
+		ModelInstances: 1,
+		CacheTTL:       5 * time.Minute,
+	}
+
+	mgr, err := cache.NewCache(cfg)
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	defer mgr.Shutdown(context.Background())
+
+	const requests = 4
+
+	krns := make([]*kronk.Kronk, requests)
+	releases := make([]func(), requests)
+	errs := make([]error, requests)
+
+	var wg sync.WaitGroup
+	for i := range requests {
+		wg.Go(func() {
+			krns[i], releases[i], errs[i] = mgr.AquireModel(context.Background(), modelID)
+		})
+	}
+	wg.Wait()
+
+	for i := range requests {
+		if errs[i] != nil {
+			t.Fatalf("expected no error acquiring model, got: %v", errs[i])
+		}
+		releases[i]()
+
+		if krns[i] != krns[0] {
+			t.Fatal("expected the concurrent requests to share one load")
+		}
+	}
+
+	if loads := mgr.Loads(modelID); len(loads) != 0 {
+		t.Errorf("expected no loads in progress, got %d", len(loads))
+	}
+}
+
 func Test_Shutdown(t *testing.T) {
 	log := initKronk(t)
 
@@ -141,10 +193,11 @@ func Test_Shutdown(t *testing.T) {
 		}
 
 		ctx := context.Background()
-		_, err = mgr.AquireModel(ctx, modelID)
+		_, release, err := mgr.AquireModel(ctx, modelID)
 		if err != nil {
 			t.Fatalf("expected no error acquiring model, got: %v", err)
 		}
+		release()
 
 		shutdownCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 		defer cancel()
@@ -170,10 +223,11 @@ func Test_Shutdown(t *testing.T) {
 		}
 
 		ctx := context.Background()
-		_, err = mgr.AquireModel(ctx, modelID)
+		_, release, err := mgr.AquireModel(ctx, modelID)
 		if err != nil {
 			t.Fatalf("expected no error acquiring model, got: %v", err)
 		}
+		release()
 
 		shutdownCtx, cancel := context.WithTimeout(context.Background(), 1*time.Nanosecond)
 		defer cancel()
@@ -200,10 +254,11 @@ func Test_Shutdown(t *testing.T) {
 		}
 
 		ctx := context.Background()
-		_, err = mgr.AquireModel(ctx, modelID)
+		_, release, err := mgr.AquireModel(ctx, modelID)
 		if err != nil {
 			t.Fatalf("expected no error acquiring model, got: %v", err)
 		}
+		release()
 
 		shutdownCtx, cancel := context.WithCancel(context.Background())
 		cancel()
@@ -228,10 +283,11 @@ func Test_Shutdown(t *testing.T) {
 		}
 
 		ctx := context.Background()
-		_, err = mgr.AquireModel(ctx, modelID)
+		_, release, err := mgr.AquireModel(ctx, modelID)
 		if err != nil {
 			t.Fatalf("expected no error acquiring model, got: %v", err)
 		}
+		release()
 
 		var wg sync.WaitGroup
 		wg.Add(1)
@@ -281,18 +337,20 @@ func Test_Eviction(t *testing.T) {
 
 		ctx := context.Background()
 
-		k1, err := mgr.AquireModel(ctx, modelID1)
+		k1, release, err := mgr.AquireModel(ctx, modelID1)
 		if err != nil {
 			t.Fatalf("expected no error acquiring model, got: %v", err)
 		}
+		release()
 
 		t.Log("waiting for TTL to expire...")
 		time.Sleep(2 * time.Second)
 
-		k2, err := mgr.AquireModel(ctx, modelID1)
+		k2, release, err := mgr.AquireModel(ctx, modelID1)
 		if err != nil {
 			t.Fatalf("expected no error re-acquiring model after eviction, got: %v", err)
 		}
+		release()
 
 		if k1 == k2 {
 			t.Fatal("same instance returned (cache may not have evicted yet)")
@@ -315,17 +373,19 @@ func Test_Eviction(t *testing.T) {
 
 		ctx := context.Background()
 
-		k1, err := mgr.AquireModel(ctx, modelID1)
+		k1, release, err := mgr.AquireModel(ctx, modelID1)
 		if err != nil {
 			t.Fatalf("expected no error acquiring first model, got: %v", err)
 		}
+		release()
 
 		time.Sleep(time.Second)
 
-		k2, err := mgr.AquireModel(ctx, modelID2)
+		k2, release, err := mgr.AquireModel(ctx, modelID2)
 		if err != nil {
 			t.Fatalf("expected no error acquiring first model, got: %v", err)
 		}
+		release()
 
 		if k1 == k2 {
 			t.Fatal("same instance returned, should have new instance")
@@ -0,0 +1,503 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/ardanlabs/kronk/sdk/kronk"
+	"github.com/ardanlabs/kronk/sdk/tools/gguf"
+	"github.com/ardanlabs/kronk/sdk/tools/models"
+	"github.com/maypok86/otter/v2"
+)
+
+// Set of errors returned when there is no room in the cache for a model and
+// no idle model can be evicted to make room for it.
+var (
+	ErrCacheFull    = errors.New("model cache is full")
+	ErrMemoryBudget = errors.New("model doesn't fit in the memory budget")
+)
+
+// residentModel represents a model in the cache that could be evicted.
+type residentModel struct {
+	slot          *slot
+	key           string
+	weight        uint64
+	activeStreams int
+	leases        int
+	pinned        bool
+	lastUsed      time.Time
+}
+
+// slot represents the room reserved for a single load of a model. The room
+// is tracked per load so the room of a model reloaded under the same key
+// isn't confused with the room of the model it replaced.
+type slot struct {
+	key      string
+	weight   uint64
+	krn      *kronk.Kronk
+	lastUsed time.Time
+	leases   int
+	draining chan struct{}
+	err      error
+}
+
+// reservation represents the room reserved for an instance added to a model
+// by scaling.
+type reservation struct {
+	slot   *slot
+	weight uint64
+}
+
+// reserve checks the model fits in the cache with the models already in it
+// and reserves room for it. With a memory budget, the model weighs the memory
+// it is estimated to use, or the size of its files when the memory can't be
+// estimated, otherwise every model weighs the same. When the model doesn't
+// fit, idle models are evicted starting with the least recently used until
+// there is room. Models used by requests and pinned models are never evicted.
+// The room of an evicted model is only released once it's unloaded.
+func (c *Cache) reserve(ctx context.Context, key string, fi models.Path, rc RuntimeConfig, instances int) (*slot, gguf.Estimate, error) {
+	var est gguf.Estimate
+	required := uint64(1)
+
+	if c.memoryBudget > 0 {
+		var err error
+		est, err = estimateMemory(fi, rc)
+		if err != nil {
+			c.log(ctx, "reserve", "key", key, "status", "unable to estimate memory, using the file size", "ERROR", err)
+
+			// The model still needs room, so it weighs at least the
+			// size of its files.
+			est, err = fileSize(fi)
+			if err != nil {
+				return nil, gguf.Estimate{}, fmt.Errorf("reserve: %w", err)
+			}
+		}
+
+		required = est.Total() * uint64(instances)
+	}
+
+	// Capture the active streams of the models in the cache before taking
+	// the lock since the cache can't be used while holding it.
+	streams := make(map[*kronk.Kronk]int)
+	for _, krn := range c.cache.All() {
+		streams[krn] = krn.ActiveStreams()
+	}
+
+	c.capMu.Lock()
+
+	// The models being evicted still use their room until they're unloaded.
+	used := c.used()
+
+	var victims []*slot
+
+	if used+required > c.capacity {
+		var ok bool
+		victims, ok = selectVictims(c.residents(streams), used+required-c.capacity)
+		if !ok {
+			c.capMu.Unlock()
+
+			if c.memoryBudget > 0 {
+				return nil, gguf.Estimate{}, fmt.Errorf("reserve: %w: model requires %s, %s of %s is in use by busy or pinned models", ErrMemoryBudget, formatBytes(required), formatBytes(used), formatBytes(c.memoryBudget))
+			}
+
+			return nil, gguf.Estimate{}, fmt.Errorf("reserve: %w: %d of %d models are busy or pinned", ErrCacheFull, used, c.capacity)
+		}
+	}
+
+	c.drain(victims)
+
+	s := slot{
+		key:      key,
+		weight:   required,
+		lastUsed: time.Now(),
+	}
+
+	c.slots[&s] = struct{}{}
+
+	c.capMu.Unlock()
+
+	// -------------------------------------------------------------------------
+
+	for _, victim := range victims {
+		c.log(ctx, "reserve", "key", key, "status", "evicting idle model", "victim", victim.key)
+	}
+
+	// Wait for the memory to be released before the model is loaded.
+	if err := c.evict(ctx, victims); err != nil {
+		c.release(&s)
+		return nil, gguf.Estimate{}, fmt.Errorf("reserve: %w", err)
+	}
+
+	if c.memoryBudget > 0 {
+		c.log(ctx, "reserve", "key", key, "weights", formatBytes(est.Weights), "kv-cache", formatBytes(est.KVCache), "compute", formatBytes(est.Compute), "instances", instances)
+	}
+
+	return &s, est, nil
+}
+
+// used returns the room used by the models in the cache, the models being
+// loaded and the models being evicted. The capMu lock must be held.
+func (c *Cache) used() uint64 {
+	var used uint64
+	for s := range c.slots {
+		used += s.weight + c.scaledWeight(s)
+	}
+
+	return used
+}
+
+// residents returns the models in the cache that aren't being evicted in
+// order from least to most recently used. The streams hold the active
+// streams of the models in the cache, so the models still loading aren't
+// returned. The capMu lock must be held.
+func (c *Cache) residents(streams map[*kronk.Kronk]int) []residentModel {
+	resident := make([]residentModel, 0, len(c.slots))
+	for s := range c.slots {
+		activeStreams, cached := streams[s.krn]
+		if !cached || s.draining != nil {
+			continue
+		}
+
+		resident = append(resident, residentModel{
+			slot:          s,
+			key:           s.key,
+			weight:        s.weight + c.scaledWeight(s),
+			activeStreams: activeStreams,
+			leases:        s.leases,
+			pinned:        c.pinned(s.key),
+			lastUsed:      s.lastUsed,
+		})
+	}
+
+	slices.SortFunc(resident, func(a, b residentModel) int {
+		return a.lastUsed.Compare(b.lastUsed)
+	})
+
+	return resident
+}
+
+// lease records a request is using the model so it isn't evicted until the
+// returned release function is called. The model is also marked as used so
+// it's evicted after the models that were used before it. False is returned
+// when the model is being evicted or has been unloaded.
+func (c *Cache) lease(krn *kronk.Kronk) (func(), bool) {
+	c.capMu.Lock()
+	defer c.capMu.Unlock()
+
+	s := c.slotOf(krn)
+	if s == nil || s.draining != nil {
+		return nil, false
+	}
+
+	s.leases++
+	s.lastUsed = time.Now()
+
+	var once sync.Once
+	release := func() {
+		once.Do(func() {
+			c.capMu.Lock()
+			defer c.capMu.Unlock()
+
+			s.leases--
+		})
+	}
+
+	return release, true
+}
+
+// leased reports if requests are using the model.
+func (c *Cache) leased(krn *kronk.Kronk) bool {
+	c.capMu.Lock()
+	defer c.capMu.Unlock()
+
+	s := c.slotOf(krn)
+
+	return s != nil && s.leases > 0
+}
+
+// drainLeases waits for the requests using the model to release it.
+func (c *Cache) drainLeases(ctx context.Context, krn *kronk.Kronk) error {
+	for c.leased(krn) {
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("drain-leases: model is in use: %w", ctx.Err())
+
+		case <-time.After(100 * time.Millisecond):
+		}
+	}
+
+	return nil
+}
+
+// slotOf returns the slot of the loaded model or nil when the model has no
+// room reserved. The capMu lock must be held.
+func (c *Cache) slotOf(krn *kronk.Kronk) *slot {
+	for s := range c.slots {
+		if s.krn == krn {
+			return s
+		}
+	}
+
+	return nil
+}
+
+// drain marks the models as being evicted. Their room stays reserved until
+// they are unloaded, then the slots are released and their draining channels
+// closed. The capMu lock must be held.
+func (c *Cache) drain(slots []*slot) {
+	for _, s := range slots {
+		s.draining = make(chan struct{})
+	}
+}
+
+// evicting marks a model removed from the cache as being evicted, which
+// happens when the model expires or the cache is shut down.
+func (c *Cache) evicting(krn *kronk.Kronk) {
+	c.capMu.Lock()
+	defer c.capMu.Unlock()
+
+	if s := c.slotOf(krn); s != nil && s.draining == nil {
+		c.drain([]*slot{s})
+	}
+}
+
+// evict removes the drained models from the cache and waits for them to be
+// unloaded, which happens in the background. A model that isn't in the cache
+// anymore is already being unloaded.
+func (c *Cache) evict(ctx context.Context, drained []*slot) error {
+	for _, s := range drained {
+		c.cache.ComputeIfPresent(s.key, func(krn *kronk.Kronk) (*kronk.Kronk, otter.ComputeOp) {
+			if krn != s.krn {
+				return krn, otter.CancelOp
+			}
+
+			return nil, otter.InvalidateOp
+		})
+	}
+
+	for _, s := range drained {
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("evict: waiting for models to unload: %w", ctx.Err())
+
+		case <-s.draining:
+			if s.err != nil {
+				return fmt.Errorf("evict: unloading %s: %w", s.key, s.err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// loaded records the model loaded for the slot and replaces the estimate with
+// the actual size of the loaded model plus the estimated KV cache and compute
+// buffers.
+func (c *Cache) loaded(s *slot, krn *kronk.Kronk, est gguf.Estimate, instances int) {
+	c.capMu.Lock()
+	defer c.capMu.Unlock()
+
+	s.krn = krn
+
+	if c.memoryBudget > 0 {
+		s.weight = (krn.ModelInfo().Size + est.KVCache + est.Compute) * uint64(instances)
+	}
+}
+
+// reserveInstance reserves room for another instance of the model when it
+// scales up. The instances of a model weigh the same, so the instance weighs
+// the weight of the model divided by the instances it was loaded with. An
+// instance doesn't evict other models, it's only added when there is room.
+// Without a memory budget, the instances of a model don't change its weight.
+func (c *Cache) reserveInstance(s *slot, instances int) (func(), error) {
+	if c.memoryBudget == 0 {
+		return func() {}, nil
+	}
+
+	c.capMu.Lock()
+	defer c.capMu.Unlock()
+
+	if _, exists := c.slots[s]; !exists || s.draining != nil {
+		return nil, fmt.Errorf("reserve-instance: model %q isn't in the cache", s.key)
+	}
+
+	used := c.used()
+
+	r := reservation{
+		slot:   s,
+		weight: s.weight / uint64(instances),
+	}
+
+	if used+r.weight > c.capacity {
+		return nil, fmt.Errorf("reserve-instance: %w: instance requires %s, %s of %s is in use", ErrMemoryBudget, formatBytes(r.weight), formatBytes(used), formatBytes(c.memoryBudget))
+	}
+
+	c.scaled[&r] = struct{}{}
+
+	release := func() {
+		c.capMu.Lock()
+		defer c.capMu.Unlock()
+
+		delete(c.scaled, &r)
+	}
+
+	return release, nil
+}
+
+// scaledWeight returns the room reserved for the instances added to the
+// model by scaling. The capMu lock must be held.
+func (c *Cache) scaledWeight(s *slot) uint64 {
+	var weight uint64
+	for r := range c.scaled {
+		if r.slot == s {
+			weight += r.weight
+		}
+	}
+
+	return weight
+}
+
+// release releases the room reserved for a model that failed to load.
+func (c *Cache) release(s *slot) {
+	c.capMu.Lock()
+	defer c.capMu.Unlock()
+
+	delete(c.slots, s)
+}
+
+// unloaded releases the room of a model that was evicted and unloaded. When
+// the model failed to unload, its room stays reserved since the model could
+// still be using it. The callers waiting for the model to be evicted are
+// notified the first time.
+func (c *Cache) unloaded(krn *kronk.Kronk, err error) {
+	c.capMu.Lock()
+	defer c.capMu.Unlock()
+
+	s := c.slotOf(krn)
+	if s == nil {
+		return
+	}
+
+	switch {
+	case err != nil:
+		s.err = err
+
+	default:
+		delete(c.slots, s)
+
+		for r := range c.scaled {
+			if r.slot == s {
+				delete(c.scaled, r)
+			}
+		}
+	}
+
+	select {
+	case <-s.draining:
+	default:
+		close(s.draining)
+	}
+}
+
+// pinned reports if the model for the key is configured to stay in the cache.
+func (c *Cache) pinned(key string) bool {
+	modelID, _ := splitKey(key)
+
+	mc, exists := c.configs.Retrieve(modelID)
+
+	return exists && mc.Pinned
+}
+
+// =============================================================================
+
+// selectVictims returns the models to evict to free the required room. The
+// models are expected in order from least to most recently used and models
+// in use or pinned are skipped. False is returned when the idle models don't
+// free enough room.
+func selectVictims(resident []residentModel, required uint64) ([]*slot, bool) {
+	var victims []*slot
+	var freed uint64
+
+	for _, rm := range resident {
+		if freed >= required {
+			break
+		}
+
+		if rm.activeStreams > 0 || rm.leases > 0 || rm.pinned {
+			continue
+		}
+
+		victims = append(victims, rm.slot)
+		freed += rm.weight
+	}
+
+	if freed < required {
+		return nil, false
+	}
+
+	return victims, true
+}
+
+// estimateMemory returns the memory an instance of the model is estimated to
+// use when loaded with the runtime config.
+func estimateMemory(fi models.Path, rc RuntimeConfig) (gguf.Estimate, error) {
+	paths := []string{fi.ModelFile}
+	if fi.ProjFile != "" {
+		paths = append(paths, fi.ProjFile)
+	}
+
+	files := make([]*gguf.File, len(paths))
+	for i, path := range paths {
+		file, err := gguf.Read(path)
+		if err != nil {
+			return gguf.Estimate{}, fmt.Errorf("estimate-memory: %w", err)
+		}
+
+		files[i] = file
+	}
+
+	cfg := gguf.EstimateConfig{
+		ContextWindow:  rc.ContextWindow,
+		NUBatch:        rc.NUBatch,
+		TypeK:          rc.TypeK,
+		TypeV:          rc.TypeV,
+		FlashAttention: rc.FlashAttention,
+	}
+
+	est, err := gguf.EstimateFiles(cfg, files...)
+	if err != nil {
+		return gguf.Estimate{}, fmt.Errorf("estimate-memory: %w", err)
+	}
+
+	return est, nil
+}
+
+// fileSize returns the size of the model files as the weights of the model
+// for when the memory it will use can't be estimated.
+func fileSize(fi models.Path) (gguf.Estimate, error) {
+	paths := []string{fi.ModelFile}
+	if fi.ProjFile != "" {
+		paths = append(paths, fi.ProjFile)
+	}
+
+	var est gguf.Estimate
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return gguf.Estimate{}, fmt.Errorf("file-size: %w", err)
+		}
+
+		est.Weights += uint64(info.Size())
+	}
+
+	return est, nil
+}
+
+func formatBytes(n uint64) string {
+	return fmt.Sprintf("%.2f GiB", float64(n)/(1<<30))
+}
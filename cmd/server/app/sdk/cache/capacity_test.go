@@ -0,0 +1,485 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ardanlabs/kronk/sdk/kronk"
+	"github.com/ardanlabs/kronk/sdk/tools/configs"
+	"github.com/ardanlabs/kronk/sdk/tools/models"
+	"github.com/maypok86/otter/v2"
+)
+
+func Test_SelectVictims(t *testing.T) {
+	resident := []residentModel{
+		{slot: &slot{key: "cold"}, weight: 4},
+		{slot: &slot{key: "busy"}, weight: 8, activeStreams: 1},
+		{slot: &slot{key: "leased"}, weight: 9, leases: 1},
+		{slot: &slot{key: "pinned"}, weight: 10, pinned: true},
+		{slot: &slot{key: "warm"}, weight: 2},
+		{slot: &slot{key: "hot"}, weight: 6},
+	}
+
+	tests := []struct {
+		name     string
+		required uint64
+		victims  []string
+		ok       bool
+	}{
+		{name: "coldest", required: 3, victims: []string{"cold"}, ok: true},
+		{name: "skip-busy-pinned", required: 5, victims: []string{"cold", "warm"}, ok: true},
+		{name: "all-idle", required: 12, victims: []string{"cold", "warm", "hot"}, ok: true},
+		{name: "not-enough", required: 13, ok: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			victims, ok := selectVictims(resident, tt.required)
+			if ok != tt.ok || !slices.Equal(slotKeys(victims), tt.victims) {
+				t.Errorf("expected %v %t, got %v %t", tt.victims, tt.ok, slotKeys(victims), ok)
+			}
+		})
+	}
+
+	// -------------------------------------------------------------------------
+
+	// The models in the cache are ordered by when they were last used, so
+	// using a model moves it to the end of the line.
+	cfgs, err := configs.NewWithPaths(t.TempDir())
+	if err != nil {
+		t.Fatalf("configs: %v", err)
+	}
+
+	if err := cfgs.AddDefaults([]configs.ModelConfig{{ID: "pinned", Pinned: true}}); err != nil {
+		t.Fatalf("add defaults: %v", err)
+	}
+
+	c := Cache{
+		configs: cfgs,
+		slots:   make(map[*slot]struct{}),
+		scaled:  make(map[*reservation]struct{}),
+	}
+
+	krns := make(map[string]*kronk.Kronk)
+	streams := make(map[*kronk.Kronk]int)
+
+	for _, key := range []string{"first/1", "pinned/1", "second/1", "third/1", "loading/1", "evicted/1"} {
+		krns[key] = &kronk.Kronk{}
+
+		s := slot{
+			key:      key,
+			weight:   1,
+			krn:      krns[key],
+			lastUsed: time.Now(),
+		}
+
+		c.slots[&s] = struct{}{}
+		streams[krns[key]] = 0
+
+		time.Sleep(time.Millisecond)
+	}
+
+	delete(streams, krns["loading/1"])
+
+	c.drain([]*slot{c.slotOf(krns["evicted/1"])})
+
+	// A leased model is used and can't be evicted until it's released.
+	release, leased := c.lease(krns["first/1"])
+	if !leased {
+		t.Fatal("expected the model to be leased")
+	}
+
+	if _, ok := selectVictims(c.residents(streams), 3); ok {
+		t.Error("expected the leased model to not be evicted")
+	}
+
+	release()
+	release()
+
+	if s := c.slotOf(krns["first/1"]); s.leases != 0 {
+		t.Errorf("expected no leases after the release, got %d", s.leases)
+	}
+
+	victims, ok := selectVictims(c.residents(streams), 2)
+	if exp := []string{"second/1", "third/1"}; !ok || !slices.Equal(slotKeys(victims), exp) {
+		t.Errorf("expected %v, got %v %t", exp, slotKeys(victims), ok)
+	}
+
+	victims, ok = selectVictims(c.residents(streams), 3)
+	if exp := []string{"second/1", "third/1", "first/1"}; !ok || !slices.Equal(slotKeys(victims), exp) {
+		t.Errorf("expected %v, got %v %t", exp, slotKeys(victims), ok)
+	}
+
+	if _, ok := selectVictims(c.residents(streams), 4); ok {
+		t.Error("expected the pinned, loading and evicted models to not be evicted")
+	}
+}
+
+func Test_ReserveInstance(t *testing.T) {
+	model := slot{key: "model", weight: 4}
+	other := slot{key: "other", weight: 2}
+
+	c := Cache{
+		memoryBudget: 10,
+		capacity:     10,
+		slots:        map[*slot]struct{}{&model: {}, &other: {}},
+		scaled:       make(map[*reservation]struct{}),
+	}
+
+	// The model was loaded with 2 instances, so an instance weighs 2.
+	release, err := c.reserveInstance(&model, 2)
+	if err != nil {
+		t.Fatalf("reserve instance: %v", err)
+	}
+
+	if _, err := c.reserveInstance(&model, 2); err != nil {
+		t.Fatalf("reserve instance: %v", err)
+	}
+
+	if _, err := c.reserveInstance(&model, 2); !errors.Is(err, ErrMemoryBudget) {
+		t.Fatalf("expected a memory budget error, got %v", err)
+	}
+
+	if w := c.scaledWeight(&model); w != 4 {
+		t.Fatalf("expected a scaled weight of 4, got %d", w)
+	}
+
+	release()
+
+	if w := c.scaledWeight(&model); w != 2 {
+		t.Fatalf("expected a scaled weight of 2, got %d", w)
+	}
+
+	// An instance can't be added to a model being evicted.
+	c.drain([]*slot{&model})
+
+	if _, err := c.reserveInstance(&model, 2); err == nil {
+		t.Fatal("expected an error for a model being evicted")
+	}
+}
+
+func Test_ReservePerLoad(t *testing.T) {
+	old := slot{key: "model/1", weight: 4, krn: &kronk.Kronk{}}
+
+	c := Cache{
+		slots:  map[*slot]struct{}{&old: {}},
+		scaled: make(map[*reservation]struct{}),
+	}
+
+	// The room of an evicted model is used until it's unloaded.
+	c.drain([]*slot{&old})
+
+	reloaded := slot{key: "model/1", weight: 6, krn: &kronk.Kronk{}}
+	c.slots[&reloaded] = struct{}{}
+
+	if used := c.used(); used != 10 {
+		t.Fatalf("expected 10 in use while the old model unloads, got %d", used)
+	}
+
+	// Unloading the old model only releases its own room.
+	c.unloaded(old.krn, nil)
+
+	select {
+	case <-old.draining:
+	default:
+		t.Fatal("expected the waiters for the old model to be notified")
+	}
+
+	if used := c.used(); used != 6 {
+		t.Fatalf("expected the reloaded model to keep its room, got %d", used)
+	}
+
+	if _, leased := c.lease(old.krn); leased {
+		t.Fatal("expected an unloaded model to not be leased")
+	}
+}
+
+func Test_ReserveFileSize(t *testing.T) {
+	cache, err := otter.New[string, *kronk.Kronk](nil)
+	if err != nil {
+		t.Fatalf("otter: %v", err)
+	}
+
+	c := Cache{
+		log:          func(ctx context.Context, msg string, args ...any) {},
+		memoryBudget: 1 << 20,
+		capacity:     1 << 20,
+		cache:        cache,
+		slots:        make(map[*slot]struct{}),
+		scaled:       make(map[*reservation]struct{}),
+	}
+
+	// A file that isn't a GGUF file can't be estimated, so the model weighs
+	// the size of the file.
+	path := filepath.Join(t.TempDir(), "model.gguf")
+	if err := os.WriteFile(path, make([]byte, 1000), 0644); err != nil {
+		t.Fatalf("write file: %v", err)
+	}
+
+	s, _, err := c.reserve(context.Background(), "model/1", models.Path{ModelFile: path}, RuntimeConfig{}, 2)
+	if err != nil {
+		t.Fatalf("reserve: %v", err)
+	}
+
+	if s.weight != 2000 {
+		t.Errorf("expected a weight of 2000, got %d", s.weight)
+	}
+
+	// A model without files fails to load.
+	if _, _, err := c.reserve(context.Background(), "missing/1", models.Path{ModelFile: path + ".missing"}, RuntimeConfig{}, 1); err == nil {
+		t.Error("expected an error for a missing model file")
+	}
+
+	if len(c.slots) != 1 {
+		t.Error("expected no room to be reserved for the missing model")
+	}
+}
+
+func slotKeys(slots []*slot) []string {
+	var keys []string
+	for _, s := range slots {
+		keys = append(keys, s.key)
+	}
+
+	return keys
+}
+
+func Test_ExpiryRefresh(t *testing.T) {
+	c, unloads := newTestCache(t, 300*time.Millisecond)
+	krn := addTestModel(t, c, "model")
+
+	// Every request for the model restarts the timer.
+	for range 6 {
+		time.Sleep(100 * time.Millisecond)
+
+		got, release, err := c.AquireModel(context.Background(), "model")
+		if err != nil {
+			t.Fatalf("aquire model: %v", err)
+		}
+		release()
+
+		if got != krn {
+			t.Fatal("expected the model to still be in the cache")
+		}
+	}
+
+	waitUnloaded(t, c, unloads, krn)
+}
+
+func Test_ExpiryPinned(t *testing.T) {
+	c, unloads := newTestCache(t, 100*time.Millisecond, configs.ModelConfig{ID: "model", Pinned: true})
+	krn := addTestModel(t, c, "model")
+
+	time.Sleep(500 * time.Millisecond)
+	c.cache.CleanUp()
+
+	if _, exists := c.cache.GetIfPresent(cacheKey("model", RuntimeConfig{})); !exists {
+		t.Fatal("expected the pinned model to stay in the cache")
+	}
+
+	// A pinned model is never evicted to make room for another model.
+	c.capMu.Lock()
+	_, ok := selectVictims(c.residents(map[*kronk.Kronk]int{krn: 0}), 1)
+	c.capMu.Unlock()
+
+	if ok {
+		t.Fatal("expected the pinned model to not be evicted")
+	}
+
+	if len(unloads) != 0 {
+		t.Fatal("expected the pinned model to not be unloaded")
+	}
+}
+
+func Test_ExpiryDeferred(t *testing.T) {
+	c, unloads := newTestCache(t, 300*time.Millisecond)
+	krn := addTestModel(t, c, "model")
+
+	_, release, err := c.AquireModel(context.Background(), "model")
+	if err != nil {
+		t.Fatalf("aquire model: %v", err)
+	}
+
+	// A model that expires while a request is using it is put back in the
+	// cache instead of being unloaded.
+	timeout := time.After(2 * time.Second)
+
+wait:
+	for {
+		c.cache.CleanUp()
+
+		select {
+		case <-unloads:
+			t.Fatal("expected the leased model to not be unloaded")
+
+		case <-timeout:
+			break wait
+
+		case <-time.After(50 * time.Millisecond):
+		}
+	}
+
+	if !c.leased(krn) {
+		t.Fatal("expected the model to still be leased")
+	}
+
+	release()
+
+	waitUnloaded(t, c, unloads, krn)
+}
+
+func Test_UnloadFailure(t *testing.T) {
+	c, unloads := newTestCache(t, time.Hour)
+	c.unloadRetry = 50 * time.Millisecond
+
+	var failures atomic.Int32
+	failures.Store(2)
+
+	c.unload = func(ctx context.Context, krn *kronk.Kronk) error {
+		if failures.Add(-1) >= 0 {
+			return errors.New("unload failed")
+		}
+
+		unloads <- krn
+		return nil
+	}
+
+	krn := addTestModel(t, c, "model")
+
+	if _, err := c.UnloadModel(context.Background(), "model"); err == nil {
+		t.Fatal("expected the unload to fail")
+	}
+
+	// The model could still be using its memory, so its room is kept and
+	// no request can use it while the unload is retried.
+	c.capMu.Lock()
+	s, used := c.slotOf(krn), c.used()
+	c.capMu.Unlock()
+
+	if s == nil || used != 1 {
+		t.Fatalf("expected the room to stay reserved, got %d", used)
+	}
+
+	if _, leased := c.lease(krn); leased {
+		t.Fatal("expected a model being evicted to not be leased")
+	}
+
+	// The room is released once a retry unloads the model.
+	waitUnloaded(t, c, unloads, krn)
+
+	c.capMu.Lock()
+	used = c.used()
+	c.capMu.Unlock()
+
+	if used != 0 {
+		t.Fatalf("expected the room to be released, got %d", used)
+	}
+
+	if err := c.Shutdown(context.Background()); err != nil {
+		t.Fatalf("shutdown: %v", err)
+	}
+}
+
+// newTestCache constructs a cache with the expiry and eviction of the models
+// but without loading any models. The unloaded models are sent on the
+// returned channel.
+func newTestCache(t *testing.T, ttl time.Duration, mcs ...configs.ModelConfig) (*Cache, chan *kronk.Kronk) {
+	t.Helper()
+
+	cfgs, err := configs.NewWithPaths(t.TempDir())
+	if err != nil {
+		t.Fatalf("configs: %v", err)
+	}
+
+	if err := cfgs.AddDefaults(mcs); err != nil {
+		t.Fatalf("add defaults: %v", err)
+	}
+
+	unloads := make(chan *kronk.Kronk, 10)
+
+	c := Cache{
+		log:      func(ctx context.Context, msg string, args ...any) {},
+		configs:  cfgs,
+		cacheTTL: ttl,
+		capacity: 3,
+		loads:    make(map[string]*Load),
+		slots:    make(map[*slot]struct{}),
+		scaled:   make(map[*reservation]struct{}),
+		unload: func(ctx context.Context, krn *kronk.Kronk) error {
+			unloads <- krn
+			return nil
+		},
+	}
+
+	opt := otter.Options[string, *kronk.Kronk]{
+		ExpiryCalculator: otter.ExpiryAccessingFunc(c.expiry),
+		OnDeletion:       c.eviction,
+	}
+
+	cache, err := otter.New(&opt)
+	if err != nil {
+		t.Fatalf("otter: %v", err)
+	}
+
+	c.cache = cache
+
+	return &c, unloads
+}
+
+// addTestModel adds a model to the cache like a load does.
+func addTestModel(t *testing.T, c *Cache, modelID string) *kronk.Kronk {
+	t.Helper()
+
+	krn := kronk.Kronk{}
+
+	s := slot{
+		key:      cacheKey(modelID, RuntimeConfig{}),
+		weight:   1,
+		krn:      &krn,
+		lastUsed: time.Now(),
+	}
+
+	c.capMu.Lock()
+	c.slots[&s] = struct{}{}
+	c.capMu.Unlock()
+
+	c.cache.Set(s.key, &krn)
+	c.itemsInCache.Add(1)
+
+	return &krn
+}
+
+// waitUnloaded waits for the model to expire and be unloaded.
+func waitUnloaded(t *testing.T, c *Cache, unloads chan *kronk.Kronk, krn *kronk.Kronk) {
+	t.Helper()
+
+	timeout := time.After(5 * time.Second)
+
+	for {
+		c.cache.CleanUp()
+
+		select {
+		case got := <-unloads:
+			if got != krn {
+				t.Fatal("expected the model to be unloaded")
+			}
+
+			for c.itemsInCache.Load() != 0 {
+				time.Sleep(10 * time.Millisecond)
+			}
+
+			return
+
+		case <-timeout:
+			t.Fatal("expected the model to expire")
+
+		case <-time.After(50 * time.Millisecond):
+		}
+	}
+}
@@ -0,0 +1,242 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/ardanlabs/kronk/sdk/kronk"
+	"github.com/ardanlabs/kronk/sdk/kronk/model"
+)
+
+// ErrNotLoaded is returned when a model to unload isn't in the cache.
+var ErrNotLoaded = errors.New("model isn't loaded")
+
+const (
+	// loadTimeout is the maximum time a model can take to load.
+	loadTimeout = 30 * time.Minute
+
+	// warmUpTimeout is the maximum time the warm-up of a model can take.
+	warmUpTimeout = 5 * time.Minute
+)
+
+// Load represents a model being loaded into the cache. The requests for the
+// model while it's loading share the same load.
+type Load struct {
+	ModelID    string
+	ConfigHash string
+	StartedAt  time.Time
+	key        string
+	progress   atomic.Uint32
+	done       chan struct{}
+	krn        *kronk.Kronk
+	err        error
+}
+
+func newLoad(key string) *Load {
+	modelID, hash := splitKey(key)
+
+	return &Load{
+		ModelID:    modelID,
+		ConfigHash: hash,
+		StartedAt:  time.Now(),
+		key:        key,
+		done:       make(chan struct{}),
+	}
+}
+
+// Progress returns the progress of loading the model files, as a value
+// between 0 and 1.
+func (l *Load) Progress() float32 {
+	return math.Float32frombits(l.progress.Load())
+}
+
+// Done returns a channel that is closed when the load has finished.
+func (l *Load) Done() <-chan struct{} {
+	return l.done
+}
+
+// Err returns the error that failed the load. It's only valid once the load
+// has finished.
+func (l *Load) Err() error {
+	return l.err
+}
+
+func (l *Load) setProgress(progress float32) {
+	l.progress.Store(math.Float32bits(progress))
+}
+
+// finish records the result of the load and releases the waiting requests.
+func (l *Load) finish(krn *kronk.Kronk, err error) {
+	l.krn = krn
+	l.err = err
+
+	if err == nil {
+		l.setProgress(1)
+	}
+
+	close(l.done)
+}
+
+// =============================================================================
+
+// Loads returns the models being loaded. When a model is specified by its id
+// or one of its aliases, only the loads for that model are returned.
+func (c *Cache) Loads(modelID string) []*Load {
+	if modelID != "" {
+		modelID = c.configs.Resolve(modelID)
+	}
+
+	c.loadMu.Lock()
+	defer c.loadMu.Unlock()
+
+	loads := make([]*Load, 0, len(c.loads))
+	for _, l := range c.loads {
+		if modelID == "" || l.ModelID == modelID {
+			loads = append(loads, l)
+		}
+	}
+
+	return loads
+}
+
+// IsLoaded reports if the model is in the cache with any runtime config. The
+// model can be specified by its id or one of its aliases.
+func (c *Cache) IsLoaded(modelID string) bool {
+	modelID = c.configs.Resolve(modelID)
+
+	for key := range c.cache.Keys() {
+		if id, _ := splitKey(key); id == modelID {
+			return true
+		}
+	}
+
+	return false
+}
+
+// =============================================================================
+
+// LoadModel loads the model with the runtime config into the cache, when it
+// isn't already, and runs a short warm-up request on every instance so the
+// first requests don't pay for the lazy initialization of the model.
+func (c *Cache) LoadModel(ctx context.Context, modelID string, rc RuntimeConfig) error {
+	krn, release, err := c.AquireModelWithConfig(ctx, modelID, rc)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	start := time.Now()
+
+	if err := warmUp(ctx, krn); err != nil {
+		return fmt.Errorf("load-model: %w", err)
+	}
+
+	c.log(ctx, "load-model", "model-name", modelID, "status", "warmed up", "took", time.Since(start).String())
+
+	return nil
+}
+
+// UnloadModel removes every variant of the model from the cache and waits for
+// them to be unloaded. The variants still loading finish loading first and
+// the requests using the model finish before it's unloaded. The model can be
+// specified by its id or one of its aliases. The number of variants unloaded
+// is returned.
+func (c *Cache) UnloadModel(ctx context.Context, modelID string) (int, error) {
+	modelID = c.configs.Resolve(modelID)
+
+	for _, l := range c.Loads(modelID) {
+		select {
+		case <-ctx.Done():
+			return 0, fmt.Errorf("unload-model: waiting for %s to load: %w", modelID, ctx.Err())
+
+		case <-l.Done():
+		}
+	}
+
+	loaded := make(map[*kronk.Kronk]bool)
+	for key, krn := range c.cache.All() {
+		if id, _ := splitKey(key); id == modelID {
+			loaded[krn] = true
+		}
+	}
+
+	c.capMu.Lock()
+
+	var slots []*slot
+	for s := range c.slots {
+		if loaded[s.krn] && s.draining == nil {
+			slots = append(slots, s)
+		}
+	}
+
+	c.drain(slots)
+
+	c.capMu.Unlock()
+
+	if len(slots) == 0 {
+		return 0, fmt.Errorf("unload-model: %s: %w", modelID, ErrNotLoaded)
+	}
+
+	for _, s := range slots {
+		c.log(ctx, "unload-model", "key", s.key, "status", "unloading model")
+	}
+
+	if err := c.evict(ctx, slots); err != nil {
+		return 0, fmt.Errorf("unload-model: %w", err)
+	}
+
+	return len(slots), nil
+}
+
+// warmer represents the calls of the kronk API used to warm up a model.
+type warmer interface {
+	ModelInfo() model.ModelInfo
+	ModelInstances() int
+	Chat(ctx context.Context, d model.D) (model.ChatResponse, error)
+	Embeddings(ctx context.Context, input string) (model.EmbedReponse, error)
+}
+
+// warmUp runs a short request on every instance of the model at the same
+// time so each request is handled by a different instance.
+func warmUp(ctx context.Context, krn warmer) error {
+	ctx, cancel := context.WithTimeout(ctx, warmUpTimeout)
+	defer cancel()
+
+	f := func() error {
+		if krn.ModelInfo().IsEmbedModel {
+			_, err := krn.Embeddings(ctx, "warm up")
+			return err
+		}
+
+		d := model.D{
+			"messages":   model.DocumentArray(model.TextMessage("user", "Hello")),
+			"max_tokens": 1,
+		}
+
+		_, err := krn.Chat(ctx, d)
+		return err
+	}
+
+	instances := krn.ModelInstances()
+
+	errs := make([]error, instances)
+
+	var wg sync.WaitGroup
+	for i := range instances {
+		wg.Go(func() {
+			errs[i] = f()
+		})
+	}
+	wg.Wait()
+
+	if err := errors.Join(errs...); err != nil {
+		return fmt.Errorf("warm-up: %w", err)
+	}
+
+	return nil
+}
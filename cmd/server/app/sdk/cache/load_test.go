@@ -0,0 +1,214 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ardanlabs/kronk/sdk/kronk"
+	"github.com/ardanlabs/kronk/sdk/kronk/model"
+)
+
+func Test_WarmUp(t *testing.T) {
+	tests := []struct {
+		name  string
+		embed bool
+		err   error
+	}{
+		{"chat", false, nil},
+		{"embed", true, nil},
+		{"failed", false, errors.New("decode failed")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := testWarmer{
+				instances: 3,
+				embed:     tt.embed,
+				err:       tt.err,
+				started:   make(chan struct{}, 3),
+			}
+
+			err := warmUp(context.Background(), &w)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("expected %v, got %v", tt.err, err)
+			}
+
+			// Every instance gets a request at the same time.
+			if n := w.chats.Load() + w.embeds.Load(); n != 3 {
+				t.Errorf("expected 3 requests, got %d", n)
+			}
+
+			if tt.embed && w.chats.Load() != 0 {
+				t.Error("expected an embedding model to only get embedding requests")
+			}
+		})
+	}
+}
+
+func Test_LoadModel(t *testing.T) {
+	c, _ := newTestCache(t, time.Hour)
+
+	// A load in progress is shared, so a failed load fails the request.
+	l := newLoad(cacheKey("model", RuntimeConfig{}))
+	c.loads[l.key] = l
+
+	done := make(chan error, 1)
+	go func() {
+		done <- c.LoadModel(context.Background(), "model", RuntimeConfig{})
+	}()
+
+	loadErr := errors.New("load failed")
+	waitBlocked(t, done)
+
+	c.loadMu.Lock()
+	delete(c.loads, l.key)
+	c.loadMu.Unlock()
+
+	l.finish(nil, loadErr)
+
+	if err := <-done; !errors.Is(err, loadErr) {
+		t.Fatalf("expected the load error, got %v", err)
+	}
+
+	// -------------------------------------------------------------------------
+
+	// The request waiting for the load can give up.
+	l = newLoad(cacheKey("model", RuntimeConfig{}))
+	c.loads[l.key] = l
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	if err := c.LoadModel(ctx, "model", RuntimeConfig{}); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected a deadline error, got %v", err)
+	}
+}
+
+func Test_UnloadModel(t *testing.T) {
+	c, unloads := newTestCache(t, time.Hour)
+
+	if _, err := c.UnloadModel(context.Background(), "model"); !errors.Is(err, ErrNotLoaded) {
+		t.Fatalf("expected a not loaded error, got %v", err)
+	}
+
+	// Every variant of the model is unloaded, the other models stay.
+	addTestModel(t, c, "model")
+	addTestModel(t, c, "other")
+
+	rc := RuntimeConfig{ContextWindow: 8192}
+	variant := kronk.Kronk{}
+
+	c.capMu.Lock()
+	c.slots[&slot{key: cacheKey("model", rc), weight: 1, krn: &variant}] = struct{}{}
+	c.capMu.Unlock()
+
+	c.cache.Set(cacheKey("model", rc), &variant)
+	c.itemsInCache.Add(1)
+
+	n, err := c.UnloadModel(context.Background(), "model")
+	if err != nil {
+		t.Fatalf("unload model: %v", err)
+	}
+
+	if n != 2 || len(unloads) != 2 {
+		t.Fatalf("expected 2 variants to be unloaded, got %d and %d unloads", n, len(unloads))
+	}
+
+	if !c.IsLoaded("other") || c.IsLoaded("model") {
+		t.Fatal("expected only the other model to be loaded")
+	}
+}
+
+func Test_UnloadModelLoading(t *testing.T) {
+	c, unloads := newTestCache(t, time.Hour)
+
+	// A model that is still loading is unloaded once the load is done.
+	l := newLoad(cacheKey("model", RuntimeConfig{}))
+	c.loads[l.key] = l
+
+	type result struct {
+		n   int
+		err error
+	}
+
+	done := make(chan result, 1)
+	go func() {
+		n, err := c.UnloadModel(context.Background(), "model")
+		done <- result{n, err}
+	}()
+
+	waitBlocked(t, done)
+
+	krn := addTestModel(t, c, "model")
+
+	c.loadMu.Lock()
+	delete(c.loads, l.key)
+	c.loadMu.Unlock()
+
+	l.finish(krn, nil)
+
+	res := <-done
+	if res.err != nil || res.n != 1 {
+		t.Fatalf("expected the loaded model to be unloaded, got %d %v", res.n, res.err)
+	}
+
+	if got := <-unloads; got != krn {
+		t.Fatal("expected the loaded model to be unloaded")
+	}
+}
+
+// =============================================================================
+
+type testWarmer struct {
+	instances int
+	embed     bool
+	err       error
+	started   chan struct{}
+	chats     atomic.Int32
+	embeds    atomic.Int32
+}
+
+func (w *testWarmer) ModelInfo() model.ModelInfo {
+	return model.ModelInfo{IsEmbedModel: w.embed}
+}
+
+func (w *testWarmer) ModelInstances() int {
+	return w.instances
+}
+
+func (w *testWarmer) Chat(ctx context.Context, d model.D) (model.ChatResponse, error) {
+	w.chats.Add(1)
+	w.wait()
+	return model.ChatResponse{}, w.err
+}
+
+func (w *testWarmer) Embeddings(ctx context.Context, input string) (model.EmbedReponse, error) {
+	w.embeds.Add(1)
+	w.wait()
+	return model.EmbedReponse{}, w.err
+}
+
+// wait returns once every instance has a request, so the test hangs when the
+// requests aren't made at the same time.
+func (w *testWarmer) wait() {
+	w.started <- struct{}{}
+
+	for len(w.started) < w.instances {
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// waitBlocked fails the test when the call already returned.
+func waitBlocked[T any](t *testing.T, done chan T) {
+	t.Helper()
+
+	select {
+	case res := <-done:
+		t.Fatalf("expected the call to wait for the load, got %v", res)
+
+	case <-time.After(50 * time.Millisecond):
+	}
+}
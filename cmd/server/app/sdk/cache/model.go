@@ -1,13 +1,26 @@
 package cache
 
-import "time"
+import (
+	"time"
 
-// ModelDetail provides details for the models in the cache.
+	"github.com/ardanlabs/kronk/sdk/kronk"
+)
+
+// ModelDetail provides details for the models in the cache. A model loaded
+// with different runtime configs has a detail for each variant. The models
+// being loaded are included with their load progress.
 type ModelDetail struct {
 	ID            string
+	ConfigHash    string
+	Config        RuntimeConfig
 	OwnedBy       string
 	ModelFamily   string
 	Size          int64
 	ExpiresAt     time.Time
+	Pinned        bool
 	ActiveStreams int
+	Queued        int
+	Instances     []kronk.InstanceStatus
+	Loading       bool
+	LoadProgress  float32
 }
@@ -0,0 +1,304 @@
+package cache
+
+import (
+	"bytes"
+	"cmp"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/ardanlabs/kronk/sdk/kronk"
+	"github.com/ardanlabs/kronk/sdk/kronk/model"
+	"github.com/ardanlabs/kronk/sdk/tools/configs"
+)
+
+// RuntimeConfig represents the load time settings for a model. A request can
+// provide these settings to override the global and per-model settings. Each
+// unique set of settings for a model is loaded as its own variant in the
+// cache. Values set to their zero value are not applied.
+//
+// Adapters is the set of adapters to load by name. The name of an adapter is
+// the file name without the extension. When empty, all the adapters found
+// next to the model are loaded.
+//
+// The remaining settings are the llama.cpp load and context options
+// documented in model.Config.
+type RuntimeConfig struct {
+	ContextWindow  int      `json:"context_window,omitempty"`
+	NBatch         int      `json:"nbatch,omitempty"`
+	NUBatch        int      `json:"nubatch,omitempty"`
+	NThreads       int      `json:"nthreads,omitempty"`
+	NThreadsBatch  int      `json:"nthreads_batch,omitempty"`
+	Adapters       []string `json:"adapters,omitempty"`
+	UseMmap        *bool    `json:"use_mmap,omitempty"`
+	UseMlock       *bool    `json:"use_mlock,omitempty"`
+	NGPULayers     *int     `json:"ngpu_layers,omitempty"`
+	OffloadKQV     *bool    `json:"offload_kqv,omitempty"`
+	FlashAttention string   `json:"flash_attention,omitempty"`
+	TypeK          string   `json:"type_k,omitempty"`
+	TypeV          string   `json:"type_v,omitempty"`
+	RopeScaling    string   `json:"rope_scaling,omitempty"`
+	RopeFreqBase   float32  `json:"rope_freq_base,omitempty"`
+	RopeFreqScale  float32  `json:"rope_freq_scale,omitempty"`
+	YarnExtFactor  float32  `json:"yarn_ext_factor,omitempty"`
+	YarnAttnFactor float32  `json:"yarn_attn_factor,omitempty"`
+	YarnBetaFast   float32  `json:"yarn_beta_fast,omitempty"`
+	YarnBetaSlow   float32  `json:"yarn_beta_slow,omitempty"`
+	YarnOrigCtx    int      `json:"yarn_orig_ctx,omitempty"`
+	NSeqMax        int      `json:"nseq_max,omitempty"`
+	SWAFull        *bool    `json:"swa_full,omitempty"`
+}
+
+// ParseRuntimeConfig converts the runtime config provided in a request.
+func ParseRuntimeConfig(v any) (RuntimeConfig, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return RuntimeConfig{}, fmt.Errorf("parse-runtime-config: %w", err)
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+
+	var rc RuntimeConfig
+	if err := dec.Decode(&rc); err != nil {
+		return RuntimeConfig{}, fmt.Errorf("parse-runtime-config: %w", err)
+	}
+
+	if err := rc.validate(); err != nil {
+		return RuntimeConfig{}, fmt.Errorf("parse-runtime-config: %w", err)
+	}
+
+	return rc, nil
+}
+
+// validate checks the settings are valid before a model is loaded with them.
+func (rc RuntimeConfig) validate() error {
+	if rc.ContextWindow < 0 || rc.NBatch < 0 || rc.NUBatch < 0 || rc.NThreads < 0 || rc.NThreadsBatch < 0 {
+		return fmt.Errorf("validate: values can't be negative")
+	}
+
+	if err := model.ValidateRuntime(rc.apply(model.Config{})); err != nil {
+		return fmt.Errorf("validate: %w", err)
+	}
+
+	return nil
+}
+
+// override returns the config with the values set in the specified config
+// replacing the values in this config.
+func (rc RuntimeConfig) override(o RuntimeConfig) RuntimeConfig {
+	rc.ContextWindow = cmp.Or(o.ContextWindow, rc.ContextWindow)
+	rc.NBatch = cmp.Or(o.NBatch, rc.NBatch)
+	rc.NUBatch = cmp.Or(o.NUBatch, rc.NUBatch)
+	rc.NThreads = cmp.Or(o.NThreads, rc.NThreads)
+	rc.NThreadsBatch = cmp.Or(o.NThreadsBatch, rc.NThreadsBatch)
+	rc.FlashAttention = cmp.Or(o.FlashAttention, rc.FlashAttention)
+	rc.TypeK = cmp.Or(o.TypeK, rc.TypeK)
+	rc.TypeV = cmp.Or(o.TypeV, rc.TypeV)
+	rc.RopeScaling = cmp.Or(o.RopeScaling, rc.RopeScaling)
+	rc.RopeFreqBase = cmp.Or(o.RopeFreqBase, rc.RopeFreqBase)
+	rc.RopeFreqScale = cmp.Or(o.RopeFreqScale, rc.RopeFreqScale)
+	rc.YarnExtFactor = cmp.Or(o.YarnExtFactor, rc.YarnExtFactor)
+	rc.YarnAttnFactor = cmp.Or(o.YarnAttnFactor, rc.YarnAttnFactor)
+	rc.YarnBetaFast = cmp.Or(o.YarnBetaFast, rc.YarnBetaFast)
+	rc.YarnBetaSlow = cmp.Or(o.YarnBetaSlow, rc.YarnBetaSlow)
+	rc.YarnOrigCtx = cmp.Or(o.YarnOrigCtx, rc.YarnOrigCtx)
+	rc.NSeqMax = cmp.Or(o.NSeqMax, rc.NSeqMax)
+
+	if o.UseMmap != nil {
+		rc.UseMmap = o.UseMmap
+	}
+
+	if o.UseMlock != nil {
+		rc.UseMlock = o.UseMlock
+	}
+
+	if o.NGPULayers != nil {
+		rc.NGPULayers = o.NGPULayers
+	}
+
+	if o.OffloadKQV != nil {
+		rc.OffloadKQV = o.OffloadKQV
+	}
+
+	if o.SWAFull != nil {
+		rc.SWAFull = o.SWAFull
+	}
+
+	if len(o.Adapters) > 0 {
+		rc.Adapters = o.Adapters
+	}
+
+	return rc
+}
+
+// apply returns the model config with the llama.cpp load and context options
+// in this config set.
+func (rc RuntimeConfig) apply(cfg model.Config) model.Config {
+	cfg.ContextWindow = rc.ContextWindow
+	cfg.NBatch = rc.NBatch
+	cfg.NUBatch = rc.NUBatch
+	cfg.NThreads = rc.NThreads
+	cfg.NThreadsBatch = rc.NThreadsBatch
+	cfg.UseMmap = rc.UseMmap
+	cfg.UseMlock = rc.UseMlock != nil && *rc.UseMlock
+	cfg.NGPULayers = rc.NGPULayers
+	cfg.OffloadKQV = rc.OffloadKQV
+	cfg.FlashAttention = rc.FlashAttention
+	cfg.TypeK = rc.TypeK
+	cfg.TypeV = rc.TypeV
+	cfg.RopeScaling = rc.RopeScaling
+	cfg.RopeFreqBase = rc.RopeFreqBase
+	cfg.RopeFreqScale = rc.RopeFreqScale
+	cfg.YarnExtFactor = rc.YarnExtFactor
+	cfg.YarnAttnFactor = rc.YarnAttnFactor
+	cfg.YarnBetaFast = rc.YarnBetaFast
+	cfg.YarnBetaSlow = rc.YarnBetaSlow
+	cfg.YarnOrigCtx = rc.YarnOrigCtx
+	cfg.NSeqMax = rc.NSeqMax
+	cfg.SWAFull = rc.SWAFull != nil && *rc.SWAFull
+
+	return cfg
+}
+
+// normalize returns the config in a form where equal settings have the same
+// representation.
+func (rc RuntimeConfig) normalize() RuntimeConfig {
+	rc.FlashAttention = strings.ToLower(rc.FlashAttention)
+	rc.TypeK = strings.ToLower(rc.TypeK)
+	rc.TypeV = strings.ToLower(rc.TypeV)
+	rc.RopeScaling = strings.ToLower(rc.RopeScaling)
+
+	if len(rc.Adapters) == 0 {
+		rc.Adapters = nil
+		return rc
+	}
+
+	adapters := make([]string, len(rc.Adapters))
+	for i, adapter := range rc.Adapters {
+		adapters[i] = strings.ToLower(adapter)
+	}
+
+	slices.Sort(adapters)
+	rc.Adapters = slices.Compact(adapters)
+
+	return rc
+}
+
+// hash returns a short hash identifying the config.
+func (rc RuntimeConfig) hash() string {
+	data, _ := json.Marshal(rc.normalize())
+	sum := sha256.Sum256(data)
+
+	return hex.EncodeToString(sum[:6])
+}
+
+// selectAdapters returns the adapters to load from the adapter files found
+// next to the model.
+func (rc RuntimeConfig) selectAdapters(adapterFiles []string) ([]model.AdapterConfig, error) {
+	if len(rc.Adapters) == 0 {
+		adapters := make([]model.AdapterConfig, len(adapterFiles))
+		for i, adapterFile := range adapterFiles {
+			adapters[i] = model.AdapterConfig{Path: adapterFile}
+		}
+
+		return adapters, nil
+	}
+
+	adapters := make([]model.AdapterConfig, 0, len(rc.Adapters))
+
+	for _, name := range rc.Adapters {
+		idx := slices.IndexFunc(adapterFiles, func(adapterFile string) bool {
+			return model.MatchAdapter(model.AdapterName(adapterFile), name)
+		})
+
+		if idx == -1 {
+			return nil, fmt.Errorf("select-adapters: adapter %q not found", name)
+		}
+
+		adapters = append(adapters, model.AdapterConfig{Path: adapterFiles[idx]})
+	}
+
+	return adapters, nil
+}
+
+// toRuntimeConfig returns the runtime settings in the model configuration.
+func toRuntimeConfig(mc configs.ModelConfig) RuntimeConfig {
+	return RuntimeConfig{
+		ContextWindow:  mc.ContextWindow,
+		NBatch:         mc.NBatch,
+		NUBatch:        mc.NUBatch,
+		NThreads:       mc.NThreads,
+		NThreadsBatch:  mc.NThreadsBatch,
+		Adapters:       mc.Adapters,
+		UseMmap:        mc.UseMmap,
+		UseMlock:       mc.UseMlock,
+		NGPULayers:     mc.NGPULayers,
+		OffloadKQV:     mc.OffloadKQV,
+		FlashAttention: mc.FlashAttention,
+		TypeK:          mc.TypeK,
+		TypeV:          mc.TypeV,
+		RopeScaling:    mc.RopeScaling,
+		RopeFreqBase:   mc.RopeFreqBase,
+		RopeFreqScale:  mc.RopeFreqScale,
+		YarnExtFactor:  mc.YarnExtFactor,
+		YarnAttnFactor: mc.YarnAttnFactor,
+		YarnBetaFast:   mc.YarnBetaFast,
+		YarnBetaSlow:   mc.YarnBetaSlow,
+		YarnOrigCtx:    mc.YarnOrigCtx,
+		NSeqMax:        mc.NSeqMax,
+		SWAFull:        mc.SWAFull,
+	}
+}
+
+// effectiveConfig returns the settings the model was loaded with.
+func effectiveConfig(krn *kronk.Kronk) RuntimeConfig {
+	cfg := krn.ModelConfig()
+
+	return RuntimeConfig{
+		ContextWindow:  cfg.ContextWindow,
+		NBatch:         cfg.NBatch,
+		NUBatch:        cfg.NUBatch,
+		NThreads:       cfg.NThreads,
+		NThreadsBatch:  cfg.NThreadsBatch,
+		Adapters:       krn.ModelInfo().Adapters,
+		UseMmap:        cfg.UseMmap,
+		UseMlock:       &cfg.UseMlock,
+		NGPULayers:     cfg.NGPULayers,
+		OffloadKQV:     cfg.OffloadKQV,
+		FlashAttention: cfg.FlashAttention,
+		TypeK:          cfg.TypeK,
+		TypeV:          cfg.TypeV,
+		RopeScaling:    cfg.RopeScaling,
+		RopeFreqBase:   cfg.RopeFreqBase,
+		RopeFreqScale:  cfg.RopeFreqScale,
+		YarnExtFactor:  cfg.YarnExtFactor,
+		YarnAttnFactor: cfg.YarnAttnFactor,
+		YarnBetaFast:   cfg.YarnBetaFast,
+		YarnBetaSlow:   cfg.YarnBetaSlow,
+		YarnOrigCtx:    cfg.YarnOrigCtx,
+		NSeqMax:        cfg.NSeqMax,
+		SWAFull:        &cfg.SWAFull,
+	}
+}
+
+// =============================================================================
+
+// cacheKey returns the key for the variant of the model loaded with the
+// specified config.
+func cacheKey(modelID string, rc RuntimeConfig) string {
+	return modelID + "/" + rc.hash()
+}
+
+// splitKey returns the model id and config hash for a cache key.
+func splitKey(key string) (modelID string, hash string) {
+	idx := strings.LastIndex(key, "/")
+	if idx == -1 {
+		return key, ""
+	}
+
+	return key[:idx], key[idx+1:]
+}
@@ -0,0 +1,130 @@
+package cache
+
+import (
+	"testing"
+
+	"github.com/ardanlabs/kronk/sdk/kronk/model"
+)
+
+func Test_RuntimeConfig(t *testing.T) {
+	t.Run("parse", func(t *testing.T) {
+		rc, err := ParseRuntimeConfig(map[string]any{
+			"context_window": 8192.0,
+			"adapters":       []any{"lora-b", "lora-a"},
+		})
+		if err != nil {
+			t.Fatalf("parse: %v", err)
+		}
+
+		if rc.ContextWindow != 8192 || len(rc.Adapters) != 2 {
+			t.Errorf("unexpected config: %+v", rc)
+		}
+
+		if _, err := ParseRuntimeConfig(map[string]any{"context_windows": 8192}); err == nil {
+			t.Error("expected an error for an unknown field")
+		}
+
+		if _, err := ParseRuntimeConfig(map[string]any{"nbatch": -1}); err == nil {
+			t.Error("expected an error for a negative value")
+		}
+
+		if _, err := ParseRuntimeConfig(map[string]any{"type_v": "q4_0", "flash_attention": "disabled"}); err == nil {
+			t.Error("expected an error for a quantized v cache without flash attention")
+		}
+
+		rc, err = ParseRuntimeConfig(map[string]any{"use_mmap": false, "ngpu_layers": 0})
+		if err != nil {
+			t.Fatalf("parse: %v", err)
+		}
+
+		if rc.UseMmap == nil || *rc.UseMmap || rc.NGPULayers == nil || *rc.NGPULayers != 0 {
+			t.Errorf("expected explicit false and zero values to be kept: %+v", rc)
+		}
+	})
+
+	t.Run("hash", func(t *testing.T) {
+		rc1 := RuntimeConfig{ContextWindow: 8192, Adapters: []string{"lora-b", "LORA-A", "lora-b"}}
+		rc2 := RuntimeConfig{ContextWindow: 8192, Adapters: []string{"lora-a", "lora-b"}}
+		rc3 := RuntimeConfig{ContextWindow: 4096, Adapters: []string{"lora-a", "lora-b"}}
+
+		if rc1.hash() != rc2.hash() {
+			t.Error("expected equal configs to have the same hash")
+		}
+
+		if rc1.hash() == rc3.hash() {
+			t.Error("expected different configs to have different hashes")
+		}
+
+		if (RuntimeConfig{}).hash() != (RuntimeConfig{Adapters: []string{}}).hash() {
+			t.Error("expected an empty adapter set to match no adapters")
+		}
+
+		modelID, hash := splitKey(cacheKey("qwen3-8b-q8_0", rc1))
+		if modelID != "qwen3-8b-q8_0" || hash != rc1.hash() {
+			t.Errorf("unexpected key parts: %s %s", modelID, hash)
+		}
+	})
+
+	t.Run("override", func(t *testing.T) {
+		rc := RuntimeConfig{ContextWindow: 32768, NBatch: 1024}.
+			override(RuntimeConfig{ContextWindow: 512}).
+			override(RuntimeConfig{Adapters: []string{"lora-a"}})
+
+		exp := RuntimeConfig{ContextWindow: 512, NBatch: 1024, Adapters: []string{"lora-a"}}
+		if rc.hash() != exp.hash() {
+			t.Errorf("expected %+v, got %+v", exp, rc)
+		}
+
+		useMmap := false
+		rc = RuntimeConfig{TypeK: "f16"}.override(RuntimeConfig{TypeK: "Q8_0", UseMmap: &useMmap})
+
+		exp = RuntimeConfig{TypeK: "q8_0", UseMmap: &useMmap}
+		if rc.hash() != exp.hash() {
+			t.Errorf("expected %+v, got %+v", exp, rc)
+		}
+
+		// An option enabled globally can be disabled by the model config or
+		// the request, and is kept when they don't set it.
+		enabled, disabled := true, false
+		global := RuntimeConfig{UseMlock: &enabled, SWAFull: &enabled}
+
+		cfg := global.override(RuntimeConfig{UseMlock: &disabled}).override(RuntimeConfig{SWAFull: &disabled}).apply(model.Config{})
+		if cfg.UseMlock || cfg.SWAFull {
+			t.Errorf("expected the options to be disabled: %+v", cfg)
+		}
+
+		cfg = global.override(RuntimeConfig{}).apply(model.Config{})
+		if !cfg.UseMlock || !cfg.SWAFull {
+			t.Errorf("expected the options to stay enabled: %+v", cfg)
+		}
+
+		if global.hash() == global.override(RuntimeConfig{UseMlock: &disabled}).hash() {
+			t.Error("expected a disabled option to change the hash")
+		}
+	})
+
+	t.Run("adapters", func(t *testing.T) {
+		files := []string{"/models/org/lora-a.gguf", "/models/org/lora-b.gguf"}
+
+		adapters, err := RuntimeConfig{}.selectAdapters(files)
+		if err != nil || len(adapters) != 2 {
+			t.Fatalf("expected all adapters, got %v %v", adapters, err)
+		}
+
+		adapters, err = RuntimeConfig{Adapters: []string{"LORA-B"}}.selectAdapters(files)
+		if err != nil || len(adapters) != 1 || adapters[0].Path != files[1] {
+			t.Fatalf("expected lora-b, got %v %v", adapters, err)
+		}
+
+		// The name is resolved like the lora field of a request, without the
+		// "lora-" prefix of the adapter file.
+		adapters, err = RuntimeConfig{Adapters: []string{"a"}}.selectAdapters(files)
+		if err != nil || len(adapters) != 1 || adapters[0].Path != files[0] {
+			t.Fatalf("expected lora-a, got %v %v", adapters, err)
+		}
+
+		if _, err := (RuntimeConfig{Adapters: []string{"lora-c"}}).selectAdapters(files); err == nil {
+			t.Error("expected an error for an unknown adapter")
+		}
+	})
+}
@@ -4,7 +4,11 @@ package errs
 import (
 	"encoding/json"
 	"fmt"
+	"math"
+	"net/http"
 	"runtime"
+	"strconv"
+	"time"
 )
 
 // ErrCode represents an error code in the system.
@@ -50,10 +54,11 @@ func (ec ErrCode) Equal(ec2 ErrCode) bool {
 
 // Error represents an error in the system.
 type Error struct {
-	Code     ErrCode `json:"code"`
-	Message  string  `json:"message"`
-	FuncName string  `json:"-"`
-	FileName string  `json:"-"`
+	Code       ErrCode       `json:"code"`
+	Message    string        `json:"message"`
+	FuncName   string        `json:"-"`
+	FileName   string        `json:"-"`
+	RetryAfter time.Duration `json:"-"`
 }
 
 // New constructs an error based on an app error.
@@ -97,6 +102,19 @@ func (e *Error) HTTPStatus() int {
 	return httpStatus[e.Code]
 }
 
+// HTTPHeader implements the web package httpHeader interface so the web
+// framework can set the Retry-After header when the client should retry
+// the request later.
+func (e *Error) HTTPHeader() http.Header {
+	if e.RetryAfter <= 0 {
+		return nil
+	}
+
+	seconds := int64(math.Ceil(e.RetryAfter.Seconds()))
+
+	return http.Header{"Retry-After": []string{strconv.FormatInt(seconds, 10)}}
+}
+
 // Equal provides support for the go-cmp package and testing.
 func (e *Error) Equal(e2 *Error) bool {
 	return e.Code == e2.Code && e.Message == e2.Message
@@ -0,0 +1,85 @@
+// Package inference provides support shared by the app layers that run
+// requests against the models in the cache.
+package inference
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/ardanlabs/kronk/cmd/server/app/sdk/authclient"
+	"github.com/ardanlabs/kronk/cmd/server/app/sdk/cache"
+	"github.com/ardanlabs/kronk/cmd/server/app/sdk/errs"
+	"github.com/ardanlabs/kronk/cmd/server/app/sdk/mid"
+	"github.com/ardanlabs/kronk/sdk/kronk"
+	"github.com/ardanlabs/kronk/sdk/kronk/model"
+)
+
+// RuntimeConfig returns the runtime config provided with the request. The
+// token must have access to the runtime-config endpoint to provide one.
+func RuntimeConfig(ctx context.Context, authClient *authclient.Client, r *http.Request, req model.D) (cache.RuntimeConfig, *errs.Error) {
+	v, exists := req["runtime_config"]
+	if !exists {
+		return cache.RuntimeConfig{}, nil
+	}
+
+	delete(req, "runtime_config")
+
+	if _, err := authClient.Authenticate(ctx, r.Header.Get("authorization"), false, "runtime-config"); err != nil {
+		return cache.RuntimeConfig{}, errs.Errorf(errs.PermissionDenied, "runtime config not allowed: %s", err)
+	}
+
+	rc, err := cache.ParseRuntimeConfig(v)
+	if err != nil {
+		return cache.RuntimeConfig{}, errs.New(errs.InvalidArgument, err)
+	}
+
+	return rc, nil
+}
+
+// Schedule returns a context that queues the request for the authenticated
+// subject at the priority provided with the request.
+func Schedule(ctx context.Context, req model.D) (context.Context, *errs.Error) {
+	ctx = kronk.WithSubject(ctx, mid.GetSubject(ctx))
+
+	v, exists := req["priority"]
+	if !exists {
+		return ctx, nil
+	}
+
+	delete(req, "priority")
+
+	name, ok := v.(string)
+	if !ok {
+		return ctx, errs.Errorf(errs.InvalidArgument, "priority must be a string")
+	}
+
+	priority, err := kronk.ParsePriority(name)
+	if err != nil {
+		return ctx, errs.New(errs.InvalidArgument, err)
+	}
+
+	return kronk.WithPriority(ctx, priority), nil
+}
+
+// AquireError maps an error from acquiring a model to an app error.
+func AquireError(err error) *errs.Error {
+	if errors.Is(err, cache.ErrCacheFull) || errors.Is(err, cache.ErrMemoryBudget) {
+		return errs.New(errs.ResourceExhausted, err)
+	}
+
+	return errs.New(errs.InvalidArgument, err)
+}
+
+// Error maps an error from running a request against a model to an app
+// error. A full queue tells the client when to retry.
+func Error(err error) *errs.Error {
+	var qfErr *kronk.QueueFullError
+	if errors.As(err, &qfErr) {
+		appErr := errs.New(errs.TooManyRequests, err)
+		appErr.RetryAfter = qfErr.RetryAfter
+		return appErr
+	}
+
+	return errs.New(errs.Internal, err)
+}
@@ -14,6 +14,7 @@ import (
 	"github.com/ardanlabs/kronk/sdk/tools/catalog"
 	"github.com/ardanlabs/kronk/sdk/tools/libs"
 	"github.com/ardanlabs/kronk/sdk/tools/models"
+	"github.com/ardanlabs/kronk/sdk/tools/sessions"
 	"github.com/ardanlabs/kronk/sdk/tools/templates"
 	"go.opentelemetry.io/otel/trace"
 )
@@ -60,6 +61,7 @@ type Config struct {
 	Cache      *cache.Cache
 	Libs       *libs.Libs
 	Models     *models.Models
+	Sessions   *sessions.Sessions
 	Catalog    *catalog.Catalog
 	Templates  *templates.Templates
 }
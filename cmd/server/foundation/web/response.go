@@ -29,6 +29,10 @@ type httpStatus interface {
 	HTTPStatus() int
 }
 
+type httpHeader interface {
+	HTTPHeader() http.Header
+}
+
 // Respond sends a response to the client.
 func Respond(ctx context.Context, w http.ResponseWriter, resp Encoder) error {
 	if _, ok := resp.(NoResponse); ok {
@@ -61,6 +65,14 @@ func Respond(ctx context.Context, w http.ResponseWriter, resp Encoder) error {
 	_, span := addSpan(ctx, "web.send.response", attribute.Int("status", statusCode))
 	defer span.End()
 
+	if v, ok := resp.(httpHeader); ok {
+		for key, values := range v.HTTPHeader() {
+			for _, value := range values {
+				w.Header().Add(key, value)
+			}
+		}
+	}
+
 	if statusCode == http.StatusNoContent {
 		w.WriteHeader(statusCode)
 		return nil
@@ -10,6 +10,7 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"os"
 	"time"
@@ -78,20 +79,18 @@ func run() error {
 		"max_tokens":  2048,
 	}
 
-	ch, err := krn.ChatStreaming(ctx, d)
-	if err != nil {
-		return fmt.Errorf("chat streaming: %w", err)
-	}
+	var reasoning bool
 
-	// -------------------------------------------------------------------------
+	for resp, err := range krn.ChatStreamingSeq(ctx, d) {
+		switch {
+		case errors.Is(err, kronk.ErrContextWindowExceeded):
+			return fmt.Errorf("question is too long for the model: %w", err)
 
-	var reasoning bool
+		case err != nil:
+			return fmt.Errorf("chat streaming: %w", err)
+		}
 
-	for resp := range ch {
 		switch resp.Choice[0].FinishReason {
-		case model.FinishReasonError:
-			return fmt.Errorf("error from model: %s", resp.Choice[0].Delta.Content)
-
 		case model.FinishReasonStop:
 			return nil
 
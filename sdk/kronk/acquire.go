@@ -3,17 +3,21 @@ package kronk
 import (
 	"context"
 	"fmt"
+	"time"
 
-	"github.com/ardanlabs/kronk/sdk/kronk/model"
+	"github.com/ardanlabs/kronk/sdk/observ/metrics"
 )
 
-func (krn *Kronk) acquireModel(ctx context.Context) (*model.Model, error) {
+// acquireModel waits in the queue for an instance of the model. The subject
+// and priority of the request are taken from the context. The time spent
+// waiting is returned.
+func (krn *Kronk) acquireModel(ctx context.Context) (*instance, time.Duration, error) {
 	err := func() error {
 		krn.shutdown.Lock()
 		defer krn.shutdown.Unlock()
 
 		if krn.shutdownFlag {
-			return fmt.Errorf("acquire-model:kronk has been unloaded")
+			return fmt.Errorf("acquire-model: %w", ErrUnloaded)
 		}
 
 		krn.activeStreams.Add(1)
@@ -21,27 +25,60 @@ func (krn *Kronk) acquireModel(ctx context.Context) (*model.Model, error) {
 	}()
 
 	if err != nil {
-		return nil, err
+		return nil, 0, err
 	}
 
 	// -------------------------------------------------------------------------
 
-	select {
-	case <-ctx.Done():
+	inst, wait, err := krn.sched.acquire(ctx, getSubject(ctx), getPriority(ctx))
+	if err != nil {
 		krn.activeStreams.Add(-1)
-		return nil, ctx.Err()
+		return nil, wait, fmt.Errorf("acquire-model: %w", err)
+	}
+
+	metrics.AddQueueWaitTime(wait)
+
+	return inst, wait, nil
+}
+
+// releaseModel gives the instance back with the result of the request so
+// the health of the instance can be tracked.
+func (krn *Kronk) releaseModel(inst *instance, err error, panicked bool) {
+	krn.sched.release(inst, err, panicked)
+	krn.activeStreams.Add(-1)
+}
+
+// borrowModel returns an instance of the model for work that only reads the
+// model without waiting in the queue. The instance counts as an active
+// stream so the model isn't unloaded until it's returned.
+func (krn *Kronk) borrowModel() (*instance, error) {
+	err := func() error {
+		krn.shutdown.Lock()
+		defer krn.shutdown.Unlock()
 
-	case llama, ok := <-krn.models:
-		if !ok {
-			krn.activeStreams.Add(-1)
-			return nil, fmt.Errorf("acquire-model:kronk has been unloaded")
+		if krn.shutdownFlag {
+			return fmt.Errorf("borrow-model: %w", ErrUnloaded)
 		}
 
-		return llama, nil
+		krn.activeStreams.Add(1)
+		return nil
+	}()
+
+	if err != nil {
+		return nil, err
+	}
+
+	inst, err := krn.sched.borrow()
+	if err != nil {
+		krn.activeStreams.Add(-1)
+		return nil, fmt.Errorf("borrow-model: %w", err)
 	}
+
+	return inst, nil
 }
 
-func (krn *Kronk) releaseModel(llama *model.Model) {
-	krn.models <- llama
+// returnModel gives back a borrowed instance.
+func (krn *Kronk) returnModel(inst *instance) {
+	krn.sched.giveBack(inst)
 	krn.activeStreams.Add(-1)
 }
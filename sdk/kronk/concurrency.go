@@ -2,32 +2,50 @@ package kronk
 
 import (
 	"context"
+	"errors"
 	"fmt"
 
 	"github.com/ardanlabs/kronk/sdk/kronk/model"
 )
 
-type nonStreamingFunc[T any] func(llama *model.Model) (T, error)
+// nonStreamingFunc is called with a context that carries the time the request
+// waited in the queue so it can be reported in the usage.
+type nonStreamingFunc[T any] func(ctx context.Context, llama *model.Model) (T, error)
 
-func nonStreaming[T any](ctx context.Context, krn *Kronk, f nonStreamingFunc[T]) (T, error) {
-	var zero T
-
-	llama, err := krn.acquireModel(ctx)
+func nonStreaming[T any](ctx context.Context, krn *Kronk, f nonStreamingFunc[T]) (resp T, err error) {
+	inst, wait, err := krn.acquireModel(ctx)
 	if err != nil {
-		return zero, err
+		return resp, err
 	}
-	defer krn.releaseModel(llama)
 
-	return f(llama)
+	// The result of the call is recorded against the instance so an
+	// instance that keeps failing or panics is replaced.
+	defer func() {
+		failed := err
+		if failed == nil {
+			failed = responseFailure(resp)
+		}
+
+		var panicked bool
+		if rec := recover(); rec != nil {
+			panicked = true
+			err = fmt.Errorf("panic: %v", rec)
+			failed = err
+		}
+
+		krn.releaseModel(inst, failed, panicked)
+	}()
+
+	return f(model.WithQueueWait(ctx, wait), inst.llama)
 }
 
 // =============================================================================
 
-type streamingFunc[T any] func(llama *model.Model) <-chan T
+type streamingFunc[T any] func(ctx context.Context, llama *model.Model) <-chan T
 type errorFunc[T any] func(err error) T
 
 func streaming[T any](ctx context.Context, krn *Kronk, f streamingFunc[T], ef errorFunc[T]) (<-chan T, error) {
-	llama, err := krn.acquireModel(ctx)
+	inst, wait, err := krn.acquireModel(ctx)
 	if err != nil {
 		return nil, err
 	}
@@ -35,27 +53,72 @@ func streaming[T any](ctx context.Context, krn *Kronk, f streamingFunc[T], ef er
 	ch := make(chan T)
 
 	go func() {
+		// The model reports failures as error responses, so the last one
+		// is recorded against the instance.
+		var failed error
+
 		defer func() {
+			var panicked bool
+
 			if rec := recover(); rec != nil {
 				sendError(ctx, ch, ef, rec)
+				failed = fmt.Errorf("panic: %v", rec)
+				panicked = true
 			}
 
+			// The instance is released before the channel is closed so
+			// it's available again once the caller sees the end of the
+			// stream.
+			krn.releaseModel(inst, failed, panicked)
 			close(ch)
-			krn.releaseModel(llama)
 		}()
 
-		lch := f(llama)
+		lch := f(model.WithQueueWait(ctx, wait), inst.llama)
 
 		for msg := range lch {
+			if err := responseFailure(msg); err != nil {
+				failed = err
+			}
+
 			if err := sendMessage(ctx, ch, msg); err != nil {
 				break
 			}
 		}
+
+		// The model stops generating once the context is done, so wait for
+		// it to finish with the instance before it's released.
+		for msg := range lch {
+			if err := responseFailure(msg); err != nil {
+				failed = err
+			}
+		}
 	}()
 
 	return ch, nil
 }
 
+// responseFailure returns the error of a response that reports a failure of
+// the model. Errors caused by the request, like a prompt that doesn't fit in
+// the context window or a canceled request, don't count against the model.
+func responseFailure[T any](msg T) error {
+	resp, ok := any(msg).(interface{ Err() error })
+	if !ok {
+		return nil
+	}
+
+	err := resp.Err()
+
+	switch {
+	case err == nil,
+		errors.Is(err, model.ErrContextWindowExceeded),
+		errors.Is(err, context.Canceled),
+		errors.Is(err, context.DeadlineExceeded):
+		return nil
+	}
+
+	return err
+}
+
 func sendMessage[T any](ctx context.Context, ch chan T, msg T) error {
 	// I want to try and send this message before we check the context.
 	// Remember the user code might not be trying to receive on this
@@ -0,0 +1,90 @@
+package kronk
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/ardanlabs/kronk/sdk/kronk/model"
+)
+
+func Test_ChatFailures(t *testing.T) {
+	reloaded := &model.Model{}
+
+	load := func(device string) (*model.Model, error) {
+		return reloaded, nil
+	}
+
+	krn := Kronk{sched: newScheduler([]*model.Model{{}}, 0, Scaling{}, nil, load, nil)}
+	krn.sched.unload = func(ctx context.Context, llama *model.Model) error {
+		return nil
+	}
+
+	// The model reports failures as error responses instead of errors.
+	respond := func(err error) model.ChatResponse {
+		return model.ChatResponseErr("id", model.ObjectChatText, "model", 0, "", err, model.Usage{})
+	}
+
+	chat := func(respErr error) {
+		f := func(ctx context.Context, m *model.Model) (model.ChatResponse, error) {
+			return respond(respErr), nil
+		}
+
+		if _, err := nonStreaming(context.Background(), &krn, f); err != nil {
+			t.Fatalf("chat: %v", err)
+		}
+	}
+
+	chatStreaming := func(respErr error) {
+		f := func(ctx context.Context, m *model.Model) <-chan model.ChatResponse {
+			ch := make(chan model.ChatResponse, 2)
+			ch <- model.ChatResponse{}
+			ch <- respond(respErr)
+			close(ch)
+
+			return ch
+		}
+
+		ch, err := streaming(context.Background(), &krn, f, respond)
+		if err != nil {
+			t.Fatalf("chat streaming: %v", err)
+		}
+
+		for range ch {
+		}
+	}
+
+	// Errors caused by the request don't count against the instance.
+	windowErr := fmt.Errorf("process-chat-request: %w", model.ErrContextWindowExceeded)
+
+	for range maxInstanceFailures {
+		chat(windowErr)
+		chatStreaming(context.Canceled)
+	}
+
+	if status := krn.sched.status()[0]; status.Failures != 0 || status.Reloads != 0 {
+		t.Fatalf("expected the request errors to be ignored, got %+v", status)
+	}
+
+	// An instance that keeps failing chats is quarantined and reloaded.
+	for i := range maxInstanceFailures {
+		if i%2 == 0 {
+			chat(errors.New("decode failed"))
+			continue
+		}
+
+		chatStreaming(errors.New("decode failed"))
+	}
+
+	krn.sched.background.Wait()
+
+	status := krn.sched.status()[0]
+	if status.State != InstanceIdle || status.Reloads != 1 || status.LastError != "decode failed" {
+		t.Fatalf("expected the instance to be reloaded, got %+v", status)
+	}
+
+	if krn.sched.instances[0].llama != reloaded {
+		t.Fatal("expected the instance to use the reloaded model")
+	}
+}
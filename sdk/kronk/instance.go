@@ -0,0 +1,120 @@
+package kronk
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/ardanlabs/kronk/sdk/kronk/model"
+)
+
+// ErrNoHealthyInstances is returned when every instance of the model failed
+// to reload and there is no instance left to handle the request.
+var ErrNoHealthyInstances = errors.New("no healthy instances of the model")
+
+// Set of states an instance of the model can be in.
+const (
+	InstanceIdle        = "idle"
+	InstanceBusy        = "busy"
+	InstanceQuarantined = "quarantined"
+	InstanceReloading   = "reloading"
+	InstanceFailed      = "failed"
+)
+
+const (
+	// maxInstanceFailures is the number of requests in a row that can fail
+	// on an instance before it's quarantined and reloaded.
+	maxInstanceFailures = 3
+
+	// maxReloadAttempts is the number of times a quarantined instance is
+	// reloaded before it's marked as failed.
+	maxReloadAttempts = 3
+
+	// reloadBackoff is the time to wait between attempts to reload an
+	// instance.
+	reloadBackoff = 5 * time.Second
+)
+
+// InstanceStatus represents the state and health of an instance of the model.
+type InstanceStatus struct {
+	ID        int
+	Device    string
+	State     string
+	Requests  int
+	Failures  int
+	Panics    int
+	Reloads   int
+	BusyTime  time.Duration
+	LastError string
+}
+
+// instance represents an instance of the model and tracks its health. The
+// fields are protected by the scheduler's mu lock. Instances added by scaling
+// have a release function to give back the memory reserved for them.
+type instance struct {
+	id        int
+	device    string
+	llama     *model.Model
+	state     string
+	acquired  time.Time
+	idleSince time.Time
+	panicsAt  int
+	requests  int
+	failures  int
+	panics    int
+	reloads   int
+	busyTime  time.Duration
+	lastErr   string
+	release   func()
+	borrowed  int
+}
+
+// record updates the health of the instance with the result of a request.
+// True is returned when the instance needs to be quarantined. A panic always
+// quarantines the instance since the state of the model can't be trusted.
+func (inst *instance) record(err error, panicked bool) bool {
+	inst.requests++
+	inst.busyTime += time.Since(inst.acquired)
+
+	// The model recovers from panics in its own goroutines, so they are
+	// detected by the model's panic count changing.
+	if inst.llama.Panics() > inst.panicsAt {
+		panicked = true
+	}
+
+	switch {
+	case panicked:
+		inst.panics++
+		inst.failures++
+		inst.lastErr = "panic"
+		if err != nil {
+			inst.lastErr = "panic: " + err.Error()
+		}
+
+		return true
+
+	case err != nil && !errors.Is(err, context.Canceled) && !errors.Is(err, context.DeadlineExceeded):
+		inst.failures++
+		inst.lastErr = err.Error()
+
+		return inst.failures >= maxInstanceFailures
+
+	default:
+		inst.failures = 0
+		return false
+	}
+}
+
+func (inst *instance) status() InstanceStatus {
+	return InstanceStatus{
+		ID:        inst.id,
+		Device:    inst.device,
+		State:     inst.state,
+		Requests:  inst.requests,
+		Failures:  inst.failures,
+		Panics:    inst.panics,
+		Reloads:   inst.reloads,
+		BusyTime:  inst.busyTime,
+		LastError: inst.lastErr,
+	}
+}
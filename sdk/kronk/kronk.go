@@ -23,7 +23,10 @@ const Version = "1.9.9"
 // =============================================================================
 
 type options struct {
-	tr model.TemplateRetriever
+	tr        model.TemplateRetriever
+	maxQueue  int
+	scaling   Scaling
+	placement Placement
 }
 
 // Option represents a functional option for configuring Kronk.
@@ -37,15 +40,44 @@ func WithTemplateRetriever(templates model.TemplateRetriever) Option {
 	}
 }
 
+// WithMaxQueue sets the maximum number of requests that can wait for an
+// instance of the model. Requests made when the queue is full fail with
+// ErrQueueFull. If not set, the queue has no limit.
+func WithMaxQueue(maxQueue int) Option {
+	return func(o *options) {
+		o.maxQueue = maxQueue
+	}
+}
+
+// WithScaling allows the number of instances of the model to grow up to
+// the maximum when requests wait too long for an instance. Added instances
+// are removed once they have been idle for the cool-down. The instances
+// created by New are the minimum.
+func WithScaling(scaling Scaling) Option {
+	return func(o *options) {
+		o.scaling = scaling
+	}
+}
+
+// WithPlacement spreads the instances of the model across a set of devices
+// using the placement policy. The device in the model config is ignored when
+// devices are provided.
+func WithPlacement(placement Placement) Option {
+	return func(o *options) {
+		o.placement = placement
+	}
+}
+
 // =============================================================================
 
 // Kronk provides a concurrently safe api for using llama.cpp to access models.
 type Kronk struct {
 	cfg           model.Config
-	models        chan *model.Model
+	sched         *scheduler
 	activeStreams atomic.Int32
 	shutdown      sync.Mutex
 	shutdownFlag  bool
+	unloadFailed  []*instance
 	modelInfo     model.ModelInfo
 }
 
@@ -78,33 +110,79 @@ func New(modelInstances int, cfg model.Config, opts ...Option) (*Kronk, error) {
 		o.tr = templates
 	}
 
+	if err := o.placement.validate(); err != nil {
+		return nil, fmt.Errorf("placement: %w", err)
+	}
+
+	place := func(used []string) (string, error) {
+		return o.placement.pick(cfg.Device, used), nil
+	}
+
 	// -------------------------------------------------------------------------
 
-	models := make(chan *model.Model, modelInstances)
-	var firstModel *model.Model
+	models := make([]*model.Model, 0, modelInstances)
+	devices := make([]string, 0, modelInstances)
 
-	for range modelInstances {
-		m, err := model.NewModel(o.tr, cfg)
+	unloadModels := func() {
+		for _, model := range models {
+			placed.remove(model)
+			model.Unload(context.Background())
+		}
+	}
+
+	for i := range modelInstances {
+		device, err := place(devices)
 		if err != nil {
-			close(models)
-			for model := range models {
-				model.Unload(context.Background())
+			unloadModels()
+			return nil, err
+		}
+
+		icfg := cfg
+		icfg.Device = device
+
+		// The instances are loaded one after the other so the progress of
+		// each instance is reported as a part of the overall progress.
+		if cfg.LoadProgress != nil {
+			icfg.LoadProgress = func(progress float32) {
+				cfg.LoadProgress((float32(i) + progress) / float32(modelInstances))
 			}
+		}
 
+		m, err := model.NewModel(o.tr, icfg)
+		if err != nil {
+			unloadModels()
 			return nil, err
 		}
 
-		models <- m
+		placed.add(m)
+
+		models = append(models, m)
+		devices = append(devices, device)
+	}
+
+	// A quarantined instance is replaced by loading the model again and
+	// scaling adds instances the same way.
+	lcfg := cfg
+	lcfg.LoadProgress = nil
 
-		if firstModel == nil {
-			firstModel = m
+	load := func(device string) (*model.Model, error) {
+		dcfg := lcfg
+		dcfg.Device = device
+
+		m, err := model.NewModel(o.tr, dcfg)
+		if err != nil {
+			return nil, err
 		}
+
+		placed.add(m)
+
+		return m, nil
 	}
 
 	krn := Kronk{
-		cfg:       firstModel.Config(),
-		models:    models,
-		modelInfo: firstModel.ModelInfo(),
+		cfg:       models[0].Config(),
+		sched:     newScheduler(models, o.maxQueue, o.scaling, place, load, cfg.Log),
+		modelInfo: models[0].ModelInfo(),
 	}
 
 	return &krn, nil
@@ -151,13 +229,33 @@ func (krn *Kronk) ModelInfo() model.ModelInfo {
 	return krn.modelInfo
 }
 
+// ModelInstances returns the number of instances of the model that are not
+// failed. With scaling, this changes with the load.
+func (krn *Kronk) ModelInstances() int {
+	return krn.sched.size()
+}
+
+// QueuedRequests returns the number of requests waiting for an instance of
+// the model.
+func (krn *Kronk) QueuedRequests() int {
+	return krn.sched.queueDepth()
+}
+
+// InstanceStatus returns the state and health of every instance of the
+// model. Instances that fail too many requests in a row or panic are
+// quarantined and reloaded.
+func (krn *Kronk) InstanceStatus() []InstanceStatus {
+	return krn.sched.status()
+}
+
 // ActiveStreams returns the number of active streams.
 func (krn *Kronk) ActiveStreams() int {
 	return int(krn.activeStreams.Load())
 }
 
 // Unload will close down all loaded models. You should call this only when you
-// are completely done using the group.
+// are completely done using the group. When some of the models fail to
+// unload, calling Unload again retries them.
 func (krn *Kronk) Unload(ctx context.Context) error {
 	if _, exists := ctx.Deadline(); !exists {
 		var cancel context.CancelFunc
@@ -167,25 +265,33 @@ func (krn *Kronk) Unload(ctx context.Context) error {
 
 	// -------------------------------------------------------------------------
 
-	err := func() error {
+	insts, err := func() ([]*instance, error) {
 		krn.shutdown.Lock()
 		defer krn.shutdown.Unlock()
 
+		// The instances that failed to unload before are unloaded again.
 		if krn.shutdownFlag {
-			return fmt.Errorf("unload:already unloaded")
+			if len(krn.unloadFailed) == 0 {
+				return nil, fmt.Errorf("unload:already unloaded")
+			}
+
+			insts := krn.unloadFailed
+			krn.unloadFailed = nil
+
+			return insts, nil
 		}
 
 		for krn.activeStreams.Load() > 0 {
 			select {
 			case <-ctx.Done():
-				return fmt.Errorf("unload:cannot unload: %d active streams: %w", krn.activeStreams.Load(), ctx.Err())
+				return nil, fmt.Errorf("unload:cannot unload: %d active streams: %w", krn.activeStreams.Load(), ctx.Err())
 
 			case <-time.After(100 * time.Millisecond):
 			}
 		}
 
 		krn.shutdownFlag = true
-		return nil
+		return krn.sched.close(), nil
 	}()
 
 	if err != nil {
@@ -195,14 +301,28 @@ func (krn *Kronk) Unload(ctx context.Context) error {
 	// -------------------------------------------------------------------------
 
 	var sb strings.Builder
+	var failed []*instance
 
-	close(krn.models)
-	for model := range krn.models {
-		if err := model.Unload(ctx); err != nil {
-			sb.WriteString(fmt.Sprintf("unload:failed to unload model: %s: %v\n", model.ModelInfo().ID, err))
+	for _, inst := range insts {
+		if err := krn.sched.unload(ctx, inst.llama); err != nil {
+			sb.WriteString(fmt.Sprintf("unload:failed to unload model: %s: %v\n", inst.llama.ModelInfo().ID, err))
+			failed = append(failed, inst)
+			continue
+		}
+
+		if inst.release != nil {
+			inst.release()
 		}
 	}
 
+	// The instances that failed to unload still use their memory, so the
+	// next call unloads them again.
+	if len(failed) > 0 {
+		krn.shutdown.Lock()
+		krn.unloadFailed = append(krn.unloadFailed, failed...)
+		krn.shutdown.Unlock()
+	}
+
 	if sb.Len() > 0 {
 		return fmt.Errorf("%s", sb.String())
 	}
@@ -216,7 +336,7 @@ func (krn *Kronk) Chat(ctx context.Context, d model.D) (model.ChatResponse, erro
 		return model.ChatResponse{}, fmt.Errorf("chat:context has no deadline, provide a reasonable timeout")
 	}
 
-	f := func(m *model.Model) (model.ChatResponse, error) {
+	f := func(ctx context.Context, m *model.Model) (model.ChatResponse, error) {
 		return m.Chat(ctx, d)
 	}
 
@@ -229,7 +349,7 @@ func (krn *Kronk) ChatStreaming(ctx context.Context, d model.D) (<-chan model.Ch
 		return nil, fmt.Errorf("chat-streaming:context has no deadline, provide a reasonable timeout")
 	}
 
-	f := func(m *model.Model) <-chan model.ChatResponse {
+	f := func(ctx context.Context, m *model.Model) <-chan model.ChatResponse {
 		return m.ChatStreaming(ctx, d)
 	}
 
@@ -322,6 +442,24 @@ func (krn *Kronk) ChatStreamingHTTP(ctx context.Context, w http.ResponseWriter,
 	return lr, nil
 }
 
+// RenderPrompt returns the prompt the chat template creates for the request
+// without running inference. This is useful for debugging chat templates.
+// The prompt is rendered with the compiled template and tokenizer of the
+// model, so the request doesn't wait in the queue for an instance.
+func (krn *Kronk) RenderPrompt(ctx context.Context, d model.D) (model.RenderResponse, error) {
+	if _, exists := ctx.Deadline(); !exists {
+		return model.RenderResponse{}, fmt.Errorf("render-prompt:context has no deadline, provide a reasonable timeout")
+	}
+
+	inst, err := krn.borrowModel()
+	if err != nil {
+		return model.RenderResponse{}, fmt.Errorf("render-prompt: %w", err)
+	}
+	defer krn.returnModel(inst)
+
+	return inst.llama.RenderPrompt(ctx, d)
+}
+
 // Embeddings provides support to interact with an embedding model.
 func (krn *Kronk) Embeddings(ctx context.Context, input string) (model.EmbedReponse, error) {
 	if !krn.ModelInfo().IsEmbedModel {
@@ -332,7 +470,7 @@ func (krn *Kronk) Embeddings(ctx context.Context, input string) (model.EmbedRepo
 		return model.EmbedReponse{}, fmt.Errorf("embed:context has no deadline, provide a reasonable timeout")
 	}
 
-	f := func(m *model.Model) (model.EmbedReponse, error) {
+	f := func(ctx context.Context, m *model.Model) (model.EmbedReponse, error) {
 		return m.Embeddings(ctx, input)
 	}
 
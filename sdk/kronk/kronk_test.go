@@ -0,0 +1,48 @@
+package kronk
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/ardanlabs/kronk/sdk/kronk/model"
+)
+
+func Test_UnloadRetry(t *testing.T) {
+	krn := Kronk{sched: newScheduler([]*model.Model{{}, {}}, 0, Scaling{}, nil, nil, nil)}
+
+	failing := krn.sched.instances[1].llama
+
+	var unloaded []*model.Model
+	krn.sched.unload = func(ctx context.Context, llama *model.Model) error {
+		if llama == failing {
+			return errors.New("free failed")
+		}
+
+		unloaded = append(unloaded, llama)
+		return nil
+	}
+
+	if err := krn.Unload(context.Background()); err == nil {
+		t.Fatal("expected the unload to fail")
+	}
+
+	if len(unloaded) != 1 {
+		t.Fatalf("expected 1 instance to be unloaded, got %d", len(unloaded))
+	}
+
+	// The next call only unloads the instance that failed.
+	failing = nil
+
+	if err := krn.Unload(context.Background()); err != nil {
+		t.Fatalf("unload: %v", err)
+	}
+
+	if len(unloaded) != 2 || unloaded[1] != krn.sched.instances[1].llama {
+		t.Fatalf("expected the failed instance to be unloaded, got %d unloads", len(unloaded))
+	}
+
+	if err := krn.Unload(context.Background()); err == nil {
+		t.Fatal("expected the model to be unloaded already")
+	}
+}
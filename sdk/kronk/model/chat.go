@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/ardanlabs/kronk/sdk/observ/metrics"
@@ -36,6 +37,7 @@ func (m *Model) ChatStreaming(ctx context.Context, d D) <-chan ChatResponse {
 
 		defer func() {
 			if rec := recover(); rec != nil {
+				m.panics.Add(1)
 				m.sendChatError(ctx, ch, id, fmt.Errorf("%v", rec))
 			}
 			close(ch)
@@ -47,6 +49,25 @@ func (m *Model) ChatStreaming(ctx context.Context, d D) <-chan ChatResponse {
 			return
 		}
 
+		adapters, selected, err := parseAdapters(d)
+		if err != nil {
+			m.sendChatError(ctx, ch, "", err)
+			return
+		}
+
+		// When the final message is continued, its content is the start of
+		// the reply and is included in the final response.
+		var prefix string
+		if params.ContinueFinalMessage {
+			msgs, _ := d["messages"].([]D)
+
+			prefix, err = finalMessageContent(msgs)
+			if err != nil {
+				m.sendChatError(ctx, ch, "", err)
+				return
+			}
+		}
+
 		lctx, err := llama.InitFromModel(m.model, m.ctxParams)
 		if err != nil {
 			m.sendChatError(ctx, ch, id, fmt.Errorf("init-from-model: unable to init model: %w", err))
@@ -58,6 +79,25 @@ func (m *Model) ChatStreaming(ctx context.Context, d D) <-chan ChatResponse {
 			llama.Free(lctx)
 		}()
 
+		applied, err := m.applyAdapters(lctx, adapters, selected)
+		if err != nil {
+			m.sendChatError(ctx, ch, id, err)
+			return
+		}
+
+		session, err := m.parseSession(d, applied)
+		if err != nil {
+			m.sendChatError(ctx, ch, id, err)
+			return
+		}
+
+		release, err := m.startSession(session, lctx)
+		if err != nil {
+			m.sendChatError(ctx, ch, id, err)
+			return
+		}
+		defer release()
+
 		var mtmdCtx mtmd.Context
 
 		if m.projFile != "" {
@@ -78,38 +118,22 @@ func (m *Model) ChatStreaming(ctx context.Context, d D) <-chan ChatResponse {
 
 		// ---------------------------------------------------------------------
 
-		chatMessages, ok, err := isOpenAIMediaRequest(d)
+		prompt, media, err := m.createPrompt(ctx, d, params)
 		if err != nil {
-			m.sendChatError(ctx, ch, id, fmt.Errorf("is-open-ai-media-request: unable to check is document is openai request: %w", err))
+			m.sendChatError(ctx, ch, id, err)
 			return
 		}
 
-		if ok {
-			d, err = toMediaMessage(d, chatMessages)
-			if err != nil {
-				m.sendChatError(ctx, ch, id, fmt.Errorf("to-media-message: unable to convert document to media message: %w", err))
-				return
-			}
-		}
-
-		// ---------------------------------------------------------------------
-
-		// OTEL: WANT TO KNOW HOW LONG THESE FUNCTION CALLS TAKES
-		start := time.Now()
-
-		prompt, media, err := m.applyRequestJinjaTemplate(ctx, d)
-		if err != nil {
-			m.sendChatError(ctx, ch, id, fmt.Errorf("apply-request-jinja-template: unable to apply jinja template: %w", err))
-			return
-		}
-
-		metrics.AddPromptCreationTime(time.Since(start))
-
 		object := ObjectChatText
 
 		if len(media) > 0 {
 			object = ObjectChatMedia
 
+			if session != nil {
+				m.sendChatError(ctx, ch, id, errors.New("chat-streaming: sessions are not supported for media requests"))
+				return
+			}
+
 			bitmap, err := m.processBitmap(lctx, mtmdCtx, prompt, media)
 			if err != nil {
 				m.sendChatError(ctx, ch, id, err)
@@ -123,12 +147,88 @@ func (m *Model) ChatStreaming(ctx context.Context, d D) <-chan ChatResponse {
 			}()
 		}
 
-		m.processChatRequest(ctx, id, lctx, object, prompt, params, ch)
+		m.processChatRequest(ctx, id, lctx, object, prompt, prefix, params, session, ch)
 	}()
 
 	return ch
 }
 
+// RenderPrompt returns the prompt the chat template creates for the request
+// without running inference. The prompt is the same one Chat would process.
+func (m *Model) RenderPrompt(ctx context.Context, d D) (RenderResponse, error) {
+	params, err := m.validateDocument(d)
+	if err != nil {
+		return RenderResponse{}, err
+	}
+
+	if params.ContinueFinalMessage {
+		msgs, _ := d["messages"].([]D)
+
+		if _, err := finalMessageContent(msgs); err != nil {
+			return RenderResponse{}, err
+		}
+	}
+
+	prompt, media, err := m.createPrompt(ctx, d, params)
+	if err != nil {
+		return RenderResponse{}, err
+	}
+
+	resp := RenderResponse{
+		Model:  m.modelInfo.ID,
+		Prompt: prompt,
+		Tokens: len(llama.Tokenize(m.vocab, prompt, true, true)),
+	}
+
+	if len(media) > 0 {
+		resp.MediaMarker = mtmd.DefaultMarker()
+		resp.Media = make([]MediaMarker, 0, len(media))
+
+		var offset int
+		for _, med := range media {
+			idx := strings.Index(prompt[offset:], resp.MediaMarker)
+			if idx == -1 {
+				break
+			}
+
+			offset += idx
+			resp.Media = append(resp.Media, MediaMarker{Offset: offset, Size: len(med)})
+			offset += len(resp.MediaMarker)
+		}
+	}
+
+	return resp, nil
+}
+
+// createPrompt converts the request into the prompt for the model using the
+// chat template. The media for the request is returned in the order of the
+// media markers in the prompt.
+func (m *Model) createPrompt(ctx context.Context, d D, params Params) (string, [][]byte, error) {
+	chatMessages, ok, err := isOpenAIMediaRequest(d)
+	if err != nil {
+		return "", nil, fmt.Errorf("is-open-ai-media-request: unable to check is document is openai request: %w", err)
+	}
+
+	if ok {
+		d, err = toMediaMessage(ctx, d, chatMessages, m.cfg.MediaFetcher)
+		if err != nil {
+			return "", nil, fmt.Errorf("to-media-message: unable to convert document to media message: %w", err)
+		}
+	}
+
+	// OTEL: WANT TO KNOW HOW LONG THESE FUNCTION CALLS TAKES
+	start := time.Now()
+
+	prompt, media, err := m.applyRequestJinjaTemplate(ctx, d, params.ContinueFinalMessage)
+	if err != nil {
+		return "", nil, fmt.Errorf("apply-request-jinja-template: unable to apply jinja template: %w", err)
+	}
+
+	metrics.AddPromptCreationTime(time.Since(start))
+
+	return prompt, media, nil
+}
+
 func (m *Model) validateDocument(d D) (Params, error) {
 	messages, exists := d["messages"]
 	if !exists {
@@ -139,18 +239,39 @@ func (m *Model) validateDocument(d D) (Params, error) {
 		return Params{}, errors.New("validate-document: messages is not a slice of documents")
 	}
 
+	addDefaultParams(d, m.cfg.DefaultParams)
+
 	params, err := parseParams(d)
 	if err != nil {
 		return Params{}, err
 	}
 
-	return params, nil
+	return applyReasoningEffort(d, params, m.modelInfo.IsGPTModel), nil
 }
 
 func (m *Model) processBitmap(lctx llama.Context, mtmdCtx mtmd.Context, prompt string, media [][]byte) ([]mtmd.Bitmap, error) {
-	bitmaps := make([]mtmd.Bitmap, len(media))
+	bitmaps := make([]mtmd.Bitmap, 0, len(media))
+
+	freeBitmaps := func() {
+		for _, b := range bitmaps {
+			mtmd.BitmapFree(b)
+		}
+	}
+
 	for i, med := range media {
-		bitmaps[i] = mtmd.BitmapInitFromBuf(mtmdCtx, &med[0], uint64(len(med)))
+		data, err := prepareMedia(med, m.cfg.MaxImagePixels)
+		if err != nil {
+			freeBitmaps()
+			return nil, fmt.Errorf("process-bitmap: media[%d]: %w", i, err)
+		}
+
+		bitmap := mtmd.BitmapInitFromBuf(mtmdCtx, &data[0], uint64(len(data)))
+		if bitmap == 0 {
+			freeBitmaps()
+			return nil, fmt.Errorf("process-bitmap: media[%d]: unable to load media", i)
+		}
+
+		bitmaps = append(bitmaps, bitmap)
 	}
 
 	output := mtmd.InputChunksInit()
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/hybridgroup/yzma/pkg/llama"
 )
@@ -74,17 +75,125 @@ type Logger func(ctx context.Context, msg string, args ...any)
 //
 // Embeddings is a boolean that determines if the model you are using is an
 // embedding model. This must be true when using an embedding model.
+//
+// Adapters is a set of LoRA adapters to load with the model. Each adapter is
+// loaded once with the model and applied to the context created for each
+// request. A request can select which adapters to apply using the "adapter"
+// or "lora" fields. The name of an adapter is the file name without the
+// extension.
+//
+// SessionDir is the directory used to persist the state of conversations
+// that provide a "session_id" in the request. When empty, sessions are not
+// supported.
+//
+// SessionTTL is the amount of time a persisted session is kept since it was
+// last saved. When set to 0, sessions don't expire.
+//
+// SessionMaxSize is the maximum number of bytes all persisted sessions can
+// use on disk. The least recently saved sessions are removed first. When set
+// to 0, there is no limit.
+//
+// MediaFetcher is used to retrieve media provided as a http(s) or file URL
+// in a request. When nil, media must be provided as base64 encoded data.
+//
+// MaxImagePixels is the maximum number of pixels (width x height) an image in
+// a request can have. Larger images are downscaled, keeping the aspect ratio,
+// before they are processed by the model.
+// When set to 0, the default value is 4194304 (2048x2048).
+//
+// DefaultParams are the sampling parameters used when a request doesn't
+// provide them. Parameters set to their zero value are not applied and the
+// package defaults are used.
+//
+// UseMmap determines if the model file is memory mapped instead of read into
+// memory. When nil, the llama.cpp default of true is used.
+//
+// UseMlock forces the system to keep the model in RAM instead of swapping or
+// compressing it. This helps CPU-only hosts keep large contexts responsive.
+//
+// NGPULayers is the number of layers to offload to the GPU. Set to 0 to run
+// the model on the CPU only. When nil, all layers are offloaded.
+//
+// OffloadKQV determines if the KV cache and the KQV operations are offloaded
+// to the GPU. When nil, the llama.cpp default of true is used.
+//
+// FlashAttention determines when flash attention is used. It accepts auto,
+// enabled, and disabled. When empty, the default value is auto.
+//
+// TypeK and TypeV are the data types of the K and V caches. Quantized types
+// reduce the memory the KV cache uses, which is what allows large contexts to
+// fit into memory. They accept f32, f16, bf16, q8_0, q5_1, q5_0, q4_1, q4_0
+// and iq4_nl. A quantized V cache requires flash attention.
+// When empty, the default value is f16.
+//
+// RopeScaling is the RoPE scaling type. It accepts none, linear, yarn and
+// longrope. When empty, the value in the model is used.
+//
+// RopeFreqBase and RopeFreqScale are the RoPE base frequency and frequency
+// scaling factor. When set to 0, the values in the model are used.
+//
+// YarnExtFactor, YarnAttnFactor, YarnBetaFast, YarnBetaSlow and YarnOrigCtx
+// are the YaRN extrapolation mix factor, magnitude scaling factor, low and
+// high correction dims, and the original context size of the model. When set
+// to 0, the llama.cpp defaults and the values in the model are used.
+//
+// NSeqMax is the maximum number of sequences a context can process.
+// When set to 0, the default value is 1.
+//
+// SWAFull determines if a full size cache is used for models with sliding
+// window attention. This uses more memory but allows the cache to be reused
+// across requests.
+//
+// LoadProgress is called with the progress of loading the model file, as a
+// value between 0 and 1. When nil, the progress isn't reported.
 type Config struct {
-	Log           Logger
-	ModelFile     string
-	ProjFile      string
-	JinjaFile     string
-	Device        string
-	ContextWindow int
-	NBatch        int
-	NUBatch       int
-	NThreads      int
-	NThreadsBatch int
+	Log            Logger
+	ModelFile      string
+	ProjFile       string
+	JinjaFile      string
+	Device         string
+	ContextWindow  int
+	NBatch         int
+	NUBatch        int
+	NThreads       int
+	NThreadsBatch  int
+	Adapters       []AdapterConfig
+	SessionDir     string
+	SessionTTL     time.Duration
+	SessionMaxSize int64
+	MediaFetcher   MediaFetcher
+	MaxImagePixels int
+	DefaultParams  Params
+	UseMmap        *bool
+	UseMlock       bool
+	NGPULayers     *int
+	OffloadKQV     *bool
+	FlashAttention string
+	TypeK          string
+	TypeV          string
+	RopeScaling    string
+	RopeFreqBase   float32
+	RopeFreqScale  float32
+	YarnExtFactor  float32
+	YarnAttnFactor float32
+	YarnBetaFast   float32
+	YarnBetaSlow   float32
+	YarnOrigCtx    int
+	NSeqMax        int
+	SWAFull        bool
+	LoadProgress   func(progress float32)
+}
+
+// AdapterConfig represents a LoRA adapter to load with the model.
+//
+// Path is the path to the adapter file. This is mandatory to provide.
+//
+// Scale is the strength of the adapter when a request doesn't select any
+// adapters. When set to 0, the adapter is only applied when a request selects
+// it and the scale defaults to 1.0.
+type AdapterConfig struct {
+	Path  string
+	Scale float32
 }
 
 func validateConfig(cfg Config) error {
@@ -92,6 +201,71 @@ func validateConfig(cfg Config) error {
 		return fmt.Errorf("validate-config: model file is required")
 	}
 
+	for i, adapter := range cfg.Adapters {
+		if adapter.Path == "" {
+			return fmt.Errorf("validate-config: adapter[%d] path is required", i)
+		}
+
+		if adapter.Scale < 0 {
+			return fmt.Errorf("validate-config: adapter[%d] scale can't be negative", i)
+		}
+	}
+
+	if cfg.SessionTTL < 0 {
+		return fmt.Errorf("validate-config: session ttl can't be negative")
+	}
+
+	if cfg.SessionMaxSize < 0 {
+		return fmt.Errorf("validate-config: session max size can't be negative")
+	}
+
+	if err := ValidateRuntime(cfg); err != nil {
+		return fmt.Errorf("validate-config: %w", err)
+	}
+
+	return nil
+}
+
+// ValidateRuntime checks the llama.cpp load and context options in the
+// config. This allows the options to be checked before a model is loaded.
+func ValidateRuntime(cfg Config) error {
+	if cfg.NGPULayers != nil && *cfg.NGPULayers < 0 {
+		return fmt.Errorf("validate-runtime: ngpu layers can't be negative")
+	}
+
+	flashAttention, err := parseFlashAttention(cfg.FlashAttention)
+	if err != nil {
+		return fmt.Errorf("validate-runtime: %w", err)
+	}
+
+	if _, err := parseCacheType(cfg.TypeK); err != nil {
+		return fmt.Errorf("validate-runtime: type k: %w", err)
+	}
+
+	if _, err := parseCacheType(cfg.TypeV); err != nil {
+		return fmt.Errorf("validate-runtime: type v: %w", err)
+	}
+
+	if isQuantizedCacheType(cfg.TypeV) && flashAttention == llama.FlashAttentionTypeDisabled {
+		return fmt.Errorf("validate-runtime: type v %q requires flash attention, it can't be disabled", cfg.TypeV)
+	}
+
+	if _, err := parseRopeScaling(cfg.RopeScaling); err != nil {
+		return fmt.Errorf("validate-runtime: %w", err)
+	}
+
+	if cfg.RopeFreqBase < 0 || cfg.RopeFreqScale < 0 {
+		return fmt.Errorf("validate-runtime: rope frequency values can't be negative")
+	}
+
+	if cfg.YarnAttnFactor < 0 || cfg.YarnBetaFast < 0 || cfg.YarnBetaSlow < 0 || cfg.YarnOrigCtx < 0 {
+		return fmt.Errorf("validate-runtime: yarn values can't be negative")
+	}
+
+	if cfg.NSeqMax < 0 {
+		return fmt.Errorf("validate-runtime: nseq max can't be negative")
+	}
+
 	return nil
 }
 
@@ -106,6 +280,10 @@ func adjustConfig(cfg Config, model llama.Model) Config {
 		cfg.NUBatch = defNUBatch
 	}
 
+	if cfg.MaxImagePixels <= 0 {
+		cfg.MaxImagePixels = defMaxImagePixels
+	}
+
 	if cfg.NThreads < 0 {
 		cfg.NThreads = 0
 	}
@@ -155,9 +333,165 @@ func modelCtxParams(cfg Config, mi ModelInfo) llama.ContextParams {
 		ctxParams.NThreadsBatch = int32(cfg.NThreadsBatch)
 	}
 
+	// The values were checked when the config was validated.
+	ctxParams.FlashAttentionType, _ = parseFlashAttention(cfg.FlashAttention)
+
+	if cfg.TypeK != "" {
+		ctxParams.TypeK, _ = parseCacheType(cfg.TypeK)
+	}
+
+	if cfg.TypeV != "" {
+		ctxParams.TypeV, _ = parseCacheType(cfg.TypeV)
+	}
+
+	if cfg.RopeScaling != "" {
+		ctxParams.RopeScalingType, _ = parseRopeScaling(cfg.RopeScaling)
+	}
+
+	if cfg.RopeFreqBase > 0 {
+		ctxParams.RopeFreqBase = cfg.RopeFreqBase
+	}
+
+	if cfg.RopeFreqScale > 0 {
+		ctxParams.RopeFreqScale = cfg.RopeFreqScale
+	}
+
+	if cfg.YarnExtFactor != 0 {
+		ctxParams.YarnExtFactor = cfg.YarnExtFactor
+	}
+
+	if cfg.YarnAttnFactor > 0 {
+		ctxParams.YarnAttnFactor = cfg.YarnAttnFactor
+	}
+
+	if cfg.YarnBetaFast > 0 {
+		ctxParams.YarnBetaFast = cfg.YarnBetaFast
+	}
+
+	if cfg.YarnBetaSlow > 0 {
+		ctxParams.YarnBetaSlow = cfg.YarnBetaSlow
+	}
+
+	if cfg.YarnOrigCtx > 0 {
+		ctxParams.YarnOrigCtx = uint32(cfg.YarnOrigCtx)
+	}
+
+	if cfg.NSeqMax > 0 {
+		ctxParams.NSeqMax = uint32(cfg.NSeqMax)
+	}
+
+	if cfg.OffloadKQV != nil {
+		ctxParams.Offload_kqv = boolToUint8(*cfg.OffloadKQV)
+	}
+
+	if cfg.SWAFull {
+		ctxParams.SwaFull = 1
+	}
+
 	return ctxParams
 }
 
+func modelParams(cfg Config) (llama.ModelParams, error) {
+	mparams := llama.ModelDefaultParams()
+
+	if cfg.Device != "" {
+		dev := llama.GGMLBackendDeviceByName(cfg.Device)
+		if dev == 0 {
+			return llama.ModelParams{}, fmt.Errorf("model-params: unknown device: %s", cfg.Device)
+		}
+		mparams.SetDevices([]llama.GGMLBackendDevice{dev})
+	}
+
+	if cfg.UseMmap != nil {
+		mparams.UseMmap = boolToUint8(*cfg.UseMmap)
+	}
+
+	if cfg.UseMlock {
+		mparams.UseMlock = 1
+	}
+
+	if cfg.NGPULayers != nil {
+		mparams.NGpuLayers = int32(*cfg.NGPULayers)
+	}
+
+	return mparams, nil
+}
+
+// =============================================================================
+
+// These are the ggml data types supported for the KV cache.
+var cacheTypes = map[string]int32{
+	"f32":    0,
+	"f16":    1,
+	"q4_0":   2,
+	"q4_1":   3,
+	"q5_0":   6,
+	"q5_1":   7,
+	"q8_0":   8,
+	"iq4_nl": 20,
+	"bf16":   30,
+}
+
+func parseCacheType(name string) (int32, error) {
+	if name == "" {
+		return cacheTypes["f16"], nil
+	}
+
+	typ, exists := cacheTypes[strings.ToLower(name)]
+	if !exists {
+		return 0, fmt.Errorf("parse-cache-type: unknown cache type %q, expecting f32, f16, bf16, q8_0, q5_1, q5_0, q4_1, q4_0 or iq4_nl", name)
+	}
+
+	return typ, nil
+}
+
+func isQuantizedCacheType(name string) bool {
+	switch strings.ToLower(name) {
+	case "", "f32", "f16", "bf16":
+		return false
+	}
+
+	return true
+}
+
+func parseFlashAttention(value string) (llama.FlashAttentionType, error) {
+	switch strings.ToLower(value) {
+	case "", "auto":
+		return llama.FlashAttentionTypeAuto, nil
+	case "enabled", "on", "true":
+		return llama.FlashAttentionTypeEnabled, nil
+	case "disabled", "off", "false":
+		return llama.FlashAttentionTypeDisabled, nil
+	}
+
+	return 0, fmt.Errorf("parse-flash-attention: unknown value %q, expecting auto, enabled or disabled", value)
+}
+
+func parseRopeScaling(value string) (llama.RopeScalingType, error) {
+	switch strings.ToLower(value) {
+	case "":
+		return llama.RopeScalingTypeUnspecified, nil
+	case "none":
+		return llama.RopeScalingTypeNone, nil
+	case "linear":
+		return llama.RopeScalingTypeLinear, nil
+	case "yarn":
+		return llama.RopeScalingTypeYARN, nil
+	case "longrope":
+		return llama.RopeScalingTypeLongROPE, nil
+	}
+
+	return 0, fmt.Errorf("parse-rope-scaling: unknown value %q, expecting none, linear, yarn or longrope", value)
+}
+
+func boolToUint8(b bool) uint8 {
+	if b {
+		return 1
+	}
+
+	return 0
+}
+
 func searchModelMeta(model llama.Model, find string) (string, bool) {
 	count := llama.ModelMetaCount(model)
 
@@ -0,0 +1,73 @@
+package model
+
+import (
+	"testing"
+
+	"github.com/hybridgroup/yzma/pkg/llama"
+)
+
+func Test_ValidateRuntime(t *testing.T) {
+	negative := -1
+
+	tests := []struct {
+		name  string
+		cfg   Config
+		valid bool
+	}{
+		{"defaults", Config{}, true},
+		{"quantized cache", Config{TypeK: "q8_0", TypeV: "Q4_0", FlashAttention: "enabled"}, true},
+		{"quantized v cache auto", Config{TypeV: "q8_0"}, true},
+		{"rope scaling", Config{RopeScaling: "yarn", RopeFreqScale: 0.25, YarnOrigCtx: 32768}, true},
+		{"unknown cache type", Config{TypeK: "q3_k"}, false},
+		{"unknown flash attention", Config{FlashAttention: "maybe"}, false},
+		{"unknown rope scaling", Config{RopeScaling: "ntk"}, false},
+		{"quantized v cache without flash attention", Config{TypeV: "q8_0", FlashAttention: "off"}, false},
+		{"negative gpu layers", Config{NGPULayers: &negative}, false},
+		{"negative nseq max", Config{NSeqMax: -1}, false},
+		{"negative rope freq base", Config{RopeFreqBase: -1}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateRuntime(tt.cfg)
+
+			switch {
+			case tt.valid && err != nil:
+				t.Errorf("expected a valid config, got %v", err)
+			case !tt.valid && err == nil:
+				t.Error("expected an error")
+			}
+		})
+	}
+}
+
+func Test_ParseCacheType(t *testing.T) {
+	tests := []struct {
+		name string
+		exp  int32
+	}{
+		{"", 1},
+		{"f32", 0},
+		{"F16", 1},
+		{"q4_0", 2},
+		{"q8_0", 8},
+		{"iq4_nl", 20},
+		{"bf16", 30},
+	}
+
+	for _, tt := range tests {
+		typ, err := parseCacheType(tt.name)
+		if err != nil {
+			t.Fatalf("%s: parse cache type: %v", tt.name, err)
+		}
+
+		if typ != tt.exp {
+			t.Errorf("%s: expected %d, got %d", tt.name, tt.exp, typ)
+		}
+	}
+
+	fa, err := parseFlashAttention("on")
+	if err != nil || fa != llama.FlashAttentionTypeEnabled {
+		t.Errorf("expected flash attention enabled, got %d %v", fa, err)
+	}
+}
@@ -0,0 +1,307 @@
+package model
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"math"
+
+	"golang.org/x/image/draw"
+	"golang.org/x/image/webp"
+)
+
+const (
+	defMaxImagePixels = 2048 * 2048
+	maxDecodePixels   = 100 * 1000 * 1000
+)
+
+// prepareMedia validates the media provided in a request before it's handed
+// to mtmd. Images are decoded, rotated based on the EXIF orientation, and
+// downscaled so they don't exceed maxPixels. Audio is returned as is.
+func prepareMedia(data []byte, maxPixels int) ([]byte, error) {
+	if len(data) == 0 {
+		return nil, errors.New("prepare-media: media is empty")
+	}
+
+	if isAudio(data) {
+		return data, nil
+	}
+
+	cfg, format, err := decodeImageConfig(data)
+	if err != nil {
+		return nil, fmt.Errorf("prepare-media: %w", err)
+	}
+
+	pixels := cfg.Width * cfg.Height
+
+	if cfg.Width <= 0 || cfg.Height <= 0 || pixels > maxDecodePixels {
+		return nil, fmt.Errorf("prepare-media: image dimensions %dx%d are not supported", cfg.Width, cfg.Height)
+	}
+
+	var orientation int
+	if format == "jpeg" {
+		orientation = exifOrientation(data)
+	}
+
+	// PNG and JPEG images mtmd can handle directly are left untouched so we
+	// don't lose quality re-encoding them.
+	if (format == "png" || format == "jpeg") && orientation <= 1 && pixels <= maxPixels {
+		return data, nil
+	}
+
+	img, err := decodeImage(data, format)
+	if err != nil {
+		return nil, fmt.Errorf("prepare-media: unable to decode %s image: %w", format, err)
+	}
+
+	img = scaleImage(img, maxPixels)
+	img = orientImage(img, orientation)
+
+	var buf bytes.Buffer
+
+	switch format {
+	case "jpeg":
+		err = jpeg.Encode(&buf, img, &jpeg.Options{Quality: 90})
+
+	default:
+		err = png.Encode(&buf, img)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("prepare-media: unable to encode image: %w", err)
+	}
+
+	return buf.Bytes(), nil
+}
+
+// =============================================================================
+
+func isAudio(data []byte) bool {
+	switch {
+	case len(data) >= 12 && bytes.Equal(data[:4], []byte("RIFF")) && bytes.Equal(data[8:12], []byte("WAVE")):
+		return true
+
+	case bytes.HasPrefix(data, []byte("ID3")),
+		bytes.HasPrefix(data, []byte("fLaC")),
+		bytes.HasPrefix(data, []byte("OggS")):
+		return true
+
+	case len(data) >= 2 && data[0] == 0xFF && data[1]&0xE0 == 0xE0:
+		return true
+	}
+
+	return false
+}
+
+func decodeImageConfig(data []byte) (image.Config, string, error) {
+	r := bytes.NewReader(data)
+
+	switch {
+	case bytes.HasPrefix(data, []byte("\x89PNG\r\n\x1a\n")):
+		cfg, err := png.DecodeConfig(r)
+		return cfg, "png", wrapCorrupt("png", err)
+
+	case bytes.HasPrefix(data, []byte("\xFF\xD8\xFF")):
+		cfg, err := jpeg.DecodeConfig(r)
+		return cfg, "jpeg", wrapCorrupt("jpeg", err)
+
+	case bytes.HasPrefix(data, []byte("GIF87a")), bytes.HasPrefix(data, []byte("GIF89a")):
+		cfg, err := gif.DecodeConfig(r)
+		return cfg, "gif", wrapCorrupt("gif", err)
+
+	case len(data) >= 12 && bytes.Equal(data[:4], []byte("RIFF")) && bytes.Equal(data[8:12], []byte("WEBP")):
+		cfg, err := webp.DecodeConfig(r)
+		return cfg, "webp", wrapCorrupt("webp", err)
+	}
+
+	return image.Config{}, "", errors.New("unsupported media format, expecting a png, jpeg, gif, or webp image or wav, mp3, or flac audio")
+}
+
+func decodeImage(data []byte, format string) (image.Image, error) {
+	r := bytes.NewReader(data)
+
+	switch format {
+	case "png":
+		return png.Decode(r)
+
+	case "jpeg":
+		return jpeg.Decode(r)
+
+	case "gif":
+		// Only the first frame of an animated gif is used.
+		return gif.Decode(r)
+
+	case "webp":
+		return webp.Decode(r)
+	}
+
+	return nil, fmt.Errorf("unsupported image format %q", format)
+}
+
+func wrapCorrupt(format string, err error) error {
+	if err == nil {
+		return nil
+	}
+
+	return fmt.Errorf("corrupt %s image: %w", format, err)
+}
+
+// =============================================================================
+
+// scaleImage downscales the image, keeping the aspect ratio, so the number of
+// pixels doesn't exceed maxPixels.
+func scaleImage(img image.Image, maxPixels int) image.Image {
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+
+	if maxPixels <= 0 || w*h <= maxPixels {
+		return img
+	}
+
+	factor := math.Sqrt(float64(maxPixels) / float64(w*h))
+	nw := max(int(float64(w)*factor), 1)
+	nh := max(int(float64(h)*factor), 1)
+
+	dst := image.NewNRGBA(image.Rect(0, 0, nw, nh))
+	draw.BiLinear.Scale(dst, dst.Bounds(), img, b, draw.Src, nil)
+
+	return dst
+}
+
+// orientImage rotates and flips the image based on the EXIF orientation so
+// the image is presented upright.
+func orientImage(img image.Image, orientation int) image.Image {
+	if orientation < 2 || orientation > 8 {
+		return img
+	}
+
+	b := img.Bounds()
+
+	src, ok := img.(*image.NRGBA)
+	if !ok || b.Min != (image.Point{}) {
+		src = image.NewNRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+		draw.Draw(src, src.Bounds(), img, b.Min, draw.Src)
+	}
+
+	w, h := b.Dx(), b.Dy()
+
+	dw, dh := w, h
+	if orientation >= 5 {
+		dw, dh = h, w
+	}
+
+	dst := image.NewNRGBA(image.Rect(0, 0, dw, dh))
+
+	for y := range dh {
+		for x := range dw {
+			var sx, sy int
+
+			switch orientation {
+			case 2:
+				sx, sy = w-1-x, y
+			case 3:
+				sx, sy = w-1-x, h-1-y
+			case 4:
+				sx, sy = x, h-1-y
+			case 5:
+				sx, sy = y, x
+			case 6:
+				sx, sy = y, h-1-x
+			case 7:
+				sx, sy = w-1-y, h-1-x
+			case 8:
+				sx, sy = w-1-y, x
+			}
+
+			si := src.PixOffset(sx, sy)
+			di := dst.PixOffset(x, y)
+			copy(dst.Pix[di:di+4], src.Pix[si:si+4])
+		}
+	}
+
+	return dst
+}
+
+// exifOrientation returns the orientation stored in the EXIF data of a jpeg
+// image. If no orientation is found, 0 is returned.
+func exifOrientation(data []byte) int {
+	if len(data) < 4 || data[0] != 0xFF || data[1] != 0xD8 {
+		return 0
+	}
+
+	pos := 2
+
+	for pos+4 <= len(data) {
+		if data[pos] != 0xFF {
+			return 0
+		}
+
+		marker := data[pos+1]
+
+		// The image data starts after the start of scan marker, so there
+		// are no more metadata segments.
+		if marker == 0xDA || marker == 0xD9 {
+			return 0
+		}
+
+		size := int(binary.BigEndian.Uint16(data[pos+2:]))
+		if size < 2 || pos+2+size > len(data) {
+			return 0
+		}
+
+		segment := data[pos+4 : pos+2+size]
+
+		if marker == 0xE1 && bytes.HasPrefix(segment, []byte("Exif\x00\x00")) {
+			return tiffOrientation(segment[6:])
+		}
+
+		pos += 2 + size
+	}
+
+	return 0
+}
+
+func tiffOrientation(tiff []byte) int {
+	if len(tiff) < 8 {
+		return 0
+	}
+
+	var order binary.ByteOrder
+
+	switch string(tiff[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return 0
+	}
+
+	ifd := int(order.Uint32(tiff[4:]))
+	if ifd < 8 || ifd+2 > len(tiff) {
+		return 0
+	}
+
+	entries := int(order.Uint16(tiff[ifd:]))
+
+	for i := range entries {
+		entry := ifd + 2 + i*12
+		if entry+12 > len(tiff) {
+			return 0
+		}
+
+		const orientationTag = 0x0112
+		const shortType = 3
+
+		if order.Uint16(tiff[entry:]) == orientationTag && order.Uint16(tiff[entry+2:]) == shortType {
+			return int(order.Uint16(tiff[entry+8:]))
+		}
+	}
+
+	return 0
+}
@@ -0,0 +1,161 @@
+package model
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"testing"
+)
+
+func Test_PrepareMedia(t *testing.T) {
+	t.Run("small png untouched", func(t *testing.T) {
+		data := encodePNG(t, testImage(4, 2))
+
+		got, err := prepareMedia(data, 100)
+		if err != nil {
+			t.Fatalf("expected no error, got: %v", err)
+		}
+
+		if !bytes.Equal(got, data) {
+			t.Fatal("expected the image to be returned as is")
+		}
+	})
+
+	t.Run("downscale", func(t *testing.T) {
+		got, err := prepareMedia(encodePNG(t, testImage(400, 200)), 200*100)
+		if err != nil {
+			t.Fatalf("expected no error, got: %v", err)
+		}
+
+		cfg := decodeConfig(t, got)
+		if cfg.Width != 200 || cfg.Height != 100 {
+			t.Fatalf("expected 200x100, got %dx%d", cfg.Width, cfg.Height)
+		}
+	})
+
+	t.Run("exif orientation", func(t *testing.T) {
+		got, err := prepareMedia(encodeJPEG(t, testImage(40, 20), 6), 10000)
+		if err != nil {
+			t.Fatalf("expected no error, got: %v", err)
+		}
+
+		cfg := decodeConfig(t, got)
+		if cfg.Width != 20 || cfg.Height != 40 {
+			t.Fatalf("expected the image to be rotated to 20x40, got %dx%d", cfg.Width, cfg.Height)
+		}
+	})
+
+	t.Run("gif first frame", func(t *testing.T) {
+		var buf bytes.Buffer
+		if err := gif.Encode(&buf, testImage(8, 8), nil); err != nil {
+			t.Fatalf("encode gif: %v", err)
+		}
+
+		got, err := prepareMedia(buf.Bytes(), 10000)
+		if err != nil {
+			t.Fatalf("expected no error, got: %v", err)
+		}
+
+		if _, err := png.Decode(bytes.NewReader(got)); err != nil {
+			t.Fatalf("expected the gif to be converted to png: %v", err)
+		}
+	})
+
+	t.Run("audio untouched", func(t *testing.T) {
+		data := []byte("RIFF\x24\x00\x00\x00WAVEfmt ")
+
+		got, err := prepareMedia(data, 100)
+		if err != nil {
+			t.Fatalf("expected no error, got: %v", err)
+		}
+
+		if !bytes.Equal(got, data) {
+			t.Fatal("expected the audio to be returned as is")
+		}
+	})
+
+	t.Run("corrupt", func(t *testing.T) {
+		data := encodePNG(t, testImage(4, 4))
+
+		if _, err := prepareMedia(data[:20], 100); err == nil {
+			t.Fatal("expected an error for a corrupt image")
+		}
+	})
+
+	t.Run("unsupported", func(t *testing.T) {
+		if _, err := prepareMedia([]byte("this is not an image"), 100); err == nil {
+			t.Fatal("expected an error for unsupported media")
+		}
+	})
+}
+
+// =============================================================================
+
+func testImage(w int, h int) image.Image {
+	img := image.NewNRGBA(image.Rect(0, 0, w, h))
+	for y := range h {
+		for x := range w {
+			img.Set(x, y, color.NRGBA{R: uint8(x), G: uint8(y), B: 128, A: 255})
+		}
+	}
+
+	return img
+}
+
+func encodePNG(t *testing.T, img image.Image) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatalf("encode png: %v", err)
+	}
+
+	return buf.Bytes()
+}
+
+// encodeJPEG encodes the image and inserts an EXIF segment with the
+// specified orientation after the start of image marker.
+func encodeJPEG(t *testing.T, img image.Image, orientation uint16) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, nil); err != nil {
+		t.Fatalf("encode jpeg: %v", err)
+	}
+
+	var tiff bytes.Buffer
+	tiff.WriteString("MM")
+	binary.Write(&tiff, binary.BigEndian, uint16(42))
+	binary.Write(&tiff, binary.BigEndian, uint32(8))
+	binary.Write(&tiff, binary.BigEndian, uint16(1))
+	binary.Write(&tiff, binary.BigEndian, []uint16{0x0112, 3})
+	binary.Write(&tiff, binary.BigEndian, uint32(1))
+	binary.Write(&tiff, binary.BigEndian, []uint16{orientation, 0})
+	binary.Write(&tiff, binary.BigEndian, uint32(0))
+
+	segment := append([]byte("Exif\x00\x00"), tiff.Bytes()...)
+
+	var out bytes.Buffer
+	out.Write(buf.Bytes()[:2])
+	out.Write([]byte{0xFF, 0xE1})
+	binary.Write(&out, binary.BigEndian, uint16(len(segment)+2))
+	out.Write(segment)
+	out.Write(buf.Bytes()[2:])
+
+	return out.Bytes()
+}
+
+func decodeConfig(t *testing.T, data []byte) image.Config {
+	t.Helper()
+
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("decode config: %v", err)
+	}
+
+	return cfg
+}
@@ -0,0 +1,1139 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf16"
+
+	"github.com/nikolalohinski/gonja/v2/builtins"
+	"github.com/nikolalohinski/gonja/v2/exec"
+)
+
+// This file provides the runtime extensions that chat templates written for
+// the HF transformers library expect. The goal is for a template to render
+// the same prompt here that apply_chat_template renders in python.
+
+// TemplateError is returned when a chat template rejects the request by
+// calling raise_exception. The message is the one provided by the template.
+type TemplateError struct {
+	Message string
+}
+
+// Error implements the error interface.
+func (te *TemplateError) Error() string {
+	return "template: " + te.Message
+}
+
+// executeTemplate renders the compiled template with the specified document.
+// When the template calls raise_exception, the error is a TemplateError so
+// callers can identify the request as the problem.
+func executeTemplate(tmpl *exec.Template, d D) (string, error) {
+	data := exec.NewContext(d)
+
+	// gonja flattens function errors into strings, so the raised error is
+	// captured here for this execution.
+	var raised *TemplateError
+	data.Set("raise_exception", func(msg string) (string, error) {
+		raised = &TemplateError{Message: msg}
+		return "", raised
+	})
+
+	s, err := tmpl.ExecuteToString(data)
+	if err != nil {
+		if raised != nil {
+			return "", raised
+		}
+
+		return "", err
+	}
+
+	return s, nil
+}
+
+// =============================================================================
+
+var (
+	reverseSlice      = regexp.MustCompile(`\[\s*::\s*-1\s*\]`)
+	loopPrevItem      = regexp.MustCompile(`\bloop\.previtem\b`)
+	loopNextItem      = regexp.MustCompile(`\bloop\.nextitem\b`)
+	rawBlockEnd       = regexp.MustCompile(`\{%[-+]?\s*endraw\s*[-+]?%\}`)
+	builtinStrMethods = []string{
+		"capitalize", "capwords", "casefold", "center", "count", "encode",
+		"endswith", "expandtabs", "find", "format", "format_map", "isalnum",
+		"isalpha", "isascii", "isdecimal", "isdigit", "islower", "isnumeric",
+		"isprintable", "isspace", "istitle", "isupper", "join", "ljust",
+		"lower", "partition", "removeprefix", "removesuffix", "rfind", "rjust",
+		"rpartition", "rsplit", "splitlines", "startswith", "swapcase",
+		"title", "upper", "zfill",
+	}
+)
+
+// rewriteTemplateSource prepares the source so gonja renders it the way
+// transformers does. Transformers renders with trim_blocks and lstrip_blocks
+// enabled, which gonja doesn't implement correctly, so the whitespace is
+// removed here. The python syntax gonja can't parse is rewritten into the
+// gonja equivalent inside the tags.
+func rewriteTemplateSource(source string) string {
+	// Jinja drops a single trailing newline from the template.
+	switch {
+	case strings.HasSuffix(source, "\r\n"):
+		source = source[:len(source)-2]
+	case strings.HasSuffix(source, "\n"):
+		source = source[:len(source)-1]
+	}
+
+	var sb strings.Builder
+	sb.Grow(len(source))
+
+	lineStart := true
+	var hoisted int
+
+	for {
+		start := strings.IndexByte(source, '{')
+		for start != -1 && (start+1 == len(source) || !strings.ContainsRune("%{#", rune(source[start+1]))) {
+			next := strings.IndexByte(source[start+1:], '{')
+			if next == -1 {
+				start = -1
+				break
+			}
+			start += next + 1
+		}
+
+		if start == -1 {
+			sb.WriteString(source)
+			return sb.String()
+		}
+
+		text, kind := source[:start], source[start+1]
+		source = source[start:]
+
+		// lstrip_blocks: remove the spaces and tabs between the start of
+		// the line and a block or comment tag.
+		if kind != '{' && !strings.HasPrefix(source[2:], "+") {
+			line := text
+			atLineStart := lineStart
+			if i := strings.LastIndexByte(text, '\n'); i != -1 {
+				line = text[i+1:]
+				atLineStart = true
+			}
+			if atLineStart && strings.Trim(line, " \t") == "" {
+				text = text[:len(text)-len(line)]
+			}
+		}
+
+		sb.WriteString(text)
+
+		end := tagEnd(source, kind)
+		if end == -1 {
+			sb.WriteString(source)
+			return sb.String()
+		}
+
+		tag := source[:end]
+		source = source[end:]
+
+		switch kind {
+		case '#':
+			sb.WriteString(tag)
+
+		default:
+			tag = rewriteSyntax(tag)
+
+			if canHoist(tag) {
+				prefix, inner := hoistConditionals(tag[2:len(tag)-2], &hoisted)
+				tag = tag[:2] + inner + tag[len(tag)-2:]
+				if prefix != "" && (strings.HasPrefix(tag, "{{-") || strings.HasPrefix(tag, "{%-")) {
+					prefix = "{%-" + prefix[2:]
+				}
+				sb.WriteString(prefix)
+			}
+
+			sb.WriteString(tag)
+
+			// The content of a raw block is copied as is.
+			if kind == '%' && strings.Trim(tag[2:len(tag)-2], "-+ \t\r\n") == "raw" {
+				if loc := rawBlockEnd.FindStringIndex(source); loc != nil {
+					sb.WriteString(source[:loc[1]])
+					source = source[loc[1]:]
+				}
+			}
+		}
+
+		// trim_blocks: remove the first newline after a block or comment tag.
+		lineStart = false
+		if kind != '{' && !strings.HasSuffix(tag[:len(tag)-2], "+") {
+			switch {
+			case strings.HasPrefix(source, "\n"):
+				source = source[1:]
+				lineStart = true
+			case strings.HasPrefix(source, "\r\n"):
+				source = source[2:]
+				lineStart = true
+			}
+		}
+	}
+}
+
+// rewriteSyntax rewrites the python syntax gonja can't parse in the tag into
+// the gonja equivalent. The string literals in the tag are copied as is so
+// text that only looks like the syntax isn't changed.
+func rewriteSyntax(tag string) string {
+	rewrite := func(code string) string {
+		code = reverseSlice.ReplaceAllString(code, "|reverse")
+		code = loopPrevItem.ReplaceAllString(code, "loop.PrevItem")
+		return loopNextItem.ReplaceAllString(code, "loop.NextItem")
+	}
+
+	var sb strings.Builder
+	sb.Grow(len(tag))
+
+	var quote byte
+	var start int
+
+	for i := 0; i < len(tag); i++ {
+		c := tag[i]
+
+		switch {
+		case quote != 0:
+			switch c {
+			case '\\':
+				i++
+			case quote:
+				quote = 0
+				sb.WriteString(tag[start : i+1])
+				start = i + 1
+			}
+
+		case c == '\'' || c == '"':
+			sb.WriteString(rewrite(tag[start:i]))
+			quote = c
+			start = i
+		}
+	}
+
+	switch {
+	case quote != 0:
+		sb.WriteString(tag[start:])
+	default:
+		sb.WriteString(rewrite(tag[start:]))
+	}
+
+	return sb.String()
+}
+
+// tagEnd returns the index just past the end of the tag the source starts
+// with. The string literals inside an expression or statement are skipped so
+// a closing delimiter inside a string doesn't end the tag.
+func tagEnd(source string, kind byte) int {
+	closing := "%}"
+	switch kind {
+	case '{':
+		closing = "}}"
+	case '#':
+		closing = "#}"
+	}
+
+	var quote byte
+
+	for i := 2; i < len(source); i++ {
+		c := source[i]
+
+		switch {
+		case quote != 0:
+			switch c {
+			case '\\':
+				i++
+			case quote:
+				quote = 0
+			}
+
+		case kind != '#' && (c == '\'' || c == '"'):
+			quote = c
+
+		case strings.HasPrefix(source[i:], closing):
+			return i + 2
+		}
+	}
+
+	return -1
+}
+
+// canHoist reports whether the conditional expressions in the tag can be
+// moved in front of it. A tag that continues a block can't have anything in
+// front of it.
+func canHoist(tag string) bool {
+	if tag[1] == '{' {
+		return true
+	}
+
+	fields := strings.Fields(strings.Trim(tag[2:len(tag)-2], "-+"))
+	if len(fields) == 0 {
+		return false
+	}
+
+	switch fields[0] {
+	case "elif", "else", "macro", "call", "filter", "raw":
+		return false
+	}
+
+	return !strings.HasPrefix(fields[0], "end")
+}
+
+// hoistConditionals moves the conditional expressions written inside
+// parentheses, brackets or braces, like (a if c else b), out of the tag
+// since gonja can only parse them at the top of an expression. Each one is
+// replaced by a variable that is set by an if block placed in front of the
+// tag, which keeps the branch that isn't taken from being evaluated.
+func hoistConditionals(expr string, counter *int) (string, string) {
+	var prefix strings.Builder
+	var out strings.Builder
+
+	var quote byte
+
+	for i := 0; i < len(expr); i++ {
+		c := expr[i]
+
+		switch {
+		case quote != 0:
+			out.WriteByte(c)
+			switch c {
+			case '\\':
+				if i+1 < len(expr) {
+					i++
+					out.WriteByte(expr[i])
+				}
+			case quote:
+				quote = 0
+			}
+			continue
+
+		case c == '\'' || c == '"':
+			quote = c
+			out.WriteByte(c)
+			continue
+
+		case c != '(' && c != '[' && c != '{':
+			out.WriteByte(c)
+			continue
+		}
+
+		end := matchingBracket(expr, i)
+		if end == -1 {
+			out.WriteString(expr[i:])
+			break
+		}
+
+		elems := splitTopLevel(expr[i+1:end], ",")
+		for j, elem := range elems {
+			key := ""
+			if c == '{' {
+				if parts := splitTopLevel(elem, ":"); len(parts) == 2 {
+					key, elem = parts[0]+":", parts[1]
+				}
+			}
+
+			pre, value := hoistConditional(elem, counter)
+			prefix.WriteString(pre)
+			elems[j] = key + value
+		}
+
+		out.WriteByte(c)
+		out.WriteString(strings.Join(elems, ","))
+		out.WriteByte(expr[end])
+
+		i = end
+	}
+
+	return prefix.String(), out.String()
+}
+
+// hoistConditional hoists the expression when it's a conditional expression
+// and returns the variable that replaces it. Any other expression is
+// searched for nested groups.
+func hoistConditional(expr string, counter *int) (string, string) {
+	parts := splitTopLevel(expr, "if")
+	if len(parts) != 2 {
+		return hoistConditionals(expr, counter)
+	}
+
+	value, cond := parts[0], parts[1]
+	alt := " none"
+
+	if parts := splitTopLevel(cond, "else"); len(parts) == 2 {
+		cond, alt = parts[0], parts[1]
+	}
+
+	*counter++
+	name := fmt.Sprintf("__kronk_cond_%d", *counter)
+
+	preCond, cond := hoistConditionals(cond, counter)
+	preValue, value := hoistConditional(value, counter)
+	preAlt, alt := hoistConditional(alt, counter)
+
+	var sb strings.Builder
+	sb.WriteString(preCond)
+	fmt.Fprintf(&sb, "{%% if %s %%}", strings.TrimSpace(cond))
+	sb.WriteString(preValue)
+	fmt.Fprintf(&sb, "{%% set %s = %s %%}{%% else %%}", name, strings.TrimSpace(value))
+	sb.WriteString(preAlt)
+	fmt.Fprintf(&sb, "{%% set %s = %s %%}{%% endif %%}", name, strings.TrimSpace(alt))
+
+	return sb.String(), " " + name + " "
+}
+
+// matchingBracket returns the index of the bracket closing the one at start
+// or -1 when it isn't closed.
+func matchingBracket(expr string, start int) int {
+	var quote byte
+	depth := 0
+
+	for i := start; i < len(expr); i++ {
+		c := expr[i]
+
+		switch {
+		case quote != 0:
+			switch c {
+			case '\\':
+				i++
+			case quote:
+				quote = 0
+			}
+
+		case c == '\'' || c == '"':
+			quote = c
+
+		case c == '(' || c == '[' || c == '{':
+			depth++
+
+		case c == ')' || c == ']' || c == '}':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+
+	return -1
+}
+
+// splitTopLevel splits the expression on the separator when it's outside of
+// strings and groups. A separator made of letters must be a whole word. The
+// split stops at the first separator for words so nested conditionals in
+// the alternative stay together.
+func splitTopLevel(expr string, sep string) []string {
+	var parts []string
+	var quote byte
+
+	isWord := sep[0] >= 'a' && sep[0] <= 'z'
+	depth := 0
+	last := 0
+
+	for i := 0; i < len(expr); i++ {
+		c := expr[i]
+
+		switch {
+		case quote != 0:
+			switch c {
+			case '\\':
+				i++
+			case quote:
+				quote = 0
+			}
+
+		case c == '\'' || c == '"':
+			quote = c
+
+		case c == '(' || c == '[' || c == '{':
+			depth++
+
+		case c == ')' || c == ']' || c == '}':
+			depth--
+
+		case depth == 0 && strings.HasPrefix(expr[i:], sep):
+			if isWord {
+				if i > 0 && (isIdentByte(expr[i-1]) || expr[i-1] == '.') {
+					continue
+				}
+				if j := i + len(sep); j < len(expr) && isIdentByte(expr[j]) {
+					continue
+				}
+			}
+
+			parts = append(parts, expr[last:i])
+			last = i + len(sep)
+			i = last - 1
+
+			if isWord {
+				return append(parts, expr[last:])
+			}
+		}
+	}
+
+	return append(parts, expr[last:])
+}
+
+func isIdentByte(c byte) bool {
+	return c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9'
+}
+
+// templateStrMethods returns the gonja string methods with the methods that
+// don't follow python replaced. The gonja versions of strip, lstrip, rstrip
+// and split don't accept being called without arguments and replace
+// requires a count.
+func templateStrMethods() *exec.MethodSet[string] {
+	methods := map[string]exec.Method[string]{
+		"strip": func(self string, _ *exec.Value, arguments *exec.VarArgs) (any, error) {
+			chars, isSet, err := optionalString(arguments, 0, "chars")
+			if err != nil {
+				return nil, err
+			}
+			if !isSet {
+				return strings.TrimFunc(self, isPySpace), nil
+			}
+			return strings.Trim(self, chars), nil
+		},
+		"lstrip": func(self string, _ *exec.Value, arguments *exec.VarArgs) (any, error) {
+			chars, isSet, err := optionalString(arguments, 0, "chars")
+			if err != nil {
+				return nil, err
+			}
+			if !isSet {
+				return strings.TrimLeftFunc(self, isPySpace), nil
+			}
+			return strings.TrimLeft(self, chars), nil
+		},
+		"rstrip": func(self string, _ *exec.Value, arguments *exec.VarArgs) (any, error) {
+			chars, isSet, err := optionalString(arguments, 0, "chars")
+			if err != nil {
+				return nil, err
+			}
+			if !isSet {
+				return strings.TrimRightFunc(self, isPySpace), nil
+			}
+			return strings.TrimRight(self, chars), nil
+		},
+		"replace": func(self string, _ *exec.Value, arguments *exec.VarArgs) (any, error) {
+			old, isSet, err := optionalString(arguments, 0, "old")
+			if err != nil || !isSet {
+				return nil, errors.New("replace: expected the old and new strings")
+			}
+			repl, isSet, err := optionalString(arguments, 1, "new")
+			if err != nil || !isSet {
+				return nil, errors.New("replace: expected the old and new strings")
+			}
+			count, err := optionalInt(arguments, 2, "count", -1)
+			if err != nil {
+				return nil, err
+			}
+			return strings.Replace(self, old, repl, count), nil
+		},
+		"split": func(self string, _ *exec.Value, arguments *exec.VarArgs) (any, error) {
+			sep, isSet, err := optionalString(arguments, 0, "sep")
+			if err != nil {
+				return nil, err
+			}
+			maxSplit, err := optionalInt(arguments, 1, "maxsplit", -1)
+			if err != nil {
+				return nil, err
+			}
+			if !isSet {
+				return splitSpace(self, maxSplit), nil
+			}
+			if sep == "" {
+				return nil, errors.New("split: empty separator")
+			}
+			if maxSplit < 0 {
+				return strings.Split(self, sep), nil
+			}
+			return strings.SplitN(self, sep, maxSplit+1), nil
+		},
+	}
+
+	for _, name := range builtinStrMethods {
+		if method, exists := builtins.Methods.Str.Get(name); exists {
+			methods[name] = method
+		}
+	}
+
+	return exec.NewMethodSet(methods)
+}
+
+// templateDictMethods returns the dictionary methods. Go maps have no order,
+// so keys, values and items are returned in key order for the output to be
+// stable between renders.
+func templateDictMethods() *exec.MethodSet[map[string]any] {
+	return exec.NewMethodSet(map[string]exec.Method[map[string]any]{
+		"keys": func(self map[string]any, _ *exec.Value, arguments *exec.VarArgs) (any, error) {
+			if err := arguments.Take(); err != nil {
+				return nil, err
+			}
+			return sortedKeys(self), nil
+		},
+		"values": func(self map[string]any, _ *exec.Value, arguments *exec.VarArgs) (any, error) {
+			if err := arguments.Take(); err != nil {
+				return nil, err
+			}
+			values := make([]any, 0, len(self))
+			for _, key := range sortedKeys(self) {
+				values = append(values, self[key])
+			}
+			return values, nil
+		},
+		"items": func(self map[string]any, _ *exec.Value, arguments *exec.VarArgs) (any, error) {
+			if err := arguments.Take(); err != nil {
+				return nil, err
+			}
+			return sortedItems(self), nil
+		},
+		"get": func(self map[string]any, _ *exec.Value, arguments *exec.VarArgs) (any, error) {
+			key, isSet, err := optionalString(arguments, 0, "key")
+			if err != nil || !isSet {
+				return nil, errors.New("get: expected a key")
+			}
+			if value, exists := self[key]; exists {
+				return value, nil
+			}
+			if len(arguments.Args) > 1 {
+				return arguments.Args[1].Interface(), nil
+			}
+			if value, exists := arguments.KwArgs["default"]; exists {
+				return value.Interface(), nil
+			}
+			return nil, nil
+		},
+	})
+}
+
+// itemsFilter implements the items filter, returning the key-value pairs of
+// a dictionary in key order.
+func itemsFilter(e *exec.Evaluator, in *exec.Value, params *exec.VarArgs) *exec.Value {
+	if !in.IsDict() {
+		return exec.AsValue([][]any{})
+	}
+
+	if m, ok := in.ToGoSimpleType(false).(map[string]any); ok {
+		return exec.AsValue(sortedItems(m))
+	}
+
+	return exec.AsValue([][]any{})
+}
+
+// reverseFilter implements the reverse filter. The gonja version sorts the
+// items before reversing them, which is not what python does.
+func reverseFilter(e *exec.Evaluator, in *exec.Value, params *exec.VarArgs) *exec.Value {
+	if in.IsString() {
+		runes := []rune(in.String())
+		slices.Reverse(runes)
+		return exec.AsValue(string(runes))
+	}
+
+	if !in.IsList() {
+		return exec.AsValue(fmt.Errorf("reverse: can't reverse %s", in.String()))
+	}
+
+	out := make([]any, 0, in.Len())
+	in.Iterate(func(idx, count int, key, value *exec.Value) bool {
+		out = append(out, key.Interface())
+		return true
+	}, func() {})
+
+	slices.Reverse(out)
+
+	return exec.AsValue(out)
+}
+
+// trimFilter implements the trim filter. The gonja version only removes
+// spaces by default where python removes all the whitespace.
+func trimFilter(e *exec.Evaluator, in *exec.Value, params *exec.VarArgs) *exec.Value {
+	if !in.IsString() {
+		return exec.AsValue(fmt.Errorf("trim: can't trim %s", in.String()))
+	}
+
+	chars, isSet, err := optionalString(params, 0, "chars")
+	if err != nil {
+		return exec.AsValue(fmt.Errorf("trim: %w", err))
+	}
+
+	if !isSet {
+		return exec.AsValue(strings.TrimFunc(in.String(), isPySpace))
+	}
+
+	return exec.AsValue(strings.Trim(in.String(), chars))
+}
+
+// strftimeNow implements the strftime_now function with the python format
+// directives.
+func strftimeNow(format string) string {
+	return strftime(time.Now(), format)
+}
+
+// raiseException implements the raise_exception function.
+func raiseException(msg string) (string, error) {
+	return "", &TemplateError{Message: msg}
+}
+
+// =============================================================================
+
+// tojsonFilter implements the tojson filter the way transformers does, which
+// is python's json.dumps with ensure_ascii off by default. The arguments in
+// order are ensure_ascii, indent, separators and sort_keys.
+func tojsonFilter(e *exec.Evaluator, in *exec.Value, params *exec.VarArgs) *exec.Value {
+	enc := pyJSONEncoder{
+		itemSep: ", ",
+		keySep:  ": ",
+	}
+
+	if v := argument(params, 0, "ensure_ascii"); v != nil {
+		enc.ensureASCII = v.IsTrue()
+	}
+
+	if v := argument(params, 1, "indent"); v != nil && !v.IsNil() {
+		switch {
+		case v.IsInteger():
+			enc.indent = strings.Repeat(" ", max(v.Integer(), 0))
+		case v.IsString():
+			enc.indent = v.String()
+		default:
+			return exec.AsValue(fmt.Errorf("tojson: indent must be an integer or a string, got %s", v.String()))
+		}
+		enc.pretty = true
+		enc.itemSep = ","
+	}
+
+	if v := argument(params, 2, "separators"); v != nil && !v.IsNil() {
+		if !v.IsList() || v.Len() != 2 {
+			return exec.AsValue(fmt.Errorf("tojson: separators must be a pair of strings, got %s", v.String()))
+		}
+		item, _ := v.GetItem(0)
+		key, _ := v.GetItem(1)
+		enc.itemSep = item.String()
+		enc.keySep = key.String()
+	}
+
+	if v := argument(params, 3, "sort_keys"); v != nil {
+		enc.sortKeys = v.IsTrue()
+	}
+
+	if err := enc.encode(in, 0); err != nil {
+		return exec.AsValue(fmt.Errorf("tojson: %w", err))
+	}
+
+	return exec.AsSafeValue(enc.sb.String())
+}
+
+type pyJSONEncoder struct {
+	sb          strings.Builder
+	ensureASCII bool
+	pretty      bool
+	indent      string
+	itemSep     string
+	keySep      string
+	sortKeys    bool
+}
+
+type pyJSONPair struct {
+	key   string
+	value any
+}
+
+func (enc *pyJSONEncoder) encode(v any, level int) error {
+	switch x := v.(type) {
+	case nil:
+		enc.sb.WriteString("null")
+		return nil
+
+	case *exec.Value:
+		if x == nil || x.IsNil() {
+			enc.sb.WriteString("null")
+			return nil
+		}
+		return enc.encode(x.Interface(), level)
+
+	case *exec.Dict:
+		pairs := make([]pyJSONPair, 0, len(x.Pairs))
+		for _, pair := range x.Pairs {
+			pairs = append(pairs, pyJSONPair{key: pair.Key.String(), value: pair.Value})
+		}
+		if enc.sortKeys {
+			sort.SliceStable(pairs, func(i, j int) bool { return pairs[i].key < pairs[j].key })
+		}
+		return enc.encodeObject(pairs, level)
+
+	case string:
+		enc.encodeString(x)
+		return nil
+
+	case bool:
+		enc.sb.WriteString(strconv.FormatBool(x))
+		return nil
+
+	case float32:
+		enc.sb.WriteString(pyFloat(float64(x)))
+		return nil
+
+	case float64:
+		enc.sb.WriteString(pyFloat(x))
+		return nil
+	}
+
+	rv := reflect.ValueOf(v)
+
+	switch rv.Kind() {
+	case reflect.Pointer, reflect.Interface:
+		if rv.IsNil() {
+			enc.sb.WriteString("null")
+			return nil
+		}
+		return enc.encode(rv.Elem().Interface(), level)
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		enc.sb.WriteString(strconv.FormatInt(rv.Int(), 10))
+		return nil
+
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		enc.sb.WriteString(strconv.FormatUint(rv.Uint(), 10))
+		return nil
+
+	case reflect.String:
+		enc.encodeString(rv.String())
+		return nil
+
+	case reflect.Map:
+		// Go maps have no order, so the keys are always sorted to keep the
+		// output stable between renders.
+		pairs := make([]pyJSONPair, 0, rv.Len())
+		iter := rv.MapRange()
+		for iter.Next() {
+			pairs = append(pairs, pyJSONPair{key: fmt.Sprint(iter.Key().Interface()), value: iter.Value().Interface()})
+		}
+		sort.Slice(pairs, func(i, j int) bool { return pairs[i].key < pairs[j].key })
+		return enc.encodeObject(pairs, level)
+
+	case reflect.Slice, reflect.Array:
+		if rv.Kind() == reflect.Slice && rv.IsNil() {
+			enc.sb.WriteString("null")
+			return nil
+		}
+		return enc.encodeArray(rv, level)
+	}
+
+	return fmt.Errorf("object of type %T is not JSON serializable", v)
+}
+
+func (enc *pyJSONEncoder) encodeObject(pairs []pyJSONPair, level int) error {
+	if len(pairs) == 0 {
+		enc.sb.WriteString("{}")
+		return nil
+	}
+
+	enc.sb.WriteByte('{')
+
+	for i, pair := range pairs {
+		if i > 0 {
+			enc.sb.WriteString(enc.itemSep)
+		}
+		enc.newline(level + 1)
+		enc.encodeString(pair.key)
+		enc.sb.WriteString(enc.keySep)
+		if err := enc.encode(pair.value, level+1); err != nil {
+			return err
+		}
+	}
+
+	enc.newline(level)
+	enc.sb.WriteByte('}')
+
+	return nil
+}
+
+func (enc *pyJSONEncoder) encodeArray(rv reflect.Value, level int) error {
+	if rv.Len() == 0 {
+		enc.sb.WriteString("[]")
+		return nil
+	}
+
+	enc.sb.WriteByte('[')
+
+	for i := range rv.Len() {
+		if i > 0 {
+			enc.sb.WriteString(enc.itemSep)
+		}
+		enc.newline(level + 1)
+		if err := enc.encode(rv.Index(i).Interface(), level+1); err != nil {
+			return err
+		}
+	}
+
+	enc.newline(level)
+	enc.sb.WriteByte(']')
+
+	return nil
+}
+
+func (enc *pyJSONEncoder) newline(level int) {
+	if !enc.pretty {
+		return
+	}
+
+	enc.sb.WriteByte('\n')
+	enc.sb.WriteString(strings.Repeat(enc.indent, level))
+}
+
+func (enc *pyJSONEncoder) encodeString(s string) {
+	enc.sb.WriteByte('"')
+
+	for _, r := range s {
+		switch {
+		case r == '"':
+			enc.sb.WriteString(`\"`)
+		case r == '\\':
+			enc.sb.WriteString(`\\`)
+		case r == '\n':
+			enc.sb.WriteString(`\n`)
+		case r == '\r':
+			enc.sb.WriteString(`\r`)
+		case r == '\t':
+			enc.sb.WriteString(`\t`)
+		case r == '\b':
+			enc.sb.WriteString(`\b`)
+		case r == '\f':
+			enc.sb.WriteString(`\f`)
+		case r < 0x20:
+			fmt.Fprintf(&enc.sb, `\u%04x`, r)
+		case enc.ensureASCII && r > 0x7e:
+			if r > 0xffff {
+				r1, r2 := utf16.EncodeRune(r)
+				fmt.Fprintf(&enc.sb, `\u%04x\u%04x`, r1, r2)
+				continue
+			}
+			fmt.Fprintf(&enc.sb, `\u%04x`, r)
+		default:
+			enc.sb.WriteRune(r)
+		}
+	}
+
+	enc.sb.WriteByte('"')
+}
+
+// pyFloat formats a float the way python's json module does. Numbers decoded
+// from a JSON request are always floats in Go, so whole numbers are written
+// without a fraction to match what python writes for the original integer.
+func pyFloat(f float64) string {
+	switch {
+	case math.IsNaN(f):
+		return "NaN"
+	case math.IsInf(f, 1):
+		return "Infinity"
+	case math.IsInf(f, -1):
+		return "-Infinity"
+	case f == math.Trunc(f) && math.Abs(f) < 1e16:
+		return strconv.FormatInt(int64(f), 10)
+	}
+
+	if exp := math.Floor(math.Log10(math.Abs(f))); exp < -4 || exp >= 16 {
+		return strconv.FormatFloat(f, 'e', -1, 64)
+	}
+
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
+
+// =============================================================================
+
+// strftime formats the time using the python strftime directives. The '-'
+// flag removes the padding like it does with glibc. Unknown directives are
+// written as is.
+func strftime(t time.Time, format string) string {
+	var sb strings.Builder
+
+	for i := 0; i < len(format); i++ {
+		if format[i] != '%' || i+1 == len(format) {
+			sb.WriteByte(format[i])
+			continue
+		}
+
+		i++
+
+		pad := true
+		if format[i] == '-' && i+1 < len(format) {
+			pad = false
+			i++
+		}
+
+		num := func(v int, width int) {
+			if pad {
+				fmt.Fprintf(&sb, "%0*d", width, v)
+				return
+			}
+			sb.WriteString(strconv.Itoa(v))
+		}
+
+		switch format[i] {
+		case 'a':
+			sb.WriteString(t.Format("Mon"))
+		case 'A':
+			sb.WriteString(t.Format("Monday"))
+		case 'b', 'h':
+			sb.WriteString(t.Format("Jan"))
+		case 'B':
+			sb.WriteString(t.Format("January"))
+		case 'c':
+			sb.WriteString(t.Format("Mon Jan _2 15:04:05 2006"))
+		case 'd':
+			num(t.Day(), 2)
+		case 'e':
+			fmt.Fprintf(&sb, "%2d", t.Day())
+		case 'f':
+			num(t.Nanosecond()/1000, 6)
+		case 'H':
+			num(t.Hour(), 2)
+		case 'I':
+			num((t.Hour()+11)%12+1, 2)
+		case 'j':
+			num(t.YearDay(), 3)
+		case 'm':
+			num(int(t.Month()), 2)
+		case 'M':
+			num(t.Minute(), 2)
+		case 'p':
+			sb.WriteString(t.Format("PM"))
+		case 'S':
+			num(t.Second(), 2)
+		case 'u':
+			num((int(t.Weekday())+6)%7+1, 1)
+		case 'U':
+			num((t.YearDay()+6-int(t.Weekday()))/7, 2)
+		case 'w':
+			num(int(t.Weekday()), 1)
+		case 'W':
+			num((t.YearDay()+6-(int(t.Weekday())+6)%7)/7, 2)
+		case 'x':
+			sb.WriteString(t.Format("01/02/06"))
+		case 'X':
+			sb.WriteString(t.Format("15:04:05"))
+		case 'y':
+			num(t.Year()%100, 2)
+		case 'Y':
+			num(t.Year(), 4)
+		case 'z':
+			sb.WriteString(t.Format("-0700"))
+		case 'Z':
+			sb.WriteString(t.Format("MST"))
+		case '%':
+			sb.WriteByte('%')
+		default:
+			sb.WriteByte('%')
+			if !pad {
+				sb.WriteByte('-')
+			}
+			sb.WriteByte(format[i])
+		}
+	}
+
+	return sb.String()
+}
+
+// =============================================================================
+
+// argument returns the positional argument at the index or the keyword
+// argument with the name. It returns nil when neither was provided.
+func argument(params *exec.VarArgs, index int, name string) *exec.Value {
+	if index < len(params.Args) {
+		return params.Args[index]
+	}
+
+	if v, exists := params.KwArgs[name]; exists {
+		return v
+	}
+
+	return nil
+}
+
+func optionalString(params *exec.VarArgs, index int, name string) (string, bool, error) {
+	v := argument(params, index, name)
+	if v == nil || v.IsNil() {
+		return "", false, nil
+	}
+
+	if !v.IsString() {
+		return "", false, fmt.Errorf("%s must be a string, got %s", name, v.String())
+	}
+
+	return v.String(), true, nil
+}
+
+func optionalInt(params *exec.VarArgs, index int, name string, def int) (int, error) {
+	v := argument(params, index, name)
+	if v == nil || v.IsNil() {
+		return def, nil
+	}
+
+	if !v.IsInteger() {
+		return 0, fmt.Errorf("%s must be an integer, got %s", name, v.String())
+	}
+
+	return v.Integer(), nil
+}
+
+func isPySpace(r rune) bool {
+	return unicode.IsSpace(r) || (r >= 0x1c && r <= 0x1f)
+}
+
+// splitSpace splits the string on runs of whitespace like python's split
+// does when no separator is provided.
+func splitSpace(s string, maxSplit int) []string {
+	if maxSplit < 0 {
+		return strings.FieldsFunc(s, isPySpace)
+	}
+
+	parts := []string{}
+
+	for {
+		s = strings.TrimLeftFunc(s, isPySpace)
+		if s == "" {
+			return parts
+		}
+
+		if len(parts) == maxSplit {
+			return append(parts, s)
+		}
+
+		end := strings.IndexFunc(s, isPySpace)
+		if end == -1 {
+			return append(parts, s)
+		}
+
+		parts = append(parts, s[:end])
+		s = s[end:]
+	}
+}
+
+func sortedKeys(m map[string]any) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+
+	slices.Sort(keys)
+
+	return keys
+}
+
+// sortedItems returns [][]any where each inner slice is [key, value]. This
+// allows gonja to unpack: for k, v in dict.items()
+func sortedItems(m map[string]any) [][]any {
+	items := make([][]any, 0, len(m))
+	for _, key := range sortedKeys(m) {
+		items = append(items, []any{key, m[key]})
+	}
+
+	return items
+}
@@ -0,0 +1,223 @@
+package model
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Test_TemplateConformance renders the templates in testdata/templates and
+// compares the prompt with the golden file holding the output of
+// transformers' apply_chat_template for the same input. The golden files are
+// rendered by testdata/templates/render.py, which also downloads the
+// templates shipped in the catalog. A template without an input file of its
+// own is rendered with default.json. Go maps have no order, so the keys in
+// the input files are sorted to match the order python keeps when tojson is
+// used.
+func Test_TemplateConformance(t *testing.T) {
+	files, err := filepath.Glob("testdata/templates/*.jinja")
+	if err != nil {
+		t.Fatalf("glob templates: %v", err)
+	}
+
+	if len(files) == 0 {
+		t.Fatal("no templates found")
+	}
+
+	for _, file := range files {
+		name := strings.TrimSuffix(filepath.Base(file), ".jinja")
+
+		t.Run(name, func(t *testing.T) {
+			source, err := readJinjaTemplate(file)
+			if err != nil {
+				t.Fatalf("read template: %v", err)
+			}
+
+			input, err := os.ReadFile(strings.TrimSuffix(file, ".jinja") + ".json")
+			if errors.Is(err, os.ErrNotExist) {
+				input, err = os.ReadFile("testdata/templates/default.json")
+			}
+			if err != nil {
+				t.Fatalf("read input: %v", err)
+			}
+
+			golden, err := os.ReadFile(strings.TrimSuffix(file, ".jinja") + ".golden")
+			if err != nil {
+				t.Fatalf("read golden: %v", err)
+			}
+
+			var d D
+			if err := json.Unmarshal(input, &d); err != nil {
+				t.Fatalf("unmarshal input: %v", err)
+			}
+
+			// The date is pinned to the one render.py uses.
+			d["strftime_now"] = func(format string) string {
+				return strftime(time.Date(2024, time.July, 5, 14, 3, 9, 0, time.UTC), format)
+			}
+
+			tmpl, err := newTemplateWithFixedItems(source)
+			if err != nil {
+				t.Fatalf("parse template: %v", err)
+			}
+
+			got, err := executeTemplate(tmpl, d)
+			if err != nil {
+				t.Fatalf("execute template: %v", err)
+			}
+
+			if got != string(golden) {
+				t.Errorf("prompt doesn't match the golden file\ngot:\n%s\nexp:\n%s", got, golden)
+			}
+		})
+	}
+}
+
+func Test_TemplateError(t *testing.T) {
+	tmpl, err := newTemplateWithFixedItems(`{%- for message in messages %}{%- if not loop.first and message.role == loop.previtem.role %}{{ raise_exception("roles must alternate") }}{%- endif %}{{ message.content }}{%- endfor %}`)
+	if err != nil {
+		t.Fatalf("parse template: %v", err)
+	}
+
+	d := D{
+		"messages": []D{
+			{"role": "user", "content": "hello"},
+			{"role": "user", "content": "hello again"},
+		},
+	}
+
+	_, err = executeTemplate(tmpl, d)
+
+	var te *TemplateError
+	if !errors.As(err, &te) {
+		t.Fatalf("expected a template error, got %v", err)
+	}
+
+	if te.Message != "roles must alternate" {
+		t.Errorf("expected the message from the template, got %q", te.Message)
+	}
+
+	d["messages"] = []D{
+		{"role": "user", "content": "hello"},
+		{"role": "assistant", "content": "hi"},
+	}
+
+	got, err := executeTemplate(tmpl, d)
+	if err != nil {
+		t.Fatalf("execute template: %v", err)
+	}
+
+	if got != "hellohi" {
+		t.Errorf("expected hellohi, got %q", got)
+	}
+}
+
+func Test_Strftime(t *testing.T) {
+	tm := time.Date(2024, time.July, 5, 14, 3, 9, 0, time.UTC)
+
+	tests := []struct {
+		format string
+		exp    string
+	}{
+		{"%d %b %Y", "05 Jul 2024"},
+		{"%Y-%m-%d", "2024-07-05"},
+		{"%A, %B %-d", "Friday, July 5"},
+		{"%H:%M:%S %p", "14:03:09 PM"},
+		{"%I %j %y %%", "02 187 24 %"},
+		{"%Q", "%Q"},
+	}
+
+	for _, tt := range tests {
+		if got := strftime(tm, tt.format); got != tt.exp {
+			t.Errorf("%s: expected %q, got %q", tt.format, tt.exp, got)
+		}
+	}
+}
+
+func Test_ToJSON(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+		exp    string
+	}{
+		{"default", `{{ value|tojson }}`, `{"a": [1, 2.5, "é<\n"], "b": null, "c": true}`},
+		{"ensure ascii", `{{ value|tojson(ensure_ascii=true) }}`, `{"a": [1, 2.5, "\u00e9<\n"], "b": null, "c": true}`},
+		{"indent", `{{ value|tojson(indent=2) }}`, "{\n  \"a\": [\n    1,\n    2.5,\n    \"é<\\n\"\n  ],\n  \"b\": null,\n  \"c\": true\n}"},
+		{"separators", `{{ value|tojson(separators=[",", ":"]) }}`, `{"a":[1,2.5,"é<\n"],"b":null,"c":true}`},
+		{"literal", `{{ {"z": 1, "a": []}|tojson }}`, `{"z": 1, "a": []}`},
+		{"sort keys", `{{ {"z": 1, "a": []}|tojson(sort_keys=true) }}`, `{"a": [], "z": 1}`},
+	}
+
+	d := D{
+		"value": D{
+			"a": []any{1, 2.5, "é<\n"},
+			"b": nil,
+			"c": true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpl, err := newTemplateWithFixedItems(tt.source)
+			if err != nil {
+				t.Fatalf("parse template: %v", err)
+			}
+
+			got, err := executeTemplate(tmpl, d)
+			if err != nil {
+				t.Fatalf("execute template: %v", err)
+			}
+
+			if got != tt.exp {
+				t.Errorf("expected %s, got %s", tt.exp, got)
+			}
+		})
+	}
+}
+
+func Test_TemplateMethods(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+		exp    string
+	}{
+		{"strip", `[{{ " \n a \n ".strip() }}|{{ " a ".lstrip() }}|{{ " a ".rstrip() }}|{{ "xax".strip("x") }}]`, "[a|a | a|a]"},
+		{"split", `{{ " a  b c ".split()|join(",") }}|{{ "a b c".split(none, 1)|join(",") }}|{{ "a,b".split(",")[-1].upper() }}`, "a,b,c|a,b c|B"},
+		{"replace", `{{ "aaa".replace("a", "b") }}|{{ "aaa".replace("a", "b", 2) }}`, "bbb|bba"},
+		{"trim", `[{{ "\n a \n"|trim }}]`, "[a]"},
+		{"dict get", `{{ value.get("a") }}|{{ value.get("x", "def") }}|{{ value.get("x") is none }}`, "1|def|True"},
+		{"dict values", `{{ value.values()|join(",") }}|{% for k, v in value.items() %}{{ k }}={{ v }} {% endfor %}`, "1,2|a=1 b=2 "},
+		{"reverse", `{% for v in list[::-1] %}{{ v }}{% endfor %}|{{ list|reverse|join }}`, "213|213"},
+		{"conditional", `{{ "x" + ("a" if list|length > 5 else ("b" if list[0] == 3 else "c")) }}`, "xb"},
+		{"loop items", `{% for v in list %}{{ loop.previtem }}-{{ loop.nextitem }} {% endfor %}`, "-1 3-2 1- "},
+		{"string literal", `{{ "x[::-1] loop.previtem" }}|{{ 'it\'s loop.nextitem' ~ list[::-1]|join }}`, "x[::-1] loop.previtem|it's loop.nextitem213"},
+		{"raw", `{% raw %}{{ list[::-1] }} {{ loop.previtem }}{% endraw %}`, "{{ list[::-1] }} {{ loop.previtem }}"},
+	}
+
+	d := D{
+		"value": D{"a": 1, "b": 2},
+		"list":  []any{3, 1, 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpl, err := newTemplateWithFixedItems(tt.source)
+			if err != nil {
+				t.Fatalf("parse template: %v", err)
+			}
+
+			got, err := executeTemplate(tmpl, d)
+			if err != nil {
+				t.Fatalf("execute template: %v", err)
+			}
+
+			if got != tt.exp {
+				t.Errorf("expected %q, got %q", tt.exp, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,242 @@
+package model
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/hybridgroup/yzma/pkg/llama"
+)
+
+// defAdapterScale is the scale used when an adapter is selected by a request
+// without providing a scale and the adapter has no configured scale.
+const defAdapterScale = 1.0
+
+// adapter represents a LoRA adapter that has been loaded for the model.
+type adapter struct {
+	name  string
+	scale float32
+	lora  llama.AdapterLora
+}
+
+// adapterSelection represents an adapter a request wants applied.
+type adapterSelection struct {
+	name  string
+	scale float32
+}
+
+func loadAdapters(mdl llama.Model, cfgs []AdapterConfig) ([]adapter, error) {
+	adapters := make([]adapter, 0, len(cfgs))
+
+	for _, cfg := range cfgs {
+		lora, err := llama.AdapterLoraInit(mdl, cfg.Path)
+		if err != nil || lora == 0 {
+			for _, a := range adapters {
+				llama.AdapterLoraFree(a.lora)
+			}
+
+			return nil, fmt.Errorf("load-adapters: unable to load adapter %q", cfg.Path)
+		}
+
+		adapters = append(adapters, adapter{
+			name:  AdapterName(cfg.Path),
+			scale: cfg.Scale,
+			lora:  lora,
+		})
+	}
+
+	return adapters, nil
+}
+
+func freeAdapters(adapters []adapter) {
+	for _, a := range adapters {
+		llama.AdapterLoraFree(a.lora)
+	}
+}
+
+// AdapterName returns the name of the adapter stored in the adapter file,
+// which is the lower case file name without the extension.
+func AdapterName(adapterFile string) string {
+	name := filepath.Base(adapterFile)
+	return strings.ToLower(strings.TrimSuffix(name, filepath.Ext(name)))
+}
+
+// MatchAdapter reports if the name selects the adapter with the specified
+// adapter name. Names are not case sensitive and adapter files stored by the
+// models system carry a "lora-" prefix that callers shouldn't need to
+// provide.
+func MatchAdapter(adapterName string, name string) bool {
+	name = strings.ToLower(name)
+
+	return adapterName == name || strings.TrimPrefix(adapterName, "lora-") == name
+}
+
+// applyAdapters applies the selected adapters to the context. If the request
+// didn't select any adapters, the adapters with a configured scale are used.
+// The adapters that were applied are returned in the form "name:scale".
+func (m *Model) applyAdapters(lctx llama.Context, selections []adapterSelection, selected bool) ([]string, error) {
+	if len(m.adapters) == 0 {
+		if len(selections) > 0 {
+			return nil, fmt.Errorf("apply-adapters: model has no adapters loaded")
+		}
+
+		return nil, nil
+	}
+
+	var applied []string
+
+	if !selected {
+		for _, a := range m.adapters {
+			if a.scale == 0 {
+				continue
+			}
+
+			if rc := llama.SetAdapterLora(lctx, a.lora, a.scale); rc != 0 {
+				return nil, fmt.Errorf("apply-adapters: unable to apply adapter %q: rc[%d]", a.name, rc)
+			}
+
+			applied = append(applied, fmt.Sprintf("%s:%g", a.name, a.scale))
+		}
+
+		return applied, nil
+	}
+
+	for _, sel := range selections {
+		a, found := m.findAdapter(sel.name)
+		if !found {
+			return nil, fmt.Errorf("apply-adapters: adapter %q not found", sel.name)
+		}
+
+		scale := sel.scale
+		if scale == 0 {
+			scale = a.scale
+		}
+
+		if scale == 0 {
+			scale = defAdapterScale
+		}
+
+		if rc := llama.SetAdapterLora(lctx, a.lora, scale); rc != 0 {
+			return nil, fmt.Errorf("apply-adapters: unable to apply adapter %q: rc[%d]", a.name, rc)
+		}
+
+		applied = append(applied, fmt.Sprintf("%s:%g", a.name, scale))
+	}
+
+	return applied, nil
+}
+
+func (m *Model) findAdapter(name string) (adapter, bool) {
+	for _, a := range m.adapters {
+		if MatchAdapter(a.name, name) {
+			return a, true
+		}
+	}
+
+	return adapter{}, false
+}
+
+// =============================================================================
+
+// parseAdapters extracts the adapters selected by the request. The "adapter"
+// field accepts the name of a single adapter. The "lora" field accepts a name,
+// a list of names, or a list of documents with a name (or id) and scale. An
+// empty "lora" list selects no adapters and runs the base model.
+func parseAdapters(d D) ([]adapterSelection, bool, error) {
+	var selections []adapterSelection
+	var selected bool
+
+	if v, exists := d["adapter"]; exists && v != nil {
+		name, ok := v.(string)
+		if !ok {
+			return nil, false, fmt.Errorf("parse-adapters: adapter is not a string")
+		}
+
+		selected = true
+
+		if name != "" {
+			selections = append(selections, adapterSelection{name: name})
+		}
+	}
+
+	v, exists := d["lora"]
+	if !exists || v == nil {
+		return selections, selected, nil
+	}
+
+	selected = true
+
+	switch lora := v.(type) {
+	case string:
+		if lora != "" {
+			selections = append(selections, adapterSelection{name: lora})
+		}
+
+	case []string:
+		for _, name := range lora {
+			selections = append(selections, adapterSelection{name: name})
+		}
+
+	case []any:
+		for i, item := range lora {
+			sel, err := parseAdapterSelection(i, item)
+			if err != nil {
+				return nil, false, err
+			}
+
+			selections = append(selections, sel)
+		}
+
+	case []D:
+		for i, item := range lora {
+			sel, err := parseAdapterSelection(i, map[string]any(item))
+			if err != nil {
+				return nil, false, err
+			}
+
+			selections = append(selections, sel)
+		}
+
+	default:
+		return nil, false, fmt.Errorf("parse-adapters: lora is not a valid type")
+	}
+
+	return selections, selected, nil
+}
+
+func parseAdapterSelection(i int, item any) (adapterSelection, error) {
+	switch v := item.(type) {
+	case string:
+		return adapterSelection{name: v}, nil
+
+	case D:
+		return parseAdapterSelection(i, map[string]any(v))
+
+	case map[string]any:
+		name, _ := v["name"].(string)
+		if name == "" {
+			name, _ = v["id"].(string)
+		}
+
+		if name == "" {
+			return adapterSelection{}, fmt.Errorf("parse-adapters: lora[%d] is missing a name", i)
+		}
+
+		var scale float32
+		if scaleVal, exists := v["scale"]; exists {
+			var err error
+			scale, err = parseFloat32(fmt.Sprintf("lora[%d].scale", i), scaleVal)
+			if err != nil {
+				return adapterSelection{}, err
+			}
+
+			if scale < 0 {
+				return adapterSelection{}, fmt.Errorf("parse-adapters: lora[%d] scale can't be negative", i)
+			}
+		}
+
+		return adapterSelection{name: name, scale: scale}, nil
+	}
+
+	return adapterSelection{}, fmt.Errorf("parse-adapters: lora[%d] is not a valid type", i)
+}
@@ -0,0 +1,86 @@
+package model
+
+import (
+	"slices"
+	"testing"
+)
+
+func Test_ParseAdapters(t *testing.T) {
+	tests := []struct {
+		name     string
+		d        D
+		exp      []adapterSelection
+		selected bool
+		valid    bool
+	}{
+		{"none", D{}, nil, false, true},
+		{"adapter", D{"adapter": "math"}, []adapterSelection{{name: "math"}}, true, true},
+		{"empty adapter", D{"adapter": ""}, nil, true, true},
+		{"lora name", D{"lora": "math"}, []adapterSelection{{name: "math"}}, true, true},
+		{"lora names", D{"lora": []string{"math", "code"}}, []adapterSelection{{name: "math"}, {name: "code"}}, true, true},
+		{"lora empty list", D{"lora": []any{}}, nil, true, true},
+		{"lora documents", D{"lora": []any{map[string]any{"name": "math", "scale": 0.5}, map[string]any{"id": "code"}}}, []adapterSelection{{name: "math", scale: 0.5}, {name: "code"}}, true, true},
+		{"lora typed documents", D{"lora": []D{{"name": "math", "scale": 2}}}, []adapterSelection{{name: "math", scale: 2}}, true, true},
+		{"adapter and lora", D{"adapter": "math", "lora": []any{"code"}}, []adapterSelection{{name: "math"}, {name: "code"}}, true, true},
+		{"zero scale", D{"lora": []any{map[string]any{"name": "math", "scale": 0}}}, []adapterSelection{{name: "math"}}, true, true},
+		{"negative scale", D{"lora": []any{map[string]any{"name": "math", "scale": -0.5}}}, nil, false, false},
+		{"scale not a number", D{"lora": []any{map[string]any{"name": "math", "scale": "high"}}}, nil, false, false},
+		{"missing name", D{"lora": []any{map[string]any{"scale": 1}}}, nil, false, false},
+		{"adapter not a string", D{"adapter": 1}, nil, false, false},
+		{"lora not valid", D{"lora": 1}, nil, false, false},
+		{"lora item not valid", D{"lora": []any{1}}, nil, false, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, selected, err := parseAdapters(tt.d)
+
+			if tt.valid != (err == nil) {
+				t.Fatalf("expected valid %t, got %v", tt.valid, err)
+			}
+
+			if selected != tt.selected || !slices.Equal(got, tt.exp) {
+				t.Errorf("expected %v %t, got %v %t", tt.exp, tt.selected, got, selected)
+			}
+		})
+	}
+}
+
+func Test_FindAdapter(t *testing.T) {
+	m := Model{
+		adapters: []adapter{
+			{name: AdapterName("/models/org/lora-math.gguf")},
+			{name: AdapterName("/models/org/Code.gguf")},
+		},
+	}
+
+	tests := []struct {
+		name  string
+		exp   string
+		found bool
+	}{
+		{"lora-math", "lora-math", true},
+		{"math", "lora-math", true},
+		{"MATH", "lora-math", true},
+		{"code", "code", true},
+		{"lora-code", "", false},
+		{"unknown", "", false},
+	}
+
+	for _, tt := range tests {
+		a, found := m.findAdapter(tt.name)
+		if found != tt.found || a.name != tt.exp {
+			t.Errorf("%s: expected %q %t, got %q %t", tt.name, tt.exp, tt.found, a.name, found)
+		}
+	}
+
+	// Unknown names and models without adapters fail before any adapter is
+	// applied to the context.
+	if _, err := m.applyAdapters(0, []adapterSelection{{name: "unknown"}}, true); err == nil {
+		t.Error("expected an error for an unknown adapter")
+	}
+
+	if _, err := (&Model{}).applyAdapters(0, []adapterSelection{{name: "math"}}, true); err == nil {
+		t.Error("expected an error for a model without adapters")
+	}
+}
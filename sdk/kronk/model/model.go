@@ -7,11 +7,13 @@ import (
 	"fmt"
 	"io"
 	"strings"
+	"sync"
 	"sync/atomic"
 	"time"
 
 	"github.com/ardanlabs/kronk/sdk/observ/metrics"
 	"github.com/hybridgroup/yzma/pkg/llama"
+	"github.com/nikolalohinski/gonja/v2/exec"
 )
 
 // TemplateRetriever returns a configured template for a model.
@@ -19,17 +21,27 @@ type TemplateRetriever interface {
 	Retrieve(modelID string) (Template, error)
 }
 
+// MediaFetcher retrieves the media referenced by a URL in a request.
+type MediaFetcher interface {
+	Fetch(ctx context.Context, url string) ([]byte, error)
+}
+
 // Model represents a model and provides a low-level API for working with it.
 type Model struct {
-	cfg           Config
-	log           Logger
-	model         llama.Model
-	vocab         llama.Vocab
-	ctxParams     llama.ContextParams
-	template      Template
-	projFile      string
-	modelInfo     ModelInfo
-	activeStreams atomic.Int32
+	cfg              Config
+	log              Logger
+	model            llama.Model
+	vocab            llama.Vocab
+	ctxParams        llama.ContextParams
+	template         Template
+	compiledTemplate *exec.Template
+	projFile         string
+	modelInfo        ModelInfo
+	adapters         []adapter
+	activeStreams    atomic.Int32
+	panics           atomic.Int32
+	sessionsMu       sync.Mutex
+	sessions         map[string]*chatSession
 }
 
 func NewModel(tmlpRetriever TemplateRetriever, cfg Config) (*Model, error) {
@@ -41,13 +53,14 @@ func NewModel(tmlpRetriever TemplateRetriever, cfg Config) (*Model, error) {
 		return nil, fmt.Errorf("new-model: unable to validate config: %w", err)
 	}
 
-	mparams := llama.ModelDefaultParams()
-	if cfg.Device != "" {
-		dev := llama.GGMLBackendDeviceByName(cfg.Device)
-		if dev == 0 {
-			return nil, fmt.Errorf("new-model: unknown device: %s", cfg.Device)
-		}
-		mparams.SetDevices([]llama.GGMLBackendDevice{dev})
+	mparams, err := modelParams(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("new-model: %w", err)
+	}
+
+	if cfg.LoadProgress != nil {
+		release := setLoadProgress(&mparams, cfg.LoadProgress)
+		defer release()
 	}
 
 	// OTEL: WANT TO KNOW HOW LONG THESE FUNCTION CALLS TAKES
@@ -65,15 +78,41 @@ func NewModel(tmlpRetriever TemplateRetriever, cfg Config) (*Model, error) {
 
 	// -------------------------------------------------------------------------
 
+	adapters, err := loadAdapters(mdl, cfg.Adapters)
+	if err != nil {
+		llama.ModelFree(mdl)
+		return nil, fmt.Errorf("new-model: unable to load adapters: %w", err)
+	}
+
+	// -------------------------------------------------------------------------
+
 	modelInfo := toModelInfo(cfg, mdl)
 
+	for _, a := range adapters {
+		modelInfo.Adapters = append(modelInfo.Adapters, a.name)
+	}
+
 	template, err := retrieveTemplate(tmlpRetriever, cfg, mdl, modelInfo)
 	if err != nil {
+		freeAdapters(adapters)
+		llama.ModelFree(mdl)
 		return nil, fmt.Errorf("new-model: failed to retrieve model template: %w", err)
 	}
 
 	modelInfo.Template = template
 
+	// The template is compiled once here so a broken template fails the
+	// load instead of the first request.
+	var compiledTemplate *exec.Template
+	if template.Script != "" {
+		compiledTemplate, err = newTemplateWithFixedItems(template.Script)
+		if err != nil {
+			freeAdapters(adapters)
+			llama.ModelFree(mdl)
+			return nil, fmt.Errorf("new-model: failed to parse template %s: %w", template.FileName, err)
+		}
+	}
+
 	// -------------------------------------------------------------------------
 
 	l := cfg.Log
@@ -84,14 +123,16 @@ func NewModel(tmlpRetriever TemplateRetriever, cfg Config) (*Model, error) {
 	// -------------------------------------------------------------------------
 
 	m := Model{
-		cfg:       cfg,
-		log:       l,
-		model:     mdl,
-		vocab:     vocab,
-		ctxParams: modelCtxParams(cfg, modelInfo),
-		template:  template,
-		projFile:  cfg.ProjFile,
-		modelInfo: modelInfo,
+		cfg:              cfg,
+		log:              l,
+		model:            mdl,
+		vocab:            vocab,
+		ctxParams:        modelCtxParams(cfg, modelInfo),
+		template:         template,
+		compiledTemplate: compiledTemplate,
+		projFile:         cfg.ProjFile,
+		modelInfo:        modelInfo,
+		adapters:         adapters,
 	}
 
 	return &m, nil
@@ -148,12 +189,19 @@ func (m *Model) Unload(ctx context.Context) error {
 		}
 	}
 
+	freeAdapters(m.adapters)
 	llama.ModelFree(m.model)
 	llama.BackendFree()
 
 	return nil
 }
 
+// Panics returns the number of requests that panicked while being processed
+// by the model. The state of the model can't be trusted after a panic.
+func (m *Model) Panics() int {
+	return int(m.panics.Load())
+}
+
 func (m *Model) Config() Config {
 	return m.cfg
 }
@@ -162,7 +210,7 @@ func (m *Model) ModelInfo() ModelInfo {
 	return m.modelInfo
 }
 
-func (m *Model) processChatRequest(ctx context.Context, id string, lctx llama.Context, object string, prompt string, params Params, ch chan<- ChatResponse) {
+func (m *Model) processChatRequest(ctx context.Context, id string, lctx llama.Context, object string, prompt string, prefix string, params Params, session *chatSession, ch chan<- ChatResponse) {
 	// These are for token counting.
 	var (
 		inputTokens      int
@@ -185,6 +233,9 @@ func (m *Model) processChatRequest(ctx context.Context, id string, lctx llama.Co
 		finalTooling   strings.Builder
 	)
 
+	// A continued message starts with the content that was provided.
+	finalContent.WriteString(prefix)
+
 	// Index is used to provide the index for each response.
 	var index int
 
@@ -195,12 +246,14 @@ func (m *Model) processChatRequest(ctx context.Context, id string, lctx llama.Co
 	// -------------------------------------------------------------------------
 
 	// Process the prompt and get the first batch for the response.
-	sampler, batch, inputTokens, outputTokens := m.startProcessing(lctx, object, prompt, params)
+	sampler, batch, promptTokens, outputTokens := m.startProcessing(ctx, lctx, object, prompt, params, session)
 	defer llama.SamplerFree(sampler)
 
+	inputTokens = len(promptTokens)
+
 	// Check that we have not exceeded the context window.
 	if inputTokens > m.cfg.ContextWindow {
-		err := fmt.Errorf("process-chat-request: input tokens %d exceed context window %d", inputTokens, m.cfg.ContextWindow)
+		err := fmt.Errorf("process-chat-request: %w: input tokens %d exceed context window %d", ErrContextWindowExceeded, inputTokens, m.cfg.ContextWindow)
 		m.sendErrorResponse(ctx, ch, id, object, 0, prompt, err, Usage{
 			PromptTokens:     inputTokens,
 			ReasoningTokens:  reasonTokens,
@@ -295,7 +348,8 @@ loop:
 		// Do this if we are not processing tooling tokens.
 		if toolFlag == 0 {
 			// At the start or end of a mode we might have an extra CRLF we don't need.
-			if m.isUnncessaryCRLF(reasonFlag, completionFlag, resp.content) {
+			// A continued message keeps everything since it follows the prefix.
+			if !(prefix != "" && completionFlag > 0) && m.isUnncessaryCRLF(reasonFlag, completionFlag, resp.content) {
 				batch = m.nextBatch(token)
 				continue
 			}
@@ -348,6 +402,22 @@ loop:
 		}
 
 		outputTokens = reasonTokens + completionTokens
+
+		// ---------------------------------------------------------------------
+
+		// If the reasoning budget has been reached, follow the next token
+		// with the model's end of thinking tag so the model moves on to
+		// the answer. The tag tokens are counted like a closing tag the
+		// model produced itself. A model whose tag isn't known by its
+		// template keeps reasoning.
+		if reasoningBudgetReached(params, reasonFlag, reasonTokens) && processor.canEndReasoning(isGTP) {
+			m.log(ctx, "chat-completion", "status", "reasoning budget reached", "id", id, "reasoning-tokens", reasonTokens)
+
+			tokens := processor.endReasoning(token, isGTP)
+			batch = llama.BatchGetOne(tokens)
+			completionTokens += len(tokens) - 1
+			outputTokens = reasonTokens + completionTokens
+		}
 	}
 
 	// -------------------------------------------------------------------------
@@ -379,6 +449,18 @@ loop:
 
 	// -------------------------------------------------------------------------
 
+	// Persist the state of the prompt so the next request in this session
+	// doesn't need to process it again.
+	if session != nil {
+		session.tokens = promptTokens
+
+		if err := m.SaveSession(session.id); err != nil {
+			m.log(ctx, "chat-completion", "status", "unable to save session", "id", id, "session-id", session.id, "ERROR", err)
+		}
+	}
+
+	// -------------------------------------------------------------------------
+
 	// OTEL: ADD DATA TO OTEL SPAN
 
 	totalTokens := inputTokens + outputTokens
@@ -398,7 +480,7 @@ loop:
 	)
 }
 
-func (m *Model) startProcessing(lctx llama.Context, object string, prompt string, params Params) (llama.Sampler, llama.Batch, int, int) {
+func (m *Model) startProcessing(ctx context.Context, lctx llama.Context, object string, prompt string, params Params, session *chatSession) (llama.Sampler, llama.Batch, []llama.Token, int) {
 	// Apply any parameters to this request like temperature or top_p.
 	sampler := toSampler(params)
 
@@ -415,8 +497,14 @@ func (m *Model) startProcessing(lctx llama.Context, object string, prompt string
 		metrics.AddPrefillNonMediaTime(time.Since(start))
 	}
 
-	batch := llama.BatchGetOne(tokens)
-	inputTokens := int(batch.NTokens)
+	// If this request is part of a session, the state of the previous
+	// request is restored and only the new part of the prompt is processed.
+	var reused int
+	if session != nil && object != ObjectChatMedia {
+		reused = m.restoreSession(ctx, lctx, session, tokens)
+	}
+
+	batch := llama.BatchGetOne(tokens[reused:])
 
 	if object != ObjectChatMedia {
 		metrics.AddTimeToFirstToken(time.Since(start))
@@ -438,7 +526,13 @@ func (m *Model) startProcessing(lctx llama.Context, object string, prompt string
 		metrics.AddTimeToFirstToken(time.Since(start))
 	}
 
-	return sampler, batch, inputTokens, outputTokens
+	return sampler, batch, tokens, outputTokens
+}
+
+// reasoningBudgetReached reports whether the model is reasoning and has used
+// the reasoning budget for the request.
+func reasoningBudgetReached(params Params, reasonFlag int, reasonTokens int) bool {
+	return params.MaxReasoningTokens > 0 && reasonFlag > 0 && reasonTokens >= params.MaxReasoningTokens
 }
 
 func (m *Model) nextBatch(token llama.Token) llama.Batch {
@@ -479,6 +573,8 @@ func (m *Model) isUnncessaryCRLF(reasonFlag int, completionFlag int, content str
 }
 
 func (m *Model) sendDeltaResponse(ctx context.Context, ch chan<- ChatResponse, id string, object string, index int, prompt string, content string, reasonFlag int, usage Usage) error {
+	usage.QueueWaitMS = queueWait(ctx).Milliseconds()
+
 	if index%100 == 0 {
 		m.log(ctx, "chat-completion", "status", "delta", "id", id, "index", index, "object", object, "reasoning", reasonFlag, "content", len(content))
 	}
@@ -499,6 +595,8 @@ func (m *Model) sendDeltaResponse(ctx context.Context, ch chan<- ChatResponse, i
 }
 
 func (m *Model) sendFinalResponse(ctx context.Context, ch chan<- ChatResponse, id string, object string, index int, prompt string, finalContent *strings.Builder, finalReasoning *strings.Builder, respToolCalls []ResponseToolCall, usage Usage) {
+	usage.QueueWaitMS = queueWait(ctx).Milliseconds()
+
 	m.log(ctx, "chat-completion", "status", "final", "id", id, "index", index, "object", object, "tooling", len(respToolCalls) > 0, "reasoning", finalReasoning.Len(), "content", finalContent.Len())
 
 	select {
@@ -525,6 +623,8 @@ func (m *Model) sendFinalResponse(ctx context.Context, ch chan<- ChatResponse, i
 }
 
 func (m *Model) sendErrorResponse(ctx context.Context, ch chan<- ChatResponse, id string, object string, index int, prompt string, err error, usage Usage) {
+	usage.QueueWaitMS = queueWait(ctx).Milliseconds()
+
 	m.log(ctx, "chat-completion", "status", "ERROR", "msg", err, "id", id, "object", object, "index", index)
 
 	select {
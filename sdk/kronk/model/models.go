@@ -9,6 +9,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/ardanlabs/kronk/sdk/tools/gguf"
 	"github.com/hybridgroup/yzma/pkg/llama"
 )
 
@@ -31,6 +32,10 @@ const (
 	FinishReasonError = "error"
 )
 
+// ErrContextWindowExceeded is returned when the prompt or a session has more
+// tokens than fit in the context window of the model.
+var ErrContextWindowExceeded = errors.New("context window exceeded")
+
 // =============================================================================
 
 // ModelInfo represents the model's card information.
@@ -48,6 +53,7 @@ type ModelInfo struct {
 	Metadata      map[string]string
 	TemplateFile  string
 	Template      Template
+	Adapters      []string
 }
 
 func toModelInfo(cfg Config, model llama.Model) ModelInfo {
@@ -57,30 +63,7 @@ func toModelInfo(cfg Config, model llama.Model) ModelInfo {
 	decoder := llama.ModelHasDecoder(model)
 	recurrent := llama.ModelIsRecurrent(model)
 	hybrid := llama.ModelIsHybrid(model)
-	count := llama.ModelMetaCount(model)
-	metadata := make(map[string]string)
-
-	for i := range count {
-		func() {
-			defer func() {
-				if rec := recover(); rec != nil {
-					return
-				}
-			}()
-
-			key, ok := llama.ModelMetaKeyByIndex(model, i)
-			if !ok {
-				return
-			}
-
-			value, ok := llama.ModelMetaValStrByIndex(model, i)
-			if !ok {
-				return
-			}
-
-			metadata[key] = value
-		}()
-	}
+	metadata := modelMetadata(cfg, model)
 
 	filename := filepath.Base(cfg.ModelFile)
 	modelID := strings.TrimSuffix(filename, path.Ext(filename))
@@ -110,6 +93,43 @@ func toModelInfo(cfg Config, model llama.Model) ModelInfo {
 	}
 }
 
+// modelMetadata returns the metadata of the model. The metadata is read from
+// the model file so arrays, like the tokenizer vocabulary, are included in a
+// shortened form. If the file can't be read, the metadata llama.cpp can
+// provide as strings is used.
+func modelMetadata(cfg Config, model llama.Model) map[string]string {
+	if file, err := gguf.Read(cfg.ModelFile); err == nil {
+		return file.MetadataStrings()
+	}
+
+	count := llama.ModelMetaCount(model)
+	metadata := make(map[string]string)
+
+	for i := range count {
+		func() {
+			defer func() {
+				if rec := recover(); rec != nil {
+					return
+				}
+			}()
+
+			key, ok := llama.ModelMetaKeyByIndex(model, i)
+			if !ok {
+				return
+			}
+
+			value, ok := llama.ModelMetaValStrByIndex(model, i)
+			if !ok {
+				return
+			}
+
+			metadata[key] = value
+		}()
+	}
+
+	return metadata
+}
+
 // =============================================================================
 
 // D represents a generic docment of fields and values.
@@ -223,6 +243,7 @@ type Usage struct {
 	OutputTokens     int     `json:"output_tokens"`
 	TotalTokens      int     `json:"total_tokens"`
 	TokensPerSecond  float64 `json:"tokens_per_second"`
+	QueueWaitMS      int64   `json:"queue_wait_ms"`
 }
 
 // ChatResponse represents output for inference models.
@@ -234,6 +255,13 @@ type ChatResponse struct {
 	Choice  []Choice `json:"choices"`
 	Usage   Usage    `json:"usage"`
 	Prompt  string   `json:"prompt"`
+	err     error
+}
+
+// Err returns the error for a response with a FinishReasonError finish
+// reason so it can be checked with errors.Is.
+func (cr ChatResponse) Err() error {
+	return cr.err
 }
 
 func chatResponseDelta(id string, object string, model string, index int, content string, reasoning bool, u Usage) ChatResponse {
@@ -319,6 +347,7 @@ func ChatResponseErr(id string, object string, model string, index int, prompt s
 		},
 		Usage:  u,
 		Prompt: prompt,
+		err:    err,
 	}
 }
 
@@ -356,6 +385,25 @@ func toEmbedResponse(modelID string, vec []float32) EmbedReponse {
 
 // =============================================================================
 
+// MediaMarker represents the location of a media item in a rendered prompt.
+type MediaMarker struct {
+	Offset int `json:"offset"`
+	Size   int `json:"size"`
+}
+
+// RenderResponse represents the prompt the chat template creates for a chat
+// request. Tokens only counts the text in the prompt, the tokens for the
+// media are created by the projection model during inference.
+type RenderResponse struct {
+	Model       string        `json:"model"`
+	Prompt      string        `json:"prompt"`
+	Tokens      int           `json:"tokens"`
+	MediaMarker string        `json:"media_marker,omitempty"`
+	Media       []MediaMarker `json:"media,omitempty"`
+}
+
+// =============================================================================
+
 type chatMessageURLData struct {
 	// Only base64 encoded image is currently supported.
 	URL string `json:"url"`
@@ -50,6 +50,15 @@ const (
 	ReasoningEffortHigh = "high"
 )
 
+// These are the reasoning token budgets used for non-GPT models when a
+// reasoning effort is provided and no max_reasoning_tokens value is.
+const (
+	reasoningBudgetMinimal = 256
+	reasoningBudgetLow     = 1024
+	reasoningBudgetMedium  = 4096
+	reasoningBudgetHigh    = 16384
+)
+
 // Params represents the different options when using a model. The defaults are
 // used when these values are set to 0.
 //
@@ -89,15 +98,33 @@ const (
 // When set to an empty string, the default value is "true".
 //
 // ReasoningEffort is a string that specifies the level of reasoning effort to
-// use for GPT models.
+// use for GPT models. For non-GPT models, when provided and MaxReasoningTokens
+// is not, the effort is mapped to a reasoning budget: none disables thinking,
+// minimal is 256, low is 1024, medium is 4096, and high is 16384 tokens.
+//
+// MaxReasoningTokens is the maximum number of tokens the model can use for
+// reasoning. When the budget is reached, the model's end of thinking tag is
+// injected and the model moves on to the answer. The budget isn't enforced
+// when the model's template doesn't use a known end of thinking tag. These
+// tokens are included in MaxTokens.
+// When set to 0, there is no limit.
+//
+// ContinueFinalMessage determines if the model should continue the final
+// message of the request instead of starting a new one. The final message
+// must be an assistant message and its content is used as the start of the
+// reply (prefill), which is useful for steering the format of the response.
+// The content is included at the start of the final response.
+// When not set, the default value is false.
 type Params struct {
-	Temperature     float32 `json:"temperature"`
-	TopK            int32   `json:"top_k"`
-	TopP            float32 `json:"top_p"`
-	MinP            float32 `json:"min_p"`
-	MaxTokens       int     `json:"max_tokens"`
-	Thinking        string  `json:"enable_thinking"`
-	ReasoningEffort string  `json:"reasoning_effort"`
+	Temperature          float32 `json:"temperature" yaml:"temperature"`
+	TopK                 int32   `json:"top_k" yaml:"top_k"`
+	TopP                 float32 `json:"top_p" yaml:"top_p"`
+	MinP                 float32 `json:"min_p" yaml:"min_p"`
+	MaxTokens            int     `json:"max_tokens" yaml:"max_tokens"`
+	Thinking             string  `json:"enable_thinking" yaml:"enable_thinking"`
+	ReasoningEffort      string  `json:"reasoning_effort" yaml:"reasoning_effort"`
+	MaxReasoningTokens   int     `json:"max_reasoning_tokens" yaml:"max_reasoning_tokens"`
+	ContinueFinalMessage bool    `json:"continue_final_message" yaml:"continue_final_message"`
 }
 
 // AddParams can be used to add the configured parameters to the
@@ -116,6 +143,35 @@ func AddParams(p Params, d D) {
 	if p.ReasoningEffort != "" {
 		d["reasoning_effort"] = p.ReasoningEffort
 	}
+
+	if p.MaxReasoningTokens > 0 {
+		d["max_reasoning_tokens"] = p.MaxReasoningTokens
+	}
+
+	if p.ContinueFinalMessage {
+		d["continue_final_message"] = true
+	}
+}
+
+// addDefaultParams adds the configured default parameters to the document
+// for the fields the request didn't provide. Parameters set to their zero
+// value are not added.
+func addDefaultParams(d D, p Params) {
+	setDefault := func(key string, value any, isSet bool) {
+		if _, exists := d[key]; !exists && isSet {
+			d[key] = value
+		}
+	}
+
+	setDefault("temperature", p.Temperature, p.Temperature > 0)
+	setDefault("top_k", p.TopK, p.TopK > 0)
+	setDefault("top_p", p.TopP, p.TopP > 0)
+	setDefault("min_p", p.MinP, p.MinP > 0)
+	setDefault("max_tokens", p.MaxTokens, p.MaxTokens > 0)
+	setDefault("enable_thinking", p.Thinking, p.Thinking != "")
+	setDefault("reasoning_effort", p.ReasoningEffort, p.ReasoningEffort != "")
+	setDefault("max_reasoning_tokens", p.MaxReasoningTokens, p.MaxReasoningTokens > 0)
+	setDefault("continue_final_message", p.ContinueFinalMessage, p.ContinueFinalMessage)
 }
 
 func parseParams(d D) (Params, error) {
@@ -182,14 +238,38 @@ func parseParams(d D) (Params, error) {
 		}
 	}
 
+	var maxReasoningTokens int
+	if maxReasoningTokensVal, exists := d["max_reasoning_tokens"]; exists {
+		var err error
+		maxReasoningTokens, err = parseInt("max_reasoning_tokens", maxReasoningTokensVal)
+		if err != nil {
+			return Params{}, err
+		}
+
+		if maxReasoningTokens < 0 {
+			return Params{}, fmt.Errorf("max_reasoning_tokens can't be negative")
+		}
+	}
+
+	var continueFinalMessage bool
+	if continueFinalMessageVal, exists := d["continue_final_message"]; exists {
+		var err error
+		continueFinalMessage, err = parseBool("continue_final_message", continueFinalMessageVal)
+		if err != nil {
+			return Params{}, err
+		}
+	}
+
 	params := Params{
-		Temperature:     temp,
-		TopK:            int32(topK),
-		TopP:            topP,
-		MinP:            minP,
-		MaxTokens:       maxTokens,
-		Thinking:        strconv.FormatBool(enableThinking),
-		ReasoningEffort: reasoningEffort,
+		Temperature:          temp,
+		TopK:                 int32(topK),
+		TopP:                 topP,
+		MinP:                 minP,
+		MaxTokens:            maxTokens,
+		Thinking:             strconv.FormatBool(enableThinking),
+		ReasoningEffort:      reasoningEffort,
+		MaxReasoningTokens:   maxReasoningTokens,
+		ContinueFinalMessage: continueFinalMessage,
 	}
 
 	return adjustParams(params), nil
@@ -227,6 +307,41 @@ func adjustParams(p Params) Params {
 	return p
 }
 
+// applyReasoningEffort maps an explicitly provided reasoning effort to a
+// reasoning budget for non-GPT models, since only the GPT templates use the
+// reasoning effort. An effort of none disables thinking.
+func applyReasoningEffort(d D, p Params, isGPT bool) Params {
+	if isGPT || p.MaxReasoningTokens > 0 {
+		return p
+	}
+
+	if _, exists := d["reasoning_effort"]; !exists {
+		return p
+	}
+
+	switch p.ReasoningEffort {
+	case ReasoningEffortNone:
+		if _, exists := d["enable_thinking"]; !exists {
+			d["enable_thinking"] = false
+			p.Thinking = ThinkingDisabled
+		}
+
+	case ReasoningEffortMinimal:
+		p.MaxReasoningTokens = reasoningBudgetMinimal
+
+	case ReasoningEffortLow:
+		p.MaxReasoningTokens = reasoningBudgetLow
+
+	case ReasoningEffortMedium:
+		p.MaxReasoningTokens = reasoningBudgetMedium
+
+	case ReasoningEffortHigh:
+		p.MaxReasoningTokens = reasoningBudgetHigh
+	}
+
+	return p
+}
+
 func toSampler(p Params) llama.Sampler {
 	sampler := llama.SamplerChainInit(llama.SamplerChainDefaultParams())
 
@@ -320,6 +435,9 @@ func parseBool(fieldName string, val any) (bool, error) {
 		}
 
 		result = b
+
+	case bool:
+		result = v
 	}
 
 	return result, nil
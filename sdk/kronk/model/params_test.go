@@ -0,0 +1,71 @@
+package model
+
+import "testing"
+
+func Test_ReasoningBudget(t *testing.T) {
+	tests := []struct {
+		name     string
+		d        D
+		isGPT    bool
+		budget   int
+		thinking string
+	}{
+		{"no effort", D{}, false, 0, ThinkingEnabled},
+		{"explicit budget", D{"max_reasoning_tokens": 100, "reasoning_effort": "high"}, false, 100, ThinkingEnabled},
+		{"low effort", D{"reasoning_effort": "low"}, false, reasoningBudgetLow, ThinkingEnabled},
+		{"high effort", D{"reasoning_effort": "high"}, false, reasoningBudgetHigh, ThinkingEnabled},
+		{"no reasoning", D{"reasoning_effort": "none"}, false, 0, ThinkingDisabled},
+		{"gpt model", D{"reasoning_effort": "low"}, true, 0, ThinkingEnabled},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			params, err := parseParams(tt.d)
+			if err != nil {
+				t.Fatalf("parse params: %v", err)
+			}
+
+			params = applyReasoningEffort(tt.d, params, tt.isGPT)
+
+			if params.MaxReasoningTokens != tt.budget {
+				t.Errorf("expected budget %d, got %d", tt.budget, params.MaxReasoningTokens)
+			}
+
+			if params.Thinking != tt.thinking {
+				t.Errorf("expected thinking %s, got %s", tt.thinking, params.Thinking)
+			}
+		})
+	}
+
+	if _, err := parseParams(D{"max_reasoning_tokens": -1}); err == nil {
+		t.Error("expected an error for a negative budget")
+	}
+}
+
+func Test_DefaultParams(t *testing.T) {
+	defaults := Params{
+		Temperature: 0.2,
+		TopK:        20,
+		Thinking:    ThinkingDisabled,
+	}
+
+	d := D{"temperature": 0.9}
+	addDefaultParams(d, defaults)
+
+	params, err := parseParams(d)
+	if err != nil {
+		t.Fatalf("parse params: %v", err)
+	}
+
+	if params.Temperature != 0.9 {
+		t.Errorf("expected the request temperature, got %v", params.Temperature)
+	}
+
+	if params.TopK != 20 {
+		t.Errorf("expected the default top_k, got %d", params.TopK)
+	}
+
+	if params.Thinking != ThinkingDisabled {
+		t.Errorf("expected thinking to be disabled, got %s", params.Thinking)
+	}
+}
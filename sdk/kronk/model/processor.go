@@ -24,15 +24,21 @@ type response struct {
 }
 
 type processor struct {
-	model      *Model
-	status     int
-	collecting bool
+	model        *Model
+	status       int
+	collecting   bool
+	reasoningEnd string
+	tokenize     func(text string) []llama.Token
 }
 
 func newProcessor(m *Model) *processor {
 	return &processor{
-		model:  m,
-		status: statusCompletion,
+		model:        m,
+		status:       statusCompletion,
+		reasoningEnd: reasoningEndTag(m.template.Script),
+		tokenize: func(text string) []llama.Token {
+			return llama.Tokenize(m.vocab, text, false, true)
+		},
 	}
 }
 
@@ -163,6 +169,47 @@ func (p *processor) gpt(lctx llama.Context, batch llama.Batch, sampler llama.Sam
 
 // =============================================================================
 
+// gptReasoningEnd ends the analysis channel of a GPT model and starts the
+// final channel.
+const gptReasoningEnd = "<|end|><|start|>assistant<|channel|>final<|message|>"
+
+// reasoningEndTag returns the tag the template uses to end the reasoning of
+// the model. An empty string is returned when the template doesn't use a tag
+// the standard processor knows.
+func reasoningEndTag(script string) string {
+	if strings.Contains(script, "</think>") {
+		return "</think>"
+	}
+
+	return ""
+}
+
+// canEndReasoning reports whether the processor knows the tag that ends the
+// reasoning for the model.
+func (p *processor) canEndReasoning(isGPT bool) bool {
+	return isGPT || p.reasoningEnd != ""
+}
+
+// endReasoning returns the sampled token followed by the tokens that end the
+// reasoning for the model and moves the processor to processing the answer.
+// This is used when the reasoning budget for the request is reached. The
+// sampled token has already been sent to the client so it must be decoded
+// before the tag to keep the context in line with the response.
+func (p *processor) endReasoning(token llama.Token, isGPT bool) []llama.Token {
+	tag := p.reasoningEnd
+
+	if isGPT {
+		tag = gptReasoningEnd
+		p.collecting = true
+	}
+
+	p.status = statusCompletion
+
+	return append([]llama.Token{token}, p.tokenize(tag)...)
+}
+
+// =============================================================================
+
 func parseGPTToolCall(content string) []ResponseToolCall {
 	// .get_weather <|constrain|>json<|message|>{"location":"NYC"}
 	// .get_weather <|constrain|>json<|message|>{"location":"NYC"}
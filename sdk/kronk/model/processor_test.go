@@ -0,0 +1,94 @@
+package model
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/hybridgroup/yzma/pkg/llama"
+)
+
+func Test_EndReasoning(t *testing.T) {
+	tag := []llama.Token{900, 901}
+
+	tests := []struct {
+		name   string
+		script string
+		isGPT  bool
+		exp    []llama.Token
+	}{
+		{"standard", "{{ '<think>\\n' }}{{ content }}{{ '</think>' }}", false, []llama.Token{1, 2, 3, 900, 901, 4, 5}},
+		{"gpt", "<|channel|>analysis", true, []llama.Token{1, 2, 3, 900, 901, 4, 5}},
+		{"unknown tag", "<reasoning>{{ content }}</reasoning>", false, []llama.Token{1, 2, 3, 4, 5}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var tokenized []string
+
+			p := processor{
+				status:       statusReasoning,
+				reasoningEnd: reasoningEndTag(tt.script),
+				tokenize: func(text string) []llama.Token {
+					tokenized = append(tokenized, text)
+					return tag
+				},
+			}
+
+			params := Params{MaxReasoningTokens: 3}
+
+			var reasonTokens, completionTokens int
+			var decoded []llama.Token
+
+			// Run the reasoning past the budget the way the generation loop
+			// does, one sampled token at a time.
+			for token := llama.Token(1); token <= 5; token++ {
+				tokens := []llama.Token{token}
+
+				var reasonFlag int
+				switch p.status {
+				case statusReasoning:
+					reasonFlag = 1
+					reasonTokens++
+				default:
+					completionTokens++
+				}
+
+				if reasoningBudgetReached(params, reasonFlag, reasonTokens) && p.canEndReasoning(tt.isGPT) {
+					tokens = p.endReasoning(token, tt.isGPT)
+					completionTokens += len(tokens) - 1
+				}
+
+				decoded = append(decoded, tokens...)
+			}
+
+			if !slices.Equal(decoded, tt.exp) {
+				t.Errorf("expected decoded tokens %v, got %v", tt.exp, decoded)
+			}
+
+			// A model whose tag isn't known keeps reasoning.
+			if len(tokenized) == 0 {
+				if reasonTokens != 5 || p.status != statusReasoning {
+					t.Errorf("expected the reasoning to continue, got %d reasoning tokens and status %d", reasonTokens, p.status)
+				}
+				return
+			}
+
+			expTag := "</think>"
+			if tt.isGPT {
+				expTag = gptReasoningEnd
+			}
+
+			if len(tokenized) != 1 || tokenized[0] != expTag {
+				t.Errorf("expected the tag %q to be injected, got %q", expTag, tokenized)
+			}
+
+			if reasonTokens != 3 || completionTokens != 4 {
+				t.Errorf("expected 3 reasoning and 4 completion tokens, got %d and %d", reasonTokens, completionTokens)
+			}
+
+			if p.status != statusCompletion || p.collecting != tt.isGPT {
+				t.Errorf("expected completion status and collecting %t, got %d and %t", tt.isGPT, p.status, p.collecting)
+			}
+		})
+	}
+}
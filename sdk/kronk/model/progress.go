@@ -0,0 +1,61 @@
+package model
+
+import (
+	"sync"
+
+	"github.com/hybridgroup/yzma/pkg/llama"
+)
+
+// loadProgress routes the llama.cpp load progress callback to the function of
+// the model being loaded. The callback is registered once since every
+// registration allocates memory that is never released.
+var loadProgress = struct {
+	sync.Mutex
+	once     sync.Once
+	callback uintptr
+	next     uintptr
+	funcs    map[uintptr]func(progress float32)
+}{
+	funcs: make(map[uintptr]func(progress float32)),
+}
+
+// setLoadProgress sets the params to report the load progress to the function.
+// The returned function must be called once the model is loaded.
+func setLoadProgress(mparams *llama.ModelParams, f func(progress float32)) func() {
+	loadProgress.once.Do(func() {
+		var p llama.ModelParams
+		p.SetProgressCallback(dispatchLoadProgress)
+		loadProgress.callback = p.ProgressCallback
+	})
+
+	loadProgress.Lock()
+	defer loadProgress.Unlock()
+
+	loadProgress.next++
+	id := loadProgress.next
+	loadProgress.funcs[id] = f
+
+	mparams.ProgressCallback = loadProgress.callback
+	mparams.ProgressCallbackUserData = id
+
+	return func() {
+		loadProgress.Lock()
+		defer loadProgress.Unlock()
+
+		delete(loadProgress.funcs, id)
+	}
+}
+
+// dispatchLoadProgress is the llama.cpp callback. The user data identifies
+// the function to call. Returning true lets the load continue.
+func dispatchLoadProgress(progress float32, userData uintptr) uint8 {
+	loadProgress.Lock()
+	f := loadProgress.funcs[userData]
+	loadProgress.Unlock()
+
+	if f != nil {
+		f(progress)
+	}
+
+	return 1
+}
@@ -10,9 +10,7 @@ import (
 	"io"
 	"maps"
 	"os"
-	"sort"
 	"strings"
-	"time"
 
 	"github.com/hybridgroup/yzma/pkg/mtmd"
 	"github.com/nikolalohinski/gonja/v2"
@@ -21,25 +19,66 @@ import (
 	"github.com/nikolalohinski/gonja/v2/loaders"
 )
 
-func (m *Model) applyRequestJinjaTemplate(ctx context.Context, d D) (string, [][]byte, error) {
+func (m *Model) applyRequestJinjaTemplate(ctx context.Context, d D, continueFinal bool) (string, [][]byte, error) {
 	dCopy := make(D, len(d))
 	maps.Copy(dCopy, d)
 
+	// The generation prompt opens a new assistant turn at the end of the
+	// prompt. When the final message is being continued, that message must
+	// stay open instead.
+	addGenerationPrompt := true
+	if v, exists := d["add_generation_prompt"]; exists {
+		var err error
+		addGenerationPrompt, err = parseBool("add_generation_prompt", v)
+		if err != nil {
+			return "", nil, fmt.Errorf("apply-request-jinja-template: %w", err)
+		}
+	}
+
+	if continueFinal {
+		addGenerationPrompt = false
+	}
+
+	dCopy["add_generation_prompt"] = addGenerationPrompt
+
 	// We need to identify if there is media in the request. If there is
 	// we want to replace the actual media with a media marker `<__media__>`.
-	// We will move the media to it's own slice. The next call that will happen
-	// is `processBitmap` which will process the prompt and media.
+	// We will move the media to it's own slice in the order the markers
+	// appear in the prompt. The next call that will happen is `processBitmap`
+	// which will process the prompt and media.
 
 	var media [][]byte
 
-	for _, doc := range dCopy["messages"].([]D) {
-		if content, exists := doc["content"]; exists {
-			switch value := content.(type) {
+	if docs, ok := dCopy["messages"].([]D); ok {
+		msgs := make([]D, len(docs))
+
+		for i, doc := range docs {
+			switch content := doc["content"].(type) {
 			case []byte:
-				media = append(media, value)
+				media = append(media, content)
+				doc = copyDocument(doc)
 				doc["content"] = fmt.Sprintf("%s\n", mtmd.DefaultMarker())
+
+			case []mediaPart:
+				parts := make([]string, 0, len(content))
+				for _, part := range content {
+					if part.data == nil {
+						parts = append(parts, part.text)
+						continue
+					}
+
+					media = append(media, part.data)
+					parts = append(parts, mtmd.DefaultMarker())
+				}
+
+				doc = copyDocument(doc)
+				doc["content"] = strings.Join(parts, "\n")
 			}
+
+			msgs[i] = doc
 		}
+
+		dCopy["messages"] = msgs
 	}
 
 	prompt, err := m.applyJinjaTemplate(ctx, dCopy)
@@ -47,26 +86,71 @@ func (m *Model) applyRequestJinjaTemplate(ctx context.Context, d D) (string, [][
 		return "", nil, err
 	}
 
+	if continueFinal {
+		msgs, _ := dCopy["messages"].([]D)
+
+		prompt, err = openFinalMessage(prompt, msgs)
+		if err != nil {
+			return "", nil, err
+		}
+	}
+
 	return prompt, media, nil
 }
 
-func (m *Model) applyJinjaTemplate(ctx context.Context, d D) (string, error) {
-	m.log(ctx, "applyJinjaTemplate", "template", m.template.FileName)
+// openFinalMessage removes everything the template added after the content
+// of the final assistant message, like the end of turn token, so the model
+// continues the message.
+func openFinalMessage(prompt string, msgs []D) (string, error) {
+	content, err := finalMessageContent(msgs)
+	if err != nil {
+		return "", err
+	}
 
-	if m.template.Script == "" {
-		return "", errors.New("apply-jinja-template: no template found")
+	if content == "" {
+		return prompt, nil
+	}
+
+	// Templates often trim the content of a message, so look for the trimmed
+	// content if the content isn't found as is.
+	for _, c := range []string{content, strings.TrimSpace(content)} {
+		if idx := strings.LastIndex(prompt, c); idx != -1 {
+			return prompt[:idx+len(c)], nil
+		}
 	}
 
-	gonja.DefaultLoader = &noFSLoader{}
+	return "", errors.New("open-final-message: unable to find the content of the final message in the prompt")
+}
 
-	t, err := newTemplateWithFixedItems(m.template.Script)
-	if err != nil {
-		return "", fmt.Errorf("apply-jinja-template: failed to parse template: %w", err)
+// finalMessageContent returns the content of the final message, which must be
+// an assistant message when the final message is continued.
+func finalMessageContent(msgs []D) (string, error) {
+	if len(msgs) == 0 {
+		return "", errors.New("final-message-content: no messages found in request")
 	}
 
-	data := exec.NewContext(d)
+	final := msgs[len(msgs)-1]
+
+	if role, _ := final["role"].(string); role != RoleAssistant {
+		return "", errors.New("final-message-content: the final message must be an assistant message to continue it")
+	}
 
-	s, err := t.ExecuteToString(data)
+	content, ok := final["content"].(string)
+	if !ok {
+		return "", errors.New("final-message-content: the content of the final message must be a string to continue it")
+	}
+
+	return content, nil
+}
+
+func (m *Model) applyJinjaTemplate(ctx context.Context, d D) (string, error) {
+	m.log(ctx, "applyJinjaTemplate", "template", m.template.FileName)
+
+	if m.compiledTemplate == nil {
+		return "", errors.New("apply-jinja-template: no template found")
+	}
+
+	s, err := executeTemplate(m.compiledTemplate, d)
 	if err != nil {
 		return "", fmt.Errorf("apply-jinja-template: failed to execute template: %w", err)
 	}
@@ -94,44 +178,31 @@ func (nl *noFSLoader) Inherit(from string) (loaders.Loader, error) {
 
 // newTemplateWithFixedItems creates a gonja template with a fixed items() method
 // that properly returns key-value pairs (the built-in one only returns values).
+// The source and environment are adjusted to render the way transformers
+// does, see jinja.go for the details.
+// The template can't access the filesystem and is safe to execute from
+// multiple goroutines, so it's compiled once when the model is loaded.
 func newTemplateWithFixedItems(source string) (*exec.Template, error) {
-	rootID := fmt.Sprintf("root-%s", string(sha256.New().Sum([]byte(source))))
+	rootID := fmt.Sprintf("root-%x", sha256.Sum256([]byte(source)))
 
-	loader, err := loaders.NewFileSystemLoader("")
-	if err != nil {
-		return nil, err
-	}
+	source = rewriteTemplateSource(source)
 
-	shiftedLoader, err := loaders.NewShiftedLoader(rootID, bytes.NewReader([]byte(source)), loader)
+	shiftedLoader, err := loaders.NewShiftedLoader(rootID, bytes.NewReader([]byte(source)), &noFSLoader{})
 	if err != nil {
 		return nil, err
 	}
 
-	// Create custom environment with fixed items() method
+	// Create custom environment with the functions, filters and methods
+	// the chat templates written for transformers expect.
 	customContext := builtins.GlobalFunctions.Inherit()
-	customContext.Set("add_generation_prompt", true)
-	customContext.Set("strftime_now", func(format string) string {
-		return time.Now().Format("2006-01-02")
-	})
-	customContext.Set("raise_exception", func(msg string) (string, error) {
-		return "", errors.New(msg)
-	})
-
-	customFilters := builtins.Filters.Update(exec.NewFilterSet(map[string]exec.FilterFunction{}))
-	customFilters.Register("items", func(e *exec.Evaluator, in *exec.Value, params *exec.VarArgs) *exec.Value {
-		if !in.IsDict() {
-			return exec.AsValue([][]any{})
-		}
-		dict := in.ToGoSimpleType(false)
-		if m, ok := dict.(map[string]any); ok {
-			items := make([][]any, 0, len(m))
-			for key, value := range m {
-				items = append(items, []any{key, value})
-			}
-			return exec.AsValue(items)
-		}
-		return exec.AsValue([][]any{})
-	})
+	customContext.Set("strftime_now", strftimeNow)
+	customContext.Set("raise_exception", raiseException)
+
+	customFilters := exec.NewFilterSet(map[string]exec.FilterFunction{}).Update(builtins.Filters)
+	customFilters.Replace("items", itemsFilter)
+	customFilters.Replace("reverse", reverseFilter)
+	customFilters.Replace("tojson", tojsonFilter)
+	customFilters.Replace("trim", trimFilter)
 
 	env := exec.Environment{
 		Context:           customContext,
@@ -139,32 +210,8 @@ func newTemplateWithFixedItems(source string) (*exec.Template, error) {
 		Tests:             builtins.Tests,
 		ControlStructures: builtins.ControlStructures,
 		Methods: exec.Methods{
-			Dict: exec.NewMethodSet(map[string]exec.Method[map[string]any]{
-				"keys": func(self map[string]any, selfValue *exec.Value, arguments *exec.VarArgs) (any, error) {
-					if err := arguments.Take(); err != nil {
-						return nil, err
-					}
-					keys := make([]string, 0, len(self))
-					for key := range self {
-						keys = append(keys, key)
-					}
-					sort.Strings(keys)
-					return keys, nil
-				},
-				"items": func(self map[string]any, selfValue *exec.Value, arguments *exec.VarArgs) (any, error) {
-					if err := arguments.Take(); err != nil {
-						return nil, err
-					}
-					// Return [][]any where each inner slice is [key, value]
-					// This allows gonja to unpack: for k, v in dict.items()
-					items := make([][]any, 0, len(self))
-					for key, value := range self {
-						items = append(items, []any{key, value})
-					}
-					return items, nil
-				},
-			}),
-			Str:   builtins.Methods.Str,
+			Dict:  templateDictMethods(),
+			Str:   templateStrMethods(),
 			List:  builtins.Methods.List,
 			Bool:  builtins.Methods.Bool,
 			Float: builtins.Methods.Float,
@@ -202,93 +249,102 @@ func isOpenAIMediaRequest(req D) (chatMessages, bool, error) {
 	return msgs, false, nil
 }
 
-func toMediaMessage(req D, msgs chatMessages) (D, error) {
-	type mediaMessage struct {
-		text string
-		data []byte
-	}
+// mediaPart represents one part of a message with media content. A part with
+// data is media and will be replaced with a media marker when the prompt is
+// created, otherwise the part is text.
+type mediaPart struct {
+	text string
+	data []byte
+}
 
-	var mediaMessages []mediaMessage
+// toMediaMessage converts the OpenAI content parts of each message into media
+// parts, preserving the role of the message and the order of the parts.
+func toMediaMessage(ctx context.Context, req D, msgs chatMessages, fetcher MediaFetcher) (D, error) {
+	docs, _ := req["messages"].([]D)
 
-	var found int
-	var mediaText string
-	var mediaData string
+	mediaDocs := make([]D, len(msgs.Messages))
 
-	// -------------------------------------------------------------------------
+	for i, msg := range msgs.Messages {
+		// Start with the original document so fields like tool calls are
+		// not lost in the conversion.
+		doc := D{}
+		if len(docs) == len(msgs.Messages) {
+			doc = copyDocument(docs[i])
+		}
+
+		role := msg.Role
+		if role == "" {
+			role = "user"
+		}
+
+		doc["role"] = role
 
-	for _, msg := range msgs.Messages {
 		switch content := msg.Content.(type) {
 		case string:
-			mediaMessages = append(mediaMessages, mediaMessage{
-				text: content,
-			})
-			continue
+			doc["content"] = content
+
+		case []chatMessageContent:
+			parts := make([]mediaPart, 0, len(content))
+
+			for _, cm := range content {
+				var mediaData string
 
-		default:
-			for _, cm := range msg.Content.([]chatMessageContent) {
 				switch cm.Type {
 				case "text":
-					found++
-					mediaText = cm.Text
+					parts = append(parts, mediaPart{text: cm.Text})
+					continue
 
 				case "image_url":
-					found++
 					mediaData = cm.ImageURL.URL
 
 				case "video_url":
-					found++
 					mediaData = cm.VideoURL.URL
 
 				case "input_audio":
-					found++
 					mediaData = cm.AudioData.Data
-				}
-
-				if found == 2 {
-					decoded, err := decodeMediaData(mediaData)
-					if err != nil {
-						return req, err
-					}
 
-					mediaMessages = append(mediaMessages, mediaMessage{
-						text: mediaText,
-						data: decoded,
-					})
-
-					found = 0
-					mediaText = ""
-					mediaData = ""
+				default:
+					continue
 				}
-			}
-		}
-	}
-
-	// -------------------------------------------------------------------------
 
-	// Here is take all the data we found (text, data) and convert everything
-	// to the MediaMessage format is a generic format most model templates
-	// support.
+				decoded, err := decodeMediaData(ctx, mediaData, fetcher)
+				if err != nil {
+					return req, err
+				}
 
-	docs := make([]D, 0, len(mediaMessages))
+				parts = append(parts, mediaPart{data: decoded})
+			}
 
-	for _, mm := range mediaMessages {
-		if len(mm.data) > 0 {
-			msgs := MediaMessage(mm.text, mm.data)
-			docs = append(docs, msgs...)
-			continue
+			doc["content"] = parts
 		}
 
-		docs = append(docs, TextMessage("user", mm.text))
+		mediaDocs[i] = doc
 	}
 
-	req["messages"] = docs
+	req["messages"] = mediaDocs
 
 	return req, nil
 }
 
-func decodeMediaData(data string) ([]byte, error) {
-	if strings.HasPrefix(data, "http://") || strings.HasPrefix(data, "https://") {
-		return nil, fmt.Errorf("to-media-message: URLs are not supported, provide base64 encoded data")
+func copyDocument(doc D) D {
+	dCopy := make(D, len(doc))
+	maps.Copy(dCopy, doc)
+
+	return dCopy
+}
+
+func decodeMediaData(ctx context.Context, data string, fetcher MediaFetcher) ([]byte, error) {
+	if strings.HasPrefix(data, "http://") || strings.HasPrefix(data, "https://") || strings.HasPrefix(data, "file://") {
+		if fetcher == nil {
+			return nil, fmt.Errorf("to-media-message: URLs are not enabled, provide base64 encoded data")
+		}
+
+		decoded, err := fetcher.Fetch(ctx, data)
+		if err != nil {
+			return nil, fmt.Errorf("to-media-message: unable to fetch media: %w", err)
+		}
+
+		return decoded, nil
 	}
 
 	if idx := strings.Index(data, ";base64,"); idx != -1 && strings.HasPrefix(data, "data:") {
@@ -1,8 +1,13 @@
 package model
 
 import (
+	"context"
 	"encoding/base64"
+	"fmt"
+	"sync"
 	"testing"
+
+	"github.com/nikolalohinski/gonja/v2/exec"
 )
 
 func Test_OpenAIToMediaMessage(t *testing.T) {
@@ -11,9 +16,22 @@ func Test_OpenAIToMediaMessage(t *testing.T) {
 
 	d := D{
 		"messages": DocumentArray(
+			D{
+				"role":    "system",
+				"content": "you are a helpful assistant",
+			},
 			openAIMediaMessage("what do you see in the picture?", openEncoded),
 			D{
-				"content": "follow up question",
+				"role":    "assistant",
+				"content": "I see a picture",
+			},
+			D{
+				"content": []D{
+					{
+						"type":      "image_url",
+						"image_url": D{"url": openEncoded},
+					},
+				},
 			},
 		),
 	}
@@ -27,26 +45,55 @@ func Test_OpenAIToMediaMessage(t *testing.T) {
 		t.Fatalf("we expected to have an openai message")
 	}
 
-	d, err = toMediaMessage(d, chatMessages)
+	d, err = toMediaMessage(context.Background(), d, chatMessages, nil)
 	if err != nil {
 		t.Fatalf("convering openai to media message: %s", err)
 	}
 
 	msgs := d["messages"].([]D)
 
-	if len(msgs) != 5 {
-		t.Fatalf("should have 5 documents in the media message, got %d", len(msgs))
+	if len(msgs) != 4 {
+		t.Fatalf("should have 4 documents in the media message, got %d", len(msgs))
+	}
+
+	roles := []string{"system", "user", "assistant", "user"}
+	for i, role := range roles {
+		if msgs[i]["role"] != role {
+			t.Fatalf("message %d: expected role %q, got %q", i, role, msgs[i]["role"])
+		}
+	}
+
+	if msgs[2]["content"] != "I see a picture" {
+		t.Fatalf("expected the assistant content to be preserved, got %v", msgs[2]["content"])
+	}
+
+	parts, ok := msgs[1]["content"].([]mediaPart)
+	if !ok {
+		t.Fatalf("expected media parts, got %T", msgs[1]["content"])
+	}
+
+	expMedia := []bool{false, true, true, false}
+	if len(parts) != len(expMedia) {
+		t.Fatalf("should have %d parts, got %d", len(expMedia), len(parts))
 	}
 
-	for _, d := range msgs {
-		data, ok := d["content"].([]byte)
-		if ok {
-			mediaEncoded := base64.StdEncoding.EncodeToString(data)
+	for i, isMedia := range expMedia {
+		if (parts[i].data != nil) != isMedia {
+			t.Fatalf("part %d: expected media %t", i, isMedia)
+		}
+
+		if isMedia {
+			mediaEncoded := base64.StdEncoding.EncodeToString(parts[i].data)
 			if openEncoded != mediaEncoded {
 				t.Fatalf("media mismatch from input to output\ngot:[%s]\nexp:[%s]", openEncoded, mediaEncoded)
 			}
 		}
 	}
+
+	parts, ok = msgs[3]["content"].([]mediaPart)
+	if !ok || len(parts) != 1 || parts[0].data == nil {
+		t.Fatalf("expected a single media part without text, got %v", msgs[3]["content"])
+	}
 }
 
 func openAIMediaMessage(text string, media string) D {
@@ -76,3 +123,86 @@ func openAIMediaMessage(text string, media string) D {
 		},
 	}
 }
+
+func Test_OpenFinalMessage(t *testing.T) {
+	msgs := DocumentArray(
+		TextMessage("user", "give me json"),
+		TextMessage("assistant", "```json\n"),
+	)
+
+	prompt := "<|im_start|>user\ngive me json<|im_end|>\n<|im_start|>assistant\n```json<|im_end|>\n"
+
+	got, err := openFinalMessage(prompt, msgs)
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	exp := "<|im_start|>user\ngive me json<|im_end|>\n<|im_start|>assistant\n```json"
+	if got != exp {
+		t.Fatalf("unexpected prompt\ngot:[%s]\nexp:[%s]", got, exp)
+	}
+
+	msgs = DocumentArray(
+		TextMessage("assistant", "hello"),
+		TextMessage("user", "give me json"),
+	)
+
+	if _, err := openFinalMessage(prompt, msgs); err == nil {
+		t.Fatal("expected an error when the final message is not an assistant message")
+	}
+}
+
+func Test_CompiledTemplate(t *testing.T) {
+	const script = `{% for message in messages %}<{{ message.role }}>{{ message.content }}{% endfor %}{% if add_generation_prompt %}<assistant>{% endif %}`
+
+	tmpl, err := newTemplateWithFixedItems(script)
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	m := Model{
+		log:              func(ctx context.Context, msg string, args ...any) {},
+		compiledTemplate: tmpl,
+	}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 10)
+
+	for i := range 10 {
+		wg.Go(func() {
+			content := fmt.Sprintf("message %d", i)
+
+			d := D{
+				"messages": DocumentArray(TextMessage("user", content)),
+			}
+
+			prompt, _, err := m.applyRequestJinjaTemplate(context.Background(), d, false)
+			if err != nil {
+				errs <- err
+				return
+			}
+
+			if exp := fmt.Sprintf("<user>%s<assistant>", content); prompt != exp {
+				errs <- fmt.Errorf("got %q, exp %q", prompt, exp)
+			}
+		})
+	}
+
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		t.Error(err)
+	}
+
+	include, err := newTemplateWithFixedItems(`{% include "/etc/hosts" %}`)
+	if err == nil {
+		if _, err := include.ExecuteToString(exec.NewContext(D{})); err == nil {
+			t.Error("expected an error including a file from the filesystem")
+		}
+	}
+
+	if _, err := newTemplateWithFixedItems(`{% for message in messages %}`); err == nil {
+		t.Error("expected an error for a broken template")
+	}
+}
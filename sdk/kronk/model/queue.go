@@ -0,0 +1,20 @@
+package model
+
+import (
+	"context"
+	"time"
+)
+
+type queueWaitKey int
+
+// WithQueueWait returns a context that carries the time the request waited
+// in the queue for this model. The wait is reported in the usage of the
+// responses.
+func WithQueueWait(ctx context.Context, wait time.Duration) context.Context {
+	return context.WithValue(ctx, queueWaitKey(1), wait)
+}
+
+func queueWait(ctx context.Context) time.Duration {
+	wait, _ := ctx.Value(queueWaitKey(1)).(time.Duration)
+	return wait
+}
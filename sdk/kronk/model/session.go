@@ -0,0 +1,287 @@
+package model
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"time"
+
+	"github.com/ardanlabs/kronk/sdk/tools/sessions"
+	"github.com/hybridgroup/yzma/pkg/llama"
+)
+
+// chatSession represents the session a chat request is part of. While the
+// request is processed, the session is bound to the context of the request
+// and the tokens of the prompt it processed.
+type chatSession struct {
+	id       string
+	adapters []string
+	lctx     llama.Context
+	tokens   []llama.Token
+}
+
+// SaveSession persists the KV state and token history of the request that is
+// processing the specified session id. Any state beyond the token history is
+// removed from the context before it's saved so the two always match. The
+// adapters applied to the context are stored with the session since the
+// state isn't valid for a context with different adapters. The chat request
+// saves its session once the response is complete.
+func (m *Model) SaveSession(id string) error {
+	session, err := m.activeSession(id)
+	if err != nil {
+		return fmt.Errorf("save-session: %w", err)
+	}
+
+	return m.saveSession(session)
+}
+
+// LoadSession restores the KV state for the specified session id into the
+// context of the request that is processing the session and returns the
+// token history the state represents. The chat request loads its session
+// before the prompt is processed.
+func (m *Model) LoadSession(id string) ([]llama.Token, error) {
+	session, err := m.activeSession(id)
+	if err != nil {
+		return nil, fmt.Errorf("load-session: %w", err)
+	}
+
+	return m.loadSession(session)
+}
+
+// =============================================================================
+
+// startSession binds the session to the context of the request processing
+// it. A session can only be processed by one request at a time since the
+// requests would overwrite each other's state. The returned function must be
+// called once the request is done with the session.
+func (m *Model) startSession(session *chatSession, lctx llama.Context) (func(), error) {
+	if session == nil {
+		return func() {}, nil
+	}
+
+	m.sessionsMu.Lock()
+	defer m.sessionsMu.Unlock()
+
+	if _, exists := m.sessions[session.id]; exists {
+		return nil, fmt.Errorf("start-session: session %q is already being processed by another request", session.id)
+	}
+
+	if m.sessions == nil {
+		m.sessions = make(map[string]*chatSession)
+	}
+
+	session.lctx = lctx
+	m.sessions[session.id] = session
+
+	f := func() {
+		m.sessionsMu.Lock()
+		defer m.sessionsMu.Unlock()
+
+		delete(m.sessions, session.id)
+	}
+
+	return f, nil
+}
+
+// activeSession returns the session bound to the request processing it.
+func (m *Model) activeSession(id string) (*chatSession, error) {
+	if m.cfg.SessionDir == "" {
+		return nil, errors.New("sessions are not enabled")
+	}
+
+	if err := sessions.ValidateID(id); err != nil {
+		return nil, err
+	}
+
+	m.sessionsMu.Lock()
+	defer m.sessionsMu.Unlock()
+
+	session, exists := m.sessions[id]
+	if !exists {
+		return nil, fmt.Errorf("session %q is not being processed by a request", id)
+	}
+
+	return session, nil
+}
+
+// saveSession writes the state of the session under a new version and then
+// commits the meta file that records the version. A load only ever reads the
+// state of the version recorded in the meta file, so a crash or a concurrent
+// save never pairs the meta file with the state of another save.
+func (m *Model) saveSession(session *chatSession) error {
+	mem, err := llama.GetMemory(session.lctx)
+	if err != nil {
+		return fmt.Errorf("save-session: unable to get memory: %w", err)
+	}
+
+	if _, err := llama.MemorySeqRm(mem, 0, llama.Pos(len(session.tokens)), -1); err != nil {
+		return fmt.Errorf("save-session: unable to trim memory: %w", err)
+	}
+
+	version, err := sessions.NewVersion()
+	if err != nil {
+		return fmt.Errorf("save-session: %w", err)
+	}
+
+	// Write the state to a temp file first so a concurrent load never sees
+	// a partial state file.
+	tmpFile, err := sessions.TempFile(m.cfg.SessionDir, session.id)
+	if err != nil {
+		return fmt.Errorf("save-session: %w", err)
+	}
+
+	if n := llama.StateSeqSaveFile(session.lctx, tmpFile, 0, session.tokens); n == 0 {
+		os.Remove(tmpFile)
+		return fmt.Errorf("save-session: unable to save state for session %q", session.id)
+	}
+
+	stateFile := sessions.StateFile(m.cfg.SessionDir, session.id, version)
+
+	if err := os.Rename(tmpFile, stateFile); err != nil {
+		os.Remove(tmpFile)
+		return fmt.Errorf("save-session: unable to rename state file: %w", err)
+	}
+
+	meta := sessions.Meta{
+		ID:       session.id,
+		ModelID:  m.modelInfo.ID,
+		Adapters: session.adapters,
+		Tokens:   len(session.tokens),
+		Version:  version,
+		Saved:    time.Now().UTC(),
+	}
+
+	if err := sessions.WriteMeta(m.cfg.SessionDir, meta); err != nil {
+		os.Remove(stateFile)
+		return fmt.Errorf("save-session: %w", err)
+	}
+
+	if _, err := sessions.Prune(m.cfg.SessionDir, m.cfg.SessionTTL, m.cfg.SessionMaxSize); err != nil {
+		return fmt.Errorf("save-session: %w", err)
+	}
+
+	return nil
+}
+
+// loadSession restores the state of the version recorded in the meta file of
+// the session. A session whose state doesn't match its meta file is rejected.
+func (m *Model) loadSession(session *chatSession) ([]llama.Token, error) {
+	meta, err := sessions.ReadMeta(m.cfg.SessionDir, session.id)
+	if err != nil {
+		return nil, fmt.Errorf("load-session: %w", err)
+	}
+
+	if m.cfg.SessionTTL > 0 && time.Since(meta.Saved) > m.cfg.SessionTTL {
+		sessions.Remove(m.cfg.SessionDir, session.id)
+		return nil, fmt.Errorf("load-session: %w", sessions.ErrNotFound)
+	}
+
+	if meta.ModelID != m.modelInfo.ID || !slices.Equal(meta.Adapters, session.adapters) {
+		return nil, fmt.Errorf("load-session: session %q was saved for model[%s] adapters%v", session.id, meta.ModelID, meta.Adapters)
+	}
+
+	if meta.Tokens > m.cfg.ContextWindow {
+		return nil, fmt.Errorf("load-session: %w: session %q has %d tokens which exceeds the context window %d", ErrContextWindowExceeded, session.id, meta.Tokens, m.cfg.ContextWindow)
+	}
+
+	stateFile := sessions.StateFile(m.cfg.SessionDir, session.id, meta.Version)
+
+	if _, err := os.Stat(stateFile); meta.Version == "" || err != nil {
+		return nil, fmt.Errorf("load-session: %w: session %q version %q", sessions.ErrMismatch, session.id, meta.Version)
+	}
+
+	tokens := make([]llama.Token, m.cfg.ContextWindow)
+
+	var count uint64
+	if n := llama.StateSeqLoadFile(session.lctx, stateFile, 0, tokens, uint64(len(tokens)), &count); n == 0 {
+		return nil, fmt.Errorf("load-session: unable to load state for session %q", session.id)
+	}
+
+	if int(count) != meta.Tokens {
+		return nil, fmt.Errorf("load-session: %w: session %q has %d tokens, expected %d", sessions.ErrMismatch, session.id, count, meta.Tokens)
+	}
+
+	return tokens[:count], nil
+}
+
+// restoreSession loads the session state into the context and returns the
+// number of prompt tokens that are already in the KV cache. Any state that
+// doesn't match the prompt is removed from the context.
+func (m *Model) restoreSession(ctx context.Context, lctx llama.Context, session *chatSession, tokens []llama.Token) int {
+	saved, err := m.LoadSession(session.id)
+	if err != nil {
+		if !errors.Is(err, sessions.ErrNotFound) {
+			m.log(ctx, "restore-session", "status", "unable to load session", "session-id", session.id, "ERROR", err)
+		}
+
+		m.clearMemory(lctx)
+		return 0
+	}
+
+	var n int
+	for n < len(saved) && n < len(tokens) && saved[n] == tokens[n] {
+		n++
+	}
+
+	// At least one prompt token must be decoded to produce the logits for
+	// the first token of the response.
+	if n == len(tokens) {
+		n--
+	}
+
+	mem, err := llama.GetMemory(lctx)
+	if err != nil {
+		m.clearMemory(lctx)
+		return 0
+	}
+
+	if ok, err := llama.MemorySeqRm(mem, 0, llama.Pos(n), -1); !ok || err != nil {
+		m.log(ctx, "restore-session", "status", "unable to trim session state", "session-id", session.id)
+		m.clearMemory(lctx)
+		return 0
+	}
+
+	m.log(ctx, "restore-session", "status", "session restored", "session-id", session.id, "saved-tokens", len(saved), "reused-tokens", n, "prompt-tokens", len(tokens))
+
+	return n
+}
+
+func (m *Model) clearMemory(lctx llama.Context) {
+	if mem, err := llama.GetMemory(lctx); err == nil {
+		llama.MemoryClear(mem, true)
+	}
+}
+
+// parseSession extracts the session information from the request.
+func (m *Model) parseSession(d D, adapters []string) (*chatSession, error) {
+	v, exists := d["session_id"]
+	if !exists || v == nil {
+		return nil, nil
+	}
+
+	id, ok := v.(string)
+	if !ok {
+		return nil, errors.New("parse-session: session_id is not a string")
+	}
+
+	if id == "" {
+		return nil, nil
+	}
+
+	if m.cfg.SessionDir == "" {
+		return nil, errors.New("parse-session: sessions are not enabled")
+	}
+
+	if err := sessions.ValidateID(id); err != nil {
+		return nil, fmt.Errorf("parse-session: %w", err)
+	}
+
+	session := chatSession{
+		id:       id,
+		adapters: adapters,
+	}
+
+	return &session, nil
+}
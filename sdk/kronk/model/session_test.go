@@ -0,0 +1,69 @@
+package model
+
+import (
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/ardanlabs/kronk/sdk/tools/sessions"
+	"github.com/hybridgroup/yzma/pkg/llama"
+)
+
+func Test_LoadSessionMismatch(t *testing.T) {
+	dir := t.TempDir()
+
+	m := Model{
+		cfg: Config{
+			SessionDir:    dir,
+			ContextWindow: 1024,
+		},
+		modelInfo: ModelInfo{ID: "test-model"},
+	}
+
+	// The session isn't bound to a request yet.
+	if _, err := m.LoadSession("support"); err == nil {
+		t.Fatal("expected an error loading a session without a request")
+	}
+
+	release, err := m.startSession(&chatSession{id: "support"}, llama.Context(0))
+	if err != nil {
+		t.Fatalf("start session: %v", err)
+	}
+	defer release()
+
+	if _, err := m.startSession(&chatSession{id: "support"}, llama.Context(0)); err == nil {
+		t.Fatal("expected a second request for the session to be rejected")
+	}
+
+	// The state on disk belongs to another save than the meta file.
+	saved, err := sessions.NewVersion()
+	if err != nil {
+		t.Fatalf("new version: %v", err)
+	}
+
+	recorded, err := sessions.NewVersion()
+	if err != nil {
+		t.Fatalf("new version: %v", err)
+	}
+
+	if err := os.WriteFile(sessions.StateFile(dir, "support", saved), []byte("state"), 0644); err != nil {
+		t.Fatalf("write state: %v", err)
+	}
+
+	meta := sessions.Meta{
+		ID:      "support",
+		ModelID: "test-model",
+		Tokens:  10,
+		Version: recorded,
+		Saved:   time.Now().UTC(),
+	}
+
+	if err := sessions.WriteMeta(dir, meta); err != nil {
+		t.Fatalf("write meta: %v", err)
+	}
+
+	if _, err := m.LoadSession("support"); !errors.Is(err, sessions.ErrMismatch) {
+		t.Fatalf("expected the mismatched session to be rejected, got: %v", err)
+	}
+}
@@ -0,0 +1,78 @@
+#!/usr/bin/env python3
+"""Renders the golden files for the chat template conformance tests.
+
+Every <name>.jinja template in this directory is rendered with the variables
+in <name>.json, or default.json when the template has no input of its own,
+by the same renderer transformers uses in apply_chat_template. The prompt is
+written to <name>.golden. The golden files must only be produced by this
+script so the tests compare the Go runtime with transformers.
+
+With --catalog, the templates shipped in the kronk_catalogs repository are
+downloaded into this directory first so they are covered by the tests.
+
+strftime_now is pinned to the same date the Go test uses so the prompts
+don't change from day to day.
+
+    pip install transformers
+    python3 render.py --catalog
+"""
+
+import argparse
+import datetime
+import json
+import pathlib
+import urllib.request
+
+from transformers.utils.chat_template_utils import _compile_jinja_template
+
+CATALOG_TEMPLATES = "https://api.github.com/repos/ardanlabs/kronk_catalogs/contents/templates"
+
+FIXED_NOW = datetime.datetime(2024, 7, 5, 14, 3, 9)
+
+HERE = pathlib.Path(__file__).parent
+
+
+def download_catalog():
+    with urllib.request.urlopen(CATALOG_TEMPLATES) as resp:
+        entries = json.load(resp)
+
+    for entry in entries:
+        if not entry["name"].endswith(".jinja"):
+            continue
+
+        with urllib.request.urlopen(entry["download_url"]) as resp:
+            (HERE / entry["name"]).write_bytes(resp.read())
+
+        print("downloaded", entry["name"])
+
+
+def render(template):
+    source = template.read_text()
+
+    inputs = template.with_suffix(".json")
+    if not inputs.exists():
+        inputs = HERE / "default.json"
+
+    variables = json.loads(inputs.read_text())
+    variables["strftime_now"] = lambda fmt: FIXED_NOW.strftime(fmt)
+
+    prompt = _compile_jinja_template(source).render(**variables)
+
+    template.with_suffix(".golden").write_text(prompt)
+    print("rendered", template.name)
+
+
+def main():
+    parser = argparse.ArgumentParser(description=__doc__.splitlines()[0])
+    parser.add_argument("--catalog", action="store_true", help="download the catalog templates first")
+    args = parser.parse_args()
+
+    if args.catalog:
+        download_catalog()
+
+    for template in sorted(HERE.glob("*.jinja")):
+        render(template)
+
+
+if __name__ == "__main__":
+    main()
@@ -0,0 +1,134 @@
+package kronk
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/ardanlabs/kronk/sdk/kronk/model"
+	"github.com/hybridgroup/yzma/pkg/llama"
+)
+
+// Set of policies for placing the instances of a model on devices.
+const (
+	PlacementRoundRobin  = "round-robin"
+	PlacementLeastLoaded = "least-loaded"
+)
+
+// Placement represents how the instances of a model are spread across a set
+// of devices. With round-robin, an instance is placed on the device with the
+// fewest instances of the model. With least-loaded, an instance is placed on
+// the device with the fewest bytes of models loaded on it by Kronk in this
+// process. The memory used by other processes isn't taken into account.
+type Placement struct {
+	// Devices is the set of devices to place the instances on. To see what
+	// devices are available, call Devices or run llama-bench --list-devices.
+	Devices []string
+
+	// Policy is the policy used to pick the device for an instance. The
+	// default is round-robin.
+	Policy string
+}
+
+func (p Placement) validate() error {
+	switch p.Policy {
+	case "", PlacementRoundRobin, PlacementLeastLoaded:
+	default:
+		return fmt.Errorf("validate: unknown placement policy %q", p.Policy)
+	}
+
+	for _, device := range p.Devices {
+		if device == "" {
+			return fmt.Errorf("validate: device name is required")
+		}
+	}
+
+	return nil
+}
+
+// pick returns the device for a new instance of the model given the devices
+// of the existing instances. The default device is returned when no devices
+// are configured.
+func (p Placement) pick(defaultDevice string, used []string) string {
+	if len(p.Devices) == 0 {
+		return defaultDevice
+	}
+
+	switch p.Policy {
+	case PlacementLeastLoaded:
+		return placed.least(p.Devices)
+
+	default:
+		return p.fewestInstances(used)
+	}
+}
+
+func (p Placement) fewestInstances(used []string) string {
+	counts := make(map[string]int)
+	for _, device := range used {
+		counts[device]++
+	}
+
+	pick := p.Devices[0]
+	for _, device := range p.Devices[1:] {
+		if counts[device] < counts[pick] {
+			pick = device
+		}
+	}
+
+	return pick
+}
+
+// Devices returns the names of the backend devices llama.cpp can load models
+// on. The library must be initialized first.
+func Devices() []string {
+	n := llama.GGMLBackendDeviceCount()
+
+	devices := make([]string, 0, n)
+	for i := range n {
+		devices = append(devices, llama.GGMLBackendDeviceName(llama.GGMLBackendDeviceGet(i)))
+	}
+
+	return devices
+}
+
+// =============================================================================
+
+// placed tracks the bytes of the models loaded on each device by every Kronk
+// in the process for the least-loaded policy.
+var placed = devices{
+	bytes: make(map[string]uint64),
+}
+
+type devices struct {
+	mu    sync.Mutex
+	bytes map[string]uint64
+}
+
+func (d *devices) add(llama *model.Model) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.bytes[llama.Config().Device] += llama.ModelInfo().Size
+}
+
+func (d *devices) remove(llama *model.Model) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	device := llama.Config().Device
+	d.bytes[device] -= min(d.bytes[device], llama.ModelInfo().Size)
+}
+
+func (d *devices) least(names []string) string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	pick := names[0]
+	for _, name := range names[1:] {
+		if d.bytes[name] < d.bytes[pick] {
+			pick = name
+		}
+	}
+
+	return pick
+}
@@ -0,0 +1,102 @@
+package kronk
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ardanlabs/kronk/sdk/kronk/model"
+)
+
+func Test_Placement(t *testing.T) {
+	devices := []string{"CPU0", "CPU1", "CPU2"}
+
+	placed.mu.Lock()
+	placed.bytes["CPU0"], placed.bytes["CPU1"], placed.bytes["CPU2"] = 4, 8, 2
+	placed.mu.Unlock()
+
+	t.Cleanup(func() {
+		placed.mu.Lock()
+		defer placed.mu.Unlock()
+
+		for _, device := range devices {
+			delete(placed.bytes, device)
+		}
+	})
+
+	tests := []struct {
+		name      string
+		placement Placement
+		used      []string
+		exp       string
+	}{
+		{"no devices", Placement{}, nil, "default"},
+		{"round-robin first", Placement{Devices: devices}, nil, "CPU0"},
+		{"round-robin next", Placement{Devices: devices}, []string{"CPU0"}, "CPU1"},
+		{"round-robin fewest", Placement{Devices: devices}, []string{"CPU0", "CPU1", "CPU2", "CPU0", "CPU2"}, "CPU1"},
+		{"least-loaded", Placement{Devices: devices, Policy: PlacementLeastLoaded}, []string{"CPU2"}, "CPU2"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.placement.pick("default", tt.used); got != tt.exp {
+				t.Errorf("expected %s, got %s", tt.exp, got)
+			}
+		})
+	}
+
+	if err := (Placement{Policy: "random"}).validate(); err == nil {
+		t.Error("expected an unknown policy to fail validation")
+	}
+}
+
+func Test_PlacementScaling(t *testing.T) {
+	placement := Placement{
+		Devices: []string{"CPU0", "CPU1"},
+	}
+
+	place := func(used []string) (string, error) {
+		return placement.pick("", used), nil
+	}
+
+	var loaded []string
+
+	load := func(device string) (*model.Model, error) {
+		loaded = append(loaded, device)
+		return &model.Model{}, nil
+	}
+
+	scaling := Scaling{
+		MaxInstances: 2,
+		QueueWait:    10 * time.Millisecond,
+	}
+
+	s := newScheduler([]*model.Model{{}}, 0, scaling, place, load, nil)
+	s.unload = func(ctx context.Context, llama *model.Model) error {
+		return nil
+	}
+	s.instances[0].device = "CPU0"
+
+	held, _, err := s.acquire(context.Background(), "a", PriorityInteractive)
+	if err != nil {
+		t.Fatalf("acquire: %v", err)
+	}
+
+	// The added instance is placed on the device without an instance.
+	added, _, err := s.acquire(context.Background(), "a", PriorityInteractive)
+	if err != nil {
+		t.Fatalf("acquire: %v", err)
+	}
+
+	if len(loaded) != 1 || loaded[0] != "CPU1" {
+		t.Fatalf("expected the instance to be loaded on CPU1, got %v", loaded)
+	}
+
+	if status := s.status(); status[added.id].Device != "CPU1" {
+		t.Fatalf("expected the status to report CPU1, got %+v", status[added.id])
+	}
+
+	s.release(held, nil, false)
+	s.release(added, nil, false)
+	s.close()
+}
@@ -0,0 +1,234 @@
+package kronk
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/ardanlabs/kronk/sdk/observ/otel"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+const (
+	// defaultScaleUpWait is the time a request waits for an instance before
+	// another instance is added.
+	defaultScaleUpWait = 2 * time.Second
+
+	// defaultScaleDownAfter is the time an added instance can stay idle
+	// before it's removed.
+	defaultScaleDownAfter = 5 * time.Minute
+)
+
+// Scaling represents the settings for growing and shrinking the number of
+// instances of a model with the load. The instances created by New are the
+// minimum and are never removed.
+type Scaling struct {
+	// MaxInstances is the maximum number of instances of the model. Scaling
+	// is off when this is not more than the number of instances given to New.
+	MaxInstances int
+
+	// QueueWait is the time a request can wait for an instance before
+	// another instance is added. The default is 2 seconds.
+	QueueWait time.Duration
+
+	// CoolDown is the time an instance needs to be idle before it's removed
+	// down to the minimum. The default is 5 minutes.
+	CoolDown time.Duration
+
+	// Reserve is called before an instance is added so the memory for it
+	// can be reserved. An error stops the instance from being added. The
+	// release function is called once the instance is removed.
+	Reserve func(ctx context.Context) (release func(), err error)
+}
+
+func (sc Scaling) withDefaults() Scaling {
+	if sc.QueueWait <= 0 {
+		sc.QueueWait = defaultScaleUpWait
+	}
+
+	if sc.CoolDown <= 0 {
+		sc.CoolDown = defaultScaleDownAfter
+	}
+
+	return sc
+}
+
+// =============================================================================
+
+// scaleUp adds an instance of the model in the background when requests are
+// still waiting and the maximum number of instances hasn't been reached.
+// Only one instance is added at a time.
+func (s *scheduler) scaleUp(ctx context.Context) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed || s.scalingUp || s.queued == 0 || s.active() >= s.scaling.MaxInstances {
+		return
+	}
+
+	s.scalingUp = true
+
+	s.background.Add(1)
+	go s.addInstance(context.WithoutCancel(ctx))
+}
+
+func (s *scheduler) addInstance(ctx context.Context) {
+	defer s.background.Done()
+
+	ctx, span := otel.AddSpan(ctx, "kronk.scale-up")
+	defer span.End()
+
+	start := time.Now()
+
+	inst, err := s.loadInstance(ctx)
+	if err != nil {
+		s.mu.Lock()
+		s.scalingUp = false
+		queued := s.queued
+		s.mu.Unlock()
+
+		span.RecordError(err)
+		s.log(ctx, "kronk", "status", "instance not added", "queued", queued, "ERROR", err)
+
+		return
+	}
+
+	// -------------------------------------------------------------------------
+
+	s.mu.Lock()
+
+	s.scalingUp = false
+
+	// The scheduler could have been closed while the model was loading.
+	if s.closed {
+		s.mu.Unlock()
+		s.removeInstance(ctx, inst)
+		return
+	}
+
+	inst.id = s.nextID
+	s.nextID++
+	s.instances = append(s.instances, inst)
+	s.makeIdle(inst)
+
+	active, queued := s.active(), s.queued
+
+	s.mu.Unlock()
+
+	span.SetAttributes(attribute.Int("instance", inst.id), attribute.String("device", inst.device), attribute.Int("instances", active))
+	s.log(ctx, "kronk", "status", "instance added", "instance", inst.id, "device", inst.device, "instances", active, "queued", queued, "took", time.Since(start))
+}
+
+// loadInstance reserves the memory for a new instance and loads the model
+// on the device picked by the placement.
+func (s *scheduler) loadInstance(ctx context.Context) (*instance, error) {
+	s.mu.Lock()
+	used := make([]string, 0, len(s.instances))
+	for _, inst := range s.instances {
+		if inst.state != InstanceFailed {
+			used = append(used, inst.device)
+		}
+	}
+	s.mu.Unlock()
+
+	device, err := s.place(used)
+	if err != nil {
+		return nil, fmt.Errorf("place: %w", err)
+	}
+
+	release := func() {}
+
+	if s.scaling.Reserve != nil {
+		if release, err = s.scaling.Reserve(ctx); err != nil {
+			return nil, fmt.Errorf("reserve: %w", err)
+		}
+	}
+
+	llama, err := s.load(device)
+	if err != nil {
+		release()
+		return nil, fmt.Errorf("load: %w", err)
+	}
+
+	inst := instance{
+		device:  device,
+		llama:   llama,
+		state:   InstanceIdle,
+		release: release,
+	}
+
+	return &inst, nil
+}
+
+// scaleDownLoop removes the instances above the minimum that have been idle
+// for the cool-down until the scheduler is closed.
+func (s *scheduler) scaleDownLoop() {
+	defer s.background.Done()
+
+	ticker := time.NewTicker(min(s.scaling.CoolDown/2, time.Minute))
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.done:
+			return
+
+		case <-ticker.C:
+			s.scaleDown()
+		}
+	}
+}
+
+func (s *scheduler) scaleDown() {
+	s.mu.Lock()
+
+	var removed []*instance
+
+	for i := 0; i < len(s.idle) && s.active() > s.minInstances; {
+		// Only the instances added by scaling are removed.
+		inst := s.idle[i]
+		if s.closed || inst.release == nil || inst.borrowed > 0 || time.Since(inst.idleSince) < s.scaling.CoolDown {
+			i++
+			continue
+		}
+
+		s.idle = append(s.idle[:i], s.idle[i+1:]...)
+
+		for j, other := range s.instances {
+			if other == inst {
+				s.instances = append(s.instances[:j], s.instances[j+1:]...)
+				break
+			}
+		}
+
+		removed = append(removed, inst)
+	}
+
+	active := s.active()
+
+	s.mu.Unlock()
+
+	// -------------------------------------------------------------------------
+
+	for _, inst := range removed {
+		ctx, span := otel.AddSpan(context.Background(), "kronk.scale-down", attribute.Int("instance", inst.id), attribute.Int("instances", active))
+
+		s.removeInstance(ctx, inst)
+		s.log(ctx, "kronk", "status", "instance removed", "instance", inst.id, "instances", active, "idle", time.Since(inst.idleSince))
+
+		span.End()
+	}
+}
+
+// removeInstance unloads the model of an instance that is no longer part of
+// the scheduler and gives back the memory reserved for it.
+func (s *scheduler) removeInstance(ctx context.Context, inst *instance) {
+	if err := s.unload(ctx, inst.llama); err != nil {
+		s.log(ctx, "kronk", "status", "unloading removed instance", "instance", inst.id, "ERROR", err)
+	}
+
+	if inst.release != nil {
+		inst.release()
+		inst.release = nil
+	}
+}
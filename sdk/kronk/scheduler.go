@@ -0,0 +1,678 @@
+package kronk
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/ardanlabs/kronk/sdk/kronk/model"
+)
+
+// ErrQueueFull is returned when a request can't be queued because the queue
+// for the model is at its maximum depth.
+var ErrQueueFull = errors.New("request queue is full")
+
+// QueueFullError provides the details of a request that was rejected
+// because the queue is full.
+type QueueFullError struct {
+	Queued     int
+	RetryAfter time.Duration
+}
+
+// Error implements the error interface.
+func (e *QueueFullError) Error() string {
+	return fmt.Sprintf("%s: %d requests queued, retry after %s", ErrQueueFull, e.Queued, e.RetryAfter)
+}
+
+// Unwrap allows the error to match ErrQueueFull.
+func (e *QueueFullError) Unwrap() error {
+	return ErrQueueFull
+}
+
+// =============================================================================
+
+// Priority represents the priority of a request in the queue. Requests with
+// a higher priority are handed an instance of the model before requests with
+// a lower priority.
+type Priority int
+
+// Set of priorities a request can have.
+const (
+	PriorityInteractive Priority = iota
+	PriorityBatch
+)
+
+// numPriorities is the number of priority levels.
+const numPriorities = 2
+
+var priorityNames = map[Priority]string{
+	PriorityInteractive: "interactive",
+	PriorityBatch:       "batch",
+}
+
+// String returns the name of the priority.
+func (p Priority) String() string {
+	return priorityNames[p]
+}
+
+// ParsePriority returns the priority for the name. An empty name is an
+// interactive priority.
+func ParsePriority(name string) (Priority, error) {
+	if name == "" {
+		return PriorityInteractive, nil
+	}
+
+	for p, n := range priorityNames {
+		if n == name {
+			return p, nil
+		}
+	}
+
+	return 0, fmt.Errorf("parse-priority: unknown priority %q, use interactive or batch", name)
+}
+
+// =============================================================================
+
+type ctxKey int
+
+const (
+	subjectKey ctxKey = iota + 1
+	priorityKey
+)
+
+// WithSubject returns a context that queues the requests made with it for
+// the subject. Subjects take turns being handed an instance of the model so
+// one subject can't starve the others.
+func WithSubject(ctx context.Context, subject string) context.Context {
+	return context.WithValue(ctx, subjectKey, subject)
+}
+
+// WithPriority returns a context that queues the requests made with it at
+// the priority.
+func WithPriority(ctx context.Context, priority Priority) context.Context {
+	return context.WithValue(ctx, priorityKey, priority)
+}
+
+func getSubject(ctx context.Context) string {
+	v, _ := ctx.Value(subjectKey).(string)
+	return v
+}
+
+func getPriority(ctx context.Context) Priority {
+	v, ok := ctx.Value(priorityKey).(Priority)
+	if !ok || v < 0 || v >= numPriorities {
+		return PriorityInteractive
+	}
+	return v
+}
+
+// =============================================================================
+
+// waiter represents a request waiting for an instance of the model.
+type waiter struct {
+	subject string
+	ready   chan *instance
+}
+
+// level represents the requests queued at one priority. The subjects with
+// requests queued are served round robin.
+type level struct {
+	subjects []string
+	waiters  map[string][]*waiter
+}
+
+// loadFunc loads a new copy of the model on the device to replace a
+// quarantined instance or to add an instance.
+type loadFunc func(device string) (*model.Model, error)
+
+// placeFunc returns the device for a new instance given the devices of the
+// existing instances.
+type placeFunc func(used []string) (string, error)
+
+// scheduler hands out the instances of a model to the requests waiting for
+// one. Requests are served by priority first and then fairly between the
+// subjects at the same priority. The least loaded idle instance is handed
+// out first. Instances that fail are quarantined and reloaded in the
+// background. With scaling, instances are added when requests wait too long
+// and removed again once they have been idle for the cool-down.
+type scheduler struct {
+	mu           sync.Mutex
+	log          model.Logger
+	load         loadFunc
+	place        placeFunc
+	unload       func(ctx context.Context, llama *model.Model) error
+	maxQueue     int
+	minInstances int
+	scaling      Scaling
+	scalingUp    bool
+	nextID       int
+	instances    []*instance
+	idle         []*instance
+	levels       [numPriorities]level
+	queued       int
+	avgHold      time.Duration
+	closed       bool
+	done         chan struct{}
+	background   sync.WaitGroup
+}
+
+func newScheduler(models []*model.Model, maxQueue int, scaling Scaling, place placeFunc, load loadFunc, log model.Logger) *scheduler {
+	if log == nil {
+		log = func(ctx context.Context, msg string, args ...any) {}
+	}
+
+	if place == nil {
+		place = func(used []string) (string, error) {
+			return "", nil
+		}
+	}
+
+	s := scheduler{
+		log:          log,
+		load:         load,
+		place:        place,
+		maxQueue:     maxQueue,
+		minInstances: len(models),
+		scaling:      scaling.withDefaults(),
+		nextID:       len(models),
+		done:         make(chan struct{}),
+		unload: func(ctx context.Context, llama *model.Model) error {
+			placed.remove(llama)
+			return llama.Unload(ctx)
+		},
+	}
+
+	for i, llama := range models {
+		inst := instance{
+			id:        i,
+			device:    llama.Config().Device,
+			llama:     llama,
+			state:     InstanceIdle,
+			idleSince: time.Now(),
+		}
+
+		s.instances = append(s.instances, &inst)
+		s.idle = append(s.idle, &inst)
+	}
+
+	for i := range s.levels {
+		s.levels[i].waiters = make(map[string][]*waiter)
+	}
+
+	if s.scaling.MaxInstances > s.minInstances {
+		s.background.Add(1)
+		go s.scaleDownLoop()
+	}
+
+	return &s
+}
+
+// acquire returns an idle instance of the model or waits in the queue for
+// one to be released. The time spent waiting is returned.
+func (s *scheduler) acquire(ctx context.Context, subject string, priority Priority) (*instance, time.Duration, error) {
+	start := time.Now()
+
+	s.mu.Lock()
+
+	if err := s.unavailable(); err != nil {
+		s.mu.Unlock()
+		return nil, 0, fmt.Errorf("acquire: %w", err)
+	}
+
+	if len(s.idle) > 0 && s.queued == 0 {
+		inst := s.takeIdle()
+
+		s.mu.Unlock()
+		return inst, 0, nil
+	}
+
+	if s.maxQueue > 0 && s.queued >= s.maxQueue {
+		err := QueueFullError{
+			Queued:     s.queued,
+			RetryAfter: s.retryAfter(),
+		}
+
+		s.mu.Unlock()
+		return nil, 0, &err
+	}
+
+	w := waiter{
+		subject: subject,
+		ready:   make(chan *instance, 1),
+	}
+
+	s.enqueue(&w, priority)
+
+	s.mu.Unlock()
+
+	// -------------------------------------------------------------------------
+
+	// A request waiting longer than the scaling threshold asks for another
+	// instance to be added.
+	var scaleUp <-chan time.Time
+	if s.scaling.MaxInstances > s.minInstances {
+		timer := time.NewTimer(s.scaling.QueueWait)
+		defer timer.Stop()
+
+		scaleUp = timer.C
+	}
+
+	for {
+		select {
+		case <-scaleUp:
+			scaleUp = nil
+			s.scaleUp(ctx)
+
+		case <-ctx.Done():
+			s.mu.Lock()
+			defer s.mu.Unlock()
+
+			// The instance could have been handed over before the request
+			// was removed from the queue, so it needs to be given back.
+			if !s.remove(&w, priority) {
+				if inst := <-w.ready; inst != nil {
+					s.makeIdle(inst)
+				}
+			}
+
+			return nil, time.Since(start), ctx.Err()
+
+		case inst := <-w.ready:
+			if inst == nil {
+				s.mu.Lock()
+				err := s.unavailable()
+				s.mu.Unlock()
+
+				return nil, time.Since(start), fmt.Errorf("acquire: %w", err)
+			}
+
+			return inst, time.Since(start), nil
+		}
+	}
+}
+
+// release records the result of the request on the instance and hands it
+// to the next request in the queue. An instance that failed too many times
+// or panicked is quarantined and reloaded instead.
+func (s *scheduler) release(inst *instance, err error, panicked bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Keep a moving average of the time an instance is held to estimate
+	// when a rejected request should be retried.
+	hold := time.Since(inst.acquired)
+	switch s.avgHold {
+	case 0:
+		s.avgHold = hold
+	default:
+		s.avgHold = (s.avgHold*7 + hold) / 8
+	}
+
+	if inst.record(err, panicked) {
+		s.quarantine(inst)
+		return
+	}
+
+	s.makeIdle(inst)
+}
+
+// borrow returns an instance for work that only reads the model, like
+// rendering a prompt, without taking the instance from the requests waiting
+// for one. The instance isn't unloaded until it's given back.
+func (s *scheduler) borrow() (*instance, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.unavailable(); err != nil {
+		return nil, err
+	}
+
+	for _, inst := range s.instances {
+		if inst.state == InstanceIdle || inst.state == InstanceBusy {
+			inst.borrowed++
+			return inst, nil
+		}
+	}
+
+	return nil, ErrNoHealthyInstances
+}
+
+// giveBack returns a borrowed instance.
+func (s *scheduler) giveBack(inst *instance) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	inst.borrowed--
+}
+
+// waitBorrowed waits for the borrowed instance to be given back.
+func (s *scheduler) waitBorrowed(inst *instance) {
+	for {
+		s.mu.Lock()
+		borrowed := inst.borrowed
+		s.mu.Unlock()
+
+		if borrowed == 0 {
+			return
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// close stops the scheduler from handing out instances and returns the
+// instances that are not failed so they can be unloaded. The requests still
+// in the queue are released with an error. Instances being reloaded or
+// added are waited on.
+func (s *scheduler) close() []*instance {
+	s.mu.Lock()
+
+	s.closed = true
+	close(s.done)
+
+	for w := s.dequeue(); w != nil; w = s.dequeue() {
+		w.ready <- nil
+	}
+
+	s.mu.Unlock()
+
+	s.background.Wait()
+
+	// -------------------------------------------------------------------------
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	idle := s.idle
+	s.idle = nil
+
+	return idle
+}
+
+// size returns the number of instances that are not failed.
+func (s *scheduler) size() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.active()
+}
+
+// queueDepth returns the number of requests waiting for an instance.
+func (s *scheduler) queueDepth() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.queued
+}
+
+// status returns the state and health of every instance.
+func (s *scheduler) status() []InstanceStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	status := make([]InstanceStatus, len(s.instances))
+	for i, inst := range s.instances {
+		status[i] = inst.status()
+	}
+
+	return status
+}
+
+// =============================================================================
+
+// active returns the number of instances that are not failed. The mu lock
+// must be held.
+func (s *scheduler) active() int {
+	var n int
+	for _, inst := range s.instances {
+		if inst.state != InstanceFailed {
+			n++
+		}
+	}
+
+	return n
+}
+
+// unavailable returns the reason no instance can be handed out, if there is
+// one. The mu lock must be held.
+func (s *scheduler) unavailable() error {
+	if s.closed {
+		return ErrUnloaded
+	}
+
+	for _, inst := range s.instances {
+		if inst.state != InstanceFailed {
+			return nil
+		}
+	}
+
+	return ErrNoHealthyInstances
+}
+
+// takeIdle removes the least loaded idle instance from the idle list and
+// marks it busy. The mu lock must be held.
+func (s *scheduler) takeIdle() *instance {
+	idx := 0
+	for i, inst := range s.idle {
+		if inst.busyTime < s.idle[idx].busyTime {
+			idx = i
+		}
+	}
+
+	inst := s.idle[idx]
+	s.idle = append(s.idle[:idx], s.idle[idx+1:]...)
+
+	s.handOut(inst)
+
+	return inst
+}
+
+// handOut marks the instance busy with a new request. The mu lock must be
+// held.
+func (s *scheduler) handOut(inst *instance) {
+	inst.state = InstanceBusy
+	inst.acquired = time.Now()
+	inst.panicsAt = inst.llama.Panics()
+}
+
+// makeIdle hands the instance to the next request in the queue or adds it to
+// the idle list when there are no requests waiting. The mu lock must be held.
+func (s *scheduler) makeIdle(inst *instance) {
+	if w := s.dequeue(); w != nil {
+		s.handOut(inst)
+		w.ready <- inst
+		return
+	}
+
+	inst.state = InstanceIdle
+	inst.idleSince = time.Now()
+	s.idle = append(s.idle, inst)
+}
+
+// quarantine takes the instance out of service and reloads it in the
+// background. The mu lock must be held.
+func (s *scheduler) quarantine(inst *instance) {
+	inst.state = InstanceQuarantined
+
+	s.log(context.Background(), "kronk", "status", "instance quarantined", "instance", inst.id, "failures", inst.failures, "panics", inst.panics, "ERROR", inst.lastErr)
+
+	s.background.Add(1)
+	go s.reload(inst)
+}
+
+// reload replaces the model of a quarantined instance with a new copy. The
+// instance is marked as failed when it can't be reloaded, and the waiting
+// requests are released with an error when no instance is left.
+func (s *scheduler) reload(inst *instance) {
+	defer s.background.Done()
+
+	ctx := context.Background()
+
+	// The model can still be in use by work that borrowed it.
+	s.waitBorrowed(inst)
+
+	if err := s.unload(ctx, inst.llama); err != nil {
+		s.log(ctx, "kronk", "status", "unloading quarantined instance", "instance", inst.id, "ERROR", err)
+	}
+
+	s.mu.Lock()
+	inst.state = InstanceReloading
+	s.mu.Unlock()
+
+	var err error
+	for attempt := range maxReloadAttempts {
+		if attempt > 0 {
+			time.Sleep(reloadBackoff)
+		}
+
+		// There is no point reloading an instance that will be unloaded.
+		s.mu.Lock()
+		closed := s.closed
+		s.mu.Unlock()
+
+		if closed {
+			err = ErrUnloaded
+			break
+		}
+
+		var llama *model.Model
+		llama, err = s.load(inst.device)
+		if err != nil {
+			s.log(ctx, "kronk", "status", "reloading instance", "instance", inst.id, "attempt", attempt+1, "ERROR", err)
+			continue
+		}
+
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		inst.llama = llama
+		inst.reloads++
+		inst.failures = 0
+
+		s.log(ctx, "kronk", "status", "instance reloaded", "instance", inst.id, "device", inst.device, "reloads", inst.reloads)
+
+		// The instance is kept idle when the scheduler is closed so the
+		// model can be unloaded with the others.
+		if s.closed {
+			inst.state = InstanceIdle
+			s.idle = append(s.idle, inst)
+			return
+		}
+
+		s.makeIdle(inst)
+		return
+	}
+
+	// -------------------------------------------------------------------------
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	inst.state = InstanceFailed
+	inst.lastErr = err.Error()
+
+	// The room reserved for an added instance isn't needed anymore.
+	if inst.release != nil {
+		inst.release()
+		inst.release = nil
+	}
+
+	s.log(ctx, "kronk", "status", "instance failed", "instance", inst.id, "ERROR", err)
+
+	if s.unavailable() != nil {
+		for w := s.dequeue(); w != nil; w = s.dequeue() {
+			w.ready <- nil
+		}
+	}
+}
+
+// =============================================================================
+
+// enqueue adds the request to the back of the queue of its subject. The
+// mu lock must be held.
+func (s *scheduler) enqueue(w *waiter, priority Priority) {
+	l := &s.levels[priority]
+
+	if len(l.waiters[w.subject]) == 0 {
+		l.subjects = append(l.subjects, w.subject)
+	}
+
+	l.waiters[w.subject] = append(l.waiters[w.subject], w)
+	s.queued++
+}
+
+// dequeue removes the next request to serve from the queue. The subject at
+// the front of the highest priority level with requests is served and then
+// moved to the back of that level. The mu lock must be held.
+func (s *scheduler) dequeue() *waiter {
+	for i := range s.levels {
+		l := &s.levels[i]
+		if len(l.subjects) == 0 {
+			continue
+		}
+
+		subject := l.subjects[0]
+		l.subjects = l.subjects[1:]
+
+		waiters := l.waiters[subject]
+		w := waiters[0]
+
+		switch len(waiters) {
+		case 1:
+			delete(l.waiters, subject)
+		default:
+			l.waiters[subject] = waiters[1:]
+			l.subjects = append(l.subjects, subject)
+		}
+
+		s.queued--
+		return w
+	}
+
+	return nil
+}
+
+// remove takes the request out of the queue. False is returned when the
+// request is no longer in the queue. The mu lock must be held.
+func (s *scheduler) remove(w *waiter, priority Priority) bool {
+	l := &s.levels[priority]
+
+	waiters := l.waiters[w.subject]
+	for i := range waiters {
+		if waiters[i] != w {
+			continue
+		}
+
+		waiters = append(waiters[:i], waiters[i+1:]...)
+
+		switch len(waiters) {
+		case 0:
+			delete(l.waiters, w.subject)
+			for j, subject := range l.subjects {
+				if subject == w.subject {
+					l.subjects = append(l.subjects[:j], l.subjects[j+1:]...)
+					break
+				}
+			}
+		default:
+			l.waiters[w.subject] = waiters
+		}
+
+		s.queued--
+		return true
+	}
+
+	return false
+}
+
+// retryAfter estimates how long it will take for the queue to have room,
+// based on the average time an instance is held. The mu lock must be held.
+func (s *scheduler) retryAfter() time.Duration {
+	instances := max(len(s.instances), 1)
+
+	wait := s.avgHold * time.Duration((s.queued+instances-1)/instances)
+
+	return max(wait.Round(time.Second), time.Second)
+}
@@ -0,0 +1,295 @@
+package kronk
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ardanlabs/kronk/sdk/kronk/model"
+)
+
+func Test_SchedulerOrder(t *testing.T) {
+	s := newScheduler([]*model.Model{{}}, 0, Scaling{}, nil, nil, nil)
+
+	held, _, err := s.acquire(context.Background(), "a", PriorityInteractive)
+	if err != nil {
+		t.Fatalf("acquire: %v", err)
+	}
+
+	// Subject a queues 2 requests before subject b queues 1 and a batch
+	// request is queued first of all.
+	queue := []struct {
+		name     string
+		subject  string
+		priority Priority
+	}{
+		{"batch", "c", PriorityBatch},
+		{"a1", "a", PriorityInteractive},
+		{"a2", "a", PriorityInteractive},
+		{"b1", "b", PriorityInteractive},
+	}
+
+	served := make(chan string, len(queue))
+
+	for i, q := range queue {
+		go func() {
+			inst, _, err := s.acquire(context.Background(), q.subject, q.priority)
+			if err != nil {
+				t.Errorf("acquire %s: %v", q.name, err)
+				return
+			}
+
+			served <- q.name
+			s.release(inst, nil, false)
+		}()
+
+		waitQueued(t, s, i+1)
+	}
+
+	s.release(held, nil, false)
+
+	exp := []string{"a1", "b1", "a2", "batch"}
+	for i, name := range exp {
+		if got := <-served; got != name {
+			t.Fatalf("request %d: expected %s, got %s", i, name, got)
+		}
+	}
+}
+
+func Test_SchedulerQueueFull(t *testing.T) {
+	s := newScheduler([]*model.Model{{}}, 1, Scaling{}, nil, nil, nil)
+
+	held, _, err := s.acquire(context.Background(), "a", PriorityInteractive)
+	if err != nil {
+		t.Fatalf("acquire: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	done := make(chan error, 1)
+	go func() {
+		_, _, err := s.acquire(ctx, "a", PriorityInteractive)
+		done <- err
+	}()
+
+	waitQueued(t, s, 1)
+
+	_, _, err = s.acquire(context.Background(), "b", PriorityInteractive)
+
+	var qfErr *QueueFullError
+	if !errors.As(err, &qfErr) || !errors.Is(err, ErrQueueFull) {
+		t.Fatalf("expected a queue full error, got %v", err)
+	}
+
+	if qfErr.RetryAfter < time.Second {
+		t.Errorf("expected a retry after of at least a second, got %s", qfErr.RetryAfter)
+	}
+
+	// Canceling the queued request frees its place in the queue.
+	cancel()
+
+	if err := <-done; !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected a canceled error, got %v", err)
+	}
+
+	if n := s.queueDepth(); n != 0 {
+		t.Fatalf("expected an empty queue, got %d", n)
+	}
+
+	s.release(held, nil, false)
+
+	if idle := s.close(); len(idle) != 1 {
+		t.Fatalf("expected 1 idle instance, got %d", len(idle))
+	}
+}
+
+func Test_SchedulerHealth(t *testing.T) {
+	reloaded := &model.Model{}
+
+	load := func(device string) (*model.Model, error) {
+		return reloaded, nil
+	}
+
+	s := newScheduler([]*model.Model{{}, {}}, 0, Scaling{}, nil, load, nil)
+	s.unload = func(ctx context.Context, llama *model.Model) error {
+		return nil
+	}
+
+	// The least loaded instance is handed out, so the busy instance 0 is
+	// skipped in favor of instance 1.
+	first, _, err := s.acquire(context.Background(), "a", PriorityInteractive)
+	if err != nil {
+		t.Fatalf("acquire: %v", err)
+	}
+
+	time.Sleep(10 * time.Millisecond)
+	s.release(first, nil, false)
+
+	for i := range maxInstanceFailures {
+		inst, _, err := s.acquire(context.Background(), "a", PriorityInteractive)
+		if err != nil {
+			t.Fatalf("acquire: %v", err)
+		}
+
+		if inst == first {
+			t.Fatalf("request %d: expected the least loaded instance", i)
+		}
+
+		s.release(inst, errors.New("decode failed"), false)
+
+		if i < maxInstanceFailures-1 && inst.state != InstanceIdle {
+			t.Fatalf("request %d: expected the instance to stay idle, got %s", i, inst.state)
+		}
+	}
+
+	s.background.Wait()
+
+	status := s.status()
+	if status[1].State != InstanceIdle || status[1].Reloads != 1 || status[1].Failures != 0 || status[1].LastError != "decode failed" {
+		t.Fatalf("expected instance 1 to be reloaded, got %+v", status[1])
+	}
+
+	if s.instances[1].llama != reloaded {
+		t.Fatal("expected instance 1 to use the reloaded model")
+	}
+
+	// A panic quarantines the instance right away.
+	inst, _, err := s.acquire(context.Background(), "a", PriorityInteractive)
+	if err != nil {
+		t.Fatalf("acquire: %v", err)
+	}
+
+	s.release(inst, errors.New("boom"), true)
+	s.background.Wait()
+
+	if status := s.status()[inst.id]; status.Panics != 1 || status.Reloads == 0 {
+		t.Fatalf("expected the panic to be recorded, got %+v", status)
+	}
+}
+
+func Test_SchedulerScaling(t *testing.T) {
+	var reserved atomic.Int32
+
+	scaling := Scaling{
+		MaxInstances: 2,
+		QueueWait:    10 * time.Millisecond,
+		CoolDown:     50 * time.Millisecond,
+		Reserve: func(ctx context.Context) (func(), error) {
+			reserved.Add(1)
+			return func() { reserved.Add(-1) }, nil
+		},
+	}
+
+	load := func(device string) (*model.Model, error) {
+		return &model.Model{}, nil
+	}
+
+	s := newScheduler([]*model.Model{{}}, 0, scaling, nil, load, nil)
+	s.unload = func(ctx context.Context, llama *model.Model) error {
+		return nil
+	}
+
+	held, _, err := s.acquire(context.Background(), "a", PriorityInteractive)
+	if err != nil {
+		t.Fatalf("acquire: %v", err)
+	}
+
+	// A request waiting longer than the queue wait gets an added instance.
+	added, _, err := s.acquire(context.Background(), "b", PriorityInteractive)
+	if err != nil {
+		t.Fatalf("acquire: %v", err)
+	}
+
+	if added == held || added.id != 1 {
+		t.Fatalf("expected the added instance 1, got %d", added.id)
+	}
+
+	if n := s.size(); n != 2 || reserved.Load() != 1 {
+		t.Fatalf("expected 2 instances and 1 reservation, got %d and %d", n, reserved.Load())
+	}
+
+	s.release(held, nil, false)
+	s.release(added, nil, false)
+
+	// Once idle for the cool-down, the instances shrink back to the minimum.
+	for range 100 {
+		if s.size() == 1 && reserved.Load() == 0 {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if n := s.size(); n != 1 || reserved.Load() != 0 {
+		t.Fatalf("expected 1 instance and no reservations, got %d and %d", n, reserved.Load())
+	}
+
+	if idle := s.close(); len(idle) != 1 {
+		t.Fatalf("expected 1 idle instance, got %d", len(idle))
+	}
+}
+
+func waitQueued(t *testing.T, s *scheduler, n int) {
+	t.Helper()
+
+	for range 100 {
+		if s.queueDepth() == n {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	t.Fatalf("expected %d queued requests, got %d", n, s.queueDepth())
+}
+
+func Test_SchedulerBorrow(t *testing.T) {
+	s := newScheduler([]*model.Model{{}}, 1, Scaling{}, nil, func(device string) (*model.Model, error) {
+		return &model.Model{}, nil
+	}, nil)
+
+	unloaded := make(chan struct{}, 1)
+	s.unload = func(ctx context.Context, llama *model.Model) error {
+		unloaded <- struct{}{}
+		return nil
+	}
+
+	held, _, err := s.acquire(context.Background(), "a", PriorityInteractive)
+	if err != nil {
+		t.Fatalf("acquire: %v", err)
+	}
+
+	// An instance can be borrowed while it's busy and the queue is full.
+	inst, err := s.borrow()
+	if err != nil {
+		t.Fatalf("borrow: %v", err)
+	}
+
+	if inst != held {
+		t.Fatal("expected the busy instance to be borrowed")
+	}
+
+	// A quarantined instance isn't unloaded until it's given back.
+	s.release(held, nil, true)
+
+	select {
+	case <-unloaded:
+		t.Fatal("expected the borrowed instance to not be unloaded")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	s.giveBack(inst)
+
+	select {
+	case <-unloaded:
+	case <-time.After(time.Second):
+		t.Fatal("expected the instance to be unloaded once given back")
+	}
+
+	s.background.Wait()
+	s.close()
+
+	if _, err := s.borrow(); !errors.Is(err, ErrUnloaded) {
+		t.Fatalf("expected an unloaded error, got %v", err)
+	}
+}
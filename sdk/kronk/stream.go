@@ -0,0 +1,91 @@
+package kronk
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"iter"
+
+	"github.com/ardanlabs/kronk/sdk/kronk/model"
+)
+
+// Set of errors returned by the streaming iterators so callers don't have to
+// inspect the content of an error response.
+var (
+	ErrUnloaded              = errors.New("kronk has been unloaded")
+	ErrCanceled              = errors.New("request canceled")
+	ErrContextWindowExceeded = model.ErrContextWindowExceeded
+)
+
+// ChatStreamingSeq provides support to interact with an inference model
+// using an iterator. Error responses are returned as errors that can be
+// checked with errors.Is against ErrContextWindowExceeded, ErrUnloaded and
+// ErrCanceled, along with the response so the usage is available. Breaking
+// out of the loop cancels the generation and releases the instance before
+// the loop returns.
+func (krn *Kronk) ChatStreamingSeq(ctx context.Context, d model.D) iter.Seq2[model.ChatResponse, error] {
+	return func(yield func(model.ChatResponse, error) bool) {
+		if _, exists := ctx.Deadline(); !exists {
+			yield(model.ChatResponse{}, errors.New("chat-streaming-seq:context has no deadline, provide a reasonable timeout"))
+			return
+		}
+
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+
+		ch, err := krn.ChatStreaming(ctx, d)
+		if err != nil {
+			yield(model.ChatResponse{}, streamError(ctx, err))
+			return
+		}
+
+		// The stream is closed once the instance is released, so wait for
+		// it when the caller stops early.
+		defer func() {
+			cancel()
+			for range ch {
+			}
+		}()
+
+		var finished bool
+
+		for resp := range ch {
+			if err := resp.Err(); err != nil {
+				yield(resp, streamError(ctx, err))
+				return
+			}
+
+			if !yield(resp, nil) {
+				return
+			}
+
+			finished = len(resp.Choice) > 0 && resp.Choice[0].FinishReason != ""
+		}
+
+		// The stream can end without an error response when the caller's
+		// context is done, which still needs to be reported.
+		if !finished {
+			err := ctx.Err()
+			if err == nil {
+				err = errors.New("stream ended without a final response")
+			}
+
+			yield(model.ChatResponse{}, streamError(ctx, err))
+		}
+	}
+}
+
+// streamError maps an error from a stream to one of the package errors when
+// it has a known cause.
+func streamError(ctx context.Context, err error) error {
+	switch {
+	case errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+		return fmt.Errorf("chat-streaming-seq: %w: %w", ErrCanceled, err)
+
+	case ctx.Err() != nil:
+		return fmt.Errorf("chat-streaming-seq: %w: %w", ErrCanceled, ctx.Err())
+
+	default:
+		return fmt.Errorf("chat-streaming-seq: %w", err)
+	}
+}
@@ -0,0 +1,37 @@
+package kronk
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/ardanlabs/kronk/sdk/kronk/model"
+)
+
+func Test_StreamError(t *testing.T) {
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	windowErr := fmt.Errorf("process-chat-request: %w: input tokens 10 exceed context window 5", model.ErrContextWindowExceeded)
+
+	tests := []struct {
+		name string
+		ctx  context.Context
+		err  error
+		exp  error
+	}{
+		{"context window", context.Background(), model.ChatResponseErr("id", model.ObjectChatText, "model", 0, "", windowErr, model.Usage{}).Err(), ErrContextWindowExceeded},
+		{"unloaded", context.Background(), fmt.Errorf("acquire-model: %w", ErrUnloaded), ErrUnloaded},
+		{"canceled", context.Background(), context.Canceled, ErrCanceled},
+		{"context done", canceled, errors.New("decode failed"), ErrCanceled},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := streamError(tt.ctx, tt.err); !errors.Is(err, tt.exp) {
+				t.Errorf("expected %v, got %v", tt.exp, err)
+			}
+		})
+	}
+}
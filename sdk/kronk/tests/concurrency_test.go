@@ -2,12 +2,14 @@ package kronk_test
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"path"
 	"strings"
 	"testing"
 	"time"
 
+	"github.com/ardanlabs/kronk/sdk/kronk"
 	"github.com/ardanlabs/kronk/sdk/kronk/model"
 	"github.com/google/uuid"
 )
@@ -194,3 +196,42 @@ func Test_ConTest3(t *testing.T) {
 		t.Errorf("expected channel to be closed")
 	}
 }
+
+func Test_ConTest4(t *testing.T) {
+	// This test breaks out of the iterator loop and checks the instance is
+	// released right away so Kronk can be unloaded.
+
+	ctx, cancel := context.WithTimeout(context.Background(), testDuration)
+	defer cancel()
+
+	krn, d := initChatTest(t, mpThinkToolChat, false)
+
+	var index int
+	for _, err := range krn.ChatStreamingSeq(ctx, d) {
+		if err != nil {
+			t.Fatalf("should not receive an error streaming: %s", err)
+		}
+
+		index++
+		if index == 5 {
+			break
+		}
+	}
+
+	if n := krn.ActiveStreams(); n != 0 {
+		t.Errorf("expected no active streams after breaking the loop, got %d", n)
+	}
+
+	shortCtx, shortCancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer shortCancel()
+
+	if err := krn.Unload(shortCtx); err != nil {
+		t.Fatalf("should not receive an error unloading Kronk: %s", err)
+	}
+
+	for _, err := range krn.ChatStreamingSeq(ctx, d) {
+		if !errors.Is(err, kronk.ErrUnloaded) {
+			t.Errorf("expected an unloaded error, got %v", err)
+		}
+	}
+}
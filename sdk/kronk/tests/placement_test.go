@@ -0,0 +1,87 @@
+package kronk_test
+
+import (
+	"context"
+	"os"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/ardanlabs/kronk/sdk/kronk"
+	"github.com/ardanlabs/kronk/sdk/kronk/model"
+)
+
+// Test_Placement loads the instances of a model across the CPU backend
+// devices llama.cpp reports. The devices can be set with PLACEMENT_DEVICES,
+// a comma separated list, like the RPC servers running on each NUMA node.
+func Test_Placement(t *testing.T) {
+	devices := placementDevices()
+	if len(devices) < 2 {
+		t.Skipf("placement needs at least 2 CPU backend devices, found %v", devices)
+	}
+
+	policies := []string{kronk.PlacementRoundRobin, kronk.PlacementLeastLoaded}
+
+	for _, policy := range policies {
+		t.Run(policy, func(t *testing.T) {
+			placement := kronk.Placement{
+				Devices: devices,
+				Policy:  policy,
+			}
+
+			krn, err := kronk.New(len(devices), model.Config{ModelFile: mpEmbed.ModelFile}, kronk.WithPlacement(placement))
+			if err != nil {
+				t.Fatalf("unable to create inference model: %v", err)
+			}
+			defer func() {
+				if err := krn.Unload(context.Background()); err != nil {
+					t.Errorf("failed to unload model: %v", err)
+				}
+			}()
+
+			// Every device gets one instance of the model.
+			var got []string
+			for _, status := range krn.InstanceStatus() {
+				got = append(got, status.Device)
+			}
+
+			slices.Sort(got)
+			exp := slices.Sorted(slices.Values(devices))
+
+			if !slices.Equal(got, exp) {
+				t.Fatalf("expected an instance on each of %v, got %v", exp, got)
+			}
+
+			// Every instance handles requests on its device.
+			ctx, cancel := context.WithTimeout(context.Background(), testDuration)
+			defer cancel()
+
+			for range devices {
+				if _, err := krn.Embeddings(ctx, "Embed this sentence"); err != nil {
+					t.Fatalf("embeddings: %v", err)
+				}
+			}
+
+			for _, status := range krn.InstanceStatus() {
+				if status.Requests == 0 || status.BusyTime == 0 {
+					t.Errorf("expected the instance on %s to handle a request, got %+v", status.Device, status)
+				}
+			}
+		})
+	}
+}
+
+func placementDevices() []string {
+	if devices := os.Getenv("PLACEMENT_DEVICES"); devices != "" {
+		return strings.Split(devices, ",")
+	}
+
+	var cpus []string
+	for _, device := range kronk.Devices() {
+		if strings.HasPrefix(device, "CPU") || strings.HasPrefix(device, "RPC") {
+			cpus = append(cpus, device)
+		}
+	}
+
+	return cpus
+}
@@ -20,6 +20,7 @@ type metrics struct {
 	prefillNonMediaTime *avgMetric
 	prefillMediaTime    *avgMetric
 	timeToFirstToken    *avgMetric
+	queueWaitTime       *avgMetric
 	chatCompletions     *usage
 }
 
@@ -35,6 +36,7 @@ func init() {
 		prefillNonMediaTime: newAvgMetric("model_prefill_nonmedia"),
 		prefillMediaTime:    newAvgMetric("model_prefill_media"),
 		timeToFirstToken:    newAvgMetric("model_ttft"),
+		queueWaitTime:       newAvgMetric("model_queue_wait"),
 		chatCompletions:     newUsage("usage_chatcompletions"),
 	}
 }
@@ -94,6 +96,11 @@ func AddTimeToFirstToken(duration time.Duration) {
 	m.timeToFirstToken.add(duration.Seconds())
 }
 
+// AddQueueWaitTime captures the specified duration a request waited for a model.
+func AddQueueWaitTime(duration time.Duration) {
+	m.queueWaitTime.add(duration.Seconds())
+}
+
 // AddChatCompletionsUsage captures the specified usage values for chat-completions.
 func AddChatCompletionsUsage(promptTokens, reasoningTokens, completionTokens, outputTokens, totalTokens int, tokensPerSecond float64) {
 	data := usageData{
@@ -2,6 +2,8 @@ package catalog
 
 import (
 	"time"
+
+	"github.com/ardanlabs/kronk/sdk/tools/configs"
 )
 
 // Metadata represents extra information about the model.
@@ -30,8 +32,9 @@ type File struct {
 
 // Files represents file information for a model.
 type Files struct {
-	Models []File `yaml:"models"`
-	Projs  []File `yaml:"projs"`
+	Models   []File `yaml:"models"`
+	Projs    []File `yaml:"projs"`
+	Adapters []File `yaml:"adapters"`
 }
 
 // ToURLS converts a slice of File to a string of the URLs.
@@ -51,18 +54,30 @@ func (f Files) ToURLS() (models []string, projs []string) {
 	return models, projs
 }
 
+// ToAdapterURLS converts the slice of adapter files to a string of the URLs.
+func (f Files) ToAdapterURLS() []string {
+	adapters := make([]string, len(f.Adapters))
+
+	for i, file := range f.Adapters {
+		adapters[i] = file.URL
+	}
+
+	return adapters
+}
+
 // Model represents information for a model.
 type Model struct {
-	ID           string       `yaml:"id"`
-	Category     string       `yaml:"category"`
-	OwnedBy      string       `yaml:"owned_by"`
-	ModelFamily  string       `yaml:"model_family"`
-	WebPage      string       `yaml:"web_page"`
-	GatedModel   bool         `yaml:"gated_model"`
-	Template     string       `yaml:"template"`
-	Files        Files        `yaml:"files"`
-	Capabilities Capabilities `yaml:"capabilities"`
-	Metadata     Metadata     `yaml:"metadata"`
+	ID           string              `yaml:"id"`
+	Category     string              `yaml:"category"`
+	OwnedBy      string              `yaml:"owned_by"`
+	ModelFamily  string              `yaml:"model_family"`
+	WebPage      string              `yaml:"web_page"`
+	GatedModel   bool                `yaml:"gated_model"`
+	Template     string              `yaml:"template"`
+	Files        Files               `yaml:"files"`
+	Capabilities Capabilities        `yaml:"capabilities"`
+	Metadata     Metadata            `yaml:"metadata"`
+	Config       configs.ModelConfig `yaml:"config"`
 	Downloaded   bool
 }
 
@@ -8,6 +8,7 @@ import (
 	"slices"
 	"strings"
 
+	"github.com/ardanlabs/kronk/sdk/tools/configs"
 	"go.yaml.in/yaml/v2"
 )
 
@@ -129,6 +130,26 @@ func (c *Catalog) RetrieveCatalogs() ([]CatalogModels, error) {
 	return catalogs, nil
 }
 
+// RetrieveModelConfigs returns the model configurations embedded in the
+// catalogs.
+func (c *Catalog) RetrieveModelConfigs() ([]configs.ModelConfig, error) {
+	catalogs, err := c.RetrieveCatalogs()
+	if err != nil {
+		return nil, fmt.Errorf("retrieve-model-configs: %w", err)
+	}
+
+	var mcs []configs.ModelConfig
+	for _, cat := range catalogs {
+		for _, model := range cat.Models {
+			mc := model.Config
+			mc.ID = model.ID
+			mcs = append(mcs, mc)
+		}
+	}
+
+	return mcs, nil
+}
+
 // =============================================================================
 
 func (c *Catalog) buildIndex() error {
@@ -0,0 +1,332 @@
+// Package configs provides support for per-model configuration files.
+package configs
+
+import (
+	"cmp"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ardanlabs/kronk/sdk/kronk/model"
+	"github.com/ardanlabs/kronk/sdk/tools/defaults"
+	"go.yaml.in/yaml/v2"
+)
+
+const (
+	localFolder = "configs"
+)
+
+// ModelConfig represents the configuration for a specific model. Values set
+// to their zero value are not applied and the server settings are used.
+//
+// ID is the id of the model the configuration is for.
+//
+// Aliases are alternate names that can be used in a request to select the
+// model, like "fast" or "embed".
+//
+// ContextWindow, NBatch, NUBatch, NThreads and NThreadsBatch override the
+// same settings in the model configuration.
+//
+// Adapters is the set of adapters to load by name. When empty, all the
+// adapters found next to the model are loaded.
+//
+// Instances is the number of instances of the model to load.
+//
+// MinInstances is the number of instances the model keeps loaded and takes
+// the place of Instances when set. MaxInstances is the number of instances
+// the model can grow to when requests wait too long for an instance, memory
+// permitting. Instances above the minimum are removed after a cool-down.
+//
+// Device is the device to load the model on.
+//
+// Devices is the set of devices to spread the instances of the model across
+// using the Placement policy, round-robin or least-loaded. Device is ignored
+// when Devices is set.
+//
+// Template is the jinja template to use instead of the one provided by the
+// catalog or the model metadata. It can be a path to a file or the name of a
+// file in the templates folder.
+//
+// TTL is the time the model can live in the cache without being used.
+//
+// Pinned keeps the model in the cache until the server shuts down. A pinned
+// model doesn't expire and isn't evicted to make room for other models.
+//
+// Params are the default sampling parameters used when a request doesn't
+// provide them.
+//
+// The remaining settings are the llama.cpp load and context options
+// documented in model.Config.
+type ModelConfig struct {
+	ID            string        `yaml:"id"`
+	Aliases       []string      `yaml:"aliases"`
+	ContextWindow int           `yaml:"context_window"`
+	NBatch        int           `yaml:"nbatch"`
+	NUBatch       int           `yaml:"nubatch"`
+	NThreads      int           `yaml:"nthreads"`
+	NThreadsBatch int           `yaml:"nthreads_batch"`
+	Adapters      []string      `yaml:"adapters"`
+	Instances     int           `yaml:"instances"`
+	MinInstances  int           `yaml:"min_instances"`
+	MaxInstances  int           `yaml:"max_instances"`
+	Device        string        `yaml:"device"`
+	Devices       []string      `yaml:"devices"`
+	Placement     string        `yaml:"placement"`
+	Template      string        `yaml:"template"`
+	TTL           time.Duration `yaml:"ttl"`
+	Pinned        bool          `yaml:"pinned"`
+	Params        model.Params  `yaml:"params"`
+
+	UseMmap        *bool   `yaml:"use_mmap"`
+	UseMlock       *bool   `yaml:"use_mlock"`
+	NGPULayers     *int    `yaml:"ngpu_layers"`
+	OffloadKQV     *bool   `yaml:"offload_kqv"`
+	FlashAttention string  `yaml:"flash_attention"`
+	TypeK          string  `yaml:"type_k"`
+	TypeV          string  `yaml:"type_v"`
+	RopeScaling    string  `yaml:"rope_scaling"`
+	RopeFreqBase   float32 `yaml:"rope_freq_base"`
+	RopeFreqScale  float32 `yaml:"rope_freq_scale"`
+	YarnExtFactor  float32 `yaml:"yarn_ext_factor"`
+	YarnAttnFactor float32 `yaml:"yarn_attn_factor"`
+	YarnBetaFast   float32 `yaml:"yarn_beta_fast"`
+	YarnBetaSlow   float32 `yaml:"yarn_beta_slow"`
+	YarnOrigCtx    int     `yaml:"yarn_orig_ctx"`
+	NSeqMax        int     `yaml:"nseq_max"`
+	SWAFull        *bool   `yaml:"swa_full"`
+}
+
+// isZero reports if nothing is configured.
+func (mc ModelConfig) isZero() bool {
+	return mc.ContextWindow == 0 &&
+		len(mc.Aliases) == 0 &&
+		mc.NBatch == 0 &&
+		mc.NUBatch == 0 &&
+		mc.NThreads == 0 &&
+		mc.NThreadsBatch == 0 &&
+		len(mc.Adapters) == 0 &&
+		mc.Instances == 0 &&
+		mc.MinInstances == 0 &&
+		mc.MaxInstances == 0 &&
+		mc.Device == "" &&
+		len(mc.Devices) == 0 &&
+		mc.Placement == "" &&
+		mc.Template == "" &&
+		mc.TTL == 0 &&
+		!mc.Pinned &&
+		mc.Params == model.Params{} &&
+		mc.UseMlock == nil &&
+		mc.SWAFull == nil &&
+		reflect.ValueOf(mc.runtimeConfig()).IsZero()
+}
+
+// runtimeConfig returns a model config with the llama.cpp load and context
+// options set.
+func (mc ModelConfig) runtimeConfig() model.Config {
+	return model.Config{
+		UseMmap:        mc.UseMmap,
+		UseMlock:       mc.UseMlock != nil && *mc.UseMlock,
+		NGPULayers:     mc.NGPULayers,
+		OffloadKQV:     mc.OffloadKQV,
+		FlashAttention: mc.FlashAttention,
+		TypeK:          mc.TypeK,
+		TypeV:          mc.TypeV,
+		RopeScaling:    mc.RopeScaling,
+		RopeFreqBase:   mc.RopeFreqBase,
+		RopeFreqScale:  mc.RopeFreqScale,
+		YarnExtFactor:  mc.YarnExtFactor,
+		YarnAttnFactor: mc.YarnAttnFactor,
+		YarnBetaFast:   mc.YarnBetaFast,
+		YarnBetaSlow:   mc.YarnBetaSlow,
+		YarnOrigCtx:    mc.YarnOrigCtx,
+		NSeqMax:        mc.NSeqMax,
+		SWAFull:        mc.SWAFull != nil && *mc.SWAFull,
+	}
+}
+
+// ModelConfigs represents a set of model configurations in a file.
+type ModelConfigs struct {
+	Models []ModelConfig `yaml:"models"`
+}
+
+// =============================================================================
+
+// Configs manages the per-model configuration system.
+type Configs struct {
+	configPath string
+	mu         sync.RWMutex
+	models     map[string]ModelConfig
+	aliases    map[string]string
+}
+
+// New constructs the configs system using defaults paths.
+func New() (*Configs, error) {
+	return NewWithPaths("")
+}
+
+// NewWithPaths constructs the configs system, If the basePath is empty, the
+// default location is used. The configuration files in the configs folder
+// are loaded.
+func NewWithPaths(basePath string) (*Configs, error) {
+	basePath = defaults.BaseDir(basePath)
+
+	configPath := filepath.Join(basePath, localFolder)
+
+	if err := os.MkdirAll(configPath, 0755); err != nil {
+		return nil, fmt.Errorf("creating configs directory: %w", err)
+	}
+
+	c := Configs{
+		configPath: configPath,
+		models:     make(map[string]ModelConfig),
+		aliases:    make(map[string]string),
+	}
+
+	if err := c.load(); err != nil {
+		return nil, err
+	}
+
+	return &c, nil
+}
+
+// Path returns the location of the configs path.
+func (c *Configs) Path() string {
+	return c.configPath
+}
+
+// AddDefaults adds the configurations for models that don't have one in the
+// configuration files, like the configurations embedded in the catalog. A
+// configuration in the files always takes precedence. Empty configurations
+// are ignored.
+func (c *Configs) AddDefaults(mcs []ModelConfig) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, mc := range mcs {
+		if mc.isZero() {
+			continue
+		}
+
+		if _, exists := c.models[strings.ToLower(mc.ID)]; exists {
+			continue
+		}
+
+		if err := c.add(mc); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Resolve returns the model id for the specified model id or alias. The id
+// is returned in lowercase.
+func (c *Configs) Resolve(modelID string) string {
+	modelID = strings.ToLower(modelID)
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if id, exists := c.aliases[modelID]; exists {
+		return id
+	}
+
+	return modelID
+}
+
+// Retrieve returns the configuration for the specified model id or alias.
+func (c *Configs) Retrieve(modelID string) (ModelConfig, bool) {
+	modelID = c.Resolve(modelID)
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	mc, exists := c.models[modelID]
+
+	return mc, exists
+}
+
+// =============================================================================
+
+func (c *Configs) load() error {
+	entries, err := os.ReadDir(c.configPath)
+	if err != nil {
+		return fmt.Errorf("load: read configs dir: %w", err)
+	}
+
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+
+		switch filepath.Ext(entry.Name()) {
+		case ".yaml", ".yml":
+		default:
+			continue
+		}
+
+		data, err := os.ReadFile(filepath.Join(c.configPath, entry.Name()))
+		if err != nil {
+			return fmt.Errorf("load: read file %s: %w", entry.Name(), err)
+		}
+
+		var mcs ModelConfigs
+		if err := yaml.UnmarshalStrict(data, &mcs); err != nil {
+			return fmt.Errorf("load: unmarshal %s: %w", entry.Name(), err)
+		}
+
+		for _, mc := range mcs.Models {
+			if _, exists := c.models[strings.ToLower(mc.ID)]; exists {
+				return fmt.Errorf("load: %s: model %q is configured more than once", entry.Name(), mc.ID)
+			}
+
+			if err := c.add(mc); err != nil {
+				return fmt.Errorf("load: %s: %w", entry.Name(), err)
+			}
+		}
+	}
+
+	return nil
+}
+
+func (c *Configs) add(mc ModelConfig) error {
+	if mc.ID == "" {
+		return fmt.Errorf("add: model id is required")
+	}
+
+	if mc.ContextWindow < 0 || mc.NBatch < 0 || mc.NUBatch < 0 || mc.Instances < 0 || mc.MinInstances < 0 || mc.MaxInstances < 0 || mc.TTL < 0 {
+		return fmt.Errorf("add: model %q: values can't be negative", mc.ID)
+	}
+
+	if minInstances := cmp.Or(mc.MinInstances, mc.Instances); mc.MaxInstances != 0 && mc.MaxInstances < minInstances {
+		return fmt.Errorf("add: model %q: max instances %d is less than min instances %d", mc.ID, mc.MaxInstances, minInstances)
+	}
+
+	if err := model.ValidateRuntime(mc.runtimeConfig()); err != nil {
+		return fmt.Errorf("add: model %q: %w", mc.ID, err)
+	}
+
+	id := strings.ToLower(mc.ID)
+
+	for _, alias := range mc.Aliases {
+		alias = strings.ToLower(alias)
+
+		if _, exists := c.models[alias]; exists || alias == id {
+			return fmt.Errorf("add: model %q: alias %q is a model id", mc.ID, alias)
+		}
+
+		if other, exists := c.aliases[alias]; exists && other != id {
+			return fmt.Errorf("add: model %q: alias %q is used by model %q", mc.ID, alias, other)
+		}
+
+		c.aliases[alias] = id
+	}
+
+	c.models[id] = mc
+
+	return nil
+}
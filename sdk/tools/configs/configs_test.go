@@ -0,0 +1,163 @@
+package configs_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ardanlabs/kronk/sdk/kronk/model"
+	"github.com/ardanlabs/kronk/sdk/tools/configs"
+	"github.com/google/go-cmp/cmp"
+)
+
+const testConfig = `models:
+  - id: Qwen3-8B-Q8_0
+    aliases: [fast, Chat]
+    context_window: 32768
+    instances: 2
+    max_instances: 4
+    devices: [CUDA0, CUDA1]
+    placement: least-loaded
+    ttl: 10m
+    pinned: true
+    params:
+      temperature: 0.6
+      top_k: 20
+      enable_thinking: "false"
+    flash_attention: enabled
+    type_k: q8_0
+    type_v: q8_0
+  - id: embeddinggemma-300m-qat-Q8_0
+    aliases: [embed]
+    context_window: 512
+`
+
+func Test_Configs(t *testing.T) {
+	basePath := t.TempDir()
+
+	writeConfig(t, basePath, "models.yaml", testConfig)
+
+	cfgs, err := configs.NewWithPaths(basePath)
+	if err != nil {
+		t.Fatalf("new configs: %v", err)
+	}
+
+	t.Run("resolve", func(t *testing.T) {
+		tests := []struct {
+			id  string
+			exp string
+		}{
+			{"fast", "qwen3-8b-q8_0"},
+			{"CHAT", "qwen3-8b-q8_0"},
+			{"embed", "embeddinggemma-300m-qat-q8_0"},
+			{"Qwen3-8B-Q8_0", "qwen3-8b-q8_0"},
+			{"other-model", "other-model"},
+		}
+
+		for _, tt := range tests {
+			if got := cfgs.Resolve(tt.id); got != tt.exp {
+				t.Errorf("%s: expected %s, got %s", tt.id, tt.exp, got)
+			}
+		}
+	})
+
+	t.Run("retrieve", func(t *testing.T) {
+		mc, exists := cfgs.Retrieve("fast")
+		if !exists {
+			t.Fatal("expected a config for the alias")
+		}
+
+		exp := configs.ModelConfig{
+			ID:            "Qwen3-8B-Q8_0",
+			Aliases:       []string{"fast", "Chat"},
+			ContextWindow: 32768,
+			Instances:     2,
+			MaxInstances:  4,
+			Devices:       []string{"CUDA0", "CUDA1"},
+			Placement:     "least-loaded",
+			TTL:           10 * time.Minute,
+			Pinned:        true,
+			Params: model.Params{
+				Temperature: 0.6,
+				TopK:        20,
+				Thinking:    model.ThinkingDisabled,
+			},
+			FlashAttention: "enabled",
+			TypeK:          "q8_0",
+			TypeV:          "q8_0",
+		}
+
+		if diff := cmp.Diff(exp, mc); diff != "" {
+			t.Errorf("config mismatch (-exp +got):\n%s", diff)
+		}
+
+		if _, exists := cfgs.Retrieve("other-model"); exists {
+			t.Error("expected no config for other-model")
+		}
+	})
+
+	t.Run("defaults", func(t *testing.T) {
+		mcs := []configs.ModelConfig{
+			{ID: "qwen3-8b-q8_0", ContextWindow: 4096},
+			{ID: "gemma-3-4b-it-q4_k_m", Aliases: []string{"vision"}, ContextWindow: 8192},
+			{ID: "no-config"},
+		}
+
+		if err := cfgs.AddDefaults(mcs); err != nil {
+			t.Fatalf("add defaults: %v", err)
+		}
+
+		if mc, _ := cfgs.Retrieve("qwen3-8b-q8_0"); mc.ContextWindow != 32768 {
+			t.Errorf("expected the file config to take precedence, got %d", mc.ContextWindow)
+		}
+
+		if mc, _ := cfgs.Retrieve("vision"); mc.ContextWindow != 8192 {
+			t.Errorf("expected the default config, got %d", mc.ContextWindow)
+		}
+
+		if _, exists := cfgs.Retrieve("no-config"); exists {
+			t.Error("expected an empty config to be ignored")
+		}
+	})
+}
+
+func Test_ConfigsInvalid(t *testing.T) {
+	tests := []struct {
+		name   string
+		config string
+	}{
+		{"unknown field", "models:\n  - id: a\n    context_windows: 512\n"},
+		{"missing id", "models:\n  - context_window: 512\n"},
+		{"duplicate model", "models:\n  - id: a\n  - id: A\n"},
+		{"duplicate alias", "models:\n  - id: a\n    aliases: [x]\n  - id: b\n    aliases: [x]\n"},
+		{"negative value", "models:\n  - id: a\n    context_window: -1\n"},
+		{"max below min instances", "models:\n  - id: a\n    min_instances: 2\n    max_instances: 1\n"},
+		{"unknown cache type", "models:\n  - id: a\n    type_k: q3_k\n"},
+		{"quantized v cache", "models:\n  - id: a\n    type_v: q8_0\n    flash_attention: disabled\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			basePath := t.TempDir()
+
+			writeConfig(t, basePath, "models.yaml", tt.config)
+
+			if _, err := configs.NewWithPaths(basePath); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+}
+
+func writeConfig(t *testing.T, basePath string, name string, content string) {
+	path := filepath.Join(basePath, "configs")
+
+	if err := os.MkdirAll(path, 0755); err != nil {
+		t.Fatalf("create configs dir: %v", err)
+	}
+
+	if err := os.WriteFile(filepath.Join(path, name), []byte(content), 0644); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+}
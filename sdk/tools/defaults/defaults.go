@@ -2,10 +2,14 @@
 package defaults
 
 import (
+	"bufio"
 	"fmt"
 	"os"
+	"os/exec"
 	"path/filepath"
 	"runtime"
+	"strconv"
+	"strings"
 
 	"github.com/hybridgroup/yzma/pkg/download"
 )
@@ -73,3 +77,46 @@ func Processor(override string) (download.Processor, error) {
 
 	return download.CPU, nil
 }
+
+// SystemMemory returns the total physical memory of the machine in bytes. If
+// the memory can't be identified, 0 is returned.
+func SystemMemory() uint64 {
+	switch runtime.GOOS {
+	case "linux":
+		f, err := os.Open("/proc/meminfo")
+		if err != nil {
+			return 0
+		}
+		defer f.Close()
+
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			fields := strings.Fields(scanner.Text())
+			if len(fields) < 2 || fields[0] != "MemTotal:" {
+				continue
+			}
+
+			kb, err := strconv.ParseUint(fields[1], 10, 64)
+			if err != nil {
+				return 0
+			}
+
+			return kb * 1024
+		}
+
+	case "darwin":
+		out, err := exec.Command("sysctl", "-n", "hw.memsize").Output()
+		if err != nil {
+			return 0
+		}
+
+		size, err := strconv.ParseUint(strings.TrimSpace(string(out)), 10, 64)
+		if err != nil {
+			return 0
+		}
+
+		return size
+	}
+
+	return 0
+}
@@ -0,0 +1,412 @@
+// Package fetcher provides support for retrieving media referenced by URL in
+// chat requests using a policy that keeps the server safe.
+package fetcher
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	defMaxSize  = 20 * 1024 * 1024
+	defTimeout  = 30 * time.Second
+	defCacheTTL = 24 * time.Hour
+)
+
+// ErrNotAllowed is returned when the policy doesn't allow the media to be
+// retrieved.
+var ErrNotAllowed = errors.New("media location not allowed")
+
+// Config represents the policy for retrieving media.
+//
+// AllowedHosts is the set of hosts media can be retrieved from over http(s).
+// An entry can be a host name, a host:port pair, or a wildcard like
+// "*.example.com" that matches any sub-domain. When empty, http(s) URLs are
+// not allowed.
+//
+// FileRoots is the set of directories media can be read from using file://
+// URLs. When empty, file URLs are not allowed.
+//
+// MaxSize is the maximum number of bytes a media item can have.
+// When set to 0, the default value is 20 MiB.
+//
+// Timeout is the maximum amount of time to retrieve a media item.
+// When set to 0, the default value is 30 seconds.
+//
+// CacheDir is the directory used to cache media retrieved over http(s). When
+// empty, media is not cached.
+//
+// CacheTTL is the amount of time a cached media item is used.
+// When set to 0, the default value is 24 hours.
+//
+// Client is the http client to use. When nil, a client is constructed.
+type Config struct {
+	AllowedHosts []string
+	FileRoots    []string
+	MaxSize      int64
+	Timeout      time.Duration
+	CacheDir     string
+	CacheTTL     time.Duration
+	Client       *http.Client
+}
+
+// Fetcher retrieves media from http(s) and file URLs.
+type Fetcher struct {
+	allowedHosts []string
+	fileRoots    []string
+	maxSize      int64
+	timeout      time.Duration
+	cacheDir     string
+	cacheTTL     time.Duration
+	client       *http.Client
+}
+
+// New constructs a fetcher with the specified policy.
+func New(cfg Config) (*Fetcher, error) {
+	if cfg.MaxSize <= 0 {
+		cfg.MaxSize = defMaxSize
+	}
+
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = defTimeout
+	}
+
+	if cfg.CacheTTL <= 0 {
+		cfg.CacheTTL = defCacheTTL
+	}
+
+	hosts := make([]string, 0, len(cfg.AllowedHosts))
+	for _, host := range cfg.AllowedHosts {
+		if host = strings.ToLower(strings.TrimSpace(host)); host != "" {
+			hosts = append(hosts, host)
+		}
+	}
+
+	roots := make([]string, 0, len(cfg.FileRoots))
+	for _, root := range cfg.FileRoots {
+		if root == "" {
+			continue
+		}
+
+		abs, err := filepath.Abs(root)
+		if err != nil {
+			return nil, fmt.Errorf("new: invalid file root %q: %w", root, err)
+		}
+
+		// Resolve symlinks so the check against the requested file, which
+		// is also resolved, compares the real locations.
+		if real, err := filepath.EvalSymlinks(abs); err == nil {
+			abs = real
+		}
+
+		roots = append(roots, abs)
+	}
+
+	if cfg.CacheDir != "" {
+		if err := os.MkdirAll(cfg.CacheDir, 0755); err != nil {
+			return nil, fmt.Errorf("new: creating cache directory: %w", err)
+		}
+	}
+
+	f := Fetcher{
+		allowedHosts: hosts,
+		fileRoots:    roots,
+		maxSize:      cfg.MaxSize,
+		timeout:      cfg.Timeout,
+		cacheDir:     cfg.CacheDir,
+		cacheTTL:     cfg.CacheTTL,
+	}
+
+	client := cfg.Client
+	if client == nil {
+		client = &http.Client{}
+	}
+
+	// Copy the client so the redirect policy doesn't change the caller's
+	// client. Every redirect must also land on an allowed host.
+	c := *client
+	c.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		if len(via) >= 5 {
+			return errors.New("too many redirects")
+		}
+
+		if !f.hostAllowed(req.URL) {
+			return fmt.Errorf("redirect to %s: %w", req.URL.Host, ErrNotAllowed)
+		}
+
+		return nil
+	}
+
+	f.client = &c
+
+	return &f, nil
+}
+
+// Fetch retrieves the media for the specified URL. Only http, https, and file
+// URLs are supported and the content must be an image, audio, or video.
+func (f *Fetcher) Fetch(ctx context.Context, rawURL string) ([]byte, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("fetch: invalid url: %w", err)
+	}
+
+	switch u.Scheme {
+	case "http", "https":
+		return f.fetchHTTP(ctx, u)
+
+	case "file":
+		return f.fetchFile(u)
+	}
+
+	return nil, fmt.Errorf("fetch: scheme %q: %w", u.Scheme, ErrNotAllowed)
+}
+
+// =============================================================================
+
+func (f *Fetcher) fetchHTTP(ctx context.Context, u *url.URL) ([]byte, error) {
+	if !f.hostAllowed(u) {
+		return nil, fmt.Errorf("fetch-http: host %q: %w", u.Host, ErrNotAllowed)
+	}
+
+	if data, ok := f.cacheGet(u.String()); ok {
+		return data, nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, f.timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("fetch-http: unable to create request: %w", err)
+	}
+
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("fetch-http: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetch-http: unexpected status: %s", resp.Status)
+	}
+
+	if resp.ContentLength > f.maxSize {
+		return nil, fmt.Errorf("fetch-http: media size %d exceeds the maximum of %d bytes", resp.ContentLength, f.maxSize)
+	}
+
+	data, err := f.readLimited(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("fetch-http: %w", err)
+	}
+
+	if _, err := Sniff(data); err != nil {
+		return nil, fmt.Errorf("fetch-http: %w", err)
+	}
+
+	f.cachePut(u.String(), data)
+
+	return data, nil
+}
+
+func (f *Fetcher) fetchFile(u *url.URL) ([]byte, error) {
+	if len(f.fileRoots) == 0 {
+		return nil, fmt.Errorf("fetch-file: file urls: %w", ErrNotAllowed)
+	}
+
+	if u.Host != "" && u.Host != "localhost" {
+		return nil, fmt.Errorf("fetch-file: host %q: %w", u.Host, ErrNotAllowed)
+	}
+
+	name, err := filepath.Abs(filepath.FromSlash(u.Path))
+	if err != nil {
+		return nil, fmt.Errorf("fetch-file: invalid path: %w", err)
+	}
+
+	name, err = filepath.EvalSymlinks(name)
+	if err != nil {
+		return nil, fmt.Errorf("fetch-file: %w", err)
+	}
+
+	if !f.fileAllowed(name) {
+		return nil, fmt.Errorf("fetch-file: path %q: %w", u.Path, ErrNotAllowed)
+	}
+
+	file, err := os.Open(name)
+	if err != nil {
+		return nil, fmt.Errorf("fetch-file: %w", err)
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return nil, fmt.Errorf("fetch-file: %w", err)
+	}
+
+	if !info.Mode().IsRegular() {
+		return nil, fmt.Errorf("fetch-file: %q is not a regular file", u.Path)
+	}
+
+	if info.Size() > f.maxSize {
+		return nil, fmt.Errorf("fetch-file: media size %d exceeds the maximum of %d bytes", info.Size(), f.maxSize)
+	}
+
+	data, err := f.readLimited(file)
+	if err != nil {
+		return nil, fmt.Errorf("fetch-file: %w", err)
+	}
+
+	if _, err := Sniff(data); err != nil {
+		return nil, fmt.Errorf("fetch-file: %w", err)
+	}
+
+	return data, nil
+}
+
+func (f *Fetcher) readLimited(r io.Reader) ([]byte, error) {
+	data, err := io.ReadAll(io.LimitReader(r, f.maxSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("read: %w", err)
+	}
+
+	if int64(len(data)) > f.maxSize {
+		return nil, fmt.Errorf("media exceeds the maximum of %d bytes", f.maxSize)
+	}
+
+	return data, nil
+}
+
+func (f *Fetcher) hostAllowed(u *url.URL) bool {
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return false
+	}
+
+	host := strings.ToLower(u.Host)
+	hostname := strings.ToLower(u.Hostname())
+
+	for _, allowed := range f.allowedHosts {
+		switch {
+		case allowed == host || allowed == hostname:
+			return true
+
+		case strings.HasPrefix(allowed, "*.") && strings.HasSuffix(hostname, allowed[1:]):
+			return true
+		}
+	}
+
+	return false
+}
+
+func (f *Fetcher) fileAllowed(name string) bool {
+	for _, root := range f.fileRoots {
+		rel, err := filepath.Rel(root, name)
+		if err != nil {
+			continue
+		}
+
+		if rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) && !filepath.IsAbs(rel) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// =============================================================================
+
+func (f *Fetcher) cacheFile(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return filepath.Join(f.cacheDir, hex.EncodeToString(sum[:]))
+}
+
+func (f *Fetcher) cacheGet(key string) ([]byte, bool) {
+	if f.cacheDir == "" {
+		return nil, false
+	}
+
+	name := f.cacheFile(key)
+
+	info, err := os.Stat(name)
+	if err != nil {
+		return nil, false
+	}
+
+	if time.Since(info.ModTime()) > f.cacheTTL {
+		os.Remove(name)
+		return nil, false
+	}
+
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return nil, false
+	}
+
+	return data, true
+}
+
+func (f *Fetcher) cachePut(key string, data []byte) {
+	if f.cacheDir == "" {
+		return
+	}
+
+	name := f.cacheFile(key)
+
+	tmp, err := os.CreateTemp(f.cacheDir, ".media-*")
+	if err != nil {
+		return
+	}
+
+	_, err = io.Copy(tmp, bytes.NewReader(data))
+	tmp.Close()
+
+	if err != nil {
+		os.Remove(tmp.Name())
+		return
+	}
+
+	if err := os.Rename(tmp.Name(), name); err != nil {
+		os.Remove(tmp.Name())
+	}
+}
+
+// =============================================================================
+
+// Sniff detects the content type of the media from its content and returns
+// an error if the content is not an image, audio, or video.
+func Sniff(data []byte) (string, error) {
+	if len(data) == 0 {
+		return "", errors.New("sniff: media is empty")
+	}
+
+	contentType := http.DetectContentType(data)
+
+	// The standard library doesn't detect these formats.
+	switch {
+	case bytes.HasPrefix(data, []byte("fLaC")):
+		contentType = "audio/flac"
+
+	case bytes.HasPrefix(data, []byte("ID3")) || (len(data) > 1 && data[0] == 0xFF && data[1]&0xE0 == 0xE0 && contentType == "application/octet-stream"):
+		contentType = "audio/mpeg"
+	}
+
+	mediaType, _, _ := strings.Cut(contentType, ";")
+
+	switch {
+	case strings.HasPrefix(mediaType, "image/"),
+		strings.HasPrefix(mediaType, "audio/"),
+		strings.HasPrefix(mediaType, "video/"):
+		return mediaType, nil
+	}
+
+	return "", fmt.Errorf("sniff: unsupported content type %q", mediaType)
+}
@@ -0,0 +1,289 @@
+package fetcher_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"image"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ardanlabs/kronk/sdk/tools/fetcher"
+)
+
+func Test_FetchHTTP(t *testing.T) {
+	img := testPNG(t)
+
+	var hits atomic.Int32
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/image.png", func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		w.Write(img)
+	})
+	mux.HandleFunc("/page.html", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("<html><body>not an image</body></html>"))
+	})
+	mux.HandleFunc("/large", func(w http.ResponseWriter, r *http.Request) {
+		w.Write(append(img, make([]byte, 1024)...))
+	})
+	mux.HandleFunc("/slow", func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(2 * time.Second):
+		}
+	})
+	mux.HandleFunc("/missing", func(w http.ResponseWriter, r *http.Request) {
+		http.NotFound(w, r)
+	})
+	mux.HandleFunc("/redirect", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "http://blocked.example.com/image.png", http.StatusFound)
+	})
+
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	host := mustHost(t, srv.URL)
+
+	f, err := fetcher.New(fetcher.Config{
+		AllowedHosts: []string{host},
+		MaxSize:      int64(len(img) + 512),
+		Timeout:      250 * time.Millisecond,
+		CacheDir:     t.TempDir(),
+	})
+	if err != nil {
+		t.Fatalf("new fetcher: %v", err)
+	}
+
+	ctx := context.Background()
+
+	t.Run("allowed host", func(t *testing.T) {
+		data, err := f.Fetch(ctx, srv.URL+"/image.png")
+		if err != nil {
+			t.Fatalf("expected no error, got: %v", err)
+		}
+
+		if !bytes.Equal(data, img) {
+			t.Fatal("expected the image data to be returned")
+		}
+	})
+
+	t.Run("cached", func(t *testing.T) {
+		before := hits.Load()
+
+		if _, err := f.Fetch(ctx, srv.URL+"/image.png"); err != nil {
+			t.Fatalf("expected no error, got: %v", err)
+		}
+
+		if hits.Load() != before {
+			t.Fatal("expected the image to be served from the cache")
+		}
+	})
+
+	t.Run("host not allowed", func(t *testing.T) {
+		_, err := f.Fetch(ctx, "http://blocked.example.com/image.png")
+		if !errors.Is(err, fetcher.ErrNotAllowed) {
+			t.Fatalf("expected not allowed error, got: %v", err)
+		}
+	})
+
+	t.Run("redirect not allowed", func(t *testing.T) {
+		_, err := f.Fetch(ctx, srv.URL+"/redirect")
+		if !errors.Is(err, fetcher.ErrNotAllowed) {
+			t.Fatalf("expected not allowed error, got: %v", err)
+		}
+	})
+
+	t.Run("content type", func(t *testing.T) {
+		if _, err := f.Fetch(ctx, srv.URL+"/page.html"); err == nil {
+			t.Fatal("expected an error for html content")
+		}
+	})
+
+	t.Run("max size", func(t *testing.T) {
+		if _, err := f.Fetch(ctx, srv.URL+"/large"); err == nil {
+			t.Fatal("expected an error for content over the max size")
+		}
+	})
+
+	t.Run("timeout", func(t *testing.T) {
+		_, err := f.Fetch(ctx, srv.URL+"/slow")
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Fatalf("expected deadline exceeded error, got: %v", err)
+		}
+	})
+
+	t.Run("bad status", func(t *testing.T) {
+		if _, err := f.Fetch(ctx, srv.URL+"/missing"); err == nil {
+			t.Fatal("expected an error for a missing file")
+		}
+	})
+
+	t.Run("unsupported scheme", func(t *testing.T) {
+		_, err := f.Fetch(ctx, "ftp://"+host+"/image.png")
+		if !errors.Is(err, fetcher.ErrNotAllowed) {
+			t.Fatalf("expected not allowed error, got: %v", err)
+		}
+	})
+}
+
+func Test_FetchWildcardHost(t *testing.T) {
+	f, err := fetcher.New(fetcher.Config{
+		AllowedHosts: []string{"*.example.com"},
+		Timeout:      50 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatalf("new fetcher: %v", err)
+	}
+
+	_, err = f.Fetch(context.Background(), "http://example.org/image.png")
+	if !errors.Is(err, fetcher.ErrNotAllowed) {
+		t.Fatalf("expected not allowed error, got: %v", err)
+	}
+
+	_, err = f.Fetch(context.Background(), "http://badexample.com/image.png")
+	if !errors.Is(err, fetcher.ErrNotAllowed) {
+		t.Fatalf("expected not allowed error, got: %v", err)
+	}
+}
+
+func Test_FetchFile(t *testing.T) {
+	img := testPNG(t)
+
+	root := t.TempDir()
+	outside := t.TempDir()
+
+	inside := filepath.Join(root, "image.png")
+	if err := os.WriteFile(inside, img, 0644); err != nil {
+		t.Fatalf("write file: %v", err)
+	}
+
+	other := filepath.Join(outside, "image.png")
+	if err := os.WriteFile(other, img, 0644); err != nil {
+		t.Fatalf("write file: %v", err)
+	}
+
+	ctx := context.Background()
+
+	t.Run("disabled", func(t *testing.T) {
+		f, err := fetcher.New(fetcher.Config{})
+		if err != nil {
+			t.Fatalf("new fetcher: %v", err)
+		}
+
+		_, err = f.Fetch(ctx, fileURL(inside))
+		if !errors.Is(err, fetcher.ErrNotAllowed) {
+			t.Fatalf("expected not allowed error, got: %v", err)
+		}
+	})
+
+	f, err := fetcher.New(fetcher.Config{
+		FileRoots: []string{root},
+	})
+	if err != nil {
+		t.Fatalf("new fetcher: %v", err)
+	}
+
+	t.Run("inside root", func(t *testing.T) {
+		data, err := f.Fetch(ctx, fileURL(inside))
+		if err != nil {
+			t.Fatalf("expected no error, got: %v", err)
+		}
+
+		if !bytes.Equal(data, img) {
+			t.Fatal("expected the image data to be returned")
+		}
+	})
+
+	t.Run("outside root", func(t *testing.T) {
+		_, err := f.Fetch(ctx, fileURL(other))
+		if !errors.Is(err, fetcher.ErrNotAllowed) {
+			t.Fatalf("expected not allowed error, got: %v", err)
+		}
+	})
+
+	t.Run("traversal", func(t *testing.T) {
+		_, err := f.Fetch(ctx, "file://"+filepath.ToSlash(root)+"/../"+filepath.Base(outside)+"/image.png")
+		if !errors.Is(err, fetcher.ErrNotAllowed) {
+			t.Fatalf("expected not allowed error, got: %v", err)
+		}
+	})
+
+	t.Run("symlink", func(t *testing.T) {
+		link := filepath.Join(root, "link.png")
+		if err := os.Symlink(other, link); err != nil {
+			t.Skipf("symlinks not supported: %v", err)
+		}
+
+		_, err := f.Fetch(ctx, fileURL(link))
+		if !errors.Is(err, fetcher.ErrNotAllowed) {
+			t.Fatalf("expected not allowed error, got: %v", err)
+		}
+	})
+}
+
+func Test_Sniff(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+		exp  string
+	}{
+		{"png", testPNG(t), "image/png"},
+		{"wav", []byte("RIFF\x24\x00\x00\x00WAVEfmt "), "audio/wave"},
+		{"mp3", []byte("ID3\x03\x00\x00\x00\x00\x00\x00"), "audio/mpeg"},
+		{"flac", []byte("fLaC\x00\x00\x00\x22"), "audio/flac"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := fetcher.Sniff(tt.data)
+			if err != nil {
+				t.Fatalf("expected no error, got: %v", err)
+			}
+
+			if got != tt.exp {
+				t.Fatalf("expected %s, got %s", tt.exp, got)
+			}
+		})
+	}
+
+	if _, err := fetcher.Sniff([]byte("just some text")); err == nil {
+		t.Fatal("expected an error for text content")
+	}
+}
+
+// =============================================================================
+
+func testPNG(t *testing.T) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, 4, 4))); err != nil {
+		t.Fatalf("encode png: %v", err)
+	}
+
+	return buf.Bytes()
+}
+
+func mustHost(t *testing.T, rawURL string) string {
+	t.Helper()
+
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		t.Fatalf("parse url: %v", err)
+	}
+
+	return u.Host
+}
+
+func fileURL(name string) string {
+	u := url.URL{Scheme: "file", Path: filepath.ToSlash(name)}
+	return u.String()
+}
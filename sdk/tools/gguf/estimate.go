@@ -0,0 +1,166 @@
+package gguf
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	defContextWindow = 4 * 1024
+	defNUBatch       = 512
+)
+
+// EstimateConfig represents the settings a model is loaded with that affect
+// the memory it uses.
+//
+// ContextWindow is the size of the context. When set to 0, the context
+// length of the model is used.
+//
+// NUBatch is the physical batch size. When set to 0, the default value is 512.
+//
+// TypeK and TypeV are the data types of the K and V caches. When empty, the
+// default value is f16.
+//
+// FlashAttention is the flash attention mode. When set to disabled, the
+// memory for the attention scores is included in the compute buffer.
+type EstimateConfig struct {
+	ContextWindow  int
+	NUBatch        int
+	TypeK          string
+	TypeV          string
+	FlashAttention string
+}
+
+// Estimate represents the memory a model is estimated to use once loaded.
+type Estimate struct {
+	ContextWindow int
+	Weights       uint64
+	KVCache       uint64
+	Compute       uint64
+}
+
+// Total returns the total number of bytes the model is estimated to use.
+func (e Estimate) Total() uint64 {
+	return e.Weights + e.KVCache + e.Compute
+}
+
+// Add returns the sum of the two estimates.
+func (e Estimate) Add(o Estimate) Estimate {
+	e.Weights += o.Weights
+	e.KVCache += o.KVCache
+	e.Compute += o.Compute
+
+	return e
+}
+
+// Estimate returns the memory the model in the file is estimated to use when
+// loaded with the specified settings. The estimate is conservative and
+// assumes the full context is allocated for every layer.
+func (f *File) Estimate(cfg EstimateConfig) (Estimate, error) {
+	typeK, err := cacheType(cfg.TypeK)
+	if err != nil {
+		return Estimate{}, err
+	}
+
+	typeV, err := cacheType(cfg.TypeV)
+	if err != nil {
+		return Estimate{}, err
+	}
+
+	est := Estimate{
+		ContextWindow: cfg.ContextWindow,
+		Weights:       f.WeightsSize(),
+	}
+
+	if est.ContextWindow <= 0 {
+		est.ContextWindow = defContextWindow
+		if v, ok := f.ArchUint("context_length"); ok && v > 0 {
+			est.ContextWindow = int(v)
+		}
+	}
+
+	nLayer, _ := f.ArchUint("block_count")
+	nEmbd, _ := f.ArchUint("embedding_length")
+	nHead, _ := f.ArchUint("attention.head_count")
+
+	// Files like vision projectors don't have a KV cache.
+	if nLayer == 0 || nEmbd == 0 || nHead == 0 {
+		return est, nil
+	}
+
+	nHeadKV, ok := f.ArchUint("attention.head_count_kv")
+	if !ok || nHeadKV == 0 {
+		nHeadKV = nHead
+	}
+
+	keyLen, ok := f.ArchUint("attention.key_length")
+	if !ok || keyLen == 0 {
+		keyLen = nEmbd / nHead
+	}
+
+	valueLen, ok := f.ArchUint("attention.value_length")
+	if !ok || valueLen == 0 {
+		valueLen = keyLen
+	}
+
+	nCtx := uint64(est.ContextWindow)
+
+	est.KVCache = nLayer * (typeK.rowSize(nCtx*nHeadKV*keyLen) + typeV.rowSize(nCtx*nHeadKV*valueLen))
+
+	// The compute buffer holds the activations and logits for a batch of
+	// tokens in f32. Without flash attention it also holds the attention
+	// scores for the batch against the full context.
+	nUBatch := uint64(cfg.NUBatch)
+	if nUBatch == 0 {
+		nUBatch = defNUBatch
+	}
+
+	est.Compute = nUBatch * 4 * (f.vocabSize() + 4*nEmbd)
+
+	if strings.EqualFold(cfg.FlashAttention, "disabled") {
+		est.Compute += nUBatch * 4 * nCtx * nHead
+	}
+
+	return est, nil
+}
+
+// EstimateFiles returns the memory a model made up of the specified files is
+// estimated to use, like a model file and its projection file.
+func EstimateFiles(cfg EstimateConfig, files ...*File) (Estimate, error) {
+	var total Estimate
+
+	for i, file := range files {
+		est, err := file.Estimate(cfg)
+		if err != nil {
+			return Estimate{}, fmt.Errorf("estimate-files: %w", err)
+		}
+
+		if i == 0 {
+			total.ContextWindow = est.ContextWindow
+		}
+
+		total = total.Add(est)
+	}
+
+	return total, nil
+}
+
+func (f *File) vocabSize() uint64 {
+	if v, ok := f.ArchUint("vocab_size"); ok {
+		return v
+	}
+
+	if t, ok := f.Tensor("token_embd.weight"); ok && len(t.Dims) == 2 {
+		return t.Dims[1]
+	}
+
+	return 0
+}
+
+func cacheType(name string) (Type, error) {
+	if name == "" {
+		return TypeF16, nil
+	}
+
+	return ParseType(name)
+}
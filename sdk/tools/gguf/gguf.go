@@ -0,0 +1,432 @@
+// Package gguf provides support for reading the header of GGUF model files
+// without loading the model weights or the llama.cpp libraries.
+package gguf
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"os"
+)
+
+const (
+	magic = 0x46554747 // GGUF in little endian.
+
+	defaultAlignment = 32
+)
+
+// These are the value types a metadata key can have.
+const (
+	typeUint8   = 0
+	typeInt8    = 1
+	typeUint16  = 2
+	typeInt16   = 3
+	typeUint32  = 4
+	typeInt32   = 5
+	typeFloat32 = 6
+	typeBool    = 7
+	typeString  = 8
+	typeArray   = 9
+	typeUint64  = 10
+	typeInt64   = 11
+	typeFloat64 = 12
+)
+
+// File represents the header of a GGUF file.
+type File struct {
+	Version  uint32
+	Metadata map[string]any
+	Tensors  []Tensor
+}
+
+// Tensor represents the information for a tensor in the file. Split is the
+// index of the file the tensor is in for a split model.
+type Tensor struct {
+	Name   string
+	Dims   []uint64
+	Type   Type
+	Offset uint64
+	Split  int
+}
+
+// Elements returns the number of elements in the tensor.
+func (t Tensor) Elements() uint64 {
+	n := uint64(1)
+	for _, dim := range t.Dims {
+		n *= dim
+	}
+
+	return n
+}
+
+// Size returns the number of bytes the tensor data uses.
+func (t Tensor) Size() uint64 {
+	return t.Type.rowSize(t.Elements())
+}
+
+// =============================================================================
+
+// Read reads the header of the specified GGUF file. When the file is the
+// first file of a split model, the tensors in the remaining files are read
+// as well.
+func Read(path string) (*File, error) {
+	file, err := readFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read: %w", err)
+	}
+
+	if err := file.readSplits(path); err != nil {
+		return nil, fmt.Errorf("read: %w", err)
+	}
+
+	return file, nil
+}
+
+func readFile(path string) (*File, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	file, err := Decode(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	return file, nil
+}
+
+// ReadURL reads the header of the GGUF file at the specified url. Only the
+// header is downloaded. The token is used as a bearer token when provided.
+func ReadURL(ctx context.Context, url string, token string) (*File, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("read-url: %w", err)
+	}
+
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("read-url: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("read-url: %s: unexpected status %s", url, resp.Status)
+	}
+
+	file, err := Decode(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("read-url: %s: %w", url, err)
+	}
+
+	return file, nil
+}
+
+// Decode reads the header of a GGUF file from the reader. Only the header is
+// read, so the reader can be the body of a remote file.
+func Decode(r io.Reader) (*File, error) {
+	d := decoder{r: bufio.NewReaderSize(r, 1<<16)}
+
+	if m := d.uint32(); d.err == nil && m != magic {
+		return nil, errors.New("decode: not a gguf file")
+	}
+
+	version := d.uint32()
+	if d.err == nil && (version < 2 || version > 3) {
+		return nil, fmt.Errorf("decode: unsupported gguf version %d", version)
+	}
+
+	tensorCount := d.uint64()
+	kvCount := d.uint64()
+
+	if d.err != nil {
+		return nil, fmt.Errorf("decode: header: %w", d.err)
+	}
+
+	file := File{
+		Version:  version,
+		Metadata: make(map[string]any, kvCount),
+	}
+
+	for range kvCount {
+		key := d.string()
+		value := d.value(d.uint32())
+
+		if d.err != nil {
+			return nil, fmt.Errorf("decode: metadata %q: %w", key, d.err)
+		}
+
+		if value != nil {
+			file.Metadata[key] = value
+		}
+	}
+
+	file.Tensors = make([]Tensor, 0, min(tensorCount, 1<<16))
+
+	for range tensorCount {
+		var t Tensor
+
+		t.Name = d.string()
+
+		nDims := d.uint32()
+		if d.err == nil && nDims > 8 {
+			return nil, fmt.Errorf("decode: tensor %q: invalid dimension count %d", t.Name, nDims)
+		}
+
+		t.Dims = make([]uint64, nDims)
+		for i := range t.Dims {
+			t.Dims[i] = d.uint64()
+		}
+
+		t.Type = Type(d.uint32())
+		t.Offset = d.uint64()
+
+		if d.err != nil {
+			return nil, fmt.Errorf("decode: tensor %q: %w", t.Name, d.err)
+		}
+
+		if _, exists := typeSizes[t.Type]; !exists {
+			return nil, fmt.Errorf("decode: tensor %q: unknown type %d", t.Name, t.Type)
+		}
+
+		file.Tensors = append(file.Tensors, t)
+	}
+
+	return &file, nil
+}
+
+// =============================================================================
+
+// Architecture returns the architecture of the model.
+func (f *File) Architecture() string {
+	arch, _ := f.String("general.architecture")
+	return arch
+}
+
+// Alignment returns the alignment of the tensor data.
+func (f *File) Alignment() uint64 {
+	if v, ok := f.Uint("general.alignment"); ok && v > 0 {
+		return v
+	}
+
+	return defaultAlignment
+}
+
+// String returns the string value for the specified key.
+func (f *File) String(key string) (string, bool) {
+	v, ok := f.Metadata[key].(string)
+	return v, ok
+}
+
+// Uint returns the value for the specified key as an unsigned integer. Any
+// integer type that isn't negative is accepted.
+func (f *File) Uint(key string) (uint64, bool) {
+	switch v := f.Metadata[key].(type) {
+	case uint8:
+		return uint64(v), true
+	case uint16:
+		return uint64(v), true
+	case uint32:
+		return uint64(v), true
+	case uint64:
+		return v, true
+	case int8:
+		return uint64(v), v >= 0
+	case int16:
+		return uint64(v), v >= 0
+	case int32:
+		return uint64(v), v >= 0
+	case int64:
+		return uint64(v), v >= 0
+	}
+
+	return 0, false
+}
+
+// ArchUint returns the value for the specified key in the namespace of the
+// model architecture, like "block_count" for "llama.block_count".
+func (f *File) ArchUint(key string) (uint64, bool) {
+	return f.Uint(f.Architecture() + "." + key)
+}
+
+// WeightsSize returns the number of bytes the tensor data uses.
+func (f *File) WeightsSize() uint64 {
+	var size uint64
+	for _, t := range f.Tensors {
+		size += t.Size()
+	}
+
+	return size
+}
+
+// Tensor returns the tensor with the specified name.
+func (f *File) Tensor(name string) (Tensor, bool) {
+	for _, t := range f.Tensors {
+		if t.Name == name {
+			return t, true
+		}
+	}
+
+	return Tensor{}, false
+}
+
+// =============================================================================
+
+type decoder struct {
+	r   *bufio.Reader
+	err error
+	buf [8]byte
+}
+
+func (d *decoder) read(n int) []byte {
+	if d.err != nil {
+		return d.buf[:n]
+	}
+
+	if _, err := io.ReadFull(d.r, d.buf[:n]); err != nil {
+		d.err = err
+	}
+
+	return d.buf[:n]
+}
+
+func (d *decoder) uint8() uint8 {
+	return d.read(1)[0]
+}
+
+func (d *decoder) uint16() uint16 {
+	return binary.LittleEndian.Uint16(d.read(2))
+}
+
+func (d *decoder) uint32() uint32 {
+	return binary.LittleEndian.Uint32(d.read(4))
+}
+
+func (d *decoder) uint64() uint64 {
+	return binary.LittleEndian.Uint64(d.read(8))
+}
+
+func (d *decoder) string() string {
+	n := d.uint64()
+	if d.err != nil {
+		return ""
+	}
+
+	if n > 1<<30 {
+		d.err = fmt.Errorf("string length %d is too large", n)
+		return ""
+	}
+
+	b := make([]byte, n)
+	if _, err := io.ReadFull(d.r, b); err != nil {
+		d.err = err
+		return ""
+	}
+
+	return string(b)
+}
+
+// value reads a metadata value of the specified type. Arrays are returned
+// as a slice of the element type, like []string or []int32.
+func (d *decoder) value(typ uint32) any {
+	switch typ {
+	case typeUint8:
+		return d.uint8()
+	case typeInt8:
+		return int8(d.uint8())
+	case typeUint16:
+		return d.uint16()
+	case typeInt16:
+		return int16(d.uint16())
+	case typeUint32:
+		return d.uint32()
+	case typeInt32:
+		return int32(d.uint32())
+	case typeFloat32:
+		return math.Float32frombits(d.uint32())
+	case typeBool:
+		return d.uint8() != 0
+	case typeString:
+		return d.string()
+	case typeUint64:
+		return d.uint64()
+	case typeInt64:
+		return int64(d.uint64())
+	case typeFloat64:
+		return math.Float64frombits(d.uint64())
+	case typeArray:
+		return d.array()
+	}
+
+	if d.err == nil {
+		d.err = fmt.Errorf("unknown value type %d", typ)
+	}
+
+	return nil
+}
+
+func (d *decoder) array() any {
+	typ := d.uint32()
+	n := d.uint64()
+
+	if d.err == nil && n > 1<<28 {
+		d.err = fmt.Errorf("array length %d is too large", n)
+	}
+
+	if d.err != nil {
+		return nil
+	}
+
+	switch typ {
+	case typeUint8:
+		return readArray(d, n, d.uint8)
+	case typeInt8:
+		return readArray(d, n, func() int8 { return int8(d.uint8()) })
+	case typeUint16:
+		return readArray(d, n, d.uint16)
+	case typeInt16:
+		return readArray(d, n, func() int16 { return int16(d.uint16()) })
+	case typeUint32:
+		return readArray(d, n, d.uint32)
+	case typeInt32:
+		return readArray(d, n, func() int32 { return int32(d.uint32()) })
+	case typeFloat32:
+		return readArray(d, n, func() float32 { return math.Float32frombits(d.uint32()) })
+	case typeBool:
+		return readArray(d, n, func() bool { return d.uint8() != 0 })
+	case typeString:
+		return readArray(d, n, d.string)
+	case typeUint64:
+		return readArray(d, n, d.uint64)
+	case typeInt64:
+		return readArray(d, n, func() int64 { return int64(d.uint64()) })
+	case typeFloat64:
+		return readArray(d, n, func() float64 { return math.Float64frombits(d.uint64()) })
+	case typeArray:
+		return readArray(d, n, d.array)
+	}
+
+	d.err = fmt.Errorf("unknown array type %d", typ)
+
+	return nil
+}
+
+func readArray[T any](d *decoder, n uint64, read func() T) []T {
+	values := make([]T, 0, min(n, 1<<20))
+
+	for i := uint64(0); i < n && d.err == nil; i++ {
+		values = append(values, read())
+	}
+
+	return values
+}
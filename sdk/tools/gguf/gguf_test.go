@@ -0,0 +1,237 @@
+package gguf_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ardanlabs/kronk/sdk/tools/gguf"
+)
+
+func Test_Read(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "model.gguf")
+
+	if err := os.WriteFile(path, testFile(), 0644); err != nil {
+		t.Fatalf("write file: %v", err)
+	}
+
+	file, err := gguf.Read(path)
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+
+	if file.Version != 3 || file.Architecture() != "llama" {
+		t.Errorf("unexpected header: version %d, arch %q", file.Version, file.Architecture())
+	}
+
+	if v, ok := file.ArchUint("block_count"); !ok || v != 2 {
+		t.Errorf("expected block count 2, got %d", v)
+	}
+
+	if len(file.Tensors) != 2 {
+		t.Fatalf("expected 2 tensors, got %d", len(file.Tensors))
+	}
+
+	// 64*1000 q8_0 elements use 2000 blocks of 34 bytes and 64*64 f32
+	// elements use 4 bytes each.
+	if size := file.WeightsSize(); size != 2000*34+64*64*4 {
+		t.Errorf("unexpected weights size %d", size)
+	}
+
+	tokens, ok := file.Metadata["tokenizer.ggml.tokens"].([]string)
+	if !ok || len(tokens) != 2 || tokens[1] != "</s>" {
+		t.Errorf("unexpected tokens array: %v", file.Metadata["tokenizer.ggml.tokens"])
+	}
+
+	if v := file.MetadataStrings()["tokenizer.ggml.tokens"]; v != `["<s>", "</s>"]` {
+		t.Errorf("unexpected formatted array: %s", v)
+	}
+
+	if _, err := gguf.Decode(bytes.NewReader([]byte("not a gguf file"))); err == nil {
+		t.Error("expected an error for an invalid file")
+	}
+}
+
+func Test_ReadSplits(t *testing.T) {
+	dir := t.TempDir()
+	prefix := filepath.Join(dir, "model")
+
+	first := testFile(testKV{"split.no", uint16(0)}, testKV{"split.count", uint16(2)})
+	second := encode(
+		[]testKV{{"split.no", uint16(1)}, {"split.count", uint16(2)}},
+		[]testTensor{{"output.weight", []uint64{64, 1000}, 8}},
+	)
+
+	if err := os.WriteFile(gguf.SplitPath(prefix, 1, 2), first, 0644); err != nil {
+		t.Fatalf("write file: %v", err)
+	}
+
+	if err := os.WriteFile(gguf.SplitPath(prefix, 2, 2), second, 0644); err != nil {
+		t.Fatalf("write file: %v", err)
+	}
+
+	file, err := gguf.Read(gguf.SplitPath(prefix, 1, 2))
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+
+	if len(file.Tensors) != 3 || file.Tensors[2].Name != "output.weight" || file.Tensors[2].Split != 1 {
+		t.Fatalf("expected the tensors of both files, got %+v", file.Tensors)
+	}
+
+	if _, err := gguf.Read(gguf.SplitPath(prefix, 2, 2)); err == nil {
+		t.Error("expected an error reading the second file")
+	}
+
+	if p, no, count, ok := gguf.ParseSplitPath("/models/Model-Q8_0-00002-of-00003.gguf"); !ok || p != "/models/Model-Q8_0" || no != 2 || count != 3 {
+		t.Errorf("unexpected split path parts: %s %d %d %t", p, no, count, ok)
+	}
+}
+
+func Test_Info(t *testing.T) {
+	file, err := gguf.Decode(bytes.NewReader(testFile(testKV{"tokenizer.chat_template", "{{ messages }}"})))
+	if err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+
+	info := file.Info()
+
+	exp := gguf.Info{
+		Architecture:   "llama",
+		ParameterCount: 64*1000 + 64*64,
+		Quantization:   "Q8_0",
+		ContextLength:  2048,
+		ChatTemplate:   "{{ messages }}",
+		HasDecoder:     true,
+	}
+
+	if info != exp {
+		t.Errorf("expected %+v, got %+v", exp, info)
+	}
+}
+
+func Test_Estimate(t *testing.T) {
+	file, err := gguf.Decode(bytes.NewReader(testFile()))
+	if err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+
+	est, err := file.Estimate(gguf.EstimateConfig{})
+	if err != nil {
+		t.Fatalf("estimate: %v", err)
+	}
+
+	// 2 layers * 2048 tokens * 2 kv heads * 16 dims for K and V in f16.
+	if est.ContextWindow != 2048 || est.KVCache != 2*2*(2048*2*16*2) {
+		t.Errorf("unexpected kv cache estimate: %+v", est)
+	}
+
+	quantized, err := file.Estimate(gguf.EstimateConfig{ContextWindow: 1024, TypeK: "q8_0", TypeV: "q8_0"})
+	if err != nil {
+		t.Fatalf("estimate: %v", err)
+	}
+
+	if quantized.KVCache != 2*2*(1024*2*16/32*34) {
+		t.Errorf("unexpected quantized kv cache estimate: %+v", quantized)
+	}
+
+	if quantized.Total() >= est.Total() {
+		t.Errorf("expected a smaller estimate for a quantized cache")
+	}
+
+	if _, err := file.Estimate(gguf.EstimateConfig{TypeK: "q9_0"}); err == nil {
+		t.Error("expected an error for an unknown cache type")
+	}
+}
+
+// =============================================================================
+
+type testKV struct {
+	key   string
+	value any
+}
+
+type testTensor struct {
+	name string
+	dims []uint64
+	typ  uint32
+}
+
+// testFile returns a small GGUF file for a llama model with 2 layers.
+func testFile(extra ...testKV) []byte {
+	kvs := []testKV{
+		{"general.architecture", "llama"},
+		{"llama.block_count", uint32(2)},
+		{"llama.context_length", uint32(2048)},
+		{"llama.embedding_length", uint32(64)},
+		{"llama.attention.head_count", uint32(4)},
+		{"llama.attention.head_count_kv", uint32(2)},
+		{"tokenizer.ggml.tokens", []string{"<s>", "</s>"}},
+		{"general.file_type", int32(7)},
+	}
+
+	tensors := []testTensor{
+		{"token_embd.weight", []uint64{64, 1000}, 8},
+		{"blk.0.attn_q.weight", []uint64{64, 64}, 0},
+	}
+
+	return encode(append(kvs, extra...), tensors)
+}
+
+func encode(kvs []testKV, tensors []testTensor) []byte {
+	var b bytes.Buffer
+
+	write := func(v any) {
+		binary.Write(&b, binary.LittleEndian, v)
+	}
+
+	writeString := func(s string) {
+		write(uint64(len(s)))
+		b.WriteString(s)
+	}
+
+	write(uint32(0x46554747))
+	write(uint32(3))
+	write(uint64(len(tensors)))
+	write(uint64(len(kvs)))
+
+	for _, kv := range kvs {
+		writeString(kv.key)
+
+		switch v := kv.value.(type) {
+		case uint16:
+			write(uint32(2))
+			write(v)
+		case uint32:
+			write(uint32(4))
+			write(v)
+		case int32:
+			write(uint32(5))
+			write(v)
+		case string:
+			write(uint32(8))
+			writeString(v)
+		case []string:
+			write(uint32(9))
+			write(uint32(8))
+			write(uint64(len(v)))
+			for _, s := range v {
+				writeString(s)
+			}
+		}
+	}
+
+	var offset uint64
+	for _, t := range tensors {
+		writeString(t.name)
+		write(uint32(len(t.dims)))
+		write(t.dims)
+		write(t.typ)
+		write(offset)
+		offset += 1024
+	}
+
+	return b.Bytes()
+}
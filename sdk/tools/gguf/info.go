@@ -0,0 +1,174 @@
+package gguf
+
+import (
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+)
+
+// maxArrayValues is the number of values shown when an array is formatted.
+const maxArrayValues = 8
+
+// Info represents the summary information for a model.
+type Info struct {
+	Architecture   string
+	Name           string
+	SizeLabel      string
+	ParameterCount uint64
+	Quantization   string
+	ContextLength  int
+	ChatTemplate   string
+	HasEncoder     bool
+	HasDecoder     bool
+	IsRecurrent    bool
+	IsHybrid       bool
+}
+
+// Info returns the summary information for the model in the file.
+func (f *File) Info() Info {
+	arch := f.Architecture()
+
+	name, _ := f.String("general.name")
+	sizeLabel, _ := f.String("general.size_label")
+	chatTemplate, _ := f.String("tokenizer.chat_template")
+	contextLength, _ := f.ArchUint("context_length")
+
+	return Info{
+		Architecture:   arch,
+		Name:           name,
+		SizeLabel:      sizeLabel,
+		ParameterCount: f.ParameterCount(),
+		Quantization:   f.Quantization(),
+		ContextLength:  int(contextLength),
+		ChatTemplate:   chatTemplate,
+		HasEncoder:     arch == "t5" || arch == "t5encoder",
+		HasDecoder:     arch != "t5encoder",
+		IsRecurrent:    slices.Contains(recurrentArchs, arch),
+		IsHybrid:       slices.Contains(hybridArchs, arch),
+	}
+}
+
+// ParameterCount returns the number of parameters in the model.
+func (f *File) ParameterCount() uint64 {
+	var n uint64
+	for _, t := range f.Tensors {
+		n += t.Elements()
+	}
+
+	return n
+}
+
+// Quantization returns the name of the quantization of the model, like Q8_0
+// or Q4_K_M. When the file doesn't specify it, the type used by most of the
+// tensor data is returned.
+func (f *File) Quantization() string {
+	if v, ok := f.Uint("general.file_type"); ok {
+		if name, exists := fileTypes[v]; exists {
+			return name
+		}
+	}
+
+	sizes := make(map[Type]uint64)
+	for _, t := range f.Tensors {
+		sizes[t.Type] += t.Size()
+	}
+
+	var typ Type
+	var size uint64
+	for t, s := range sizes {
+		if s > size {
+			typ, size = t, s
+		}
+	}
+
+	if size == 0 {
+		return ""
+	}
+
+	return strings.ToUpper(typ.String())
+}
+
+// MetadataStrings returns the metadata with the values formatted as strings.
+// Arrays are shortened to their first values and their length.
+func (f *File) MetadataStrings() map[string]string {
+	metadata := make(map[string]string, len(f.Metadata))
+
+	for k, v := range f.Metadata {
+		metadata[k] = FormatValue(v)
+	}
+
+	return metadata
+}
+
+// FormatValue returns a metadata value formatted as a string. Arrays are
+// shortened to their first values and their length.
+func FormatValue(v any) string {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Slice {
+		return fmt.Sprint(v)
+	}
+
+	n := rv.Len()
+
+	values := make([]string, 0, min(n, maxArrayValues))
+	for i := range min(n, maxArrayValues) {
+		switch ev := rv.Index(i).Interface().(type) {
+		case string:
+			values = append(values, fmt.Sprintf("%q", ev))
+		default:
+			values = append(values, FormatValue(ev))
+		}
+	}
+
+	if n > maxArrayValues {
+		return fmt.Sprintf("[%s, ...] (%d items)", strings.Join(values, ", "), n)
+	}
+
+	return "[" + strings.Join(values, ", ") + "]"
+}
+
+// =============================================================================
+
+// recurrentArchs are the architectures llama.cpp treats as recurrent.
+var recurrentArchs = []string{"mamba", "mamba2", "rwkv6", "rwkv6qwen2", "rwkv7", "arwkv7"}
+
+// hybridArchs are the architectures llama.cpp treats as hybrid.
+var hybridArchs = []string{"jamba", "falcon-h1", "granitehybrid", "lfm2", "plamo2", "nemotron_h", "qwen3next"}
+
+// fileTypes maps the general.file_type values to their names.
+var fileTypes = map[uint64]string{
+	0:  "F32",
+	1:  "F16",
+	2:  "Q4_0",
+	3:  "Q4_1",
+	7:  "Q8_0",
+	8:  "Q5_0",
+	9:  "Q5_1",
+	10: "Q2_K",
+	11: "Q3_K_S",
+	12: "Q3_K_M",
+	13: "Q3_K_L",
+	14: "Q4_K_S",
+	15: "Q4_K_M",
+	16: "Q5_K_S",
+	17: "Q5_K_M",
+	18: "Q6_K",
+	19: "IQ2_XXS",
+	20: "IQ2_XS",
+	21: "Q2_K_S",
+	22: "IQ3_XS",
+	23: "IQ3_XXS",
+	24: "IQ1_S",
+	25: "IQ4_NL",
+	26: "IQ3_S",
+	27: "IQ3_M",
+	28: "IQ2_S",
+	29: "IQ2_M",
+	30: "IQ4_XS",
+	31: "IQ1_M",
+	32: "BF16",
+	36: "TQ1_0",
+	37: "TQ2_0",
+	38: "MXFP4_MOE",
+}
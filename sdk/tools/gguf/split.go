@@ -0,0 +1,68 @@
+package gguf
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+)
+
+// splitName matches the file names llama.cpp uses for a split model, like
+// "model-00001-of-00003.gguf".
+var splitName = regexp.MustCompile(`^(.*)-(\d{5})-of-(\d{5})\.gguf$`)
+
+// SplitPath returns the path of the specified file of a split model. The
+// file number starts at 1.
+func SplitPath(prefix string, no int, count int) string {
+	return fmt.Sprintf("%s-%05d-of-%05d.gguf", prefix, no, count)
+}
+
+// ParseSplitPath returns the prefix, file number and file count for the path
+// of a file of a split model. False is returned when the path isn't for a
+// split model.
+func ParseSplitPath(path string) (prefix string, no int, count int, ok bool) {
+	m := splitName.FindStringSubmatch(path)
+	if m == nil {
+		return "", 0, 0, false
+	}
+
+	no, _ = strconv.Atoi(m[2])
+	count, _ = strconv.Atoi(m[3])
+
+	if no < 1 || count < 1 || no > count {
+		return "", 0, 0, false
+	}
+
+	return m[1], no, count, true
+}
+
+// readSplits reads the remaining files of a split model and adds their
+// tensors to the file.
+func (f *File) readSplits(path string) error {
+	count, _ := f.Uint("split.count")
+	if count <= 1 {
+		return nil
+	}
+
+	if no, _ := f.Uint("split.no"); no != 0 {
+		return fmt.Errorf("read-splits: file %d of %d of a split model, the first file is required", no+1, count)
+	}
+
+	prefix, _, _, ok := ParseSplitPath(path)
+	if !ok {
+		return fmt.Errorf("read-splits: unable to identify the split files for %s", path)
+	}
+
+	for i := 2; i <= int(count); i++ {
+		split, err := readFile(SplitPath(prefix, i, int(count)))
+		if err != nil {
+			return fmt.Errorf("read-splits: %w", err)
+		}
+
+		for _, t := range split.Tensors {
+			t.Split = i - 1
+			f.Tensors = append(f.Tensors, t)
+		}
+	}
+
+	return nil
+}
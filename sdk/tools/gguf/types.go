@@ -0,0 +1,120 @@
+package gguf
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Type represents the ggml data type of a tensor.
+type Type uint32
+
+// Set of ggml data types.
+const (
+	TypeF32     Type = 0
+	TypeF16     Type = 1
+	TypeQ4_0    Type = 2
+	TypeQ4_1    Type = 3
+	TypeQ5_0    Type = 6
+	TypeQ5_1    Type = 7
+	TypeQ8_0    Type = 8
+	TypeQ8_1    Type = 9
+	TypeQ2_K    Type = 10
+	TypeQ3_K    Type = 11
+	TypeQ4_K    Type = 12
+	TypeQ5_K    Type = 13
+	TypeQ6_K    Type = 14
+	TypeQ8_K    Type = 15
+	TypeIQ2_XXS Type = 16
+	TypeIQ2_XS  Type = 17
+	TypeIQ3_XXS Type = 18
+	TypeIQ1_S   Type = 19
+	TypeIQ4_NL  Type = 20
+	TypeIQ3_S   Type = 21
+	TypeIQ2_S   Type = 22
+	TypeIQ4_XS  Type = 23
+	TypeI8      Type = 24
+	TypeI16     Type = 25
+	TypeI32     Type = 26
+	TypeI64     Type = 27
+	TypeF64     Type = 28
+	TypeIQ1_M   Type = 29
+	TypeBF16    Type = 30
+	TypeTQ1_0   Type = 34
+	TypeTQ2_0   Type = 35
+	TypeMXFP4   Type = 39
+)
+
+type typeSize struct {
+	name      string
+	blockSize uint64
+	size      uint64
+}
+
+// typeSizes provides the number of elements in a block and the number of
+// bytes a block uses for each type.
+var typeSizes = map[Type]typeSize{
+	TypeF32:     {"f32", 1, 4},
+	TypeF16:     {"f16", 1, 2},
+	TypeQ4_0:    {"q4_0", 32, 18},
+	TypeQ4_1:    {"q4_1", 32, 20},
+	TypeQ5_0:    {"q5_0", 32, 22},
+	TypeQ5_1:    {"q5_1", 32, 24},
+	TypeQ8_0:    {"q8_0", 32, 34},
+	TypeQ8_1:    {"q8_1", 32, 36},
+	TypeQ2_K:    {"q2_k", 256, 84},
+	TypeQ3_K:    {"q3_k", 256, 110},
+	TypeQ4_K:    {"q4_k", 256, 144},
+	TypeQ5_K:    {"q5_k", 256, 176},
+	TypeQ6_K:    {"q6_k", 256, 210},
+	TypeQ8_K:    {"q8_k", 256, 292},
+	TypeIQ2_XXS: {"iq2_xxs", 256, 66},
+	TypeIQ2_XS:  {"iq2_xs", 256, 74},
+	TypeIQ3_XXS: {"iq3_xxs", 256, 98},
+	TypeIQ1_S:   {"iq1_s", 256, 50},
+	TypeIQ4_NL:  {"iq4_nl", 32, 18},
+	TypeIQ3_S:   {"iq3_s", 256, 110},
+	TypeIQ2_S:   {"iq2_s", 256, 82},
+	TypeIQ4_XS:  {"iq4_xs", 256, 136},
+	TypeI8:      {"i8", 1, 1},
+	TypeI16:     {"i16", 1, 2},
+	TypeI32:     {"i32", 1, 4},
+	TypeI64:     {"i64", 1, 8},
+	TypeF64:     {"f64", 1, 8},
+	TypeIQ1_M:   {"iq1_m", 256, 56},
+	TypeBF16:    {"bf16", 1, 2},
+	TypeTQ1_0:   {"tq1_0", 256, 54},
+	TypeTQ2_0:   {"tq2_0", 256, 66},
+	TypeMXFP4:   {"mxfp4", 32, 17},
+}
+
+// ParseType parses the name of a ggml data type, like "q8_0".
+func ParseType(name string) (Type, error) {
+	name = strings.ToLower(name)
+
+	for typ, ts := range typeSizes {
+		if ts.name == name {
+			return typ, nil
+		}
+	}
+
+	return 0, fmt.Errorf("parse-type: unknown type %q", name)
+}
+
+// String returns the name of the type.
+func (t Type) String() string {
+	if ts, exists := typeSizes[t]; exists {
+		return ts.name
+	}
+
+	return fmt.Sprintf("type(%d)", uint32(t))
+}
+
+// rowSize returns the number of bytes the specified number of elements use.
+func (t Type) rowSize(elements uint64) uint64 {
+	ts, exists := typeSizes[t]
+	if !exists {
+		return 0
+	}
+
+	return (elements + ts.blockSize - 1) / ts.blockSize * ts.size
+}
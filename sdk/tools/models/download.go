@@ -98,8 +98,72 @@ func (m *Models) Download(ctx context.Context, log Logger, modelURL string, proj
 	return mp, nil
 }
 
+// DownloadAdapters downloads the specified LoRA adapters and installs them
+// next to the model file they were trained for. The adapter files are stored
+// with a "lora-" prefix so the index can associate them with the model.
+func (m *Models) DownloadAdapters(ctx context.Context, log Logger, modelFile string, adapterURLs []string) ([]string, error) {
+	if len(adapterURLs) == 0 {
+		return nil, nil
+	}
+
+	if !hasNetwork() {
+		return nil, fmt.Errorf("download-adapters: no network available")
+	}
+
+	defer func() {
+		if err := m.BuildIndex(); err != nil {
+			log(ctx, "download-adapters: unable to create index", "ERROR", err)
+		}
+	}()
+
+	progress := func(src string, currentSize int64, totalSize int64, mibPerSec float64, complete bool) {
+		log(ctx, fmt.Sprintf("\x1b[1A\r\x1b[Kdownload-adapters: Downloading %s... %d MiB of %d MiB (%.2f MiB/s)", src, currentSize/(1024*1024), totalSize/(1024*1024), mibPerSec))
+	}
+
+	adapterFiles := make([]string, 0, len(adapterURLs))
+
+	for _, adapterURL := range adapterURLs {
+		log(ctx, fmt.Sprintf("download-adapters: adapter-url[%s] model-file[%s]", adapterURL, modelFile))
+
+		adapterFile, err := m.downloadAdapter(ctx, modelFile, adapterURL, progress)
+		if err != nil {
+			return nil, fmt.Errorf("download-adapters: %w", err)
+		}
+
+		adapterFiles = append(adapterFiles, adapterFile)
+	}
+
+	return adapterFiles, nil
+}
+
 // =============================================================================
 
+func (m *Models) downloadAdapter(ctx context.Context, modelFile string, adapterURL string, progress downloader.ProgressFunc) (string, error) {
+	fileName, err := extractFileName(adapterURL)
+	if err != nil {
+		return "", fmt.Errorf("download-adapter: unable to extract file name: %w", err)
+	}
+
+	adapterFile := createAdapterFileName(modelFile, fileName)
+
+	if _, err := os.Stat(adapterFile); err == nil {
+		return adapterFile, nil
+	}
+
+	downloaded, _, err := m.pullModel(ctx, adapterURL, progress)
+	if err != nil {
+		return "", err
+	}
+
+	if downloaded != adapterFile {
+		if err := os.Rename(downloaded, adapterFile); err != nil {
+			return "", fmt.Errorf("download-adapter: unable to rename adapter file: %w", err)
+		}
+	}
+
+	return adapterFile, nil
+}
+
 func (m *Models) downloadModel(ctx context.Context, modelFileURL string, projFileURL string, progress downloader.ProgressFunc) (Path, error) {
 	modelFileName, downloadedMF, err := m.pullModel(ctx, modelFileURL, progress)
 	if err != nil {
@@ -189,6 +253,14 @@ func createProjFileName(modelFileName string) string {
 	return strings.Replace(modelFileName, modelID, profFileName, 1)
 }
 
+func createAdapterFileName(modelFileName string, adapterFileName string) string {
+	if !strings.HasPrefix(adapterFileName, adapterPrefix) {
+		adapterFileName = adapterPrefix + adapterFileName
+	}
+
+	return filepath.Join(filepath.Dir(modelFileName), adapterFileName)
+}
+
 func extractModelID(modelFileName string) string {
 	return strings.TrimSuffix(path.Base(modelFileName), path.Ext(modelFileName))
 }
@@ -9,12 +9,14 @@ import (
 	"sync"
 
 	"github.com/ardanlabs/kronk/sdk/tools/defaults"
+	"github.com/ardanlabs/kronk/sdk/tools/gguf"
 	"go.yaml.in/yaml/v2"
 )
 
 var (
-	localFolder = "models"
-	indexFile   = ".index.yaml"
+	localFolder   = "models"
+	indexFile     = ".index.yaml"
+	adapterPrefix = "lora-"
 )
 
 // Models manages the model system.
@@ -93,6 +95,7 @@ func (m *Models) BuildIndex() error {
 
 			modelfiles := make(map[string]string)
 			projFiles := make(map[string]string)
+			var adapterFiles []string
 
 			for _, fileEntry := range fileEntries {
 				if fileEntry.IsDir() {
@@ -111,20 +114,42 @@ func (m *Models) BuildIndex() error {
 					continue
 				}
 
-				modelID := extractModelID(fileEntry.Name())
+				// LoRA adapters are stored next to the base models they were
+				// trained for and apply to every model in the directory.
+				if strings.HasPrefix(name, adapterPrefix) {
+					adapterFiles = append(adapterFiles, filepath.Join(m.modelsPath, org, modelFamily, fileEntry.Name()))
+					continue
+				}
+
+				// A split model is indexed by the first file, llama.cpp loads
+				// the remaining files.
+				if prefix, no, _, ok := gguf.ParseSplitPath(name); ok {
+					if no != 1 {
+						continue
+					}
+
+					name = prefix + ".gguf"
+				}
+
+				modelID := extractModelID(name)
 				modelfiles[modelID] = filepath.Join(m.modelsPath, org, modelFamily, fileEntry.Name())
 			}
 
 			for modelID, modelFile := range modelfiles {
 				mp := Path{
-					ModelFile:  modelFile,
-					Downloaded: true,
+					ModelFile:    modelFile,
+					AdapterFiles: adapterFiles,
+					Downloaded:   true,
 				}
 
 				if projFile, exists := projFiles[modelID]; exists {
 					mp.ProjFile = projFile
 				}
 
+				if file, err := gguf.Read(modelFile); err == nil {
+					mp.Metadata = toMetadata(file.Info())
+				}
+
 				modelID = strings.ToLower(modelID)
 				index[modelID] = mp
 			}
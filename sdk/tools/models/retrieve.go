@@ -8,6 +8,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/ardanlabs/kronk/sdk/tools/gguf"
 	"go.yaml.in/yaml/v2"
 )
 
@@ -96,10 +97,11 @@ func (m *Models) retrieveFile(modelID string) (File, error) {
 
 // Info provides all the model details.
 type Info struct {
-	ID      string
-	Object  string
-	Created int64
-	OwnedBy string
+	ID       string
+	Object   string
+	Created  int64
+	OwnedBy  string
+	Metadata Metadata
 }
 
 // RetrieveInfo provides details for the specified model.
@@ -111,11 +113,17 @@ func (m *Models) RetrieveInfo(modelID string) (Info, error) {
 		return Info{}, fmt.Errorf("show-model: unable to get model file information: %w", err)
 	}
 
+	mp, err := m.RetrievePath(modelID)
+	if err != nil {
+		return Info{}, fmt.Errorf("show-model: unable to get model path: %w", err)
+	}
+
 	mi := Info{
-		ID:      mf.ID,
-		Object:  "model",
-		Created: mf.Modified.UnixMilli(),
-		OwnedBy: mf.OwnedBy,
+		ID:       mf.ID,
+		Object:   "model",
+		Created:  mf.Modified.UnixMilli(),
+		OwnedBy:  mf.OwnedBy,
+		Metadata: mp.Metadata,
 	}
 
 	return mi, nil
@@ -123,11 +131,33 @@ func (m *Models) RetrieveInfo(modelID string) (Info, error) {
 
 // =============================================================================
 
-// Path returns file path information about a model.
+// Path returns file path information about a model. The metadata is read
+// from the model file when the index is built.
 type Path struct {
-	ModelFile  string
-	ProjFile   string
-	Downloaded bool
+	ModelFile    string
+	ProjFile     string
+	AdapterFiles []string
+	Downloaded   bool
+	Metadata     Metadata
+}
+
+// Metadata provides the information stored in the header of a model file.
+type Metadata struct {
+	Architecture   string
+	ParameterCount uint64
+	Quantization   string
+	ContextLength  int
+	ChatTemplate   string
+}
+
+func toMetadata(info gguf.Info) Metadata {
+	return Metadata{
+		Architecture:   info.Architecture,
+		ParameterCount: info.ParameterCount,
+		Quantization:   info.Quantization,
+		ContextLength:  info.ContextLength,
+		ChatTemplate:   info.ChatTemplate,
+	}
 }
 
 // RetrievePath locates the physical location on disk and returns the full path.
@@ -263,8 +263,12 @@ func (sec *Security) generateAdminToken(keysPath string) error {
 	const admin = true
 
 	endpoints := map[string]auth.RateLimit{
-		"chat-completions": {Limit: 0, Window: auth.RateUnlimited},
-		"embeddings":       {Limit: 0, Window: auth.RateUnlimited},
+		"chat-completions":     {Limit: 0, Window: auth.RateUnlimited},
+		"chat-render":          {Limit: 0, Window: auth.RateUnlimited},
+		"runtime-config":       {Limit: 0, Window: auth.RateUnlimited},
+		"embeddings":           {Limit: 0, Window: auth.RateUnlimited},
+		"audio-transcriptions": {Limit: 0, Window: auth.RateUnlimited},
+		"audio-translations":   {Limit: 0, Window: auth.RateUnlimited},
 	}
 
 	const tenYears = time.Minute * 526000
@@ -0,0 +1,369 @@
+// Package sessions provides support for managing the conversation sessions
+// persisted to disk by models.
+package sessions
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/ardanlabs/kronk/sdk/tools/defaults"
+	"go.yaml.in/yaml/v2"
+)
+
+var (
+	localFolder = "sessions"
+	stateExt    = ".state"
+	metaExt     = ".yaml"
+)
+
+// versionLen is the length of the version that names the state files.
+const versionLen = 16
+
+var (
+	// ErrNotFound is returned when a session doesn't exist.
+	ErrNotFound = errors.New("session not found")
+
+	// ErrMismatch is returned when the state file of a session doesn't match
+	// the version recorded in its meta file.
+	ErrMismatch = errors.New("session state doesn't match its meta")
+)
+
+var validID = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_.-]{0,127}$`)
+
+// Sessions manages the sessions system.
+type Sessions struct {
+	sessionsPath string
+}
+
+// New constructs the sessions system using defaults paths.
+func New() (*Sessions, error) {
+	return NewWithPaths("")
+}
+
+// NewWithPaths constructs the sessions system, If the basePath is empty, the
+// default location is used.
+func NewWithPaths(basePath string) (*Sessions, error) {
+	basePath = defaults.BaseDir(basePath)
+
+	sessionsPath := filepath.Join(basePath, localFolder)
+
+	if err := os.MkdirAll(sessionsPath, 0755); err != nil {
+		return nil, fmt.Errorf("creating sessions directory: %w", err)
+	}
+
+	s := Sessions{
+		sessionsPath: sessionsPath,
+	}
+
+	return &s, nil
+}
+
+// Path returns the location of the sessions path.
+func (s *Sessions) Path() string {
+	return s.sessionsPath
+}
+
+// List returns all the sessions stored on disk.
+func (s *Sessions) List() ([]Info, error) {
+	return List(s.sessionsPath)
+}
+
+// Retrieve returns information for the specified session.
+func (s *Sessions) Retrieve(id string) (Info, error) {
+	return Retrieve(s.sessionsPath, id)
+}
+
+// Remove deletes the specified session from disk.
+func (s *Sessions) Remove(id string) error {
+	return Remove(s.sessionsPath, id)
+}
+
+// Prune removes sessions that are older than the ttl and then the oldest
+// sessions until the total size is under maxSize.
+func (s *Sessions) Prune(ttl time.Duration, maxSize int64) ([]string, error) {
+	return Prune(s.sessionsPath, ttl, maxSize)
+}
+
+// =============================================================================
+
+// Meta represents the information stored next to the state of a session.
+type Meta struct {
+	ID       string    `yaml:"id"`
+	ModelID  string    `yaml:"model_id"`
+	Adapters []string  `yaml:"adapters,omitempty"`
+	Tokens   int       `yaml:"tokens"`
+	Version  string    `yaml:"version"`
+	Saved    time.Time `yaml:"saved"`
+}
+
+// Info provides information about a stored session.
+type Info struct {
+	ID       string
+	ModelID  string
+	Adapters []string
+	Tokens   int
+	Size     int64
+	Saved    time.Time
+}
+
+// ValidateID checks the session id can be used as a file name.
+func ValidateID(id string) error {
+	if !validID.MatchString(id) || strings.Contains(id, "..") {
+		return fmt.Errorf("validate-id: invalid session id %q", id)
+	}
+
+	return nil
+}
+
+// NewVersion returns a new version for the state of a session. Every save
+// writes its state to a file named by its own version and the meta file
+// records the version it belongs to, so a meta file is never paired with the
+// state of another save.
+func NewVersion() (string, error) {
+	b := make([]byte, versionLen/2)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("new-version: %w", err)
+	}
+
+	return hex.EncodeToString(b), nil
+}
+
+// StateFile returns the location of the state file for the version of the
+// session.
+func StateFile(dir string, id string, version string) string {
+	return filepath.Join(dir, id+"."+version+stateExt)
+}
+
+// MetaFile returns the location of the meta file for the session.
+func MetaFile(dir string, id string) string {
+	return filepath.Join(dir, id+metaExt)
+}
+
+// TempFile creates an empty temp file in the sessions folder for a file of
+// the session that is written and then renamed into place. Every call gets
+// its own file so concurrent saves of the same session don't collide.
+func TempFile(dir string, id string) (string, error) {
+	f, err := os.CreateTemp(dir, id+".*.tmp")
+	if err != nil {
+		return "", fmt.Errorf("temp-file: %w", err)
+	}
+
+	if err := f.Close(); err != nil {
+		os.Remove(f.Name())
+		return "", fmt.Errorf("temp-file: %w", err)
+	}
+
+	return f.Name(), nil
+}
+
+// WriteMeta writes the meta file for the session. The state file for the
+// version in the meta must be written before the meta file since the meta
+// file marks the session as complete. Once the meta file is in place, the
+// state file of the version it replaced is removed.
+func WriteMeta(dir string, meta Meta) error {
+	data, err := yaml.Marshal(&meta)
+	if err != nil {
+		return fmt.Errorf("write-meta: marshal: %w", err)
+	}
+
+	tmp, err := TempFile(dir, meta.ID)
+	if err != nil {
+		return fmt.Errorf("write-meta: %w", err)
+	}
+
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("write-meta: write: %w", err)
+	}
+
+	prev, _ := ReadMeta(dir, meta.ID)
+
+	if err := os.Rename(tmp, MetaFile(dir, meta.ID)); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("write-meta: rename: %w", err)
+	}
+
+	if prev.Version != "" && prev.Version != meta.Version {
+		os.Remove(StateFile(dir, meta.ID, prev.Version))
+	}
+
+	return nil
+}
+
+// ReadMeta reads the meta file for the session.
+func ReadMeta(dir string, id string) (Meta, error) {
+	data, err := os.ReadFile(MetaFile(dir, id))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return Meta{}, ErrNotFound
+		}
+
+		return Meta{}, fmt.Errorf("read-meta: %w", err)
+	}
+
+	var meta Meta
+	if err := yaml.Unmarshal(data, &meta); err != nil {
+		return Meta{}, fmt.Errorf("read-meta: unmarshal: %w", err)
+	}
+
+	return meta, nil
+}
+
+// Retrieve returns information for the specified session in the directory.
+func Retrieve(dir string, id string) (Info, error) {
+	if err := ValidateID(id); err != nil {
+		return Info{}, err
+	}
+
+	meta, err := ReadMeta(dir, id)
+	if err != nil {
+		return Info{}, err
+	}
+
+	info, err := os.Stat(StateFile(dir, id, meta.Version))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return Info{}, fmt.Errorf("retrieve: %w: session %q version %q", ErrMismatch, id, meta.Version)
+		}
+
+		return Info{}, fmt.Errorf("retrieve: stat: %w", err)
+	}
+
+	inf := Info{
+		ID:       meta.ID,
+		ModelID:  meta.ModelID,
+		Adapters: meta.Adapters,
+		Tokens:   meta.Tokens,
+		Size:     info.Size(),
+		Saved:    meta.Saved,
+	}
+
+	return inf, nil
+}
+
+// List returns all the sessions stored in the directory, newest first.
+func List(dir string) ([]Info, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+
+		return nil, fmt.Errorf("list: reading sessions directory: %w", err)
+	}
+
+	var list []Info
+
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || filepath.Ext(name) != metaExt {
+			continue
+		}
+
+		inf, err := Retrieve(dir, strings.TrimSuffix(name, metaExt))
+		if err != nil {
+			continue
+		}
+
+		list = append(list, inf)
+	}
+
+	slices.SortFunc(list, func(a, b Info) int {
+		return b.Saved.Compare(a.Saved)
+	})
+
+	return list, nil
+}
+
+// Remove deletes the specified session from the directory.
+func Remove(dir string, id string) error {
+	if err := ValidateID(id); err != nil {
+		return err
+	}
+
+	errMeta := os.Remove(MetaFile(dir, id))
+	errState := removeStates(dir, id)
+
+	if errors.Is(errMeta, os.ErrNotExist) && errors.Is(errState, os.ErrNotExist) {
+		return ErrNotFound
+	}
+
+	if errMeta != nil && !errors.Is(errMeta, os.ErrNotExist) {
+		return fmt.Errorf("remove: %w", errMeta)
+	}
+
+	if errState != nil && !errors.Is(errState, os.ErrNotExist) {
+		return fmt.Errorf("remove: %w", errState)
+	}
+
+	return nil
+}
+
+// Prune removes the sessions in the directory that are older than the ttl
+// and then removes the oldest sessions until the total size is under maxSize.
+// A ttl or maxSize of 0 disables that limit. The ids of the removed sessions
+// are returned.
+func Prune(dir string, ttl time.Duration, maxSize int64) ([]string, error) {
+	list, err := List(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var removed []string
+	var total int64
+
+	// The list is sorted newest first so the size budget is spent on the
+	// most recently used sessions.
+	for _, inf := range list {
+		expired := ttl > 0 && time.Since(inf.Saved) > ttl
+		oversize := maxSize > 0 && total+inf.Size > maxSize
+
+		if !expired && !oversize {
+			total += inf.Size
+			continue
+		}
+
+		if err := Remove(dir, inf.ID); err != nil && !errors.Is(err, ErrNotFound) {
+			return removed, fmt.Errorf("prune: %w", err)
+		}
+
+		removed = append(removed, inf.ID)
+	}
+
+	return removed, nil
+}
+
+// removeStates deletes every version of the state file for the session from
+// the directory. Versions are left behind by saves that didn't get to write
+// their meta file.
+func removeStates(dir string, id string) error {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+
+	pattern := id + "." + strings.Repeat("?", versionLen) + stateExt
+
+	err = os.ErrNotExist
+	for _, entry := range entries {
+		if match, _ := filepath.Match(pattern, entry.Name()); !match {
+			continue
+		}
+
+		if rerr := os.Remove(filepath.Join(dir, entry.Name())); rerr != nil && !errors.Is(rerr, os.ErrNotExist) {
+			return rerr
+		}
+
+		err = nil
+	}
+
+	return err
+}
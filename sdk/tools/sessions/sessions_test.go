@@ -0,0 +1,216 @@
+package sessions_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ardanlabs/kronk/sdk/tools/sessions"
+)
+
+func Test_ValidateID(t *testing.T) {
+	valid := []string{"abc", "support-bot_42", "a.b.c", "0123456789"}
+	for _, id := range valid {
+		if err := sessions.ValidateID(id); err != nil {
+			t.Errorf("expected %q to be valid, got: %v", id, err)
+		}
+	}
+
+	invalid := []string{"", "../etc", "a/b", "a..b", ".hidden", "a b"}
+	for _, id := range invalid {
+		if err := sessions.ValidateID(id); err == nil {
+			t.Errorf("expected %q to be invalid", id)
+		}
+	}
+}
+
+func Test_ListRemove(t *testing.T) {
+	sess, err := sessions.NewWithPaths(t.TempDir())
+	if err != nil {
+		t.Fatalf("new sessions: %v", err)
+	}
+
+	now := time.Now().UTC()
+	writeSession(t, sess.Path(), "older", 10, now.Add(-time.Minute))
+	writeSession(t, sess.Path(), "newer", 20, now)
+
+	list, err := sess.List()
+	if err != nil {
+		t.Fatalf("list: %v", err)
+	}
+
+	if len(list) != 2 {
+		t.Fatalf("expected 2 sessions, got %d", len(list))
+	}
+
+	if list[0].ID != "newer" || list[1].ID != "older" {
+		t.Errorf("expected newest session first, got %s, %s", list[0].ID, list[1].ID)
+	}
+
+	if list[0].Size != 20 || list[0].ModelID != "test-model" {
+		t.Errorf("unexpected session info: %+v", list[0])
+	}
+
+	if err := sess.Remove("older"); err != nil {
+		t.Fatalf("remove: %v", err)
+	}
+
+	if _, err := sess.Retrieve("older"); !errors.Is(err, sessions.ErrNotFound) {
+		t.Errorf("expected not found after remove, got: %v", err)
+	}
+
+	if err := sess.Remove("older"); !errors.Is(err, sessions.ErrNotFound) {
+		t.Errorf("expected not found removing twice, got: %v", err)
+	}
+}
+
+func Test_Prune(t *testing.T) {
+	sess, err := sessions.NewWithPaths(t.TempDir())
+	if err != nil {
+		t.Fatalf("new sessions: %v", err)
+	}
+
+	now := time.Now().UTC()
+	writeSession(t, sess.Path(), "expired", 10, now.Add(-2*time.Hour))
+	writeSession(t, sess.Path(), "old", 100, now.Add(-30*time.Minute))
+	writeSession(t, sess.Path(), "recent", 100, now.Add(-time.Minute))
+	writeSession(t, sess.Path(), "current", 100, now)
+
+	removed, err := sess.Prune(time.Hour, 250)
+	if err != nil {
+		t.Fatalf("prune: %v", err)
+	}
+
+	if len(removed) != 2 || removed[0] != "old" || removed[1] != "expired" {
+		t.Fatalf("expected old and expired to be removed, got %v", removed)
+	}
+
+	list, err := sess.List()
+	if err != nil {
+		t.Fatalf("list: %v", err)
+	}
+
+	if len(list) != 2 || list[0].ID != "current" || list[1].ID != "recent" {
+		t.Fatalf("unexpected sessions after prune: %+v", list)
+	}
+}
+
+func Test_Mismatch(t *testing.T) {
+	dir := t.TempDir()
+
+	writeSession(t, dir, "session", 10, time.Now().UTC())
+
+	first, err := sessions.ReadMeta(dir, "session")
+	if err != nil {
+		t.Fatalf("read meta: %v", err)
+	}
+
+	// A new save replaces the state of the previous one once its meta
+	// file is in place.
+	writeSession(t, dir, "session", 20, time.Now().UTC())
+
+	if _, err := os.Stat(sessions.StateFile(dir, "session", first.Version)); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("expected the replaced state to be removed, got: %v", err)
+	}
+
+	inf, err := sessions.Retrieve(dir, "session")
+	if err != nil {
+		t.Fatalf("retrieve: %v", err)
+	}
+
+	if inf.Size != 20 {
+		t.Errorf("expected the state of the last save, got size %d", inf.Size)
+	}
+
+	// A meta file that points to a state that wasn't written is rejected.
+	first.Saved = time.Now().UTC()
+	if err := sessions.WriteMeta(dir, first); err != nil {
+		t.Fatalf("write meta: %v", err)
+	}
+
+	if _, err := sessions.Retrieve(dir, "session"); !errors.Is(err, sessions.ErrMismatch) {
+		t.Errorf("expected a mismatch, got: %v", err)
+	}
+
+	list, err := sessions.List(dir)
+	if err != nil {
+		t.Fatalf("list: %v", err)
+	}
+
+	if len(list) != 0 {
+		t.Errorf("expected the mismatched session to be left out, got %+v", list)
+	}
+
+	if err := sessions.Remove(dir, "session"); err != nil {
+		t.Fatalf("remove: %v", err)
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("read dir: %v", err)
+	}
+
+	if len(entries) != 0 {
+		t.Errorf("expected every file of the session to be removed, got %d", len(entries))
+	}
+}
+
+// =============================================================================
+
+func writeSession(t *testing.T, dir string, id string, size int, saved time.Time) {
+	t.Helper()
+
+	version, err := sessions.NewVersion()
+	if err != nil {
+		t.Fatalf("new version: %v", err)
+	}
+
+	if err := os.WriteFile(sessions.StateFile(dir, id, version), make([]byte, size), 0644); err != nil {
+		t.Fatalf("write state: %v", err)
+	}
+
+	meta := sessions.Meta{
+		ID:      id,
+		ModelID: "test-model",
+		Tokens:  size,
+		Version: version,
+		Saved:   saved,
+	}
+
+	if err := sessions.WriteMeta(dir, meta); err != nil {
+		t.Fatalf("write meta: %v", err)
+	}
+}
+
+func Test_WriteMetaConcurrent(t *testing.T) {
+	dir := t.TempDir()
+
+	var wg sync.WaitGroup
+	for i := range 20 {
+		wg.Go(func() {
+			meta := sessions.Meta{ID: "shared", ModelID: "test-model", Tokens: i}
+			if err := sessions.WriteMeta(dir, meta); err != nil {
+				t.Errorf("write meta %d: %v", i, err)
+			}
+		})
+	}
+	wg.Wait()
+
+	if _, err := sessions.ReadMeta(dir, "shared"); err != nil {
+		t.Fatalf("read meta: %v", err)
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("read dir: %v", err)
+	}
+
+	for _, entry := range entries {
+		if filepath.Ext(entry.Name()) == ".tmp" {
+			t.Errorf("expected no temp files left, got %s", entry.Name())
+		}
+	}
+}